@@ -1,6 +1,6 @@
 // MIT License
 //
-// (C) Copyright [2020-2021,2023,2025] Hewlett Packard Enterprise Development LP
+// (C) Copyright [2020-2021,2023,2025-2026] Hewlett Packard Enterprise Development LP
 //
 // Permission is hereby granted, free of charge, to any person obtaining a
 // copy of this software and associated documentation files (the "Software"),
@@ -39,16 +39,31 @@ type Route struct {
 type Routes []Route
 
 const (
-	URL_BASE      = "/hmi"
-	URL_VERSION   = "/v1"
-	URL_ROOT      = URL_BASE + URL_VERSION
-	URL_HEARTBEAT = URL_ROOT + "/heartbeat"
-	URL_PARAMS    = URL_ROOT + "/params"
-	URL_HB_STATES = URL_ROOT + "/hbstates"
-	URL_HB_STATE  = URL_ROOT + "/hbstate"
-	URL_LIVENESS  = URL_ROOT + "/liveness"
-	URL_READINESS = URL_ROOT + "/readiness"
-	URL_HEALTH    = URL_ROOT + "/health"
+	URL_BASE         = "/hmi"
+	URL_VERSION      = "/v1"
+	URL_ROOT         = URL_BASE + URL_VERSION
+	URL_HEARTBEAT    = URL_ROOT + "/heartbeat"
+	URL_HEARTBEATS   = URL_ROOT + "/heartbeats"
+	URL_PARAMS       = URL_ROOT + "/params"
+	URL_PARAMS_HIST  = URL_PARAMS + "/history"
+	URL_HB_STATES    = URL_ROOT + "/hbstates"
+	URL_HB_STATE     = URL_ROOT + "/hbstate"
+	URL_HB_HISTORY   = URL_ROOT + "/hbhistory"
+	URL_FLAPPING     = URL_ROOT + "/flapping"
+	URL_POLICIES     = URL_ROOT + "/policies"
+	URL_SUPPRESSIONS = URL_ROOT + "/suppressions"
+	URL_RECONCILE    = URL_ROOT + "/reconcile"
+	URL_NEVERSTARTED = URL_ROOT + "/neverstarted"
+	URL_INSTANCES    = URL_ROOT + "/instances"
+	URL_LIVENESS     = URL_ROOT + "/liveness"
+	URL_READINESS    = URL_ROOT + "/readiness"
+	URL_HEALTH       = URL_ROOT + "/health"
+	URL_METRICS      = URL_ROOT + "/metrics"
+
+	URL_VERSION_V2    = "/v2"
+	URL_ROOT_V2       = URL_BASE + URL_VERSION_V2
+	URL_PARAMS_V2     = URL_ROOT_V2 + "/params"
+	URL_PARAMS_SCHEMA = URL_PARAMS_V2 + "/schema"
 )
 
 // Generate the API routes
@@ -78,12 +93,17 @@ func generateRoutes() Routes {
 		Route{"hbRcv",
 			strings.ToUpper("Post"),
 			URL_HEARTBEAT,
-			hbRcv,
+			countHBs(HB_EP_HEARTBEAT, hbRcv),
 		},
 		Route{"hbRcvXName",
 			strings.ToUpper("Post"),
 			URL_HEARTBEAT + "/{xname}",
-			hbRcvXName,
+			countHBs(HB_EP_HEARTBEAT_XNAME, hbRcvXName),
+		},
+		Route{"hbRcvBatch",
+			strings.ToUpper("Post"),
+			URL_HEARTBEATS,
+			countHBBatch(hbRcvBatch),
 		},
 		Route{"params_get",
 			strings.ToUpper("Get"),
@@ -95,11 +115,36 @@ func generateRoutes() Routes {
 			URL_PARAMS,
 			paramsIO,
 		},
+		Route{"params_history_get",
+			strings.ToUpper("Get"),
+			URL_PARAMS_HIST,
+			paramsHistoryIO,
+		},
+		Route{"params_v2_get",
+			strings.ToUpper("Get"),
+			URL_PARAMS_V2,
+			paramsV2IO,
+		},
+		Route{"params_v2_patch",
+			strings.ToUpper("Patch"),
+			URL_PARAMS_V2,
+			paramsV2IO,
+		},
+		Route{"params_schema_get",
+			strings.ToUpper("Get"),
+			URL_PARAMS_SCHEMA,
+			paramsSchemaIO,
+		},
 		Route{"doHealth",
 			strings.ToUpper("Get"),
 			URL_HEALTH,
 			doHealth,
 		},
+		Route{"doMetrics",
+			strings.ToUpper("Get"),
+			URL_METRICS,
+			doMetrics,
+		},
 		Route{"doLiveness",
 			strings.ToUpper("Get"),
 			URL_LIVENESS,
@@ -115,10 +160,85 @@ func generateRoutes() Routes {
 			URL_HB_STATES,
 			hbStates,
 		},
+		Route{"hbStatesList",
+			strings.ToUpper("Get"),
+			URL_HB_STATES,
+			hbStatesList,
+		},
 		Route{"hbStateSingle",
 			strings.ToUpper("Get"),
 			URL_HB_STATE + "/{xname}",
 			hbStateSingle,
 		},
+		Route{"hbHistory",
+			strings.ToUpper("Get"),
+			URL_HB_HISTORY + "/{xname}",
+			hbHistoryIO,
+		},
+		Route{"flapping_get",
+			strings.ToUpper("Get"),
+			URL_FLAPPING,
+			flappingIO,
+		},
+		Route{"policies_get",
+			strings.ToUpper("Get"),
+			URL_POLICIES,
+			policiesIO,
+		},
+		Route{"policies_post",
+			strings.ToUpper("Post"),
+			URL_POLICIES,
+			policiesIO,
+		},
+		Route{"policy_get",
+			strings.ToUpper("Get"),
+			URL_POLICIES + "/{name}",
+			policyIO,
+		},
+		Route{"policy_put",
+			strings.ToUpper("Put"),
+			URL_POLICIES + "/{name}",
+			policyIO,
+		},
+		Route{"policy_delete",
+			strings.ToUpper("Delete"),
+			URL_POLICIES + "/{name}",
+			policyIO,
+		},
+		Route{"suppressions_get",
+			strings.ToUpper("Get"),
+			URL_SUPPRESSIONS,
+			suppressionsIO,
+		},
+		Route{"suppressions_post",
+			strings.ToUpper("Post"),
+			URL_SUPPRESSIONS,
+			suppressionsIO,
+		},
+		Route{"suppression_get",
+			strings.ToUpper("Get"),
+			URL_SUPPRESSIONS + "/{id}",
+			suppressionIO,
+		},
+		Route{"suppression_delete",
+			strings.ToUpper("Delete"),
+			URL_SUPPRESSIONS + "/{id}",
+			suppressionIO,
+		},
+		Route{"reconcile_get",
+			strings.ToUpper("Get"),
+			URL_RECONCILE,
+			reconcileIO,
+		},
+		Route{"neverstarted_get",
+			strings.ToUpper("Get"),
+			URL_NEVERSTARTED,
+			neverStartedIO,
+		},
+		Route{"instances_get",
+			strings.ToUpper("Get"),
+			URL_INSTANCES,
+			instancesIO,
+		},
 	}
 }
@@ -0,0 +1,226 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Parameter file.  The file named by --config or HBTD_CONFIG holds
+// parameters in JSON or YAML, using the same names as PATCH /params.
+// Parameters are built from the defaults, then the file, then env vars,
+// then cmdline params, each overriding the ones before.  On SIGHUP the
+// parameters are rebuilt the same way and stored as a new revision, so
+// all instances pick them up.
+/////////////////////////////////////////////////////////////////////////////
+
+// Parameter file, and the cmdline params to re-apply over it on reload.
+
+var configFile string
+var cmdlineParams op_params
+
+/////////////////////////////////////////////////////////////////////////////
+// Read a parameter file and convert it into parameter JSON.  Since YAML is
+// a superset of JSON, both are read as YAML.  Values may be strings,
+// numbers or booleans; parameter names are matched without regard to case.
+//
+// fname(in):   Parameter file name.
+// errstr(out): Errors for unknown parameters or bad value types.
+// Return:      Parameter JSON for parse_parm_json(); nil on success, else
+//              error reading or parsing the file.
+/////////////////////////////////////////////////////////////////////////////
+
+func readConfigFile(fname string, errstr *string) ([]byte, error) {
+	var v map[string]interface{}
+
+	ba, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(ba, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	pnames := make(map[string]string)
+	mtype := reflect.TypeOf(inidata{})
+	for i := 0; i < mtype.NumField(); i++ {
+		nm := strings.Split(mtype.Field(i).Tag.Get("json"), ",")[0]
+		pnames[strings.ToLower(nm)] = nm
+	}
+
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jmap := make(map[string]string)
+	for _, key := range keys {
+		nm, ok := pnames[strings.ToLower(key)]
+		if !ok {
+			*errstr += fmt.Sprintf("Unknown parameter '%s'; ", key)
+			continue
+		}
+		switch val := v[key].(type) {
+		case nil:
+		case string:
+			jmap[nm] = val
+		case int:
+			jmap[nm] = strconv.Itoa(val)
+		case int64:
+			jmap[nm] = strconv.FormatInt(val, 10)
+		case uint64:
+			jmap[nm] = strconv.FormatUint(val, 10)
+		case float64:
+			jmap[nm] = strconv.FormatFloat(val, 'f', -1, 64)
+		case bool:
+			jmap[nm] = strconv.FormatBool(val)
+		default:
+			*errstr += fmt.Sprintf("Parameter '%s' must be a string, number or boolean; ",
+				key)
+		}
+	}
+
+	return json.Marshal(jmap)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Apply a parameter file.  Everything in the file is checked with the same
+// rules as PATCH /params and all errors are reported together; if there
+// are any, no parameters are changed.
+//
+// fname(in): Parameter file name.
+// Return:    nil on success, else error listing all problems found.
+/////////////////////////////////////////////////////////////////////////////
+
+func parse_config_file(fname string) error {
+	var errstr string
+
+	jdata, err := readConfigFile(fname, &errstr)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fname, err)
+	}
+
+	saved := app_params
+	savedPort := server_url_port
+	rval := parse_parm_json(jdata, PARAM_FILE, &errstr)
+	if errstr == "" {
+		return nil
+	}
+	if rval == 0 {
+		app_params = saved
+		server_url_port = savedPort
+		applyLogParams()
+	}
+	return fmt.Errorf("%s: %s", fname, strings.TrimSuffix(errstr, "; "))
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Build the parameters from the defaults, the parameter file if any, env
+// vars and the saved cmdline params, in that order.
+//
+// Args:   None.
+// Return: nil on success, else parameter file error.
+/////////////////////////////////////////////////////////////////////////////
+
+func load_params() error {
+	initAppParams()
+	server_url_port = URL_PORT
+
+	if configFile != "" {
+		err := parse_config_file(configFile)
+		if err != nil {
+			return err
+		}
+	}
+	parse_env_vars()
+	parse_cmdline_params(cmdlineParams)
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Reload the parameters, on SIGHUP.  The ports and K/V store URL can only
+// be changed with a restart.  The new parameters are stored as the next
+// revision so other instances pick them up.  If the parameter file has
+// errors, the current parameters are kept.
+//
+// Args:   None.
+// Return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func reloadParams() {
+	paramLock.Lock()
+	defer paramLock.Unlock()
+
+	saved := app_params
+	savedPort := server_url_port
+	oldParams := cur_param_data()
+
+	err := load_params()
+	if err != nil {
+		app_params = saved
+		server_url_port = savedPort
+		applyLogParams()
+		logMain.Error(fmt.Sprintf("Error reloading parameters, keeping current ones: %v", err),
+			"error", err)
+		return
+	}
+
+	if app_params.port.string_param != saved.port.string_param {
+		logMain.Warn(fmt.Sprintf("Parameter '%s' can't be changed without a restart.",
+			app_params.port.name))
+		app_params.port = saved.port
+	}
+	if app_params.kv_url.string_param != saved.kv_url.string_param {
+		logMain.Warn(fmt.Sprintf("Parameter '%s' can't be changed without a restart.",
+			app_params.kv_url.name))
+		app_params.kv_url = saved.kv_url
+	}
+	if app_params.grpc_port.int_param != saved.grpc_port.int_param {
+		logMain.Warn(fmt.Sprintf("Parameter '%s' can't be changed without a restart.",
+			app_params.grpc_port.name))
+		app_params.grpc_port = saved.grpc_port
+	}
+	server_url_port = savedPort
+	applyLogParams()
+
+	_, err = paramStore()
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error storing reloaded parameters: %v", err),
+			"error", err)
+		return
+	}
+	recordParamChange(oldParams, PARAM_SRC_RELOAD, nil)
+	logMain.Info(fmt.Sprintf("Parameters reloaded, now revision %d.", paramRevision),
+		"revision", paramRevision)
+}
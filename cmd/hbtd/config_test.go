@@ -0,0 +1,243 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Cmdline params with nothing set.
+
+func unsetCmdline() op_params {
+	return op_params{debug_level: app_param{int_param: UNINT},
+		use_telemetry:      app_param{string_param: UNSTR},
+		telemetry_host:     app_param{string_param: UNSTR},
+		warntime:           app_param{int_param: UNINT},
+		errtime:            app_param{int_param: UNINT},
+		check_interval:     app_param{int_param: UNINT},
+		port:               app_param{string_param: URL_PORT},
+		kv_url:             app_param{string_param: UNSTR},
+		statemgr_url:       app_param{string_param: UNSTR},
+		statemgr_retries:   app_param{int_param: UNINT},
+		statemgr_timeout:   app_param{int_param: UNINT},
+		reconcile_interval: app_param{int_param: UNINT},
+		log_levels:         app_param{string_param: UNSTR},
+		log_format:         app_param{string_param: UNSTR},
+		hb_history:         app_param{int_param: UNINT},
+		flap_count:         app_param{int_param: UNINT},
+		flap_window:        app_param{int_param: UNINT},
+		flap_settle:        app_param{int_param: UNINT},
+		topo_threshold:     app_param{int_param: UNINT},
+		topo_window:        app_param{int_param: UNINT},
+		topo_tag:           app_param{string_param: UNSTR},
+		inventory_interval: app_param{int_param: UNINT},
+		inventory_grace:    app_param{int_param: UNINT},
+		inventory_roles:    app_param{string_param: UNSTR},
+		grpc_port:          app_param{int_param: UNINT},
+	}
+}
+
+func writeConfig(t *testing.T, fname, data string) {
+	if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+		t.Fatalf("ERROR writing parameter file: %v", err)
+	}
+}
+
+// Test loading parameters from a parameter file.
+
+func TestConfigFile(t *testing.T) {
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	origParams := app_params
+	origPort := server_url_port
+	defer func() {
+		app_params = origParams
+		server_url_port = origPort
+		configFile = ""
+		cmdlineParams = op_params{}
+		applyLogParams()
+	}()
+
+	dir := t.TempDir()
+	yfile := filepath.Join(dir, "hbtd.yaml")
+	jfile := filepath.Join(dir, "hbtd.json")
+	bfile := filepath.Join(dir, "bad.yaml")
+
+	//Defaults < file < env vars < cmdline params
+
+	writeConfig(t, yfile, "warntime: 20\nErrtime: 40\ninterval: 7\n"+
+		"use_telemetry: false\nport: 1234\nsm_url: http://sm:27779/hsm/v2\n")
+	t.Setenv("HBTD_ERRTIME", "50")
+	configFile = yfile
+	cmdlineParams = unsetCmdline()
+	cmdlineParams.warntime.int_param = 25
+
+	if err := load_params(); err != nil {
+		t.Fatalf("ERROR loading parameter file: %v", err)
+	}
+	if app_params.check_interval.int_param != 7 {
+		t.Errorf("Expected interval 7 from file, got %d", app_params.check_interval.int_param)
+	}
+	if app_params.errtime.int_param != 50 {
+		t.Errorf("Expected errtime 50 from env, got %d", app_params.errtime.int_param)
+	}
+	if app_params.warntime.int_param != 25 {
+		t.Errorf("Expected warntime 25 from cmdline, got %d", app_params.warntime.int_param)
+	}
+	if (app_params.use_telemetry.int_param != 0) ||
+		(app_params.statemgr_url.string_param != "http://sm:27779/hsm/v2") {
+		t.Errorf("Expected use_telemetry/sm_url from file, got %d/%s",
+			app_params.use_telemetry.int_param, app_params.statemgr_url.string_param)
+	}
+	if (app_params.port.string_param != "1234") || (server_url_port != "1234") {
+		t.Errorf("Expected port 1234 from file, got %s/%s",
+			app_params.port.string_param, server_url_port)
+	}
+	if app_params.statemgr_retries.int_param != SM_RETRIES {
+		t.Errorf("Expected default sm_retries %d, got %d", SM_RETRIES,
+			app_params.statemgr_retries.int_param)
+	}
+
+	//JSON, with the same string values as PATCH /params
+
+	writeConfig(t, jfile, `{"Warntime":"15","Sm_retries":"6"}`)
+	configFile = jfile
+	cmdlineParams = unsetCmdline()
+	if err := load_params(); err != nil {
+		t.Fatalf("ERROR loading JSON parameter file: %v", err)
+	}
+	if (app_params.warntime.int_param != 15) || (app_params.statemgr_retries.int_param != 6) {
+		t.Errorf("Expected warntime/sm_retries 15/6, got %d/%d",
+			app_params.warntime.int_param, app_params.statemgr_retries.int_param)
+	}
+
+	//All errors are reported, and nothing is applied.
+
+	writeConfig(t, bfile, "warntime: abc\nerrtime: -1\nbogus: 1\nsm_url: [a, b]\ninterval: 9\n")
+	before := app_params
+	err := parse_config_file(bfile)
+	if err == nil {
+		t.Fatalf("Bad parameter file didn't fail.")
+	}
+	for _, exp := range []string{"'warntime' with illegal value 'abc'",
+		"'errtime' with illegal value '-1'", "Unknown parameter 'bogus'",
+		"'sm_url' must be"} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("Expected error containing \"%s\", got: %v", exp, err)
+		}
+	}
+	if app_params != before {
+		t.Errorf("Parameters changed by bad parameter file.")
+	}
+
+	//Unknown parameters alone still reject the file.
+
+	writeConfig(t, bfile, "interval: 9\nwarn_time: 3\n")
+	err = parse_config_file(bfile)
+	if (err == nil) || (app_params.check_interval.int_param != before.check_interval.int_param) {
+		t.Errorf("Parameter file with unknown parameter was applied, err: %v", err)
+	}
+
+	err = parse_config_file(filepath.Join(dir, "missing.yaml"))
+	if err == nil {
+		t.Errorf("Missing parameter file didn't fail.")
+	}
+}
+
+// Test reloading the parameter file.
+
+func TestConfigReload(t *testing.T) {
+	var doc paramsDoc
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	origParams := app_params
+	origPort := server_url_port
+	kvHandle.Delete(KV_PARAM_KEY)
+	paramSynced = false
+	paramRevision = 0
+	defer func() {
+		app_params = origParams
+		server_url_port = origPort
+		configFile = ""
+		cmdlineParams = op_params{}
+		paramSynced = false
+		paramRevision = 0
+		kvHandle.Delete(KV_PARAM_KEY)
+		applyLogParams()
+	}()
+
+	configFile = filepath.Join(t.TempDir(), "hbtd.yaml")
+	cmdlineParams = unsetCmdline()
+	writeConfig(t, configFile, "warntime: 33\nport: 4321\n")
+	if err := load_params(); err != nil {
+		t.Fatalf("ERROR loading parameter file: %v", err)
+	}
+
+	//Reloaded parameters are stored as the next revision; the port can't
+	//change.
+
+	writeConfig(t, configFile, "warntime: 44\nport: 9999\nkv_url: https://other:2379\n")
+	reloadParams()
+	if app_params.warntime.int_param != 44 {
+		t.Errorf("Expected reloaded warntime 44, got %d", app_params.warntime.int_param)
+	}
+	if (app_params.port.string_param != "4321") || (server_url_port != "4321") {
+		t.Errorf("Port changed by reload: %s/%s", app_params.port.string_param,
+			server_url_port)
+	}
+	if app_params.kv_url.string_param != KV_URL_BASE {
+		t.Errorf("K/V URL changed by reload: %s", app_params.kv_url.string_param)
+	}
+	val, exists, _ := kvHandle.Get(KV_PARAM_KEY)
+	if !exists {
+		t.Fatalf("Reloaded parameters not stored.")
+	}
+	if err := json.Unmarshal([]byte(val), &doc); err != nil {
+		t.Fatalf("Can't unmarshal stored parameters '%s': %v", val, err)
+	}
+	if (doc.Revision != 1) || (doc.Warntime != "44") || (paramRevision != 1) {
+		t.Errorf("Expected stored revision 1 with warntime 44, got %d/%s (running %d)",
+			doc.Revision, doc.Warntime, paramRevision)
+	}
+
+	//A bad file on reload keeps the current parameters.
+
+	writeConfig(t, configFile, "warntime: 55\nerrtime: x\n")
+	reloadParams()
+	if (app_params.warntime.int_param != 44) || (paramRevision != 1) {
+		t.Errorf("Bad reload changed parameters: warntime %d, revision %d",
+			app_params.warntime.int_param, paramRevision)
+	}
+}
@@ -0,0 +1,422 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Leader election.  One instance at a time is elected leader; it does the
+// work that only one instance should do: the HB check when it can't be
+// sharded, and reporting ended suppression windows.  Leadership is held by
+// a lease which the leader keeps renewing; if the leader goes away or
+// stalls, its lease lapses and another instance takes over within
+// ELECTION_TTL plus ELECTION_POLL.
+/////////////////////////////////////////////////////////////////////////////
+
+type hbElection interface {
+	// Is this instance the leader?
+	IsLeader() bool
+
+	// Identity of the current leader, "" if not known.
+	Leader() string
+
+	// Stop campaigning, giving up leadership if held.
+	Resign() error
+}
+
+const (
+	ELECTION_KEY = "hbtd_leader"
+	ELECTION_TTL = 10 * time.Second
+)
+
+// How often an instance which isn't the leader tries to take over.
+
+var electionPoll = time.Second
+
+var hbElect hbElection
+var hbElectLock sync.Mutex
+
+// This instance's identity as a leader.
+
+var leaderID string
+
+// Convenience functions, is this instance the leader and who is.
+
+func isLeader() bool {
+	hbElectLock.Lock()
+	el := hbElect
+	hbElectLock.Unlock()
+	return (el != nil) && el.IsLeader()
+}
+
+// Does this instance do the work only one instance may do: the HB check
+// when it can't be sharded, and sending the HSM outbox?  With no check
+// interval there is no timer and hence only this instance; otherwise it
+// is the elected leader.
+
+func isChecker() bool {
+	return (app_params.check_interval.int_param == 0) || isLeader()
+}
+
+func leaderName() string {
+	hbElectLock.Lock()
+	el := hbElect
+	hbElectLock.Unlock()
+	if el == nil {
+		return ""
+	}
+	return el.Leader()
+}
+
+// Record a change of leadership of this instance.
+
+func leaderChanged(leader bool) {
+	updateInstance(func(inst *hbInstance) {
+		inst.Role = HB_ROLE_MEMBER
+		if leader {
+			inst.Role = HB_ROLE_LEADER
+		}
+	})
+	if leader {
+		mLeader.Set(1)
+		logMain.Info(fmt.Sprintf("'%s' elected leader.", leaderID),
+			"instance", leaderID)
+	} else {
+		mLeader.Set(0)
+		logMain.Warn(fmt.Sprintf("'%s' is no longer leader.", leaderID),
+			"instance", leaderID)
+	}
+	mLeaderChanges.Inc()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Start campaigning for leadership, retrying until the campaign can be
+// started.  Meant to be run as a goroutine.
+//
+// id(in): This instance's identity as leader.
+// Return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func startElection(id string) {
+	leaderID = id
+	for ix := 1; ; ix++ {
+		el, err := kvHandle.Campaign(id)
+		if err == nil {
+			hbElectLock.Lock()
+			hbElect = el
+			hbElectLock.Unlock()
+			logMain.Info(fmt.Sprintf("Campaigning for leadership as '%s'.", id),
+				"instance", id)
+			return
+		}
+		logMain.Error(fmt.Sprintf("Error starting leader election (attempt %d): %v", ix, err),
+			"error", err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Generate this instance's identity as leader: the host (pod) name and
+// the instance's life key.
+
+func createLeaderID(ikey string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%s", host, ikey)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Election for a single instance (the in-memory store), which is always
+// the leader.
+/////////////////////////////////////////////////////////////////////////////
+
+type soloElection struct {
+	id string
+}
+
+func (se *soloElection) IsLeader() bool {
+	return true
+}
+
+func (se *soloElection) Leader() string {
+	return se.id
+}
+
+func (se *soloElection) Resign() error {
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Election using a lease key, for stores without native elections
+// (memcached, Redis).  The key holds the leader's identity.  The leader
+// renews it every third of ELECTION_TTL; the others try to create it every
+// ELECTION_POLL, which succeeds once the leader's lease has lapsed.
+/////////////////////////////////////////////////////////////////////////////
+
+type leaseStore interface {
+	Get(key string) (string, bool, error)
+	leaseAcquire(key string, token string, ttl time.Duration) (bool, error)
+	leaseRenew(key string, token string, ttl time.Duration) (bool, error)
+}
+
+type leaseElection struct {
+	ls        leaseStore
+	id        string
+	mutex     sync.Mutex
+	leader    string
+	isLeader  bool
+	lastRenew time.Time
+	poll      time.Duration
+	stop      chan struct{}
+}
+
+func newLeaseElection(ls leaseStore, id string) *leaseElection {
+	le := &leaseElection{ls: ls, id: id, poll: electionPoll,
+		stop: make(chan struct{})}
+	le.attempt(time.Now())
+	go le.run()
+	return le
+}
+
+func (le *leaseElection) run() {
+	lastTry := time.Now()
+	ticker := time.NewTicker(le.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-le.stop:
+			return
+		case now := <-ticker.C:
+			if le.IsLeader() && (now.Sub(lastTry) < ELECTION_TTL/3) {
+				continue
+			}
+			lastTry = now
+			le.attempt(now)
+		}
+	}
+}
+
+// Renew our lease if we're the leader, else try to become the leader.
+
+func (le *leaseElection) attempt(now time.Time) {
+	le.mutex.Lock()
+	wasLeader := le.isLeader
+	le.mutex.Unlock()
+
+	leader := wasLeader
+	if wasLeader {
+		ok, err := le.ls.leaseRenew(ELECTION_KEY, le.id, ELECTION_TTL)
+		if err != nil {
+			//The lease may have lapsed by now; if so, step down.
+
+			logMain.Error(fmt.Sprintf("Error renewing leader lease: %v", err),
+				"error", err)
+			if now.Sub(le.lastRenew) >= ELECTION_TTL {
+				leader = false
+			}
+		} else {
+			leader = ok
+			le.lastRenew = now
+		}
+	} else {
+		ok, err := le.ls.leaseAcquire(ELECTION_KEY, le.id, ELECTION_TTL)
+		if err != nil {
+			logTrace(logMain, fmt.Sprintf("Can't campaign for leader: %v", err),
+				"error", err)
+		} else if ok {
+			leader = true
+			le.lastRenew = now
+		}
+	}
+
+	cur, exists, err := le.ls.Get(ELECTION_KEY)
+	le.mutex.Lock()
+	le.isLeader = leader
+	if err == nil {
+		if !exists {
+			cur = ""
+		}
+		le.leader = cur
+	}
+	le.mutex.Unlock()
+
+	if leader != wasLeader {
+		leaderChanged(leader)
+	}
+}
+
+func (le *leaseElection) IsLeader() bool {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+	return le.isLeader
+}
+
+func (le *leaseElection) Leader() string {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+	return le.leader
+}
+
+func (le *leaseElection) Resign() error {
+	close(le.stop)
+
+	le.mutex.Lock()
+	wasLeader := le.isLeader
+	le.isLeader = false
+	le.mutex.Unlock()
+
+	if !wasLeader {
+		return nil
+	}
+	leaderChanged(false)
+	_, err := le.ls.leaseRenew(ELECTION_KEY, le.id, 0)
+	return err
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// ETCD election, using an ETCD session lease and the concurrency package's
+// campaign/observe.  If the session's lease is lost, leadership is given
+// up and a new campaign is started with a new session.
+/////////////////////////////////////////////////////////////////////////////
+
+type etcdElection struct {
+	cli      *clientv3.Client
+	id       string
+	mutex    sync.Mutex
+	leader   string
+	isLeader bool
+	poll     time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newEtcdElection(cli *clientv3.Client, id string) *etcdElection {
+	ee := &etcdElection{cli: cli, id: id, poll: electionPoll,
+		done: make(chan struct{})}
+	ee.ctx, ee.cancel = context.WithCancel(context.Background())
+	go ee.run()
+	return ee
+}
+
+func (ee *etcdElection) setLeader(leader bool) {
+	ee.mutex.Lock()
+	changed := (ee.isLeader != leader)
+	ee.isLeader = leader
+	ee.mutex.Unlock()
+	if changed {
+		leaderChanged(leader)
+	}
+}
+
+func (ee *etcdElection) run() {
+	defer close(ee.done)
+
+	for ee.ctx.Err() == nil {
+		sess, err := concurrency.NewSession(ee.cli, concurrency.WithContext(ee.ctx),
+			concurrency.WithTTL(int(ELECTION_TTL/time.Second)))
+		if err != nil {
+			logMain.Error(fmt.Sprintf("Error creating leader election session: %v", err),
+				"error", err)
+			time.Sleep(ee.poll)
+			continue
+		}
+
+		el := concurrency.NewElection(sess, ELECTION_KEY)
+		octx, ocancel := context.WithCancel(ee.ctx)
+		go ee.observe(octx, el)
+
+		//Blocks until elected, the session is lost or we resign.
+
+		cctx, ccancel := context.WithCancel(ee.ctx)
+		go func() {
+			select {
+			case <-sess.Done():
+			case <-cctx.Done():
+			}
+			ccancel()
+		}()
+		err = el.Campaign(cctx, ee.id)
+		if err == nil {
+			ee.setLeader(true)
+			select {
+			case <-sess.Done():
+			case <-ee.ctx.Done():
+				rctx, rcancel := context.WithTimeout(context.Background(), 5*time.Second)
+				el.Resign(rctx)
+				rcancel()
+			}
+			ee.setLeader(false)
+		} else if ee.ctx.Err() == nil {
+			logMain.Error(fmt.Sprintf("Error campaigning for leader: %v", err),
+				"error", err)
+			time.Sleep(ee.poll)
+		}
+
+		ccancel()
+		ocancel()
+		sess.Close()
+	}
+}
+
+// Keep track of who the leader is.
+
+func (ee *etcdElection) observe(ctx context.Context, el *concurrency.Election) {
+	for rsp := range el.Observe(ctx) {
+		if len(rsp.Kvs) == 0 {
+			continue
+		}
+		ee.mutex.Lock()
+		ee.leader = string(rsp.Kvs[0].Value)
+		ee.mutex.Unlock()
+	}
+}
+
+func (ee *etcdElection) IsLeader() bool {
+	ee.mutex.Lock()
+	defer ee.mutex.Unlock()
+	return ee.isLeader
+}
+
+func (ee *etcdElection) Leader() string {
+	ee.mutex.Lock()
+	defer ee.mutex.Unlock()
+	return ee.leader
+}
+
+func (ee *etcdElection) Resign() error {
+	ee.cancel()
+	<-ee.done
+	return nil
+}
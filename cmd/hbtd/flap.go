@@ -0,0 +1,228 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Flap detection.  A component whose heartbeat keeps stopping and
+// restarting would otherwise cause an HSM update and a telemetry message
+// for every warning and restart.  The HB checker keeps the times of each
+// component's recent warnings and restarts in its HB record; once there
+// are flap_count of them within flap_window seconds the component is
+// flapping.  A flapping component is held in the warning state: one
+// "flapping" telemetry message is sent, and its warnings and restarts are
+// not sent, until it has gone flap_settle seconds without one.  If it is
+// heartbeating then, a restart is sent to release it.  Errors and expected
+// stops are never held.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	FLAP_COUNT  = 6
+	FLAP_WINDOW = 600
+	FLAP_SETTLE = 300
+
+	FLAP_MESSAGE_ID = "Heartbeat Flapping"
+)
+
+type hbFlapRsp struct {
+	XName          string    `json:"XName"`
+	Since          time.Time `json:"Since"`          //When flapping was detected
+	LastTransition time.Time `json:"LastTransition"` //Last warning or restart
+	Transitions    int       `json:"Transitions"`    //Within flap_window
+}
+
+type hbFlapList struct {
+	Flapping []hbFlapRsp `json:"Flapping"`
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Record a warning or restart for a component and decide whether it is
+// sent.  Called by the HB checker, which stores the HB record.
+//
+// hbb(in/out):  HB record of the component.
+// to_state(in): HB_stopped_warn or HB_restarted_warn.
+// now(in):      Current time, Unix seconds.
+// Return:       true if the transition is to be sent, false if the
+//               component is flapping and the transition is held.
+/////////////////////////////////////////////////////////////////////////////
+
+func flapTransition(hbb *hbinfo, to_state int, now int64) bool {
+	if app_params.flap_count.int_param <= 0 {
+		hbb.Flap_times = nil
+		return (hbb.Flapping == 0)
+	}
+
+	since := now - int64(app_params.flap_window.int_param)
+	times := make([]int64, 0, len(hbb.Flap_times)+1)
+	for _, ft := range hbb.Flap_times {
+		if ft > since {
+			times = append(times, ft)
+		}
+	}
+	hbb.Flap_times = append(times, now)
+
+	if hbb.Flapping != 0 {
+		mTransitionsDamped.WithLabelValues(hbTransitionName(to_state)).Inc()
+		logChecker.Debug(fmt.Sprintf("Holding %s notification for flapping component '%s'.",
+			hbTransitionName(to_state), hbb.Component),
+			"component", hbb.Component, "transition", hbTransitionName(to_state))
+		return false
+	}
+	if len(hbb.Flap_times) < app_params.flap_count.int_param {
+		return true
+	}
+
+	//Just started flapping.  A warning is sent, since that's the state the
+	//component is held in; a restart isn't, since the warning before it was.
+
+	hbb.Flapping = now
+	info := fmt.Sprintf("Heartbeat flapping, %d warnings and restarts in %d seconds; holding in warning state until stable for %d seconds.",
+		len(hbb.Flap_times), app_params.flap_window.int_param,
+		app_params.flap_settle.int_param)
+	logChecker.Warn(fmt.Sprintf("%s: %s", hbb.Component, info),
+		"component", hbb.Component, "transitions", len(hbb.Flap_times))
+	mFlapStarts.Inc()
+
+	if suppressedBy(hbb.Component, HB_stopped_warn) == "" {
+		telemsg := telemetry_json_v1{MessageID: FLAP_MESSAGE_ID, Id: hbb.Component,
+			NewState: base.StateReady.String(), NewFlag: base.FlagWarning.String(),
+			LastHBTimeStamp: hbb.Last_hb_timestamp, Info: info}
+		select {
+		case telemetryQ <- telemsg:
+		default:
+			mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+			logTelemetry.Info("Telemetry bus not accepting messages, flapping event not sent.",
+				"component", hbb.Component)
+		}
+	}
+
+	if to_state == HB_stopped_warn {
+		return true
+	}
+	mTransitionsDamped.WithLabelValues(hbTransitionName(to_state)).Inc()
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Check whether a flapping component has settled, and if so stop holding
+// it.  If it is heartbeating normally a restart is sent; if it is still in
+// the warning state, nothing needs to be sent since the last thing sent
+// was a warning.  Called by the HB checker for flapping components.
+//
+// hbb(in/out): HB record of the component.
+// now(in):     Current time, Unix seconds.
+// Return:      true if the HB record changed, else false.
+/////////////////////////////////////////////////////////////////////////////
+
+func flapSettled(hbb *hbinfo, now int64) bool {
+	if hbb.Flapping == 0 {
+		return false
+	}
+	if (app_params.flap_count.int_param > 0) && (len(hbb.Flap_times) > 0) &&
+		(now-hbb.Flap_times[len(hbb.Flap_times)-1] < int64(app_params.flap_settle.int_param)) {
+		return false
+	}
+
+	logChecker.Info(fmt.Sprintf("Heartbeat no longer flapping for '%s'", hbb.Component),
+		"component", hbb.Component, "flapping", now-hbb.Flapping)
+	hbb.Flapping = 0
+	hbb.Flap_times = nil
+	if hbb.Had_warning == HB_WARN_NONE {
+		hb_update_notify(hbb, HB_restarted_warn)
+	}
+	return true
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/flapping.  Lists flapping components.
+// Optional query parameter:
+//
+//   prefix=xname    Only return components with this xname prefix
+/////////////////////////////////////////////////////////////////////////////
+
+func flappingIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_FLAPPING
+	prefix := xnametypes.NormalizeHMSCompID(r.URL.Query().Get("prefix"))
+
+	kvlist, err := kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching all hbtd keys from KV store: %v", err),
+			"error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GETRANGE operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	since := time.Now().Unix() - int64(app_params.flap_window.int_param)
+	rsp := hbFlapList{Flapping: []hbFlapRsp{}}
+	for _, kv := range kvlist {
+		var hbb hbinfo
+
+		if kv.Key == KV_PARAM_KEY {
+			continue
+		}
+		if (prefix != "") && !strings.HasPrefix(kv.Key, prefix) {
+			continue
+		}
+		if umerr := json.Unmarshal([]byte(kv.Value), &hbb); umerr != nil {
+			logMain.Error(fmt.Sprintf("Error unmarshalling '%s': %v", kv.Value, umerr),
+				"component", kv.Key, "error", umerr)
+			continue
+		}
+		if hbb.Flapping == 0 {
+			continue
+		}
+
+		frsp := hbFlapRsp{XName: hbb.Component, Since: time.Unix(hbb.Flapping, 0).UTC()}
+		for _, ft := range hbb.Flap_times {
+			if ft > since {
+				frsp.Transitions++
+			}
+		}
+		if len(hbb.Flap_times) > 0 {
+			frsp.LastTransition = time.Unix(hbb.Flap_times[len(hbb.Flap_times)-1], 0).UTC()
+		}
+		rsp.Flapping = append(rsp.Flapping, frsp)
+	}
+
+	sort.Slice(rsp.Flapping, func(i, j int) bool {
+		return rsp.Flapping[i].XName < rsp.Flapping[j].XName
+	})
+	sendJSON(w, http.StatusOK, &rsp, errinst)
+}
@@ -0,0 +1,202 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test flap detection, damping and settling.
+
+func TestFlapDetection(t *testing.T) {
+	origParams := app_params
+	app_params.flap_count.int_param = 4
+	app_params.flap_window.int_param = 600
+	app_params.flap_settle.int_param = 30
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	comp := "x3008c0s0b0n0"
+	hbb := hbinfo{Component: comp}
+	defer func() {
+		app_params = origParams
+		hbMapLock.Lock()
+		delete(RestartMap, comp)
+		hbMapLock.Unlock()
+	}()
+	drainTelemetryQ()
+
+	//Warning, restart, warning: all sent.  The next restart makes four
+	//within the window, so it is held and a flapping event is sent.
+
+	t0 := time.Now().Unix()
+	edges := []int{HB_stopped_warn, HB_restarted_warn, HB_stopped_warn}
+	for ix, edge := range edges {
+		if !flapTransition(&hbb, edge, t0+int64(ix*10)) {
+			t.Errorf("Transition %d held before flapping", ix)
+		}
+	}
+	if hbb.Flapping != 0 {
+		t.Fatalf("Flapping after %d transitions", len(edges))
+	}
+	if flapTransition(&hbb, HB_restarted_warn, t0+30) {
+		t.Errorf("Restart sent for flapping component")
+	}
+	if hbb.Flapping != t0+30 {
+		t.Fatalf("Expected flapping since %d, got %d", t0+30, hbb.Flapping)
+	}
+	msgs := drainTelemetryQ()
+	if (len(msgs) != 1) || (msgs[0].MessageID != FLAP_MESSAGE_ID) || (msgs[0].Id != comp) ||
+		(msgs[0].NewFlag != "Warning") {
+		t.Errorf("Expected one flapping event, got %+v", msgs)
+	}
+
+	//Further transitions are held, without more flapping events.
+
+	if flapTransition(&hbb, HB_stopped_warn, t0+40) ||
+		flapTransition(&hbb, HB_restarted_warn, t0+50) {
+		t.Errorf("Transition sent for flapping component")
+	}
+	if msgs = drainTelemetryQ(); len(msgs) != 0 {
+		t.Errorf("Unexpected telemetry while flapping: %+v", msgs)
+	}
+
+	//Not settled until flap_settle seconds after the last transition; then
+	//a restart releases it.
+
+	hbb.Had_warning = HB_WARN_NONE
+	if flapSettled(&hbb, t0+70) {
+		t.Errorf("Settled too soon")
+	}
+	if !flapSettled(&hbb, t0+80) || (hbb.Flapping != 0) || (len(hbb.Flap_times) != 0) {
+		t.Errorf("Not settled: %+v", hbb)
+	}
+	hbMapLock.Lock()
+	if RestartMap[comp] == 0 {
+		t.Errorf("No restart sent on settling")
+	}
+	delete(RestartMap, comp)
+	hbMapLock.Unlock()
+
+	//Transitions outside the window don't count.
+
+	for ix := 0; ix < 6; ix++ {
+		if !flapTransition(&hbb, HB_stopped_warn, t0+int64(ix*300)) {
+			t.Errorf("Transition %d outside window held", ix)
+		}
+	}
+
+	//Settling in the warning state sends nothing; turning flap detection
+	//off settles right away.
+
+	hbb = hbinfo{Component: comp, Had_warning: HB_WARN_NORMAL, Flapping: t0,
+		Flap_times: []int64{t0}}
+	if !flapSettled(&hbb, t0+30) {
+		t.Errorf("Not settled in warning state")
+	}
+	hbb = hbinfo{Component: comp, Flapping: t0, Flap_times: []int64{t0}}
+	app_params.flap_count.int_param = 0
+	if flapTransition(&hbb, HB_restarted_warn, t0+1) || !flapSettled(&hbb, t0+1) {
+		t.Errorf("Flap detection off, component not released: %+v", hbb)
+	}
+	hbMapLock.Lock()
+	if RestartMap[comp] == 0 {
+		t.Errorf("No restart sent on settling")
+	}
+	hbMapLock.Unlock()
+}
+
+// Test GET /flapping and the flapping indication in the HB state APIs.
+
+func TestFlappingIO(t *testing.T) {
+	var flist hbFlapList
+	var srsp hbSingleStateRsp
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	origParams := app_params
+	initAppParams()
+	defer func() { app_params = origParams }()
+
+	now := time.Now().Unix()
+	rcv := strconv.FormatUint(uint64(now), 16)
+	recs := []hbinfo{
+		{Component: "x3008c0s1b0n0", Last_hb_rcv_time: rcv, Flapping: now - 100,
+			Flap_times: []int64{now - 5000, now - 100, now - 50}},
+		{Component: "x3008c0s2b0n0", Last_hb_rcv_time: rcv},
+		{Component: "x3009c0s1b0n0", Last_hb_rcv_time: rcv, Flapping: now - 10,
+			Flap_times: []int64{now - 10}},
+	}
+	for _, rec := range recs {
+		ba, _ := json.Marshal(&rec)
+		kvHandle.Store(rec.Component, string(ba))
+		defer kvHandle.Delete(rec.Component)
+	}
+
+	rr := policyReq(t, "GET", URL_FLAPPING, "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &flist); err != nil {
+		t.Fatalf("Can't unmarshal flapping list: %v", err)
+	}
+	if (len(flist.Flapping) != 2) || (flist.Flapping[0].XName != "x3008c0s1b0n0") ||
+		(flist.Flapping[1].XName != "x3009c0s1b0n0") {
+		t.Fatalf("Unexpected flapping list: %s", rr.Body.String())
+	}
+	frsp := flist.Flapping[0]
+	if (frsp.Transitions != 2) || (frsp.Since.Unix() != now-100) ||
+		(frsp.LastTransition.Unix() != now-50) {
+		t.Errorf("Unexpected flapping entry: %+v", frsp)
+	}
+
+	rr = policyReq(t, "GET", URL_FLAPPING+"?prefix=X3009", "", http.StatusOK)
+	flist = hbFlapList{}
+	json.Unmarshal(rr.Body.Bytes(), &flist)
+	if (len(flist.Flapping) != 1) || (flist.Flapping[0].XName != "x3009c0s1b0n0") {
+		t.Errorf("Unexpected flapping list for prefix: %s", rr.Body.String())
+	}
+
+	//A flapping component is held in the warning state.
+
+	rr = policyReq(t, "GET", URL_HB_STATE+"/x3008c0s1b0n0?verbose=true", "", http.StatusOK)
+	json.Unmarshal(rr.Body.Bytes(), &srsp)
+	if !srsp.Flapping || (srsp.State != HB_STATE_WARN) {
+		t.Errorf("Unexpected flapping component state: %s", rr.Body.String())
+	}
+	rr = policyReq(t, "GET", URL_HB_STATE+"/x3008c0s2b0n0?verbose=true", "", http.StatusOK)
+	srsp = hbSingleStateRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &srsp)
+	if srsp.Flapping || (srsp.State != HB_STATE_OK) {
+		t.Errorf("Unexpected component state: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,305 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative hbtdpb/hbtd.proto
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cray-HPE/hms-hbtd/cmd/hbtd/hbtdpb"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// gRPC heartbeat service.  With tens of thousands of nodes heartbeating
+// every few seconds, an HTTP request per heartbeat is expensive.  When
+// grpc_port is set, a gRPC listener is started alongside the HTTP one,
+// offering a unary Heartbeat RPC, a client-streaming HeartbeatStream RPC
+// over which a node keeps one connection open and pushes its heartbeats,
+// and an HBStates query.  Heartbeats are stored exactly as HTTP ones are,
+// and counted in the same metrics under their own endpoint labels.  The
+// service is defined in hbtdpb/hbtd.proto.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	GRPC_PORT = 0 //Off by default
+
+	GRPC_STOP_TIMEOUT   = 5  //Seconds to wait for RPCs to finish on shutdown
+	GRPC_KEEPALIVE_MIN  = 10 //Minimum client keepalive ping interval, seconds
+	GRPC_KEEPALIVE_TIME = 60 //Server keepalive ping interval, seconds
+)
+
+type hbGRPCHeartbeat struct {
+	hbtdpb.UnimplementedHeartbeatServiceServer
+}
+
+type hbGRPCState struct {
+	hbtdpb.UnimplementedHBStateServiceServer
+}
+
+// Convenience function, convert an HTTP status code into a gRPC one.
+
+func grpcCode(code int) codes.Code {
+	switch {
+	case code < 300:
+		return codes.OK
+	case code == http.StatusNotFound:
+		return codes.NotFound
+	case code < 500:
+		return codes.InvalidArgument
+	case code == http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// Convenience function.  Check the fields of a gRPC heartbeat.  These are
+// checked as for the per-component HTTP heartbeat, plus the component name
+// and NID if present.
+//
+// req(in): Heartbeat to check.
+// Return:  Empty string if valid, else a description of the problem.
+
+func validateHBGRPC(req *hbtdpb.HeartbeatRequest) string {
+	ferrstr := ""
+	if req.GetComponent() == "" {
+		ferrstr = "Missing Component field"
+	} else if req.GetStatus() == "" {
+		ferrstr = "Missing Status field"
+	} else if req.GetTimestamp() == "" {
+		ferrstr = "Missing Timestamp field"
+	}
+
+	if ferrstr != "" {
+		logIngest.Info(fmt.Sprintf("Incomplete gRPC heartbeat: %s", ferrstr),
+			"component", req.GetComponent(), "error", ferrstr)
+		return ferrstr
+	}
+
+	if xnametypes.GetHMSType(req.GetComponent()) == xnametypes.HMSTypeInvalid {
+		logIngest.Info(fmt.Sprintf("Invalid XName in gRPC heartbeat: %s", req.GetComponent()),
+			"component", req.GetComponent())
+		return "Invalid Component Name"
+	}
+
+	if req.GetNid() != "" {
+		_, cerr := strconv.ParseInt(req.GetNid(), 0, 64)
+		if cerr != nil {
+			logIngest.Info(fmt.Sprintf("Invalid NID in gRPC heartbeat: %s", req.GetNid()),
+				"component", req.GetComponent(), "nid", req.GetNid())
+			return "Invalid NID"
+		}
+	}
+
+	return ""
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Handle one heartbeat received over gRPC: check it, store it the same way
+// as an HTTP heartbeat, and count it.
+//
+// endpoint(in): Endpoint label for the heartbeats received metric.
+// req(in):      Heartbeat.
+// Return:       nil on success, else a gRPC status error.
+/////////////////////////////////////////////////////////////////////////////
+
+func grpcHB(endpoint string, req *hbtdpb.HeartbeatRequest) error {
+	code := http.StatusOK
+	errstr := validateHBGRPC(req)
+	if errstr != "" {
+		code = http.StatusBadRequest
+	} else {
+		if logEnabled(logIngest, slog.LevelDebug) {
+			logIngest.Debug(fmt.Sprintf("gRPC Heartbeat: Component: %s, Host: %s, NID: %s, Status: %s, time: %s",
+				req.GetComponent(), req.GetHostname(), req.GetNid(), req.GetStatus(),
+				req.GetTimestamp()),
+				"component", req.GetComponent(), "hostname", req.GetHostname(),
+				"nid", req.GetNid(), "status", req.GetStatus(),
+				"timestamp", req.GetTimestamp())
+		}
+		code, errstr = storeHB(req.GetComponent(), req.GetTimestamp(), req.GetStatus())
+	}
+
+	mHBReceived.WithLabelValues(endpoint, hbResultLabel(code)).Inc()
+	if code != http.StatusOK {
+		return status.Error(grpcCode(code), errstr)
+	}
+	return nil
+}
+
+// Entry point for the unary Heartbeat RPC.
+
+func (s *hbGRPCHeartbeat) Heartbeat(ctx context.Context, req *hbtdpb.HeartbeatRequest) (*hbtdpb.HeartbeatResponse, error) {
+	err := grpcHB(HB_EP_GRPC, req)
+	if err != nil {
+		return nil, err
+	}
+	return &hbtdpb.HeartbeatResponse{}, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for the HeartbeatStream RPC.  Heartbeats are handled as they
+// arrive until the client closes the stream, then a summary is returned.
+// A bad heartbeat doesn't end the stream, since the next one may well be
+// fine; it is counted, and the reason for the last one is returned.
+/////////////////////////////////////////////////////////////////////////////
+
+func (s *hbGRPCHeartbeat) HeartbeatStream(stream grpc.ClientStreamingServer[hbtdpb.HeartbeatRequest, hbtdpb.HeartbeatStreamSummary]) error {
+	var summary hbtdpb.HeartbeatStreamSummary
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&summary)
+		}
+		if err != nil {
+			logIngest.Debug(fmt.Sprintf("gRPC heartbeat stream ended: %v", err),
+				"error", err, "accepted", summary.Accepted, "rejected", summary.Rejected)
+			return err
+		}
+
+		herr := grpcHB(HB_EP_GRPC_STREAM, req)
+		if herr != nil {
+			summary.Rejected++
+			summary.LastError = status.Convert(herr).Message()
+		} else {
+			summary.Accepted++
+		}
+	}
+}
+
+// Entry point for the HBStates RPC, the same as POST /hmi/v1/hbstates.
+
+func (s *hbGRPCState) HBStates(ctx context.Context, req *hbtdpb.HBStatesRequest) (*hbtdpb.HBStatesResponse, error) {
+	states, pdet := getHBStates(req.GetXnames(), URL_HB_STATES, req.GetVerbose())
+	if pdet != nil {
+		return nil, status.Error(grpcCode(pdet.Status), pdet.Detail)
+	}
+
+	rsp := &hbtdpb.HBStatesResponse{HbStates: make([]*hbtdpb.HBState, 0, len(states))}
+	for ix := range states {
+		rsp.HbStates = append(rsp.HbStates, hbStatePB(&states[ix]))
+	}
+	return rsp, nil
+}
+
+// Convenience function, convert a component's HB state to its gRPC form.
+
+func hbStatePB(st *hbSingleStateRsp) *hbtdpb.HBState {
+	pst := &hbtdpb.HBState{Xname: st.XName, Heartbeating: st.Heartbeating,
+		State: st.State, LastHbTimestamp: st.Last_hb_timestamp,
+		LastHbStatus: st.Last_hb_status, WarningReason: st.WarningReason,
+		Flapping: st.Flapping}
+	if st.SecondsSinceLastHB != nil {
+		pst.SecondsSinceLastHb = *st.SecondsSinceLastHB
+	}
+	if det := st.Detection; det != nil {
+		pst.Detection = &hbtdpb.Detection{Detector: det.Detector, Policy: det.Policy,
+			Learning: det.Learning, Samples: int32(det.Samples),
+			MeanInterval: det.MeanInterval, StdDev: det.StdDev, Phi: det.Phi,
+			Warntime: int32(det.Warntime), Errtime: int32(det.Errtime)}
+	}
+	return pst
+}
+
+// Create the gRPC server with the heartbeat and HB state services.
+// Clients with long-lived heartbeat streams may ping to keep them open
+// through idle timeouts.
+
+func newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             GRPC_KEEPALIVE_MIN * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time: GRPC_KEEPALIVE_TIME * time.Second,
+		}))
+	hbtdpb.RegisterHeartbeatServiceServer(srv, &hbGRPCHeartbeat{})
+	hbtdpb.RegisterHBStateServiceServer(srv, &hbGRPCState{})
+	return srv
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Start the gRPC listener, if grpc_port is set.
+//
+// Args:   None.
+// Return: gRPC server, nil if not started.
+/////////////////////////////////////////////////////////////////////////////
+
+func startGRPC() *grpc.Server {
+	port := app_params.grpc_port.int_param
+	if port <= 0 {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Can't listen on gRPC port %d, gRPC disabled: %v",
+			port, err), "port", port, "error", err)
+		return nil
+	}
+
+	srv := newGRPCServer()
+	go func() {
+		serr := srv.Serve(lis)
+		if serr != nil {
+			logMain.Error(fmt.Sprintf("gRPC server failed: %v", serr),
+				"error", serr)
+		}
+	}()
+
+	logMain.Info(fmt.Sprintf("gRPC server listening on port %d.", port),
+		"port", port)
+	return srv
+}
+
+// Stop the gRPC server.  Heartbeat streams are long-lived and won't end on
+// their own, so they are cut off if they are still open after
+// GRPC_STOP_TIMEOUT seconds.
+
+func stopGRPC(srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(GRPC_STOP_TIMEOUT * time.Second):
+		srv.Stop()
+	}
+}
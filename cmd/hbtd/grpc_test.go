@@ -0,0 +1,209 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/Cray-HPE/hms-hbtd/cmd/hbtd/hbtdpb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Test the gRPC heartbeat and HB state services.
+
+func TestGRPCHeartbeat(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	origParams := app_params
+	initAppParams()
+	defer func() { app_params = origParams }()
+
+	comps := []string{"x3012c0s0b0n0", "x3012c0s1b0n0", "x3012c0s2b0n0"}
+	for _, comp := range comps {
+		kvHandle.Delete(comp)
+	}
+	defer func() {
+		hbMapLock.Lock()
+		for _, comp := range comps {
+			kvHandle.Delete(comp)
+			delete(StartMap, comp)
+		}
+		hbMapLock.Unlock()
+	}()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Can't create gRPC client: %v", err)
+	}
+	defer conn.Close()
+	hbc := hbtdpb.NewHeartbeatServiceClient(conn)
+	stc := hbtdpb.NewHBStateServiceClient(conn)
+	ctx := context.Background()
+
+	//Unary heartbeats, good and bad.
+
+	okHB := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_GRPC, "ok"))
+	badHB := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_GRPC, "bad_request"))
+
+	_, err = hbc.Heartbeat(ctx, &hbtdpb.HeartbeatRequest{Component: comps[0],
+		Nid: "12", Status: "OK", Timestamp: "2026-01-02T03:04:05+00:00"})
+	if err != nil {
+		t.Errorf("Heartbeat failed: %v", err)
+	}
+	hbb, _ := getHBInfo(comps[0], "test")
+	if (hbb == nil) || (hbb.Last_hb_status != "OK") ||
+		(hbb.Last_hb_timestamp != "2026-01-02T03:04:05+00:00") {
+		t.Errorf("Heartbeat not stored: %+v", hbb)
+	}
+
+	badReqs := []*hbtdpb.HeartbeatRequest{
+		{Status: "OK", Timestamp: "2026-01-02T03:04:05+00:00"},
+		{Component: comps[1], Timestamp: "2026-01-02T03:04:05+00:00"},
+		{Component: comps[1], Status: "OK"},
+		{Component: "bogus", Status: "OK", Timestamp: "2026-01-02T03:04:05+00:00"},
+		{Component: comps[1], Nid: "x", Status: "OK", Timestamp: "2026-01-02T03:04:05+00:00"},
+	}
+	for ix, req := range badReqs {
+		_, err = hbc.Heartbeat(ctx, req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Bad heartbeat %d: expected InvalidArgument, got %v", ix, err)
+		}
+	}
+	if hbb, _ = getHBInfo(comps[1], "test"); hbb != nil {
+		t.Errorf("Bad heartbeat stored: %+v", hbb)
+	}
+
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_GRPC, "ok")); v != okHB+1 {
+		t.Errorf("Expected %g ok gRPC heartbeats, got %g", okHB+1, v)
+	}
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_GRPC, "bad_request")); v != badHB+float64(len(badReqs)) {
+		t.Errorf("Expected %g bad gRPC heartbeats, got %g", badHB+float64(len(badReqs)), v)
+	}
+
+	//A stream carrying heartbeats for two components, with a bad one in
+	//between that doesn't end it.
+
+	stream, err := hbc.HeartbeatStream(ctx)
+	if err != nil {
+		t.Fatalf("Can't open heartbeat stream: %v", err)
+	}
+	streamReqs := []*hbtdpb.HeartbeatRequest{
+		{Component: comps[1], Status: "OK", Timestamp: "2026-01-02T03:04:06+00:00"},
+		{Component: comps[1], Status: "OK"},
+		{Component: comps[1], Status: "Ready", Timestamp: "2026-01-02T03:04:07+00:00"},
+		{Component: comps[2], Status: "Shutdown", Timestamp: "2026-01-02T03:04:07+00:00"},
+	}
+	for _, req := range streamReqs {
+		if err = stream.Send(req); err != nil {
+			t.Fatalf("Can't send on heartbeat stream: %v", err)
+		}
+	}
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("Heartbeat stream failed: %v", err)
+	}
+	if (summary.GetAccepted() != 3) || (summary.GetRejected() != 1) ||
+		(summary.GetLastError() != "Missing Timestamp field") {
+		t.Errorf("Unexpected stream summary: %v", summary)
+	}
+	hbb, _ = getHBInfo(comps[1], "test")
+	if (hbb == nil) || (hbb.Last_hb_status != "Ready") || (len(hbb.History) != 2) {
+		t.Errorf("Stream heartbeats not stored: %+v", hbb)
+	}
+
+	//HB states.
+
+	srsp, err := stc.HBStates(ctx, &hbtdpb.HBStatesRequest{
+		Xnames: []string{comps[1], comps[2], "x3012c0s3b0n0"}, Verbose: true})
+	if err != nil {
+		t.Fatalf("HBStates failed: %v", err)
+	}
+	exp := []struct {
+		state        string
+		heartbeating bool
+	}{
+		{HB_STATE_OK, true},
+		{HB_STATE_STOPPING, true},
+		{HB_STATE_UNKNOWN, false},
+	}
+	states := srsp.GetHbStates()
+	if len(states) != len(exp) {
+		t.Fatalf("Expected %d HB states, got %v", len(exp), srsp)
+	}
+	for ix, st := range states {
+		if (st.GetState() != exp[ix].state) || (st.GetHeartbeating() != exp[ix].heartbeating) {
+			t.Errorf("Unexpected HB state %d: %v", ix, st)
+		}
+	}
+	if (states[0].GetLastHbStatus() != "Ready") || (states[0].GetDetection() == nil) ||
+		(states[0].GetDetection().GetDetector() != DETECTOR_FIXED) ||
+		(states[0].GetDetection().GetErrtime() != int32(app_params.errtime.int_param)) {
+		t.Errorf("Unexpected verbose HB state: %v", states[0])
+	}
+
+	srsp, err = stc.HBStates(ctx, &hbtdpb.HBStatesRequest{Xnames: []string{comps[0]}})
+	if (err != nil) || (len(srsp.GetHbStates()) != 1) ||
+		(srsp.GetHbStates()[0].GetState() != "") || !srsp.GetHbStates()[0].GetHeartbeating() {
+		t.Errorf("Unexpected non-verbose HB state: %v, %v", srsp, err)
+	}
+}
+
+// Test HTTP to gRPC status code conversion.
+
+func TestGRPCCode(t *testing.T) {
+	exp := map[int]codes.Code{
+		http.StatusOK:                  codes.OK,
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusInternalServerError: codes.Internal,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+	}
+	for hcode, gcode := range exp {
+		if grpcCode(hcode) != gcode {
+			t.Errorf("HTTP %d: expected %v, got %v", hcode, gcode, grpcCode(hcode))
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+	"github.com/gorilla/mux"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Heartbeat history.  Each component's HB record keeps its most recent
+// heartbeats (hb_history of them), oldest first, so that late, bursty or
+// missing heartbeats can be told apart after the fact.  Since the history
+// is part of the HB record it is shared by all instances, and goes away
+// with the record when the component stops heartbeating.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	HB_HISTORY_SIZE = 20
+	HB_HISTORY_MAX  = 1000
+)
+
+type hbHistEntry struct {
+	Received  time.Time `json:"Received"`  //Arrival time
+	Timestamp string    `json:"Timestamp"` //Time stamp, set by sender
+	Status    string    `json:"Status"`
+}
+
+// Inter-arrival statistics, in seconds.
+
+type hbHistStats struct {
+	Count     int     `json:"Count"` //Number of gaps
+	MeanGap   float64 `json:"MeanGap"`
+	P99Gap    float64 `json:"P99Gap"`
+	MaxGap    float64 `json:"MaxGap"`
+	SinceLast float64 `json:"SinceLast"` //Current gap, since the last HB
+}
+
+type hbHistRsp struct {
+	XName   string        `json:"XName"`
+	Size    int           `json:"Size"` //Heartbeats kept
+	Stats   hbHistStats   `json:"Stats"`
+	History []hbHistEntry `json:"History"`
+}
+
+// Add a heartbeat to a component's history, dropping the oldest ones
+// beyond the configured size.
+//
+// hbb(in/out):  Component's HB record.
+// rcv(in):      Arrival time.
+// timestamp(in): Sender's time stamp.
+// status(in):   Heartbeat status.
+
+func addHBHistory(hbb *hbinfo, rcv time.Time, timestamp, status string) {
+	size := app_params.hb_history.int_param
+	if size <= 0 {
+		hbb.History = nil
+		return
+	}
+
+	hbb.History = append(hbb.History, hbHistEntry{Received: rcv.UTC(),
+		Timestamp: timestamp, Status: status})
+	if len(hbb.History) > size {
+		hbb.History = append([]hbHistEntry(nil), hbb.History[len(hbb.History)-size:]...)
+	}
+}
+
+// Round seconds to milliseconds, for display.
+
+func roundMS(secs float64) float64 {
+	return math.Round(secs*1000) / 1000
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Compute inter-arrival statistics from a heartbeat history.  The p99 gap
+// uses the nearest-rank method, so with fewer than 100 gaps it is the
+// largest one.
+//
+// hist(in): Heartbeat history, oldest first.
+// now(in):  Current time.
+// Return:   Statistics.
+/////////////////////////////////////////////////////////////////////////////
+
+func hbHistoryStats(hist []hbHistEntry, now time.Time) hbHistStats {
+	var stats hbHistStats
+	var total float64
+
+	if len(hist) == 0 {
+		return stats
+	}
+	stats.SinceLast = roundMS(now.Sub(hist[len(hist)-1].Received).Seconds())
+	if len(hist) < 2 {
+		return stats
+	}
+
+	gaps := make([]float64, 0, len(hist)-1)
+	for ix := 1; ix < len(hist); ix++ {
+		gap := hist[ix].Received.Sub(hist[ix-1].Received).Seconds()
+		gaps = append(gaps, gap)
+		total += gap
+	}
+	sort.Float64s(gaps)
+
+	stats.Count = len(gaps)
+	stats.MeanGap = roundMS(total / float64(len(gaps)))
+	stats.MaxGap = roundMS(gaps[len(gaps)-1])
+	rank := int(math.Ceil(0.99*float64(len(gaps)))) - 1
+	stats.P99Gap = roundMS(gaps[rank])
+	return stats
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/hbhistory/{xname}
+/////////////////////////////////////////////////////////////////////////////
+
+func hbHistoryIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	targ := xnametypes.NormalizeHMSCompID(mux.Vars(r)["xname"])
+	errinst := URL_HB_HISTORY + "/" + targ
+
+	hbb, pdet := getHBInfo(targ, errinst)
+	if pdet != nil {
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if hbb == nil {
+		pdet = base.NewProblemDetails("about:blank",
+			"Not Found",
+			fmt.Sprintf("Component '%s' is not heartbeating", targ),
+			errinst, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	rsp := hbHistRsp{XName: targ, Size: app_params.hb_history.int_param,
+		Stats: hbHistoryStats(hbb.History, time.Now()), History: hbb.History}
+	if rsp.History == nil {
+		rsp.History = []hbHistEntry{}
+	}
+	sendJSON(w, http.StatusOK, &rsp, errinst)
+}
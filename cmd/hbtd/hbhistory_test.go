@@ -0,0 +1,142 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test the inter-arrival statistics.
+
+func TestHBHistoryStats(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	stats := hbHistoryStats(nil, start)
+	if stats != (hbHistStats{}) {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+
+	hist := []hbHistEntry{{Received: start}}
+	stats = hbHistoryStats(hist, start.Add(2500*time.Millisecond))
+	if (stats.Count != 0) || (stats.SinceLast != 2.5) {
+		t.Errorf("Unexpected single HB stats: %+v", stats)
+	}
+
+	//Gaps of 3, 3, 3, 9 and 2 seconds.
+
+	rcv := start
+	for _, gap := range []int{3, 3, 3, 9, 2} {
+		rcv = rcv.Add(time.Duration(gap) * time.Second)
+		hist = append(hist, hbHistEntry{Received: rcv})
+	}
+	stats = hbHistoryStats(hist, rcv.Add(time.Second))
+	exp := hbHistStats{Count: 5, MeanGap: 4, P99Gap: 9, MaxGap: 9, SinceLast: 1}
+	if stats != exp {
+		t.Errorf("Expected stats %+v, got %+v", exp, stats)
+	}
+
+	//With 200 gaps, p99 is the 198th smallest.
+
+	hist = []hbHistEntry{{Received: start}}
+	rcv = start
+	for ix := 1; ix <= 200; ix++ {
+		rcv = rcv.Add(time.Duration(ix) * time.Millisecond)
+		hist = append(hist, hbHistEntry{Received: rcv})
+	}
+	stats = hbHistoryStats(hist, rcv)
+	if (stats.Count != 200) || (stats.P99Gap != 0.198) || (stats.MaxGap != 0.2) ||
+		(stats.MeanGap != 0.101) {
+		t.Errorf("Unexpected stats for 200 gaps: %+v", stats)
+	}
+}
+
+// Test heartbeat history retention and GET /hbhistory/{xname}.
+
+func TestHBHistoryIO(t *testing.T) {
+	var rsp hbHistRsp
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	origSize := app_params.hb_history.int_param
+	app_params.hb_history.int_param = 3
+	defer func() { app_params.hb_history.int_param = origSize }()
+
+	comp := "x3007c0s0b0n0"
+	kvHandle.Delete(comp)
+	defer func() {
+		kvHandle.Delete(comp)
+		hbMapLock.Lock()
+		delete(StartMap, comp)
+		hbMapLock.Unlock()
+	}()
+
+	for ix := 0; ix < 5; ix++ {
+		ts := fmt.Sprintf("2026-01-02T03:04:%02d+00:00", ix)
+		policyReq(t, "POST", URL_HEARTBEAT+"/"+comp,
+			fmt.Sprintf(`{"Status":"Status%d","Timestamp":"%s"}`, ix, ts), http.StatusOK)
+	}
+
+	rr := policyReq(t, "GET", URL_HB_HISTORY+"/X3007C0S0B0N0", "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &rsp); err != nil {
+		t.Fatalf("Can't unmarshal history: %v", err)
+	}
+	if (rsp.XName != comp) || (rsp.Size != 3) || (len(rsp.History) != 3) {
+		t.Fatalf("Unexpected history response: %s", rr.Body.String())
+	}
+	for ix, ent := range rsp.History {
+		if (ent.Status != fmt.Sprintf("Status%d", ix+2)) ||
+			(ent.Timestamp != fmt.Sprintf("2026-01-02T03:04:%02d+00:00", ix+2)) ||
+			ent.Received.IsZero() {
+			t.Errorf("Unexpected history entry %d: %+v", ix, ent)
+		}
+	}
+	if (rsp.Stats.Count != 2) || (rsp.Stats.MaxGap > 1) {
+		t.Errorf("Unexpected history stats: %+v", rsp.Stats)
+	}
+
+	//A size of 0 drops the history at the next heartbeat.
+
+	app_params.hb_history.int_param = 0
+	policyReq(t, "POST", URL_HEARTBEAT+"/"+comp,
+		`{"Status":"OK","Timestamp":"2026-01-02T03:05:00+00:00"}`, http.StatusOK)
+	rr = policyReq(t, "GET", URL_HB_HISTORY+"/"+comp, "", http.StatusOK)
+	rsp = hbHistRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if (rsp.History == nil) || (len(rsp.History) != 0) || (rsp.Stats.Count != 0) {
+		t.Errorf("Expected empty history, got %s", rr.Body.String())
+	}
+
+	policyReq(t, "GET", URL_HB_HISTORY+"/x3007c0s0b0n9", "", http.StatusNotFound)
+}
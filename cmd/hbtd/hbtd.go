@@ -1,6 +1,6 @@
 // MIT License
 //
-// (C) Copyright [2018-2021,2023] Hewlett Packard Enterprise Development LP
+// (C) Copyright [2018-2021,2023,2026] Hewlett Packard Enterprise Development LP
 //
 // Permission is hereby granted, free of charge, to any person obtaining a
 // copy of this software and associated documentation files (the "Software"),
@@ -40,6 +40,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"os"
@@ -53,7 +54,6 @@ import (
 	"time"
 
 	"github.com/Cray-HPE/hms-base/v2"
-	"github.com/Cray-HPE/hms-hmetcd"
 	"github.com/Cray-HPE/hms-msgbus"
 )
 
@@ -70,38 +70,66 @@ type app_param struct {
 }
 
 type op_params struct {
-	debug_level      app_param
-	nosm             app_param
-	use_telemetry    app_param
-	telemetry_host   app_param
-	warntime         app_param
-	errtime          app_param
-	port             app_param //set at startup, not runtime changeable
-	kv_url           app_param
-	check_interval   app_param
-	statemgr_url     app_param
-	statemgr_timeout app_param
-	statemgr_retries app_param
-	clear_on_gap     app_param
+	debug_level        app_param
+	nosm               app_param
+	use_telemetry      app_param
+	telemetry_host     app_param
+	warntime           app_param
+	errtime            app_param
+	port               app_param //set at startup, not runtime changeable
+	kv_url             app_param
+	check_interval     app_param
+	statemgr_url       app_param
+	statemgr_timeout   app_param
+	statemgr_retries   app_param
+	clear_on_gap       app_param
+	reconcile_interval app_param
+	hb_history         app_param
+	flap_count         app_param
+	flap_window        app_param
+	flap_settle        app_param
+	topo_threshold     app_param
+	topo_window        app_param
+	topo_tag           app_param
+	inventory_interval app_param
+	inventory_grace    app_param
+	inventory_roles    app_param
+	grpc_port          app_param //set at startup, not runtime changeable
+	log_levels         app_param
+	log_format         app_param
 }
 
-// For parsing/unmarshalling a JSON parameter file.  Can't combine with
-// op_params since unmarshalling the .ini file would overwrite the cmdline
-// args.
+// For parsing/unmarshalling JSON parameter data (PATCH /params, stored
+// parameters and the --config file).  Can't combine with op_params since
+// unmarshalling the parameter data would overwrite the cmdline args.
 
 type inidata struct {
-	Debug          string `json:"Debug"`
-	Nosm           string `json:"Nosm"`
-	Use_telemetry  string `json:"Use_telemetry"`
-	Telemetry_host string `json:"Telemetry_host"`
-	Warntime       string `json:"Warntime"`
-	Errtime        string `json:"Errtime"`
-	Port           string `json:"Port"`
-	Kv_url         string `json:"Kv_url"`
-	Interval       string `json:"Interval"`
-	Sm_url         string `json:"Sm_url"`
-	Sm_timeout     string `json:"Sm_timeout"`
-	Sm_retries     string `json:"Sm_retries"`
+	Debug              string `json:"Debug"`
+	Nosm               string `json:"Nosm"`
+	Use_telemetry      string `json:"Use_telemetry"`
+	Telemetry_host     string `json:"Telemetry_host"`
+	Warntime           string `json:"Warntime"`
+	Errtime            string `json:"Errtime"`
+	Port               string `json:"Port"`
+	Kv_url             string `json:"Kv_url"`
+	Interval           string `json:"Interval"`
+	Sm_url             string `json:"Sm_url"`
+	Sm_timeout         string `json:"Sm_timeout"`
+	Sm_retries         string `json:"Sm_retries"`
+	Reconcile_interval string `json:"Reconcile_interval"`
+	Hb_history         string `json:"Hb_history"`
+	Flap_count         string `json:"Flap_count"`
+	Flap_window        string `json:"Flap_window"`
+	Flap_settle        string `json:"Flap_settle"`
+	Topo_threshold     string `json:"Topo_threshold"`
+	Topo_window        string `json:"Topo_window"`
+	Topo_tag           string `json:"Topo_tag"`
+	Inventory_interval string `json:"Inventory_interval"`
+	Inventory_grace    string `json:"Inventory_grace"`
+	Inventory_roles    string `json:"Inventory_roles"`
+	Grpc_port          string `json:"Grpc_port"`
+	Log_levels         string `json:"Log_levels,omitempty"`
+	Log_format         string `json:"Log_format,omitempty"`
 }
 
 // HB server URL segment description.
@@ -134,6 +162,7 @@ const (
 	SM_URL_MID    = "State/Components"
 	SM_URL_SUFFIX = "BulkStateData"
 	SM_URL_READY  = "service/ready"
+	SM_URL_QUERY  = "Query"
 	SM_RETRIES    = 3
 	SM_TIMEOUT    = 10
 
@@ -147,13 +176,12 @@ const (
 	PARAM_START       = 1
 	PARAM_PATCH       = 2
 	PARAM_SYNC        = 3
+	PARAM_FILE        = 4
 
 	UNSTR = "xxx"
 	UNINT = -1
 
-	HBTD_LIFE_KEY_PRE   = "hbtd_lifekey-"
-	HBTD_LIFE_KEY_START = 0
-	HBTD_LIFE_KEY_END   = (^uint32(0) >> 1)
+	HBTD_LIFE_KEY_PRE = "hbtd_lifekey-"
 
 	URL_PORT = "28500"
 )
@@ -179,38 +207,60 @@ var msgbusConfig = msgbus.MsgBusConfig{BusTech: msgbus.BusTechKafka,
 
 var serviceName string
 var msgbusHandle msgbus.MsgBusIO = nil
-var kvHandle hmetcd.Kvi
+var kvHandle hbStore
 var tbMutex *sync.Mutex = &sync.Mutex{}
 var htrans httpTrans
 var server_url_port = URL_PORT
 var staleKeys = false
+var instanceKey string
 var Running = true
 
-// This will be used for output.  We will normally use the built-in
-// functions, but we want to be able to override them for test purposes.
+// This will be used for output.  We will normally use logPrintf() and
+// logPrintln() (see logging.go), but we want to be able to override them
+// for test purposes.
 
-var hbtdPrintf = log.Printf
-var hbtdPrintln = log.Println
+var hbtdPrintf = logPrintf
+var hbtdPrintln = logPrintln
 
 /////////////////////////////////////////////////////////////////////////////
 // Initialize default values to the application parameters.
 /////////////////////////////////////////////////////////////////////////////
 
 func initAppParams() {
-	app_params = op_params{
-		debug_level:      app_param{name: "debug", int_param: 0},
-		nosm:             app_param{name: "nosm", int_param: 0},
-		use_telemetry:    app_param{name: "use_telemetry", int_param: 1},
-		telemetry_host:   app_param{name: "telemetry_host", string_param: ""},
-		warntime:         app_param{name: "warntime", int_param: 10},
-		errtime:          app_param{name: "errtime", int_param: 30},
-		check_interval:   app_param{name: "interval", int_param: 5},
-		port:             app_param{name: "port", string_param: URL_PORT},
-		kv_url:           app_param{name: "kv_url", string_param: KV_URL_BASE},
-		statemgr_url:     app_param{name: "sm_url", string_param: SM_URL_BASE},
-		statemgr_retries: app_param{name: "sm_retries", int_param: SM_RETRIES},
-		statemgr_timeout: app_param{name: "sm_timeout", int_param: SM_TIMEOUT},
-		clear_on_gap:     app_param{name: "clear_on_gap", int_param: 0},
+	app_params = defaultParams()
+}
+
+// Get the default application parameter values.
+
+func defaultParams() op_params {
+	return op_params{
+		debug_level:        app_param{name: "debug", int_param: 0},
+		nosm:               app_param{name: "nosm", int_param: 0},
+		use_telemetry:      app_param{name: "use_telemetry", int_param: 1},
+		telemetry_host:     app_param{name: "telemetry_host", string_param: ""},
+		warntime:           app_param{name: "warntime", int_param: 10},
+		errtime:            app_param{name: "errtime", int_param: 30},
+		check_interval:     app_param{name: "interval", int_param: 5},
+		port:               app_param{name: "port", string_param: URL_PORT},
+		kv_url:             app_param{name: "kv_url", string_param: KV_URL_BASE},
+		statemgr_url:       app_param{name: "sm_url", string_param: SM_URL_BASE},
+		statemgr_retries:   app_param{name: "sm_retries", int_param: SM_RETRIES},
+		statemgr_timeout:   app_param{name: "sm_timeout", int_param: SM_TIMEOUT},
+		clear_on_gap:       app_param{name: "clear_on_gap", int_param: 0},
+		reconcile_interval: app_param{name: "reconcile_interval", int_param: RECONCILE_INTERVAL},
+		hb_history:         app_param{name: "hb_history", int_param: HB_HISTORY_SIZE},
+		flap_count:         app_param{name: "flap_count", int_param: FLAP_COUNT},
+		flap_window:        app_param{name: "flap_window", int_param: FLAP_WINDOW},
+		flap_settle:        app_param{name: "flap_settle", int_param: FLAP_SETTLE},
+		topo_threshold:     app_param{name: "topo_threshold", int_param: TOPO_THRESHOLD},
+		topo_window:        app_param{name: "topo_window", int_param: TOPO_WINDOW},
+		topo_tag:           app_param{name: "topo_tag", int_param: TOPO_TAG},
+		inventory_interval: app_param{name: "inventory_interval", int_param: INVENTORY_INTERVAL},
+		inventory_grace:    app_param{name: "inventory_grace", int_param: INVENTORY_GRACE},
+		inventory_roles:    app_param{name: "inventory_roles", string_param: INVENTORY_ROLES},
+		grpc_port:          app_param{name: "grpc_port", int_param: GRPC_PORT},
+		log_levels:         app_param{name: "log_levels", string_param: ""},
+		log_format:         app_param{name: "log_format", string_param: LOG_FORMAT_TEXT},
 	}
 }
 
@@ -231,6 +281,9 @@ func plainPrint(format string, a ...interface{}) {
 func printHelp() {
 	hbtdPrintf("Usage: %s [options]\n\n", path.Base(os.Args[0]))
 	hbtdPrintf("  --help                      Help text.\n")
+	hbtdPrintf("  --config=file               JSON or YAML parameter file.  Env vars\n")
+	hbtdPrintf("                              and options override its values.\n")
+	hbtdPrintf("                              Reloaded on SIGHUP.\n")
 	hbtdPrintf("  --debug=num                 Debug level.  (Default: 0)\n")
 	hbtdPrintf("  --use_telemetry=yes|no      Inject notifications into message.\n")
 	hbtdPrintf("                              bus. (Default: yes)\n")
@@ -254,6 +307,51 @@ func printHelp() {
 	hbtdPrintf("  --sm_timeout=secs           State Manager access timeout. (Default: %d)\n",
 		SM_TIMEOUT)
 	hbtdPrintf("  --nosm                      Don't contact State Manager (for testing).\n")
+	hbtdPrintf("  --reconcile_interval=secs   HSM reconciliation interval, 0 == never.\n")
+	hbtdPrintf("                              (Default: %d seconds)\n",
+		RECONCILE_INTERVAL)
+	hbtdPrintf("  --hb_history=num            Heartbeats kept per component for\n")
+	hbtdPrintf("                              /hbhistory, 0 == none.  (Default: %d)\n",
+		HB_HISTORY_SIZE)
+	hbtdPrintf("  --flap_count=num            Warnings and restarts within flap_window\n")
+	hbtdPrintf("                              marking a component as flapping, 0 == no\n")
+	hbtdPrintf("                              flap detection.  (Default: %d)\n",
+		FLAP_COUNT)
+	hbtdPrintf("  --flap_window=secs          Flap detection window.  (Default: %d seconds)\n",
+		FLAP_WINDOW)
+	hbtdPrintf("  --flap_settle=secs          Time without warnings or restarts before a\n")
+	hbtdPrintf("                              component is no longer flapping.\n")
+	hbtdPrintf("                              (Default: %d seconds)\n",
+		FLAP_SETTLE)
+	hbtdPrintf("  --topo_threshold=pct        Percent of the components under a slot,\n")
+	hbtdPrintf("                              chassis or cabinet that must die within\n")
+	hbtdPrintf("                              topo_window for it to be the suspected\n")
+	hbtdPrintf("                              cause, 0 == no correlation.  (Default: %d)\n",
+		TOPO_THRESHOLD)
+	hbtdPrintf("  --topo_window=secs          Topology correlation window.\n")
+	hbtdPrintf("                              (Default: %d seconds)\n",
+		TOPO_WINDOW)
+	hbtdPrintf("  --topo_tag=yes|no           Note the suspected cause in HSM messages.\n")
+	hbtdPrintf("                              (Default: yes)\n")
+	hbtdPrintf("  --inventory_interval=secs   Interval for syncing the components\n")
+	hbtdPrintf("                              expected to heartbeat with HSM,\n")
+	hbtdPrintf("                              0 == never.  (Default: %d seconds)\n",
+		INVENTORY_INTERVAL)
+	hbtdPrintf("  --inventory_grace=secs      Time for an expected component to start\n")
+	hbtdPrintf("                              heartbeating.  (Default: %d seconds)\n",
+		INVENTORY_GRACE)
+	hbtdPrintf("  --inventory_roles=roles     HSM roles of the nodes expected to\n")
+	hbtdPrintf("                              heartbeat, comma separated.\n")
+	hbtdPrintf("                              (Default: %s)\n",
+		INVENTORY_ROLES)
+	hbtdPrintf("  --grpc_port=num             gRPC port to listen on, 0 == no gRPC.\n")
+	hbtdPrintf("                              (Default: %d)\n", GRPC_PORT)
+	hbtdPrintf("  --log_levels=spec           Log levels, e.g. 'info,checker=debug'.\n")
+	hbtdPrintf("                              Subsystems: ingest, checker, hsm,\n")
+	hbtdPrintf("                              telemetry, kv, main.  Levels: trace,\n")
+	hbtdPrintf("                              debug, info, warn, error.\n")
+	hbtdPrintf("                              (Default: from debug level)\n")
+	hbtdPrintf("  --log_format=text|json      Log output format.  (Default: text)\n")
 	hbtdPrintf("\n")
 }
 
@@ -291,6 +389,20 @@ func get_telemetry_host(hspec string) (string, int, string, error) {
 /////////////////////////////////////////////////////////////////////////////
 
 func gen_cur_param_json(paramstr *[]byte) int {
+	pj := cur_param_data()
+
+	ba, err := json.Marshal(pj)
+	if err != nil {
+		hbtdPrintln("INTERNAL ERROR marshalling json:", err)
+		return -1
+	}
+	*paramstr = ba
+	return 0
+}
+
+// Get the current configurable parameter values in their JSON form.
+
+func cur_param_data() inidata {
 	var pj inidata
 
 	pj.Debug = strconv.Itoa(app_params.debug_level.int_param)
@@ -305,14 +417,21 @@ func gen_cur_param_json(paramstr *[]byte) int {
 	pj.Sm_url = app_params.statemgr_url.string_param
 	pj.Sm_timeout = strconv.Itoa(app_params.statemgr_timeout.int_param)
 	pj.Sm_retries = strconv.Itoa(app_params.statemgr_retries.int_param)
-
-	ba, err := json.Marshal(pj)
-	if err != nil {
-		hbtdPrintln("INTERNAL ERROR marshalling json:", err)
-		return -1
-	}
-	*paramstr = ba
-	return 0
+	pj.Reconcile_interval = strconv.Itoa(app_params.reconcile_interval.int_param)
+	pj.Hb_history = strconv.Itoa(app_params.hb_history.int_param)
+	pj.Flap_count = strconv.Itoa(app_params.flap_count.int_param)
+	pj.Flap_window = strconv.Itoa(app_params.flap_window.int_param)
+	pj.Flap_settle = strconv.Itoa(app_params.flap_settle.int_param)
+	pj.Topo_threshold = strconv.Itoa(app_params.topo_threshold.int_param)
+	pj.Topo_window = strconv.Itoa(app_params.topo_window.int_param)
+	pj.Topo_tag = strconv.Itoa(app_params.topo_tag.int_param)
+	pj.Inventory_interval = strconv.Itoa(app_params.inventory_interval.int_param)
+	pj.Inventory_grace = strconv.Itoa(app_params.inventory_grace.int_param)
+	pj.Inventory_roles = app_params.inventory_roles.string_param
+	pj.Grpc_port = strconv.Itoa(app_params.grpc_port.int_param)
+	pj.Log_levels = app_params.log_levels.string_param
+	pj.Log_format = app_params.log_format.string_param
+	return pj
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -323,7 +442,21 @@ func gen_cur_param_json(paramstr *[]byte) int {
 /////////////////////////////////////////////////////////////////////////////
 
 func parse_cmd_line() {
+	parse_cmdline_params(get_cmd_line())
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Read the command line arguments without applying them.  They are saved
+// in cmdlineParams so they can be re-applied when parameters are reloaded,
+// and the parameter file, if any, is saved in configFile.
+//
+// Args:   None.
+// Return: Command line arguments; unset ones are UNINT/UNSTR.
+/////////////////////////////////////////////////////////////////////////////
+
+func get_cmd_line() op_params {
 	helpP := flag.Bool("help", false, "Help text")
+	confP := flag.String("config", UNSTR, "JSON or YAML parameter file.")
 	dlevP := flag.Int(app_params.debug_level.name, UNINT, "Debug level")
 	teleP := flag.String(app_params.use_telemetry.name, UNSTR, "Inject notifications into telemetry bus")
 	thostP := flag.String(app_params.telemetry_host.name, UNSTR, "Telemetry service host:port")
@@ -336,6 +469,20 @@ func parse_cmd_line() {
 	smtryP := flag.Int(app_params.statemgr_retries.name, UNINT, "State Mgr retry max count.")
 	smtoP := flag.Int(app_params.statemgr_timeout.name, UNINT, "State Mgr timeout duration.")
 	nosmP := flag.Bool(app_params.nosm.name, false, "Don't contact State Manager")
+	rcivP := flag.Int(app_params.reconcile_interval.name, UNINT, "HSM reconciliation interval.")
+	hhisP := flag.Int(app_params.hb_history.name, UNINT, "Heartbeats kept per component.")
+	flcP := flag.Int(app_params.flap_count.name, UNINT, "Transitions marking a component as flapping.")
+	flwP := flag.Int(app_params.flap_window.name, UNINT, "Flap detection window.")
+	flsP := flag.Int(app_params.flap_settle.name, UNINT, "Flap settle time.")
+	ttrP := flag.Int(app_params.topo_threshold.name, UNINT, "Topology correlation threshold.")
+	twinP := flag.Int(app_params.topo_window.name, UNINT, "Topology correlation window.")
+	ttagP := flag.String(app_params.topo_tag.name, UNSTR, "Note suspected cause in HSM messages.")
+	invivP := flag.Int(app_params.inventory_interval.name, UNINT, "Inventory sync interval.")
+	invgrP := flag.Int(app_params.inventory_grace.name, UNINT, "Inventory grace period.")
+	invrlP := flag.String(app_params.inventory_roles.name, UNSTR, "HSM roles expected to heartbeat.")
+	grpcP := flag.Int(app_params.grpc_port.name, UNINT, "gRPC port to listen on.")
+	loglP := flag.String(app_params.log_levels.name, UNSTR, "Log levels.")
+	logfP := flag.String(app_params.log_format.name, UNSTR, "Log output format.")
 
 	flag.Parse()
 
@@ -350,20 +497,39 @@ func parse_cmd_line() {
 		nosmi = 1
 	}
 	tvars := op_params{debug_level: app_param{name: "", int_param: *dlevP, string_param: ""},
-		nosm:             app_param{name: "", int_param: nosmi, string_param: ""},
-		use_telemetry:    app_param{name: "", int_param: 0, string_param: *teleP},
-		telemetry_host:   app_param{name: "", int_param: 0, string_param: *thostP},
-		warntime:         app_param{name: "", int_param: *warnP, string_param: ""},
-		errtime:          app_param{name: "", int_param: *errP, string_param: ""},
-		check_interval:   app_param{name: "", int_param: *checkP, string_param: ""},
-		port:             app_param{name: "", int_param: 0, string_param: *portP},
-		kv_url:           app_param{name: "", int_param: 0, string_param: *kvurlP},
-		statemgr_url:     app_param{name: "", int_param: 0, string_param: *smurlP},
-		statemgr_retries: app_param{name: "", int_param: *smtryP, string_param: ""},
-		statemgr_timeout: app_param{name: "", int_param: *smtoP, string_param: ""},
-	}
-
-	parse_cmdline_params(tvars)
+		nosm:               app_param{name: "", int_param: nosmi, string_param: ""},
+		use_telemetry:      app_param{name: "", int_param: 0, string_param: *teleP},
+		telemetry_host:     app_param{name: "", int_param: 0, string_param: *thostP},
+		warntime:           app_param{name: "", int_param: *warnP, string_param: ""},
+		errtime:            app_param{name: "", int_param: *errP, string_param: ""},
+		check_interval:     app_param{name: "", int_param: *checkP, string_param: ""},
+		port:               app_param{name: "", int_param: 0, string_param: *portP},
+		kv_url:             app_param{name: "", int_param: 0, string_param: *kvurlP},
+		statemgr_url:       app_param{name: "", int_param: 0, string_param: *smurlP},
+		statemgr_retries:   app_param{name: "", int_param: *smtryP, string_param: ""},
+		statemgr_timeout:   app_param{name: "", int_param: *smtoP, string_param: ""},
+		reconcile_interval: app_param{name: "", int_param: *rcivP, string_param: ""},
+		hb_history:         app_param{name: "", int_param: *hhisP, string_param: ""},
+		flap_count:         app_param{name: "", int_param: *flcP, string_param: ""},
+		flap_window:        app_param{name: "", int_param: *flwP, string_param: ""},
+		flap_settle:        app_param{name: "", int_param: *flsP, string_param: ""},
+		topo_threshold:     app_param{name: "", int_param: *ttrP, string_param: ""},
+		topo_window:        app_param{name: "", int_param: *twinP, string_param: ""},
+		topo_tag:           app_param{name: "", int_param: 0, string_param: *ttagP},
+		inventory_interval: app_param{name: "", int_param: *invivP, string_param: ""},
+		inventory_grace:    app_param{name: "", int_param: *invgrP, string_param: ""},
+		inventory_roles:    app_param{name: "", int_param: 0, string_param: *invrlP},
+		grpc_port:          app_param{name: "", int_param: *grpcP, string_param: ""},
+		log_levels:         app_param{name: "", int_param: 0, string_param: *loglP},
+		log_format:         app_param{name: "", int_param: 0, string_param: *logfP},
+	}
+
+	configFile = os.Getenv("HBTD_CONFIG")
+	if *confP != UNSTR {
+		configFile = *confP
+	}
+	cmdlineParams = tvars
+	return tvars
 }
 
 func parse_cmdline_params(tvars op_params) {
@@ -459,6 +625,129 @@ func parse_cmdline_params(tvars op_params) {
 			app_params.statemgr_timeout.int_param = tvars.statemgr_timeout.int_param
 		}
 	}
+
+	if tvars.reconcile_interval.int_param != UNINT {
+		if tvars.reconcile_interval.int_param <= 0 {
+			app_params.reconcile_interval.int_param = 0
+		} else {
+			app_params.reconcile_interval.int_param = tvars.reconcile_interval.int_param
+		}
+	}
+
+	if tvars.hb_history.int_param != UNINT {
+		if tvars.hb_history.int_param <= 0 {
+			app_params.hb_history.int_param = 0
+		} else if tvars.hb_history.int_param > HB_HISTORY_MAX {
+			hbtdPrintf("ERROR: %s value %d is more than %d.\n",
+				app_params.hb_history.name, tvars.hb_history.int_param, HB_HISTORY_MAX)
+		} else {
+			app_params.hb_history.int_param = tvars.hb_history.int_param
+		}
+	}
+
+	if tvars.flap_count.int_param != UNINT {
+		if tvars.flap_count.int_param <= 0 {
+			app_params.flap_count.int_param = 0
+		} else {
+			app_params.flap_count.int_param = tvars.flap_count.int_param
+		}
+	}
+
+	if tvars.flap_window.int_param != UNINT {
+		if tvars.flap_window.int_param <= 0 {
+			app_params.flap_window.int_param = 1
+		} else {
+			app_params.flap_window.int_param = tvars.flap_window.int_param
+		}
+	}
+
+	if tvars.flap_settle.int_param != UNINT {
+		if tvars.flap_settle.int_param <= 0 {
+			app_params.flap_settle.int_param = 1
+		} else {
+			app_params.flap_settle.int_param = tvars.flap_settle.int_param
+		}
+	}
+
+	if tvars.topo_threshold.int_param != UNINT {
+		if tvars.topo_threshold.int_param <= 0 {
+			app_params.topo_threshold.int_param = 0
+		} else if tvars.topo_threshold.int_param > 100 {
+			hbtdPrintf("ERROR: %s value %d is more than 100.\n",
+				app_params.topo_threshold.name, tvars.topo_threshold.int_param)
+		} else {
+			app_params.topo_threshold.int_param = tvars.topo_threshold.int_param
+		}
+	}
+
+	if tvars.topo_window.int_param != UNINT {
+		if tvars.topo_window.int_param <= 0 {
+			app_params.topo_window.int_param = 1
+		} else {
+			app_params.topo_window.int_param = tvars.topo_window.int_param
+		}
+	}
+
+	if tvars.topo_tag.string_param != UNSTR {
+		lcut := strings.ToLower(tvars.topo_tag.string_param)
+		if (lcut == "0") || (lcut == "no") || (lcut == "off") || (lcut == "false") {
+			app_params.topo_tag.int_param = 0
+		} else if (lcut == "1") || (lcut == "yes") || (lcut == "on") || (lcut == "true") {
+			app_params.topo_tag.int_param = 1
+		} else {
+			hbtdPrintf("ERROR: %s value '%s' is invalid.\n",
+				app_params.topo_tag.name, tvars.topo_tag.string_param)
+		}
+	}
+
+	if tvars.inventory_interval.int_param != UNINT {
+		if tvars.inventory_interval.int_param <= 0 {
+			app_params.inventory_interval.int_param = 0
+		} else {
+			app_params.inventory_interval.int_param = tvars.inventory_interval.int_param
+		}
+	}
+
+	if tvars.inventory_grace.int_param != UNINT {
+		if tvars.inventory_grace.int_param <= 0 {
+			app_params.inventory_grace.int_param = 1
+		} else {
+			app_params.inventory_grace.int_param = tvars.inventory_grace.int_param
+		}
+	}
+
+	if tvars.inventory_roles.string_param != UNSTR && tvars.inventory_roles.string_param != "" {
+		app_params.inventory_roles.string_param = tvars.inventory_roles.string_param
+	}
+
+	if tvars.grpc_port.int_param != UNINT {
+		if (tvars.grpc_port.int_param < 0) || (tvars.grpc_port.int_param > 65535) {
+			hbtdPrintf("ERROR: invalid gRPC port number '%d'.\n",
+				tvars.grpc_port.int_param)
+		} else {
+			app_params.grpc_port.int_param = tvars.grpc_port.int_param
+		}
+	}
+
+	if tvars.log_levels.string_param != UNSTR && tvars.log_levels.string_param != "" {
+		_, norm, lerr := parseLogLevels(tvars.log_levels.string_param)
+		if lerr != nil {
+			hbtdPrintf("ERROR: invalid %s value '%s': %v.\n",
+				app_params.log_levels.name, tvars.log_levels.string_param, lerr)
+		} else {
+			app_params.log_levels.string_param = norm
+		}
+	}
+
+	if tvars.log_format.string_param != UNSTR && tvars.log_format.string_param != "" {
+		lf, lerr := parseLogFormat(tvars.log_format.string_param)
+		if lerr != nil {
+			hbtdPrintf("ERROR: invalid %s value '%s'.\n",
+				app_params.log_format.name, tvars.log_format.string_param)
+		} else {
+			app_params.log_format.string_param = lf
+		}
+	}
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -539,6 +828,74 @@ func parse_env_vars() {
 	__env_parse_int("HBTD_SM_RETRIES", &app_params.statemgr_retries.int_param)
 	__env_parse_int("HBTD_SM_TIMEOUT", &app_params.statemgr_timeout.int_param)
 	__env_parse_int("HBTD_CLEAR_ON_GAP", &app_params.clear_on_gap.int_param)
+	__env_parse_int("HBTD_RECONCILE_INTERVAL", &app_params.reconcile_interval.int_param)
+
+	hhis := app_params.hb_history.int_param
+	__env_parse_int("HBTD_HB_HISTORY", &hhis)
+	if hhis > HB_HISTORY_MAX {
+		hbtdPrintf("ERROR: HBTD_HB_HISTORY value %d is more than %d.\n", hhis, HB_HISTORY_MAX)
+	} else {
+		app_params.hb_history.int_param = hhis
+	}
+
+	__env_parse_int("HBTD_FLAP_COUNT", &app_params.flap_count.int_param)
+	__env_parse_int("HBTD_FLAP_WINDOW", &app_params.flap_window.int_param)
+	__env_parse_int("HBTD_FLAP_SETTLE", &app_params.flap_settle.int_param)
+	if app_params.flap_window.int_param <= 0 {
+		app_params.flap_window.int_param = 1
+	}
+	if app_params.flap_settle.int_param <= 0 {
+		app_params.flap_settle.int_param = 1
+	}
+
+	ttr := app_params.topo_threshold.int_param
+	__env_parse_int("HBTD_TOPO_THRESHOLD", &ttr)
+	if ttr > 100 {
+		hbtdPrintf("ERROR: HBTD_TOPO_THRESHOLD value %d is more than 100.\n", ttr)
+	} else {
+		app_params.topo_threshold.int_param = ttr
+	}
+	__env_parse_int("HBTD_TOPO_WINDOW", &app_params.topo_window.int_param)
+	if app_params.topo_window.int_param <= 0 {
+		app_params.topo_window.int_param = 1
+	}
+	__env_parse_bool("HBTD_TOPO_TAG", &app_params.topo_tag.int_param)
+
+	__env_parse_int("HBTD_INVENTORY_INTERVAL", &app_params.inventory_interval.int_param)
+	__env_parse_int("HBTD_INVENTORY_GRACE", &app_params.inventory_grace.int_param)
+	if app_params.inventory_grace.int_param <= 0 {
+		app_params.inventory_grace.int_param = 1
+	}
+	__env_parse_string("HBTD_INVENTORY_ROLES", &app_params.inventory_roles.string_param)
+
+	gport := app_params.grpc_port.int_param
+	__env_parse_int("HBTD_GRPC_PORT", &gport)
+	if (gport < 0) || (gport > 65535) {
+		hbtdPrintf("ERROR: invalid HBTD_GRPC_PORT value %d.\n", gport)
+	} else {
+		app_params.grpc_port.int_param = gport
+	}
+
+	var lstr string
+	__env_parse_string("HBTD_LOG_LEVELS", &lstr)
+	if lstr != "" {
+		_, norm, lerr := parseLogLevels(lstr)
+		if lerr != nil {
+			hbtdPrintf("ERROR: invalid HBTD_LOG_LEVELS value '%s': %v.\n", lstr, lerr)
+		} else {
+			app_params.log_levels.string_param = norm
+		}
+	}
+	lstr = ""
+	__env_parse_string("HBTD_LOG_FORMAT", &lstr)
+	if lstr != "" {
+		lf, lerr := parseLogFormat(lstr)
+		if lerr != nil {
+			hbtdPrintf("ERROR: invalid HBTD_LOG_FORMAT value '%s'.\n", lstr)
+		} else {
+			app_params.log_format.string_param = lf
+		}
+	}
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -549,7 +906,7 @@ func parse_env_vars() {
 // errors, in some cases not.
 //
 // parm_json(in): Byte array containing config params in JSON format.
-// whence(in):    PARAM_START, PARAM_PATCH, PARAM_SYNC.
+// whence(in):    PARAM_START, PARAM_PATCH, PARAM_SYNC, PARAM_FILE.
 // errstr(out):   Generated errors encountered, for caller's use.
 // Return:        0 on success, -1 on error.
 /////////////////////////////////////////////////////////////////////////////
@@ -674,7 +1031,10 @@ func parse_parm_json(parm_json []byte, whence int, errstr *string) int {
 		}
 	}
 
-	if jdata.Kv_url != "" {
+	// The K/V store URL is per-instance; don't take it from other
+	// instances.
+
+	if (jdata.Kv_url != "") && (whence != PARAM_SYNC) {
 		tpd.kv_url.string_param = jdata.Kv_url
 	}
 
@@ -686,6 +1046,15 @@ func parse_parm_json(parm_json []byte, whence int, errstr *string) int {
 			*errstr += fmt.Sprintf("Parameter '%s' can't be changed in PATCH operation; ",
 				app_params.port.name)
 			bad = -1
+		} else if whence == PARAM_FILE {
+			_, err := strconv.ParseUint(jdata.Port, 0, 32)
+			if err != nil {
+				*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+					app_params.port.name, jdata.Port)
+				bad = -1
+			} else {
+				tpd.port.string_param = jdata.Port
+			}
 		}
 	}
 
@@ -715,11 +1084,169 @@ func parse_parm_json(parm_json []byte, whence int, errstr *string) int {
 		}
 	}
 
+	if jdata.Reconcile_interval != "" {
+		xx, err := strconv.ParseUint(jdata.Reconcile_interval, 0, 32)
+		if err != nil {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.reconcile_interval.name, jdata.Reconcile_interval)
+			bad = -1
+		} else {
+			tpd.reconcile_interval.int_param = int(xx)
+		}
+	}
+
+	if jdata.Hb_history != "" {
+		xx, err := strconv.ParseUint(jdata.Hb_history, 0, 32)
+		if (err != nil) || (xx > HB_HISTORY_MAX) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.hb_history.name, jdata.Hb_history)
+			bad = -1
+		} else {
+			tpd.hb_history.int_param = int(xx)
+		}
+	}
+
+	if jdata.Flap_count != "" {
+		xx, err := strconv.ParseUint(jdata.Flap_count, 0, 32)
+		if err != nil {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.flap_count.name, jdata.Flap_count)
+			bad = -1
+		} else {
+			tpd.flap_count.int_param = int(xx)
+		}
+	}
+
+	if jdata.Flap_window != "" {
+		xx, err := strconv.ParseUint(jdata.Flap_window, 0, 32)
+		if (err != nil) || (xx == 0) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.flap_window.name, jdata.Flap_window)
+			bad = -1
+		} else {
+			tpd.flap_window.int_param = int(xx)
+		}
+	}
+
+	if jdata.Flap_settle != "" {
+		xx, err := strconv.ParseUint(jdata.Flap_settle, 0, 32)
+		if (err != nil) || (xx == 0) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.flap_settle.name, jdata.Flap_settle)
+			bad = -1
+		} else {
+			tpd.flap_settle.int_param = int(xx)
+		}
+	}
+
+	if jdata.Topo_threshold != "" {
+		xx, err := strconv.ParseUint(jdata.Topo_threshold, 0, 32)
+		if (err != nil) || (xx > 100) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.topo_threshold.name, jdata.Topo_threshold)
+			bad = -1
+		} else {
+			tpd.topo_threshold.int_param = int(xx)
+		}
+	}
+
+	if jdata.Topo_window != "" {
+		xx, err := strconv.ParseUint(jdata.Topo_window, 0, 32)
+		if (err != nil) || (xx == 0) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.topo_window.name, jdata.Topo_window)
+			bad = -1
+		} else {
+			tpd.topo_window.int_param = int(xx)
+		}
+	}
+
+	if jdata.Topo_tag != "" {
+		lcut := strings.ToLower(jdata.Topo_tag)
+		if (lcut == "0") || (lcut == "no") || (lcut == "off") || (lcut == "false") {
+			tpd.topo_tag.int_param = 0
+		} else if (lcut == "1") || (lcut == "yes") || (lcut == "on") || (lcut == "true") {
+			tpd.topo_tag.int_param = 1
+		} else {
+			*errstr += fmt.Sprintf("Parameter '%s' with unknown value '%s'; ",
+				app_params.topo_tag.name, jdata.Topo_tag)
+			bad = -1
+		}
+	}
+
+	if jdata.Inventory_interval != "" {
+		xx, err := strconv.ParseUint(jdata.Inventory_interval, 0, 32)
+		if err != nil {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.inventory_interval.name, jdata.Inventory_interval)
+			bad = -1
+		} else {
+			tpd.inventory_interval.int_param = int(xx)
+		}
+	}
+
+	if jdata.Inventory_grace != "" {
+		xx, err := strconv.ParseUint(jdata.Inventory_grace, 0, 32)
+		if (err != nil) || (xx == 0) {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.inventory_grace.name, jdata.Inventory_grace)
+			bad = -1
+		} else {
+			tpd.inventory_grace.int_param = int(xx)
+		}
+	}
+
+	if jdata.Inventory_roles != "" {
+		tpd.inventory_roles.string_param = jdata.Inventory_roles
+	}
+
+	// The gRPC port, like the HTTP port, is only settable at startup.
+
+	if jdata.Grpc_port != "" {
+		if whence == PARAM_PATCH {
+			*errstr += fmt.Sprintf("Parameter '%s' can't be changed in PATCH operation; ",
+				app_params.grpc_port.name)
+			bad = -1
+		} else if whence == PARAM_FILE {
+			xx, err := strconv.ParseUint(jdata.Grpc_port, 0, 16)
+			if err != nil {
+				*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+					app_params.grpc_port.name, jdata.Grpc_port)
+				bad = -1
+			} else {
+				tpd.grpc_port.int_param = int(xx)
+			}
+		}
+	}
+
+	if jdata.Log_levels != "" {
+		_, norm, lerr := parseLogLevels(jdata.Log_levels)
+		if lerr != nil {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s' (%v); ",
+				app_params.log_levels.name, jdata.Log_levels, lerr)
+			bad = -1
+		} else {
+			tpd.log_levels.string_param = norm
+		}
+	}
+
+	if jdata.Log_format != "" {
+		lf, lerr := parseLogFormat(jdata.Log_format)
+		if lerr != nil {
+			*errstr += fmt.Sprintf("Parameter '%s' with illegal value '%s'; ",
+				app_params.log_format.name, jdata.Log_format)
+			bad = -1
+		} else {
+			tpd.log_format.string_param = lf
+		}
+	}
+
 	if bad == 0 {
 		//Apply the previous app_params (tpd) + new stuff to app_params.
 		//If badness happened, don't apply any of the new stuff.
 		app_params = tpd
 		server_url_port = tpd.port.string_param
+		applyLogParams()
 	}
 
 	return bad
@@ -748,10 +1275,8 @@ func telebusConnect() {
 				if terr != nil {
 					hbtdPrintln("ERROR: telemetry host is not set or is invalid:", terr)
 				} else {
-					if app_params.debug_level.int_param > 0 {
-						hbtdPrintf("Connecting to telemetry host: '%s:%d:%s'\n",
-							host, port, topic)
-					}
+					logTelemetry.Debug(fmt.Sprintf("Connecting to telemetry host: '%s:%d:%s'",
+						host, port, topic), "host", host, "port", port, "topic", topic)
 					msgbusConfig.Host = host
 					msgbusConfig.Port = port
 					msgbusConfig.Topic = topic
@@ -809,18 +1334,19 @@ func openKV() {
 		}
 	}
 
-	// Try to open connection to ETCD.  This service is worthless until
+	// Try to open connection to the K/V store.  This service is worthless until
 	// this succeeds, so try forever.  Liveness and readiness probes will
 	// fail until it works, which is the Kubernetes Way(TM).
 
 	ix := 1
 	for {
-		kvHandle, kverr = hmetcd.Open(app_params.kv_url.string_param, "")
+		kvHandle, kverr = openStore(app_params.kv_url.string_param)
 		if kverr != nil {
-			hbtdPrintf("ERROR opening connection to ETCD (%s) (attempt %d): %v",
+			hbtdPrintf("ERROR opening connection to K/V store (%s) (attempt %d): %v",
 				app_params.kv_url.string_param, ix, kverr)
 		} else {
-			hbtdPrintf("ETCD connection succeeded.\n")
+			hbtdPrintf("K/V store connection succeeded.\n")
+			kvHandle = &kvMetrics{hbStore: kvHandle}
 			break
 		}
 		ix++
@@ -855,16 +1381,20 @@ func createInstanceKey() string {
 	return ikey
 }
 
+// Fetch all life keys.  The numbers in them aren't zero-padded, so they
+// don't sort numerically; fetch everything with the life key prefix.
+
+func getLifeKeys() ([]hbKV, error) {
+	return kvHandle.GetRange(HBTD_LIFE_KEY_PRE, HBTD_LIFE_KEY_PRE+"~")
+}
+
 // Check to see if there are any HBTD life keys.  If there are none, that means
 // we are the first instance to run.  This can mean that there were >=1 inst
 // running at some time in the past, and if so, there is HB info that is stale.
 // That info has to be deleted and re-discovered.
 
 func checkLifeKeys() {
-	lstart := HBTD_LIFE_KEY_PRE + fmt.Sprintf("%d", HBTD_LIFE_KEY_START)
-	lend := HBTD_LIFE_KEY_PRE + fmt.Sprintf("%d", HBTD_LIFE_KEY_END)
-
-	kvlist, kverr := kvHandle.GetRange(lstart, lend)
+	kvlist, kverr := getLifeKeys()
 	if kverr != nil {
 		hbtdPrintf("ERROR: Can't retrieve life keys: %v\nAssuming the worst,deleting all HB key info.")
 		staleKeys = true
@@ -912,6 +1442,20 @@ func printParams() {
 	hbtdPrintf("sm_url         %s\n", app_params.statemgr_url.string_param)
 	hbtdPrintf("sm_timeout     %d\n", app_params.statemgr_timeout.int_param)
 	hbtdPrintf("sm_retries     %d\n", app_params.statemgr_retries.int_param)
+	hbtdPrintf("reconcile_interval %d\n", app_params.reconcile_interval.int_param)
+	hbtdPrintf("hb_history     %d\n", app_params.hb_history.int_param)
+	hbtdPrintf("flap_count     %d\n", app_params.flap_count.int_param)
+	hbtdPrintf("flap_window    %d\n", app_params.flap_window.int_param)
+	hbtdPrintf("flap_settle    %d\n", app_params.flap_settle.int_param)
+	hbtdPrintf("topo_threshold %d\n", app_params.topo_threshold.int_param)
+	hbtdPrintf("topo_window    %d\n", app_params.topo_window.int_param)
+	hbtdPrintf("topo_tag       %d\n", app_params.topo_tag.int_param)
+	hbtdPrintf("inventory_interval %d\n", app_params.inventory_interval.int_param)
+	hbtdPrintf("inventory_grace %d\n", app_params.inventory_grace.int_param)
+	hbtdPrintf("inventory_roles %s\n", app_params.inventory_roles.string_param)
+	hbtdPrintf("grpc_port      %d\n", app_params.grpc_port.int_param)
+	hbtdPrintf("log_levels     %s\n", logLevelsString())
+	hbtdPrintf("log_format     %s\n", app_params.log_format.string_param)
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -930,17 +1474,18 @@ func main() {
 	}
 	log.Printf("Service name: '%s'", serviceName)
 
-	initAppParams()
-
-	//Gather ENV vars.  These are the first level of parameters.
+	//Parameters come from the defaults, then the parameter file if any,
+	//then env vars, then cmdline params, each overriding the ones before.
 
-	parse_env_vars()
-
-	//Parse cmdline params if any.  These override .ini params and env vars
-
-	parse_cmd_line()
+	get_cmd_line()
+	err = load_params()
+	if err != nil {
+		log.Printf("FATAL: Can't load parameter file: %v", err)
+		os.Exit(1)
+	}
+	applyLogParams()
 
-	if app_params.debug_level.int_param > 0 {
+	if logEnabled(logMain, slog.LevelDebug) {
 		printParams()
 	}
 
@@ -970,24 +1515,30 @@ func main() {
 	//Generate a unique instance key and check for HBTD life keys.  If none,
 	//delete stale HB data in KV store.
 
-	instanceKey := createInstanceKey()
+	instanceKey = createInstanceKey()
 	checkLifeKeys()
 
-	// Write our instance-specific life key
+	//Load HB timeout policies and notification suppressions.  These are
+	//refreshed by the HB checker.
 
-	go func() {
-		for {
-			err := kvHandle.TempKey(instanceKey)
-			if err != nil {
-				hbtdPrintf("ERROR: Can't create life key '%s', retrying...",
-					instanceKey)
-			} else {
-				hbtdPrintf("Life key '%s' created.", instanceKey)
-				break
-			}
-			time.Sleep(2 * time.Second)
-		}
-	}()
+	_, perr := loadPolicies()
+	if perr != nil {
+		hbtdPrintf("ERROR: Can't load HB timeout policies: %v", perr)
+	}
+	_, perr = loadSuppressions()
+	if perr != nil {
+		hbtdPrintf("ERROR: Can't load HB notification suppressions: %v", perr)
+	}
+
+	// Write our instance-specific life key, describing this instance
+
+	initInstance(instanceKey)
+	startParamSync()
+	go registerInstance()
+
+	//Campaign for leadership
+
+	go startElection(createLeaderID(instanceKey))
 
 	//Start the thread for handling state mgr messaging
 
@@ -1013,6 +1564,18 @@ func main() {
 	go telebusConnect()
 	go telemetry_handler()
 
+	//Fire up HSM reconciliation thread
+
+	go reconcile_handler()
+
+	//Fire up inventory sync thread
+
+	go inventory_handler()
+
+	//Fire up the gRPC heartbeat listener, if configured
+
+	grpcSrv := startGRPC()
+
 	hbtdPrintf("Listening on port %s\n", server_url_port)
 
 	// Fire up the web service and enter the server loop.
@@ -1027,11 +1590,32 @@ func main() {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	idleConnsClosed := make(chan struct{})
 
+	//Reload the parameter file on SIGHUP
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadParams()
+		}
+	}()
+
 	go func() {
 		<-c
 		Running = false
 
-		//Gracefully shutdown the HTTP server
+		//Give up leadership right away so another instance can take over
+
+		hbElectLock.Lock()
+		if hbElect != nil {
+			hbElect.Resign()
+		}
+		hbElectLock.Unlock()
+
+		//Gracefully shutdown the gRPC and HTTP servers
+		if grpcSrv != nil {
+			stopGRPC(grpcSrv)
+		}
 		lerr := srv.Shutdown(context.Background())
 		if lerr != nil {
 			log.Printf("ERROR: HTTP server shutdown error: %v", lerr)
@@ -40,7 +40,7 @@ type inidata_plus struct {
 
 var ini_set = []inidata_plus{
 	{
-		jstr:    "{\"Debug\":\"1\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"1\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_DEBUG=1",
 		params: inidata{
 			Debug:          "1",
@@ -58,7 +58,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"1\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"1\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_NOSM=1",
 		params: inidata{
 			Debug:          "0",
@@ -76,7 +76,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"1\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"1\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_USE_TELEMETRY=1",
 		params: inidata{
 			Debug:          "0",
@@ -94,7 +94,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"localhost:9092:heartbeat_notifications\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"localhost:9092:heartbeat_notifications\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_TELEMETRY_HOST=localhost:9092:heartbeat_notifications",
 		params: inidata{
 			Debug:          "0",
@@ -112,7 +112,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"5\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"5\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_WARNTIME=5",
 		params: inidata{
 			Debug:          "0",
@@ -130,7 +130,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"6\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"6\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_ERRTIME=6",
 		params: inidata{
 			Debug:          "0",
@@ -148,7 +148,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"https://localhost:1234/kvstore\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"https://localhost:1234/kvstore\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_KV_URL=https://localhost:1234/kvstore",
 		params: inidata{
 			Debug:          "0",
@@ -166,7 +166,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"12\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"12\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_INTERVAL=12",
 		params: inidata{
 			Debug:          "0",
@@ -184,7 +184,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"http://a.b.c:8989/hmi/v1\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"http://a.b.c:8989/hmi/v1\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_SM_URL=http://a.b.c:8989/hmi/v1",
 		params: inidata{
 			Debug:          "0",
@@ -202,7 +202,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"5\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"5\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_SM_TIMEOUT=5",
 		params: inidata{
 			Debug:          "0",
@@ -220,7 +220,7 @@ var ini_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"6\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"6\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\",\"Grpc_port\":\"0\"}",
 		env_var: "HBTD_SM_RETRIES=6",
 		params: inidata{
 			Debug:          "0",
@@ -241,7 +241,7 @@ var ini_set = []inidata_plus{
 
 var fail_set = []inidata_plus{
 	{
-		jstr:    "{\"Debug\":\"x\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"x\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_DEBUG=x",
 		params: inidata{
 			Debug:          "0",
@@ -259,7 +259,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":0,\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":0,\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_DEBUG=x",
 		params: inidata{
 			Debug:          "0",
@@ -277,7 +277,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"x\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"x\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_NOSM=x",
 		params: inidata{
 			Debug:          "0",
@@ -295,7 +295,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"x\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"x\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_USE_TELEMETRY=x",
 		params: inidata{
 			Debug:          "0",
@@ -313,7 +313,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"x\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"x\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_WARNTIME=x",
 		params: inidata{
 			Debug:          "0",
@@ -331,7 +331,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"x\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"x\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_ERRTIME=x",
 		params: inidata{
 			Debug:          "0",
@@ -349,7 +349,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"x\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"x\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_INTERVAL=x",
 		params: inidata{
 			Debug:          "0",
@@ -367,7 +367,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"x\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"x\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_SM_TIMEOUT=x",
 		params: inidata{
 			Debug:          "0",
@@ -385,7 +385,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"x\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"x\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_SM_RETRIES=x",
 		params: inidata{
 			Debug:          "0",
@@ -403,7 +403,7 @@ var fail_set = []inidata_plus{
 		},
 	},
 	{
-		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"1234\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\"}",
+		jstr:    "{\"Debug\":\"0\",\"Nosm\":\"0\",\"Use_telemetry\":\"0\",\"Telemetry_host\":\"\",\"Warntime\":\"0\",\"Errtime\":\"0\",\"Port\":\"1234\",\"Kv_url\":\"\",\"Interval\":\"0\",\"Sm_url\":\"\",\"Sm_timeout\":\"0\",\"Sm_retries\":\"0\",\"Reconcile_interval\":\"0\",\"Hb_history\":\"0\",\"Flap_count\":\"0\",\"Flap_window\":\"0\",\"Flap_settle\":\"0\",\"Topo_threshold\":\"0\",\"Topo_window\":\"0\",\"Topo_tag\":\"0\",\"Inventory_interval\":\"0\",\"Inventory_grace\":\"0\",\"Inventory_roles\":\"\"}",
 		env_var: "HBTD_PORT=x",
 		params: inidata{
 			Debug:          "0",
@@ -424,6 +424,9 @@ var fail_set = []inidata_plus{
 
 var printHelpOutput string = `Usage: ./hbtd [options]
   --help                      Help text.
+  --config=file               JSON or YAML parameter file.  Env vars
+                              and options override its values.
+                              Reloaded on SIGHUP.
   --debug=num                 Debug level.  (Default: 0)
   --use_telemetry=yes|no      Inject notifications into message.
                               bus. (Default: yes)
@@ -442,6 +445,41 @@ var printHelpOutput string = `Usage: ./hbtd [options]
   --sm_retries=num            Number of State Manager access retries. (Default: 3)
   --sm_timeout=secs           State Manager access timeout. (Default: 10)
   --nosm                      Don't contact State Manager (for testing).
+  --reconcile_interval=secs   HSM reconciliation interval, 0 == never.
+                              (Default: 300 seconds)
+  --hb_history=num            Heartbeats kept per component for
+                              /hbhistory, 0 == none.  (Default: 20)
+  --flap_count=num            Warnings and restarts within flap_window
+                              marking a component as flapping, 0 == no
+                              flap detection.  (Default: 6)
+  --flap_window=secs          Flap detection window.  (Default: 600 seconds)
+  --flap_settle=secs          Time without warnings or restarts before a
+                              component is no longer flapping.
+                              (Default: 300 seconds)
+  --topo_threshold=pct        Percent of the components under a slot,
+                              chassis or cabinet that must die within
+                              topo_window for it to be the suspected
+                              cause, 0 == no correlation.  (Default: 80)
+  --topo_window=secs          Topology correlation window.
+                              (Default: 120 seconds)
+  --topo_tag=yes|no           Note the suspected cause in HSM messages.
+                              (Default: yes)
+  --inventory_interval=secs   Interval for syncing the components
+                              expected to heartbeat with HSM,
+                              0 == never.  (Default: 0 seconds)
+  --inventory_grace=secs      Time for an expected component to start
+                              heartbeating.  (Default: 600 seconds)
+  --inventory_roles=roles     HSM roles of the nodes expected to
+                              heartbeat, comma separated.
+                              (Default: Compute)
+  --grpc_port=num             gRPC port to listen on, 0 == no gRPC.
+                              (Default: 0)
+  --log_levels=spec           Log levels, e.g. 'info,checker=debug'.
+                              Subsystems: ingest, checker, hsm,
+                              telemetry, kv, main.  Levels: trace,
+                              debug, info, warn, error.
+                              (Default: from debug level)
+  --log_format=text|json      Log output format.  (Default: text)
 `
 
 var printParamsOutput = `debug_level    0
@@ -456,6 +494,20 @@ interval       5
 sm_url         http://localhost:27779/hsm/v2
 sm_timeout     10
 sm_retries     3
+reconcile_interval 300
+hb_history     20
+flap_count     6
+flap_window    600
+flap_settle    300
+topo_threshold 80
+topo_window    120
+topo_tag       1
+inventory_interval 0
+inventory_grace 600
+inventory_roles Compute
+grpc_port      0
+log_levels     checker=info,hsm=info,ingest=info,kv=info,main=info,telemetry=info
+log_format     text
 `
 
 // Zero's out the global app_params data
@@ -473,6 +525,20 @@ func zero_app_params() {
 	app_params.statemgr_url = app_param{"", 0, ""}
 	app_params.statemgr_timeout = app_param{"", 0, ""}
 	app_params.statemgr_retries = app_param{"", 0, ""}
+	app_params.reconcile_interval = app_param{"", 0, ""}
+	app_params.hb_history = app_param{"", 0, ""}
+	app_params.flap_count = app_param{"", 0, ""}
+	app_params.flap_window = app_param{"", 0, ""}
+	app_params.flap_settle = app_param{"", 0, ""}
+	app_params.topo_threshold = app_param{"", 0, ""}
+	app_params.topo_window = app_param{"", 0, ""}
+	app_params.topo_tag = app_param{"", 0, ""}
+	app_params.inventory_interval = app_param{"", 0, ""}
+	app_params.inventory_grace = app_param{"", 0, ""}
+	app_params.inventory_roles = app_param{"", 0, ""}
+	app_params.grpc_port = app_param{"", 0, ""}
+	app_params.log_levels = app_param{"", 0, ""}
+	app_params.log_format = app_param{"", 0, ""}
 }
 
 // Compare an app parameter structure against the global app params.
@@ -813,7 +879,7 @@ func TestParse_cmd_line(t *testing.T) {
 		"--port=1234", "--warntime=5", "--errtime=10",
 		"--sm_retries=12", "--sm_timeout=34",
 		"--sm_url=e.f.g.h", "--telemetry_host=aaaa:1234:bbbb",
-		"--interval=12", "--use_telemetry=1"}
+		"--interval=12", "--use_telemetry=1", "--reconcile_interval=60"}
 
 	parse_cmd_line()
 
@@ -853,6 +919,10 @@ func TestParse_cmd_line(t *testing.T) {
 		t.Errorf("ERROR, statemgr_url incorrect, expected 'e.f.g.h', got '%s'\n",
 			app_params.statemgr_url.string_param)
 	}
+	if app_params.reconcile_interval.int_param != 60 {
+		t.Errorf("ERROR, reconcile_interval incorrect, expected 60, got %d\n",
+			app_params.reconcile_interval.int_param)
+	}
 	if app_params.telemetry_host.string_param != "aaaa:1234:bbbb" {
 		t.Errorf("ERROR, telemetry_host incorrect, expected 'aaaa:1234:bbbb', got '%s'\n",
 			app_params.telemetry_host.string_param)
@@ -986,7 +1056,7 @@ func TestCheckLifeKeys(t *testing.T) {
 	staleKeys = false
 	ik := createInstanceKey()
 	ik = HBTD_LIFE_KEY_PRE + "0"
-	err = kvHandle.TempKey(ik)
+	err = kvHandle.TempKey(ik, "1")
 	if err != nil {
 		t.Errorf("Error setting TempKey: %v", err)
 	}
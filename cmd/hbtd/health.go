@@ -37,6 +37,8 @@ type HealthResponse struct {
 	KvStoreStatus string `json:"KvStore"`
 	MsgBusStatus  string `json:"MsgBus"`
 	HsmStatus     string `json:"HsmStatus"`
+	Leader        string `json:"Leader"`
+	Instance      string `json:"Instance"`
 }
 
 var hsmReady = false
@@ -178,6 +180,13 @@ func doHealth(w http.ResponseWriter, r *http.Request) {
 		stats.MsgBusStatus = "Not Connected"
 	}
 
+	// Leader election
+	stats.Instance = leaderID
+	stats.Leader = leaderName()
+	if stats.Leader == "" {
+		stats.Leader = "Unknown"
+	}
+
 	// write the output
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
@@ -32,8 +32,6 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
-
-	"github.com/Cray-HPE/hms-hmetcd"
 )
 
 func TestLiveness(t *testing.T) {
@@ -103,7 +101,7 @@ func TestReadiness(t *testing.T) {
 	kvHandle = nil
 	defer func() { kvHandle = pickledKV }()
 	var kverr error
-	kvHandle, kverr = hmetcd.Open("mem:", "")
+	kvHandle, kverr = openStore("mem:")
 	if kverr != nil {
 		t.Fatal("KV/ETCD open failed:", kverr)
 	}
@@ -169,7 +167,7 @@ func TestHealth(t *testing.T) {
 
 	// start the KV Store and test again
 	var kverr error
-	kvHandle, kverr = hmetcd.Open("mem:", "")
+	kvHandle, kverr = openStore("mem:")
 	if kverr != nil {
 		t.Fatal("KV/ETCD open failed:", kverr)
 	}
@@ -218,6 +216,20 @@ func TestHealth(t *testing.T) {
 	if stats.HsmStatus != "Not initialized" {
 		t.Fatal("Expected HSM not initialized")
 	}
+	if stats.Leader != "Unknown" {
+		t.Errorf("Expected unknown leader, got '%s'", stats.Leader)
+	}
+
+	// now test with leader election running
+	startElection("hbtd-0/hbtd_lifekey-1")
+	defer func() { hbElect = nil }()
+	rr6 := httptest.NewRecorder()
+	handler1.ServeHTTP(rr6, req1)
+	stats = HealthResponse{}
+	json.Unmarshal(rr6.Body.Bytes(), &stats)
+	if (stats.Leader != "hbtd-0/hbtd_lifekey-1") || (stats.Instance != stats.Leader) {
+		t.Errorf("Unexpected leader '%s', instance '%s'", stats.Leader, stats.Instance)
+	}
 }
 
 func TestHSMReadies(t *testing.T) {
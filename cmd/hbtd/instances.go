@@ -0,0 +1,216 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Instance (replica) registry.  Each running instance's life key holds a
+// description of the instance, which it keeps up to date as its role
+// changes and as it runs HB audits.  Since life keys go away with their
+// instance, the life keys are the list of live replicas.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	HB_ROLE_LEADER  = "leader"
+	HB_ROLE_MEMBER  = "member"
+	HB_ROLE_UNKNOWN = "unknown"
+)
+
+type hbInstance struct {
+	ID        string     `json:"ID"`   //Life key
+	Name      string     `json:"Name"` //Pod name
+	StartTime time.Time  `json:"StartTime"`
+	Version   string     `json:"Version"`
+	LastAudit *time.Time `json:"LastAudit,omitempty"`
+	Role      string     `json:"Role"`
+
+	ParamRevision int `json:"ParamRevision"`
+}
+
+type hbInstanceList struct {
+	LastAuditBy string       `json:"LastAuditBy"`
+	Instances   []hbInstance `json:"Instances"`
+}
+
+// Service version, set at build time with
+// -ldflags "-X main.hbtdVersion=<version>".
+
+var hbtdVersion = "unknown"
+
+// This instance's description, and whether its life key has been created.
+
+var thisInstance hbInstance
+var instanceLive bool
+var instanceLock sync.Mutex
+
+/////////////////////////////////////////////////////////////////////////////
+// Fill in this instance's description.  Done before campaigning for
+// leadership so a change of role is never lost.
+//
+// ikey(in): This instance's life key.
+// Return:   None.
+/////////////////////////////////////////////////////////////////////////////
+
+func initInstance(ikey string) {
+	instanceLock.Lock()
+	thisInstance = hbInstance{ID: ikey, Name: serviceName, StartTime: time.Now(),
+		Version: hbtdVersion, Role: HB_ROLE_MEMBER}
+	instanceLive = false
+	instanceLock.Unlock()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Create this instance's life key, retrying until it can be created.
+// Meant to be run as a goroutine.
+//
+// Args:   None.
+// Return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func registerInstance() {
+	for {
+		instanceLock.Lock()
+		ikey := thisInstance.ID
+		err := writeInstance()
+		if err == nil {
+			instanceLive = true
+		}
+		instanceLock.Unlock()
+
+		if err == nil {
+			logMain.Info(fmt.Sprintf("Life key '%s' created.", ikey), "instance", ikey)
+			return
+		}
+		logKV.Error(fmt.Sprintf("Can't create life key '%s', retrying: %v", ikey, err),
+			"instance", ikey, "error", err)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// Write this instance's life key.  Called with instanceLock held.
+
+func writeInstance() error {
+	ba, err := json.Marshal(&thisInstance)
+	if err != nil {
+		return err
+	}
+	return kvHandle.TempKey(thisInstance.ID, string(ba))
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Change this instance's description, updating its life key if it has
+// been created.
+//
+// update(in): Func to change the description.
+// Return:     None.
+/////////////////////////////////////////////////////////////////////////////
+
+func updateInstance(update func(inst *hbInstance)) {
+	instanceLock.Lock()
+	defer instanceLock.Unlock()
+
+	update(&thisInstance)
+	if !instanceLive {
+		return
+	}
+	err := writeInstance()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error updating life key '%s': %v", thisInstance.ID, err),
+			"key", thisInstance.ID, "error", err)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Get the live instances from the life keys.  Life keys written by older
+// versions don't have a description; these are listed by ID only.
+//
+// Args:   None.
+// Return: Instances, sorted by name then ID; nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func getInstances() ([]hbInstance, error) {
+	kvlist, err := getLifeKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	insts := make([]hbInstance, 0, len(kvlist))
+	for _, kv := range kvlist {
+		var inst hbInstance
+		if json.Unmarshal([]byte(kv.Value), &inst) != nil {
+			inst = hbInstance{Role: HB_ROLE_UNKNOWN}
+		}
+		inst.ID = kv.Key
+		insts = append(insts, inst)
+	}
+	sort.Slice(insts, func(i, j int) bool {
+		if insts[i].Name == insts[j].Name {
+			return insts[i].ID < insts[j].ID
+		}
+		return insts[i].Name < insts[j].Name
+	})
+	return insts, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/instances.  Lists the live instances, and
+// which one ran an HB audit last.
+/////////////////////////////////////////////////////////////////////////////
+
+func instancesIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	var ilist hbInstanceList
+	var last time.Time
+	errinst := URL_INSTANCES
+
+	insts, err := getInstances()
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GET operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	ilist.Instances = insts
+	for _, inst := range insts {
+		if (inst.LastAudit != nil) && inst.LastAudit.After(last) {
+			last = *inst.LastAudit
+			ilist.LastAuditBy = inst.ID
+		}
+	}
+
+	sendJSON(w, http.StatusOK, &ilist, errinst)
+}
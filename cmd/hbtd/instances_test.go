@@ -0,0 +1,143 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test the instance registry and the /instances API.
+
+func TestInstances(t *testing.T) {
+	var inst hbInstance
+	var ilist hbInstanceList
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+	clearLifeKeys()
+
+	origName := serviceName
+	defer func() {
+		instanceLock.Lock()
+		instanceLive = false
+		instanceLock.Unlock()
+		serviceName = origName
+		clearLifeKeys()
+	}()
+
+	//No instances
+
+	rr := policyReq(t, "GET", URL_INSTANCES, "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &ilist); err != nil {
+		t.Fatalf("Can't unmarshal instance list: %v", err)
+	}
+	if (len(ilist.Instances) != 0) || (ilist.LastAuditBy != "") {
+		t.Errorf("Expected no instances, got %v", ilist)
+	}
+
+	//Updates before the life key is created aren't lost.  Life key numbers
+	//are as createInstanceKey() makes them, not zero-padded.
+
+	serviceName = "cray-hbtd-abc-0"
+	ikey := HBTD_LIFE_KEY_PRE + "1681692777"
+	initInstance(ikey)
+	leaderChanged(true)
+	if _, exists, _ := kvHandle.Get(ikey); exists {
+		t.Errorf("Life key created before registration.")
+	}
+	registerInstance()
+
+	val, exists, _ := kvHandle.Get(ikey)
+	if !exists {
+		t.Fatalf("Life key not created.")
+	}
+	if err := json.Unmarshal([]byte(val), &inst); err != nil {
+		t.Fatalf("Can't unmarshal life key '%s': %v", val, err)
+	}
+	if (inst.ID != ikey) || (inst.Name != serviceName) || (inst.Version != hbtdVersion) ||
+		(inst.Role != HB_ROLE_LEADER) || (inst.LastAudit != nil) ||
+		(time.Since(inst.StartTime) > time.Minute) {
+		t.Errorf("Unexpected life key contents: %v", inst)
+	}
+
+	//Another instance which audited earlier, and a life key from an older
+	//version.
+
+	earlier := time.Now().Add(-time.Hour)
+	other := hbInstance{ID: HBTD_LIFE_KEY_PRE + "846930886", Name: "cray-hbtd-abc-1",
+		StartTime: earlier, Version: "1.24.0", LastAudit: &earlier, Role: HB_ROLE_MEMBER}
+	ba, _ := json.Marshal(&other)
+	kvHandle.TempKey(other.ID, string(ba))
+	kvHandle.TempKey(HBTD_LIFE_KEY_PRE+"719885386", "1")
+
+	updateInstance(func(inst *hbInstance) {
+		now := time.Now()
+		inst.LastAudit = &now
+	})
+
+	rr = policyReq(t, "GET", URL_INSTANCES, "", http.StatusOK)
+	ilist = hbInstanceList{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &ilist); err != nil {
+		t.Fatalf("Can't unmarshal instance list: %v", err)
+	}
+	if len(ilist.Instances) != 3 {
+		t.Fatalf("Expected 3 instances, got %d", len(ilist.Instances))
+	}
+	if ilist.LastAuditBy != ikey {
+		t.Errorf("Expected last audit by '%s', got '%s'", ikey, ilist.LastAuditBy)
+	}
+	exp := []struct{ id, name, role string }{
+		{HBTD_LIFE_KEY_PRE + "719885386", "", HB_ROLE_UNKNOWN},
+		{ikey, "cray-hbtd-abc-0", HB_ROLE_LEADER},
+		{other.ID, "cray-hbtd-abc-1", HB_ROLE_MEMBER},
+	}
+	for ix, ex := range exp {
+		got := ilist.Instances[ix]
+		if (got.ID != ex.id) || (got.Name != ex.name) || (got.Role != ex.role) {
+			t.Errorf("Instance %d: expected %s/%s/%s, got %s/%s/%s", ix,
+				ex.id, ex.name, ex.role, got.ID, got.Name, got.Role)
+		}
+	}
+
+	//Giving up leadership
+
+	leaderChanged(false)
+	val, _, _ = kvHandle.Get(ikey)
+	inst = hbInstance{}
+	json.Unmarshal([]byte(val), &inst)
+	if inst.Role != HB_ROLE_MEMBER {
+		t.Errorf("Expected role '%s' after stepping down, got '%s'", HB_ROLE_MEMBER, inst.Role)
+	}
+
+	policyReq(t, "POST", URL_INSTANCES, "{}", http.StatusMethodNotAllowed)
+}
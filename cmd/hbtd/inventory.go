@@ -0,0 +1,374 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Inventory sync.  HBTD only knows about a component once it heartbeats, so
+// a node which never boots far enough to start heartbeating is invisible to
+// it.  If inventory_interval is set, one instance periodically fetches the
+// enabled nodes HSM has as On or Ready with one of the inventory_roles, the
+// components expected to heartbeat.  Those with no HB record are noted with
+// the time they were first seen that way; once inventory_grace seconds have
+// passed without a heartbeat they have never started, and a telemetry
+// message is sent for each, unless its notifications are suppressed.  The
+// results are kept in the KV store for the API.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	HBTD_INVENTORY_LAST_KEY = "hbtd_inventory-last"
+	HBTD_INVENTORY_KEY      = "hbtd_inventory"
+
+	INVENTORY_INTERVAL = 0   //seconds, 0 == no inventory sync
+	INVENTORY_GRACE    = 600 //seconds
+	INVENTORY_ROLES    = "Compute"
+
+	NEVER_STARTED_MESSAGE_ID = "Heartbeat Never Started"
+)
+
+// A component expected to heartbeat which hasn't yet.
+
+type hbInvEntry struct {
+	XName        string     `json:"XName"`
+	Role         string     `json:"Role"`
+	HSMState     string     `json:"HSMState"`
+	FirstSeen    time.Time  `json:"FirstSeen"`              //Expected, with no HB
+	NeverStarted *time.Time `json:"NeverStarted,omitempty"` //Grace period ended
+	Reported     bool       `json:"Reported,omitempty"`     //Telemetry sent
+}
+
+type hbInventory struct {
+	Instance     string       `json:"Instance"`
+	LastSync     time.Time    `json:"LastSync"`
+	Expected     int          `json:"Expected"`     //Per HSM
+	Heartbeating int          `json:"Heartbeating"` //Of those expected
+	Error        string       `json:"Error,omitempty"`
+	Waiting      []hbInvEntry `json:"Waiting"`
+}
+
+// For GET /neverstarted
+
+type hbNeverStartedRsp struct {
+	LastSync     time.Time    `json:"LastSync"`
+	Expected     int          `json:"Expected"`
+	Heartbeating int          `json:"Heartbeating"`
+	Waiting      int          `json:"Waiting"` //Within the grace period
+	Error        string       `json:"Error,omitempty"`
+	NeverStarted []hbInvEntry `json:"NeverStarted"`
+}
+
+// For fetching expected components from HSM.
+
+type hsmInvComp struct {
+	ID    string `json:"ID"`
+	State string `json:"State"`
+	Role  string `json:"Role"`
+}
+
+type hsmInvCompArray struct {
+	Components []hsmInvComp `json:"Components"`
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Thread function.  Periodically syncs the components expected to
+// heartbeat with HSM, if this instance is the one to do it.
+/////////////////////////////////////////////////////////////////////////////
+
+func inventory_handler() {
+	for {
+		time.Sleep(RECONCILE_POLL * time.Second)
+
+		ivl := app_params.inventory_interval.int_param
+		if (ivl == 0) || !hsmReady || (app_params.nosm.int_param != 0) {
+			continue
+		}
+		if !claimRun(HBTD_INVENTORY_LAST_KEY, "inventory sync", time.Now(), ivl) {
+			continue
+		}
+		inventorySync(time.Now())
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Fetch the components expected to heartbeat from HSM.
+//
+// roles(in): Comma separated HSM roles.
+// Return:    Components;
+//            nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func getHSMExpected(roles string) ([]hsmInvComp, error) {
+	qv := url.Values{}
+	qv.Set("type", xnametypes.Node.String())
+	qv.Set("enabled", "true")
+	qv.Add("state", base.StateOn.String())
+	qv.Add("state", base.StateReady.String())
+	for _, role := range strings.Split(roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			qv.Add("role", role)
+		}
+	}
+	smurl := app_params.statemgr_url.string_param + "/" + SM_URL_MID + "?" + qv.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		(time.Duration(app_params.statemgr_timeout.int_param) *
+			time.Second))
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", smurl, nil)
+	base.SetHTTPUserAgent(req, serviceName)
+
+	rsp, err := htrans.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(rsp.Body)
+	base.DrainAndCloseResponseBody(rsp)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HSM component query returned '%s'", rsp.Status)
+	}
+
+	var carr hsmInvCompArray
+	err = json.Unmarshal(body, &carr)
+	if err != nil {
+		return nil, err
+	}
+	return carr.Components, nil
+}
+
+// Fetch the inventory sync results from the KV store.  Returns nil if
+// there are none.
+
+func loadInventory() (*hbInventory, error) {
+	val, exists, err := kvHandle.Get(HBTD_INVENTORY_KEY)
+	if (err != nil) || !exists {
+		return nil, err
+	}
+	var inv hbInventory
+	err = json.Unmarshal([]byte(val), &inv)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Sync the components expected to heartbeat with HSM, report those that
+// have never started and store the results.  If HSM can't be reached, the
+// previous results are kept, with the error noted.
+//
+// now(in): Current time.
+// Return:  Inventory sync results.
+/////////////////////////////////////////////////////////////////////////////
+
+func inventorySync(now time.Time) *hbInventory {
+	inv := &hbInventory{Instance: serviceName, LastSync: now, Waiting: []hbInvEntry{}}
+	prev, err := loadInventory()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching inventory sync results, starting over: %v", err),
+			"error", err)
+	}
+	if prev == nil {
+		prev = &hbInventory{}
+	}
+
+	comps, err := getHSMExpected(app_params.inventory_roles.string_param)
+	var kvlist []hbKV
+	if err == nil {
+		kvlist, err = kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	}
+	if err != nil {
+		logHSM.Error(fmt.Sprintf("Error syncing inventory with HSM: %v", err),
+			"error", err)
+		prev.Instance = serviceName
+		prev.LastSync = now
+		prev.Error = err.Error()
+		storeInventory(prev)
+		return prev
+	}
+
+	tracked := make(map[string]bool)
+	for _, kv := range kvlist {
+		tracked[kv.Key] = true
+	}
+	waiting := make(map[string]hbInvEntry)
+	for _, ent := range prev.Waiting {
+		waiting[ent.XName] = ent
+	}
+
+	grace := time.Duration(app_params.inventory_grace.int_param) * time.Second
+	nnever := 0
+	for _, comp := range comps {
+		xname := xnametypes.NormalizeHMSCompID(comp.ID)
+		inv.Expected++
+		if tracked[xname] {
+			inv.Heartbeating++
+			if ent, ok := waiting[xname]; ok && (ent.NeverStarted != nil) {
+				logHSM.Info(fmt.Sprintf("'%s' has started heartbeating", xname),
+					"component", xname)
+			}
+			continue
+		}
+
+		ent, ok := waiting[xname]
+		if !ok {
+			ent = hbInvEntry{XName: xname, FirstSeen: now}
+		}
+		ent.Role = comp.Role
+		ent.HSMState = comp.State
+		if (ent.NeverStarted == nil) && (now.Sub(ent.FirstSeen) >= grace) {
+			nst := now
+			ent.NeverStarted = &nst
+			logHSM.Warn(fmt.Sprintf("No heartbeat from '%s' within %d seconds of it being %s in HSM",
+				xname, app_params.inventory_grace.int_param, comp.State),
+				"component", xname, "role", comp.Role, "hsm_state", comp.State)
+		}
+		if ent.NeverStarted != nil {
+			nnever++
+			if !ent.Reported {
+				ent.Reported = neverStartedNotify(&ent)
+			}
+		}
+		inv.Waiting = append(inv.Waiting, ent)
+	}
+	mNeverStarted.Set(float64(nnever))
+
+	sort.Slice(inv.Waiting, func(i, j int) bool {
+		return inv.Waiting[i].XName < inv.Waiting[j].XName
+	})
+	logHSM.Debug(fmt.Sprintf("Inventory sync done, %d expected, %d heartbeating, %d never started.",
+		inv.Expected, inv.Heartbeating, nnever),
+		"expected", inv.Expected, "heartbeating", inv.Heartbeating,
+		"never_started", nnever, "duration", time.Since(now))
+	storeInventory(inv)
+	return inv
+}
+
+// Store the inventory sync results in the KV store.
+
+func storeInventory(inv *hbInventory) {
+	ba, err := json.Marshal(inv)
+	if err == nil {
+		err = kvHandle.Store(HBTD_INVENTORY_KEY, string(ba))
+	}
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error storing inventory sync results: %v", err),
+			"error", err)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Send the telemetry message for a component which never started
+// heartbeating.
+//
+// ent(in): Inventory entry of the component.
+// Return:  true if the message was sent, false if the component's
+//          notifications are suppressed or the telemetry bus isn't
+//          accepting messages.
+/////////////////////////////////////////////////////////////////////////////
+
+func neverStartedNotify(ent *hbInvEntry) bool {
+	if suppressedBy(ent.XName, HB_stopped_error) != "" {
+		return false
+	}
+
+	telemsg := telemetry_json_v1{MessageID: NEVER_STARTED_MESSAGE_ID, Id: ent.XName,
+		NewState: ent.HSMState, NewFlag: base.FlagWarning.String(),
+		Info: fmt.Sprintf("No heartbeat received since %s, when %s in HSM.",
+			ent.FirstSeen.UTC().Format(time.RFC3339), ent.HSMState)}
+	select {
+	case telemetryQ <- telemsg:
+		return true
+	default:
+		mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+		logTelemetry.Info("Telemetry bus not accepting messages, never started event not sent.",
+			"component", ent.XName)
+	}
+	return false
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/neverstarted.  Lists the components expected
+// to heartbeat which never started, as of the last inventory sync done by
+// any instance.  Optional query parameter:
+//
+//   prefix=xname    Only return components with this xname prefix
+/////////////////////////////////////////////////////////////////////////////
+
+func neverStartedIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_NEVERSTARTED
+	prefix := xnametypes.NormalizeHMSCompID(r.URL.Query().Get("prefix"))
+
+	inv, err := loadInventory()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching inventory sync results: %v", err),
+			"key", HBTD_INVENTORY_KEY, "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GET operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if inv == nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Not Found",
+			"No inventory sync has been done",
+			errinst, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	rsp := hbNeverStartedRsp{LastSync: inv.LastSync, Expected: inv.Expected,
+		Heartbeating: inv.Heartbeating, Error: inv.Error,
+		NeverStarted: []hbInvEntry{}}
+	for _, ent := range inv.Waiting {
+		if ent.NeverStarted == nil {
+			rsp.Waiting++
+			continue
+		}
+		if (prefix != "") && !strings.HasPrefix(ent.XName, prefix) {
+			continue
+		}
+		rsp.NeverStarted = append(rsp.NeverStarted, ent)
+	}
+	sendJSON(w, http.StatusOK, &rsp, errinst)
+}
@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Components the fake HSM expects to heartbeat, and the status it returns.
+
+var fakeHSMInvComps []hsmInvComp
+var fakeHSMInvStatus = http.StatusOK
+
+func fakeHSMInventoryHandler(w http.ResponseWriter, req *http.Request) {
+	if (req.Method != "GET") || !strings.HasSuffix(req.URL.Path, "/"+SM_URL_MID) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := req.URL.Query()
+	states := q["state"]
+	sort.Strings(states)
+	if (q.Get("type") != "Node") || (q.Get("enabled") != "true") ||
+		!reflect.DeepEqual(states, []string{"On", "Ready"}) ||
+		!reflect.DeepEqual(q["role"], []string{"Compute", "Application"}) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if fakeHSMInvStatus != http.StatusOK {
+		w.WriteHeader(fakeHSMInvStatus)
+		return
+	}
+	ba, _ := json.Marshal(&hsmInvCompArray{Components: fakeHSMInvComps})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ba)
+}
+
+// Test syncing the components expected to heartbeat with HSM, and
+// GET /neverstarted.
+
+func TestInventorySync(t *testing.T) {
+	var kval string
+	var rsp hbNeverStartedRsp
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	srv := httptest.NewServer(http.HandlerFunc(fakeHSMInventoryHandler))
+	defer srv.Close()
+
+	htrans.transport = &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	htrans.client = &http.Client{Transport: htrans.transport,
+		Timeout: (20 * time.Second),
+	}
+	origParams := app_params
+	initAppParams()
+	app_params.statemgr_url.string_param = srv.URL
+	app_params.statemgr_timeout.int_param = 5
+	app_params.inventory_grace.int_param = 300
+	app_params.inventory_roles.string_param = "Compute, Application"
+	defer func() {
+		app_params = origParams
+		kvHandle.Delete(HBTD_INVENTORY_KEY)
+	}()
+	drainTelemetryQ()
+
+	kvHandle.Delete(HBTD_INVENTORY_KEY)
+	policyReq(t, "GET", URL_NEVERSTARTED, "", http.StatusNotFound)
+
+	//One of three expected components is heartbeating.
+
+	fakeHSMInvComps = []hsmInvComp{
+		{ID: "x3013c0s0b0n0", State: "Ready", Role: "Compute"},
+		{ID: "x3013c0s1b0n0", State: "On", Role: "Compute"},
+		{ID: "X3014C0S0B0N0", State: "On", Role: "Application"},
+	}
+	make_key(&kval, "x3013c0s0b0n0", time.Now().Unix())
+	kvHandle.Store("x3013c0s0b0n0", kval)
+	defer kvHandle.Delete("x3013c0s0b0n0")
+	defer kvHandle.Delete("x3013c0s1b0n0")
+
+	now := time.Now()
+	inv := inventorySync(now)
+	if (inv.Error != "") || (inv.Expected != 3) || (inv.Heartbeating != 1) ||
+		(len(inv.Waiting) != 2) || (inv.Waiting[0].XName != "x3013c0s1b0n0") ||
+		(inv.Waiting[1].XName != "x3014c0s0b0n0") || (inv.Waiting[0].NeverStarted != nil) {
+		t.Fatalf("Unexpected inventory: %+v", inv)
+	}
+	rr := policyReq(t, "GET", URL_NEVERSTARTED, "", http.StatusOK)
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if (rsp.Expected != 3) || (rsp.Waiting != 2) || (len(rsp.NeverStarted) != 0) {
+		t.Errorf("Unexpected never started response: %s", rr.Body.String())
+	}
+	if msgs := drainTelemetryQ(); len(msgs) != 0 {
+		t.Errorf("Unexpected telemetry messages: %+v", msgs)
+	}
+
+	//After the grace period, the two waiting have never started.  Each is
+	//reported once.
+
+	inventorySync(now.Add(300 * time.Second))
+	msgs := drainTelemetryQ()
+	if len(msgs) != 2 {
+		t.Fatalf("Expected 2 telemetry messages, got %+v", msgs)
+	}
+	for ix, comp := range []string{"x3013c0s1b0n0", "x3014c0s0b0n0"} {
+		if (msgs[ix].MessageID != NEVER_STARTED_MESSAGE_ID) || (msgs[ix].Id != comp) ||
+			(msgs[ix].NewState != "On") || (msgs[ix].NewFlag != "Warning") {
+			t.Errorf("Unexpected telemetry message: %+v", msgs[ix])
+		}
+	}
+	inventorySync(now.Add(310 * time.Second))
+	if msgs = drainTelemetryQ(); len(msgs) != 0 {
+		t.Errorf("Never started components reported again: %+v", msgs)
+	}
+
+	rr = policyReq(t, "GET", URL_NEVERSTARTED+"?prefix=X3014", "", http.StatusOK)
+	rsp = hbNeverStartedRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if (rsp.Waiting != 0) || (len(rsp.NeverStarted) != 1) ||
+		(rsp.NeverStarted[0].XName != "x3014c0s0b0n0") ||
+		(rsp.NeverStarted[0].Role != "Application") || !rsp.NeverStarted[0].Reported ||
+		!rsp.NeverStarted[0].FirstSeen.Equal(now) ||
+		!rsp.NeverStarted[0].NeverStarted.Equal(now.Add(300*time.Second)) {
+		t.Errorf("Unexpected never started response: %s", rr.Body.String())
+	}
+
+	//One starts heartbeating, and HSM no longer expects the other.
+
+	make_key(&kval, "x3013c0s1b0n0", time.Now().Unix())
+	kvHandle.Store("x3013c0s1b0n0", kval)
+	fakeHSMInvComps = fakeHSMInvComps[:2]
+	inv = inventorySync(now.Add(320 * time.Second))
+	if (inv.Expected != 2) || (inv.Heartbeating != 2) || (len(inv.Waiting) != 0) {
+		t.Errorf("Unexpected inventory: %+v", inv)
+	}
+
+	//HSM failure keeps the previous results.
+
+	fakeHSMInvComps = append(fakeHSMInvComps, hsmInvComp{ID: "x3013c0s2b0n0", State: "On"})
+	inventorySync(now.Add(330 * time.Second))
+	fakeHSMInvStatus = http.StatusServiceUnavailable
+	defer func() { fakeHSMInvStatus = http.StatusOK }()
+	inv = inventorySync(now.Add(340 * time.Second))
+	if (inv.Error == "") || (len(inv.Waiting) != 1) || !inv.LastSync.Equal(now.Add(340*time.Second)) {
+		t.Errorf("Unexpected inventory after HSM failure: %+v", inv)
+	}
+	rr = policyReq(t, "GET", URL_NEVERSTARTED, "", http.StatusOK)
+	rsp = hbNeverStartedRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if (rsp.Error == "") || (rsp.Waiting != 1) {
+		t.Errorf("Unexpected never started response: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,356 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Structured logging.  Each subsystem has its own logger with its own
+// runtime-adjustable level.  In "text" format log records are printed via
+// hbtdPrintf() as the level followed by the message, e.g. "WARNING: ...".
+// In "json" format each record is a JSON object containing the message plus
+// the level, the subsystem, the instance and any fields attached to the
+// record.  Messages therefore don't carry a level prefix of their own.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	LOG_SUBSYS_MAIN      = "main"
+	LOG_SUBSYS_INGEST    = "ingest"
+	LOG_SUBSYS_CHECKER   = "checker"
+	LOG_SUBSYS_HSM       = "hsm"
+	LOG_SUBSYS_TELEMETRY = "telemetry"
+	LOG_SUBSYS_KV        = "kv"
+
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+// Below slog's Debug, for very chatty per-operation messages.
+
+const LevelTrace = slog.LevelDebug - 4
+
+var logSubsystems = []string{LOG_SUBSYS_MAIN, LOG_SUBSYS_INGEST,
+	LOG_SUBSYS_CHECKER, LOG_SUBSYS_HSM, LOG_SUBSYS_TELEMETRY, LOG_SUBSYS_KV}
+
+var logLevelNames = map[string]slog.Level{
+	"trace": LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+var logLevels = make(map[string]*slog.LevelVar)
+var logJSONOut io.Writer = os.Stderr
+
+// JSON handler all subsystem loggers render through; nil in text format.
+// Replaced as a whole when the format changes, so log calls never block on
+// a PATCH of the format.
+
+type logJSONBase struct {
+	h slog.Handler
+}
+
+var logJSON atomic.Pointer[logJSONBase]
+
+var (
+	logMain      = newSubsysLogger(LOG_SUBSYS_MAIN)
+	logIngest    = newSubsysLogger(LOG_SUBSYS_INGEST)
+	logChecker   = newSubsysLogger(LOG_SUBSYS_CHECKER)
+	logHSM       = newSubsysLogger(LOG_SUBSYS_HSM)
+	logTelemetry = newSubsysLogger(LOG_SUBSYS_TELEMETRY)
+	logKV        = newSubsysLogger(LOG_SUBSYS_KV)
+)
+
+// slog handler which checks the subsystem's level and then renders the
+// record in the currently selected format.
+
+type hbtdLogHandler struct {
+	subsys string
+	ops    []func(slog.Handler) slog.Handler //WithAttrs/WithGroup, in order
+	jcache atomic.Pointer[logJSONCache]
+}
+
+// This handler's JSON handler, derived from the base it was built from.
+
+type logJSONCache struct {
+	base *logJSONBase
+	h    slog.Handler
+}
+
+func newSubsysLogger(subsys string) *slog.Logger {
+	lv, ok := logLevels[subsys]
+	if !ok {
+		lv = &slog.LevelVar{}
+		logLevels[subsys] = lv
+	}
+	return slog.New(&hbtdLogHandler{subsys: subsys})
+}
+
+func (h *hbtdLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= logLevels[h.subsys].Level()
+}
+
+func (h *hbtdLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	base := logJSON.Load()
+	if base == nil {
+		hbtdPrintf("%s: %s", logLevelLabel(r.Level), r.Message)
+		return nil
+	}
+
+	jc := h.jcache.Load()
+	if (jc == nil) || (jc.base != base) {
+		jh := base.h.WithAttrs([]slog.Attr{slog.String("subsystem", h.subsys)})
+		for _, op := range h.ops {
+			jh = op(jh)
+		}
+		jc = &logJSONCache{base: base, h: jh}
+		h.jcache.Store(jc)
+	}
+	r.Message = strings.TrimSpace(r.Message)
+	return jc.h.Handle(ctx, r)
+}
+
+func (h *hbtdLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := &hbtdLogHandler{subsys: h.subsys}
+	nh.ops = append(append(nh.ops, h.ops...),
+		func(jh slog.Handler) slog.Handler { return jh.WithAttrs(attrs) })
+	return nh
+}
+
+func (h *hbtdLogHandler) WithGroup(name string) slog.Handler {
+	nh := &hbtdLogHandler{subsys: h.subsys}
+	nh.ops = append(append(nh.ops, h.ops...),
+		func(jh slog.Handler) slog.Handler { return jh.WithGroup(name) })
+	return nh
+}
+
+// Level as shown in text format.
+
+func logLevelLabel(lvl slog.Level) string {
+	switch {
+	case lvl >= slog.LevelError:
+		return "ERROR"
+	case lvl >= slog.LevelWarn:
+		return "WARNING"
+	case lvl >= slog.LevelInfo:
+		return "INFO"
+	case lvl >= slog.LevelDebug:
+		return "DEBUG"
+	}
+	return "TRACE"
+}
+
+// Render our trace level by name rather than as "DEBUG-4".
+
+func logReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if lvl, ok := a.Value.Any().(slog.Level); ok && lvl == LevelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// Convenience functions.
+
+func logTrace(l *slog.Logger, msg string, args ...any) {
+	l.Log(context.Background(), LevelTrace, msg, args...)
+}
+
+func logEnabled(l *slog.Logger, level slog.Level) bool {
+	return l.Enabled(context.Background(), level)
+}
+
+// Legacy free-text output.  hbtdPrintf()/hbtdPrintln() point at these, so
+// that in JSON format messages not yet converted to a subsystem logger still
+// come out as JSON records.  The level is inferred from the message, and a
+// level prefix is dropped since the record carries the level.
+
+func legacyLevel(msg string) (slog.Level, string) {
+	for _, pfx := range []struct {
+		str string
+		lvl slog.Level
+	}{{"ERROR:", slog.LevelError}, {"WARNING:", slog.LevelWarn},
+		{"INFO:", slog.LevelInfo}} {
+		if strings.HasPrefix(msg, pfx.str) {
+			return pfx.lvl, strings.TrimSpace(msg[len(pfx.str):])
+		}
+	}
+	if strings.Contains(msg, "ERROR") {
+		return slog.LevelError, msg
+	}
+	return slog.LevelInfo, msg
+}
+
+func legacyLog(msg string) {
+	lvl, msg := legacyLevel(msg)
+	logMain.Log(context.Background(), lvl, msg)
+}
+
+func logPrintf(format string, a ...interface{}) {
+	if logJSON.Load() == nil {
+		log.Printf(format, a...)
+		return
+	}
+	legacyLog(fmt.Sprintf(format, a...))
+}
+
+func logPrintln(a ...interface{}) {
+	if logJSON.Load() == nil {
+		log.Println(a...)
+		return
+	}
+	legacyLog(fmt.Sprintln(a...))
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Parse a log level specification.  This is a comma-separated list of
+// subsystem=level entries; an entry with no subsystem sets the level of
+// all subsystems not otherwise specified.  Example: "info,checker=debug".
+//
+// spec(in): Log level specification.
+// Return:   Map of subsystem to level ("" for the default);
+//           Normalized specification;
+//           Error if the specification is invalid, else nil.
+/////////////////////////////////////////////////////////////////////////////
+
+func parseLogLevels(spec string) (map[string]slog.Level, string, error) {
+	lmap := make(map[string]slog.Level)
+	var norm []string
+
+	for _, ent := range strings.Split(spec, ",") {
+		ent = strings.ToLower(strings.TrimSpace(ent))
+		if ent == "" {
+			continue
+		}
+		subsys, lname := "", ent
+		if ix := strings.Index(ent, "="); ix >= 0 {
+			subsys, lname = ent[:ix], ent[ix+1:]
+			found := false
+			for _, ss := range logSubsystems {
+				if ss == subsys {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, "", fmt.Errorf("unknown log subsystem '%s'", subsys)
+			}
+		}
+		lvl, ok := logLevelNames[lname]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown log level '%s'", lname)
+		}
+		if _, dup := lmap[subsys]; dup {
+			return nil, "", fmt.Errorf("log level for '%s' specified more than once", subsys)
+		}
+		lmap[subsys] = lvl
+		norm = append(norm, ent)
+	}
+
+	//Default level first, then subsystems in alphabetical order.
+	sort.Slice(norm, func(i, j int) bool {
+		if strings.Contains(norm[i], "=") != strings.Contains(norm[j], "=") {
+			return !strings.Contains(norm[i], "=")
+		}
+		return norm[i] < norm[j]
+	})
+	return lmap, strings.Join(norm, ","), nil
+}
+
+// Validate a log format name.
+
+func parseLogFormat(fmtstr string) (string, error) {
+	lc := strings.ToLower(strings.TrimSpace(fmtstr))
+	if lc != LOG_FORMAT_TEXT && lc != LOG_FORMAT_JSON {
+		return "", fmt.Errorf("unknown log format '%s'", fmtstr)
+	}
+	return lc, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Apply the logging parameters.  The level of each subsystem comes from the
+// log_levels parameter if it names the subsystem, else from its default
+// entry, else from the legacy debug level (0 = info, 1 = debug, 2+ = trace).
+// Called whenever parameters are set.
+//
+// Args, return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func applyLogParams() {
+	base := slog.LevelInfo
+	if app_params.debug_level.int_param > 1 {
+		base = LevelTrace
+	} else if app_params.debug_level.int_param > 0 {
+		base = slog.LevelDebug
+	}
+
+	//Already validated when set.
+	lmap, _, _ := parseLogLevels(app_params.log_levels.string_param)
+	if lvl, ok := lmap[""]; ok {
+		base = lvl
+	}
+	for _, ss := range logSubsystems {
+		if lvl, ok := lmap[ss]; ok {
+			logLevels[ss].Set(lvl)
+		} else {
+			logLevels[ss].Set(base)
+		}
+	}
+
+	if app_params.log_format.string_param != LOG_FORMAT_JSON {
+		logJSON.Store(nil)
+	} else if logJSON.Load() == nil {
+		var jh slog.Handler = slog.NewJSONHandler(logJSONOut,
+			&slog.HandlerOptions{Level: LevelTrace, ReplaceAttr: logReplaceAttr})
+		jh = jh.WithAttrs([]slog.Attr{slog.String("instance", serviceName)})
+		logJSON.Store(&logJSONBase{h: jh})
+	}
+}
+
+// Current effective level of each subsystem, for display.
+
+func logLevelsString() string {
+	var ents []string
+	for _, ss := range logSubsystems {
+		lvl := logLevels[ss].Level()
+		name := strings.ToLower(lvl.String())
+		if lvl == LevelTrace {
+			name = "trace"
+		}
+		ents = append(ents, ss+"="+name)
+	}
+	sort.Strings(ents)
+	return strings.Join(ents, ",")
+}
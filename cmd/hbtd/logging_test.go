@@ -0,0 +1,249 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test log level specification parsing and application.
+
+func TestLogLevels(t *testing.T) {
+	goodSpecs := []struct {
+		spec string
+		norm string
+	}{
+		{"", ""},
+		{"debug", "debug"},
+		{" Checker=Trace, info ", "info,checker=trace"},
+		{"kv=error,hsm=warn", "hsm=warn,kv=error"},
+	}
+	for _, tst := range goodSpecs {
+		_, norm, err := parseLogLevels(tst.spec)
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %v", tst.spec, err)
+		} else if norm != tst.norm {
+			t.Errorf("'%s': expected '%s', got '%s'", tst.spec, tst.norm, norm)
+		}
+	}
+
+	for _, spec := range []string{"verbose", "disk=info", "info,warn", "checker=info,checker=debug"} {
+		if _, _, err := parseLogLevels(spec); err == nil {
+			t.Errorf("'%s': expected an error", spec)
+		}
+	}
+
+	//Legacy debug level sets the default, log_levels overrides it.
+
+	app_params.debug_level.int_param = 2
+	app_params.log_levels.string_param = "hsm=warn"
+	applyLogParams()
+	if logLevels[LOG_SUBSYS_CHECKER].Level() != LevelTrace ||
+		logLevels[LOG_SUBSYS_HSM].Level() != slog.LevelWarn {
+		t.Errorf("Unexpected levels: %s", logLevelsString())
+	}
+
+	app_params.debug_level.int_param = 0
+	app_params.log_levels.string_param = "debug,ingest=error"
+	applyLogParams()
+	exp := "checker=debug,hsm=debug,ingest=error,kv=debug,main=debug,telemetry=debug"
+	if logLevelsString() != exp {
+		t.Errorf("Expected levels '%s', got '%s'", exp, logLevelsString())
+	}
+	if logEnabled(logIngest, slog.LevelWarn) || !logEnabled(logKV, slog.LevelDebug) {
+		t.Errorf("Subsystem levels not honored.")
+	}
+
+	app_params.log_levels.string_param = ""
+	applyLogParams()
+}
+
+// Test text and JSON log output.
+
+func TestLogFormat(t *testing.T) {
+	var jbuf bytes.Buffer
+	var rec map[string]interface{}
+
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	app_params.debug_level.int_param = 0
+	app_params.log_levels.string_param = ""
+
+	//Text: level and message, via hbtdPrintf().
+
+	testPrintClear()
+	logChecker.Warn("something", "component", "x0c0s0b0n0")
+	logChecker.Debug("not shown")
+	if tpd := testPrintData(); tpd != "WARNING: something\n" {
+		t.Errorf("Unexpected text output: '%s'", tpd)
+	}
+
+	//JSON: message plus fields.
+
+	origOut := logJSONOut
+	logJSONOut = &jbuf
+	app_params.log_format.string_param = LOG_FORMAT_JSON
+	app_params.log_levels.string_param = "checker=trace"
+	applyLogParams()
+
+	logTrace(logChecker.With("attempt", 1), "checked\n", "component", "x0c0s0b0n0")
+	err := json.Unmarshal(jbuf.Bytes(), &rec)
+	if err != nil {
+		t.Fatalf("Bad JSON log output '%s': %v", jbuf.String(), err)
+	}
+	if rec["msg"] != "checked" || rec["level"] != "TRACE" ||
+		rec["subsystem"] != LOG_SUBSYS_CHECKER || rec["component"] != "x0c0s0b0n0" ||
+		rec["attempt"] != float64(1) {
+		t.Errorf("Unexpected JSON log record: %s", jbuf.String())
+	}
+
+	//Legacy output is JSON too, with the level taken from the message.
+
+	jbuf.Reset()
+	logPrintf("ERROR: bad thing %d", 42)
+	rec = nil
+	json.Unmarshal(jbuf.Bytes(), &rec)
+	if rec["msg"] != "bad thing 42" || rec["level"] != "ERROR" ||
+		rec["subsystem"] != LOG_SUBSYS_MAIN {
+		t.Errorf("Unexpected legacy JSON log record: %s", jbuf.String())
+	}
+
+	//Back to text.
+
+	app_params.log_format.string_param = LOG_FORMAT_TEXT
+	app_params.log_levels.string_param = ""
+	applyLogParams()
+	logJSONOut = origOut
+	testPrintClear()
+	logHSM.Info("text again")
+	if !strings.Contains(testPrintData(), "INFO: text again") {
+		t.Errorf("Text output not restored.")
+	}
+}
+
+// Test switching the log format while logging.
+
+func TestLogFormatSwitch(t *testing.T) {
+	var jbuf bytes.Buffer
+	var jlock sync.Mutex
+
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	origOut := logJSONOut
+	logJSONOut = &lockedWriter{w: &jbuf, lock: &jlock}
+	app_params.log_levels.string_param = ""
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for ix := 0; ix < 4; ix++ {
+		wg.Add(1)
+		go func(ix int) {
+			defer wg.Done()
+			lg := logChecker.With("worker", ix)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				lg.Info("switching")
+				logPrintf("INFO: legacy %d", ix)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}(ix)
+	}
+	for ix := 0; ix < 50; ix++ {
+		if (ix % 2) == 0 {
+			app_params.log_format.string_param = LOG_FORMAT_JSON
+		} else {
+			app_params.log_format.string_param = LOG_FORMAT_TEXT
+		}
+		applyLogParams()
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	app_params.log_format.string_param = LOG_FORMAT_TEXT
+	applyLogParams()
+	logJSONOut = origOut
+
+	jlock.Lock()
+	defer jlock.Unlock()
+	if !strings.Contains(jbuf.String(), `"msg":"switching"`) {
+		t.Errorf("No JSON output while switching formats.")
+	}
+	if !strings.Contains(testPrintData(), "INFO: switching") {
+		t.Errorf("No text output while switching formats.")
+	}
+}
+
+type lockedWriter struct {
+	w    io.Writer
+	lock *sync.Mutex
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	return lw.w.Write(p)
+}
+
+// Test setting log parameters via PATCH /params.
+
+func TestLogParamsPatch(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	policyReq(t, "PATCH", URL_PARAMS, `{"Log_levels":"checker=debug,bogus=info"}`,
+		http.StatusBadRequest)
+	policyReq(t, "PATCH", URL_PARAMS, `{"Log_format":"xml"}`, http.StatusBadRequest)
+
+	rr := policyReq(t, "PATCH", URL_PARAMS, `{"Log_levels":"Checker=Debug"}`,
+		http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `"Log_levels":"checker=debug"`) {
+		t.Errorf("Unexpected PATCH response: %s", rr.Body.String())
+	}
+	if !logEnabled(logChecker, slog.LevelDebug) || logEnabled(logHSM, slog.LevelDebug) {
+		t.Errorf("Log levels not applied: %s", logLevelsString())
+	}
+
+	policyReq(t, "PATCH", URL_PARAMS, `{"Log_levels":"info"}`, http.StatusOK)
+	app_params.log_levels.string_param = ""
+	applyLogParams()
+}
@@ -0,0 +1,390 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Prometheus metrics, served in text format on /hmi/v1/metrics.
+/////////////////////////////////////////////////////////////////////////////
+
+const METRICS_NAMESPACE = "hbtd"
+
+// Heartbeat receive endpoint labels
+
+const (
+	HB_EP_HEARTBEAT       = "heartbeat"
+	HB_EP_HEARTBEAT_XNAME = "heartbeat_xname"
+	HB_EP_HEARTBEATS      = "heartbeats"
+	HB_EP_GRPC            = "grpc"
+	HB_EP_GRPC_STREAM     = "grpc_stream"
+)
+
+// Queue labels
+
+const (
+	QUEUE_HSM       = "hsm"
+	QUEUE_TELEMETRY = "telemetry"
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	mHBReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "heartbeats_received_total",
+		Help:      "Heartbeats received, by endpoint and result.",
+	}, []string{"endpoint", "result"})
+
+	mComponents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "components",
+		Help:      "Tracked components by heartbeat state, as of the last HB check done by this instance; DEAD ones were declared dead by that check.",
+	}, []string{"state"})
+
+	mTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "transitions_total",
+		Help:      "Heartbeat state transitions, by type.",
+	}, []string{"type"})
+
+	mTransitionsSuppressed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "transitions_suppressed_total",
+		Help:      "Heartbeat state transitions not sent due to a suppression, by type.",
+	}, []string{"type"})
+
+	mTransitionsDamped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "transitions_damped_total",
+		Help:      "Heartbeat state transitions not sent because the component is flapping, by type.",
+	}, []string{"type"})
+
+	mFlapStarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "flap_starts_total",
+		Help:      "Times a component was found to be flapping by HB checks done by this instance.",
+	})
+
+	mTopoOutages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "topology_outages_total",
+		Help:      "Slots, chassis and cabinets suspected of causing their components' heartbeats to stop, by level.",
+	}, []string{"level"})
+
+	mNeverStarted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "never_started_components",
+		Help:      "Components expected to heartbeat per HSM which never started, as of the last inventory sync done by this instance.",
+	})
+
+	mCheckerDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "checker_duration_seconds",
+		Help:      "Time taken by HB checks done by this instance.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	mLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "leader",
+		Help:      "1 if this instance is the elected leader, else 0.",
+	})
+
+	mLeaderChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "leader_changes_total",
+		Help:      "Times this instance became or stopped being the leader.",
+	})
+
+	mParamRevision = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "param_revision",
+		Help:      "Revision of the stored parameters this instance is running.",
+	})
+
+	mCheckerInstances = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "checker_instances",
+		Help:      "Instances sharing the HB checks, as of the last HB check done by this instance.",
+	})
+
+	mCheckerRebalances = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "checker_rebalances_total",
+		Help:      "Times the HB check shards changed due to instances starting or stopping.",
+	})
+
+	mQueueDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "queue_drops_total",
+		Help:      "Messages dropped because a queue was full, by queue.",
+	}, []string{"queue"})
+
+	mHSMPatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "hsm_patch_duration_seconds",
+		Help:      "HSM BulkStateData PATCH latency, by transition type.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 3, 10),
+	}, []string{"type"})
+
+	mHSMPatchFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "hsm_patch_failures_total",
+		Help:      "Failed HSM BulkStateData PATCHes, by transition type.",
+	}, []string{"type"})
+
+	mOutboxPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "hsm_outbox_pending",
+		Help:      "HB transitions in the HSM notification outbox not yet sent, as of the last HSM update done by this instance.",
+	})
+
+	mReconcileFixes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "hsm_reconcile_fixes_total",
+		Help:      "Components whose HSM State/Flag was corrected by HSM reconciliation done by this instance.",
+	})
+
+	mKVDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "kv_op_duration_seconds",
+		Help:      "KV store operation latency, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+	}, []string{"op"})
+
+	mKVErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "kv_op_errors_total",
+		Help:      "KV store operation errors, by operation.",
+	}, []string{"op"})
+
+	mKVBatchOps = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "kv_batch_ops",
+		Help:      "Number of writes in each batched KV store write.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+	})
+
+	mKVBatchFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "kv_batch_fallbacks_total",
+		Help:      "Batched KV store writes which failed and were retried one key at a time.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(mHBReceived, mComponents, mTransitions,
+		mTransitionsSuppressed, mTransitionsDamped, mFlapStarts, mTopoOutages, mCheckerDuration, mLeader, mLeaderChanges,
+		mParamRevision, mCheckerInstances, mCheckerRebalances,
+		mQueueDrops, mHSMPatchDuration, mHSMPatchFailures, mOutboxPending,
+		mReconcileFixes, mNeverStarted, mKVDuration, mKVErrors, mKVBatchOps,
+		mKVBatchFallbacks)
+
+	metricsRegistry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   METRICS_NAMESPACE,
+		Name:        "queue_depth",
+		Help:        "Number of messages waiting in a queue, by queue.",
+		ConstLabels: prometheus.Labels{"queue": QUEUE_HSM},
+	}, func() float64 { return float64(len(hsmUpdateQ)) }))
+	metricsRegistry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   METRICS_NAMESPACE,
+		Name:        "queue_depth",
+		Help:        "Number of messages waiting in a queue, by queue.",
+		ConstLabels: prometheus.Labels{"queue": QUEUE_TELEMETRY},
+	}, func() float64 { return float64(len(telemetryQ)) }))
+
+	metricsRegistry.MustRegister(collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Entry point for GET /hmi/v1/metrics
+
+var doMetrics = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP
+
+// Convenience function, convert an HTTP status code into a heartbeat
+// receive result label.
+
+func hbResultLabel(code int) string {
+	switch {
+	case code < 300:
+		return "ok"
+	case code == http.StatusMethodNotAllowed:
+		return "bad_method"
+	case code < 500:
+		return "bad_request"
+	}
+	return "error"
+}
+
+// Captures the status code written by an HTTP handler.
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.code = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Wrap a single-heartbeat HTTP handler so that each heartbeat received is
+// counted by endpoint and result.
+
+func countHBs(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler(sr, r)
+		mHBReceived.WithLabelValues(endpoint, hbResultLabel(sr.code)).Inc()
+	}
+}
+
+// Wrap the batch heartbeat HTTP handler.  Heartbeats in an accepted batch
+// are counted individually by the handler; a batch rejected as a whole is
+// counted once here.
+
+func countHBBatch(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler(sr, r)
+		if sr.code != http.StatusOK {
+			mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, hbResultLabel(sr.code)).Inc()
+		}
+	}
+}
+
+// Non-blocking send to the HSM update queue.  The queue only carries
+// "something changed" pokes; the transitions themselves are in the global
+// HB transition maps, and each poke sends all of them.  Callers put their
+// transitions in the maps before poking, so if the queue is full, the pokes
+// already in it will send them and a dropped poke loses nothing.
+
+func pokeHSMQ(val int) {
+	select {
+	case hsmUpdateQ <- val:
+	default:
+		mQueueDrops.WithLabelValues(QUEUE_HSM).Inc()
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// KV store wrapper, records latency and errors of each operation.
+/////////////////////////////////////////////////////////////////////////////
+
+type kvMetrics struct {
+	hbStore
+}
+
+func kvObserve(op string, tstart time.Time, err error) {
+	elapsed := time.Since(tstart)
+	mKVDuration.WithLabelValues(op).Observe(elapsed.Seconds())
+	if logEnabled(logKV, LevelTrace) {
+		logTrace(logKV, fmt.Sprintf("KV %s took %v, error: %v", op, elapsed, err),
+			"op", op, "duration", elapsed, "error", err)
+	}
+	if err != nil {
+		mKVErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func (kvm *kvMetrics) Get(key string) (string, bool, error) {
+	tstart := time.Now()
+	val, ok, err := kvm.hbStore.Get(key)
+	kvObserve("get", tstart, err)
+	return val, ok, err
+}
+
+func (kvm *kvMetrics) GetMulti(keys []string) (map[string]string, error) {
+	tstart := time.Now()
+	vals, err := kvm.hbStore.GetMulti(keys)
+	kvObserve("getmulti", tstart, err)
+	return vals, err
+}
+
+func (kvm *kvMetrics) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	tstart := time.Now()
+	kvs, err := kvm.hbStore.GetRange(keystart, keyend)
+	kvObserve("getrange", tstart, err)
+	return kvs, err
+}
+
+func (kvm *kvMetrics) Store(key string, value string) error {
+	tstart := time.Now()
+	err := kvm.hbStore.Store(key, value)
+	kvObserve("store", tstart, err)
+	return err
+}
+
+func (kvm *kvMetrics) Create(key string, value string) (bool, error) {
+	tstart := time.Now()
+	ok, err := kvm.hbStore.Create(key, value)
+	kvObserve("create", tstart, err)
+	return ok, err
+}
+
+func (kvm *kvMetrics) Delete(key string) error {
+	tstart := time.Now()
+	err := kvm.hbStore.Delete(key)
+	kvObserve("delete", tstart, err)
+	return err
+}
+
+func (kvm *kvMetrics) Batch(ops []hbStoreOp) error {
+	tstart := time.Now()
+	err := kvm.hbStore.Batch(ops)
+	kvObserve("batch", tstart, err)
+	return err
+}
+
+func (kvm *kvMetrics) TempKey(key string, val string) error {
+	tstart := time.Now()
+	err := kvm.hbStore.TempKey(key, val)
+	kvObserve("tempkey", tstart, err)
+	return err
+}
+
+// A lock held by another instance is not an error, so only latency is
+// recorded for lock acquisition.
+
+func (kvm *kvMetrics) DistTimedLock(tosec int) error {
+	tstart := time.Now()
+	err := kvm.hbStore.DistTimedLock(tosec)
+	kvObserve("lock", tstart, nil)
+	return err
+}
+
+func (kvm *kvMetrics) DistUnlock() error {
+	tstart := time.Now()
+	err := kvm.hbStore.DistUnlock()
+	kvObserve("unlock", tstart, err)
+	return err
+}
@@ -0,0 +1,227 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Test that HB receives, transitions and KV operations are counted, and
+// that the metrics endpoint serves them.
+
+func TestMetrics(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	app_params.debug_level.int_param = 0
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	comp := "x3006c0s0b0n0"
+	ts := time.Now().Format(time.RFC3339)
+
+	//Single HB endpoints
+
+	okXN := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEAT_XNAME, "ok"))
+	badXN := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEAT_XNAME, "bad_request"))
+	started := testutil.ToFloat64(mTransitions.WithLabelValues("Started"))
+
+	policyReq(t, "POST", URL_HEARTBEAT+"/"+comp,
+		`{"Status":"OK","Timestamp":"`+ts+`"}`, http.StatusOK)
+	policyReq(t, "POST", URL_HEARTBEAT+"/"+comp,
+		`{"Status":"OK","Timestamp":"`+ts+`"}`, http.StatusOK)
+	policyReq(t, "POST", URL_HEARTBEAT+"/"+comp, `{"Status":"OK"}`,
+		http.StatusBadRequest)
+
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEAT_XNAME, "ok")); v != okXN+2 {
+		t.Errorf("Expected %v OK heartbeats, got %v", okXN+2, v)
+	}
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEAT_XNAME, "bad_request")); v != badXN+1 {
+		t.Errorf("Expected %v bad heartbeats, got %v", badXN+1, v)
+	}
+	if v := testutil.ToFloat64(mTransitions.WithLabelValues("Started")); v != started+1 {
+		t.Errorf("Expected %v start transitions, got %v", started+1, v)
+	}
+
+	//Batch endpoint counts each HB; a rejected batch is counted once.
+
+	okB := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, "ok"))
+	badB := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, "bad_request"))
+
+	policyReq(t, "POST", URL_HEARTBEATS,
+		`[{"Component":"`+comp+`","Hostname":"nid0001","NID":"0001","Status":"OK","Timestamp":"`+ts+`"},`+
+			`{"Component":"xyzzy","Hostname":"nid0002","NID":"0002","Status":"OK","Timestamp":"`+ts+`"}]`,
+		http.StatusOK)
+	policyReq(t, "POST", URL_HEARTBEATS, `{"Component":"x"}`, http.StatusBadRequest)
+
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, "ok")); v != okB+1 {
+		t.Errorf("Expected %v OK batch heartbeats, got %v", okB+1, v)
+	}
+	if v := testutil.ToFloat64(mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, "bad_request")); v != badB+2 {
+		t.Errorf("Expected %v bad batch heartbeats, got %v", badB+2, v)
+	}
+
+	//KV wrapper
+
+	origKV := kvHandle
+	kvHandle = &kvMetrics{hbStore: origKV}
+	kvHandle.Get(comp)
+	kvHandle.Delete(comp)
+	kvHandle = origKV
+
+	hbMapLock.Lock()
+	delete(StartMap, comp)
+	hbMapLock.Unlock()
+
+	//Component states add up to all audited components, including the
+	//ones declared dead or stopped by the audit.
+
+	app_params.check_interval.int_param = 0
+	app_params.warntime.int_param = 5
+	app_params.errtime.int_param = 20
+	staleKeys = false
+	now := time.Now().Unix()
+	recs := []hbinfo{
+		{Component: "x3006c0s1b0n0", Last_hb_rcv_time: fmt.Sprintf("%x", now)},
+		{Component: "x3006c0s2b0n0", Last_hb_rcv_time: fmt.Sprintf("%x", now-10)},
+		{Component: "x3006c0s3b0n0", Last_hb_rcv_time: fmt.Sprintf("%x", now-30)},
+		{Component: "x3006c0s4b0n0", Last_hb_rcv_time: fmt.Sprintf("%x", now-10),
+			Last_hb_status: "Shutdown"},
+	}
+	for _, rec := range recs {
+		ba, _ := json.Marshal(&rec)
+		kvHandle.Store(rec.Component, string(ba))
+		defer kvHandle.Delete(rec.Component)
+	}
+	kvlist, _ := kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	ntracked := 0
+	for _, kv := range kvlist {
+		if kv.Key != KV_PARAM_KEY {
+			ntracked++
+		}
+	}
+
+	hb_checker()
+	ncounted := 0
+	for _, state := range []string{HB_STATE_OK, HB_STATE_WARN, HB_STATE_DEAD, HB_STATE_STOPPING} {
+		v := testutil.ToFloat64(mComponents.WithLabelValues(state))
+		if v < 1 {
+			t.Errorf("Expected at least one %s component, got %v", state, v)
+		}
+		ncounted += int(v)
+	}
+	if ncounted != ntracked {
+		t.Errorf("Component states add up to %d, expected %d", ncounted, ntracked)
+	}
+	hbMapLock.Lock()
+	for _, rec := range recs {
+		for _, tmap := range hbTransitionMaps() {
+			delete(tmap, rec.Component)
+		}
+	}
+	hbMapLock.Unlock()
+
+	//Endpoint
+
+	rr := policyReq(t, "GET", URL_METRICS, "", http.StatusOK)
+	body := rr.Body.String()
+	for _, name := range []string{
+		`hbtd_heartbeats_received_total{endpoint="heartbeat_xname",result="ok"}`,
+		`hbtd_transitions_total{type="Started"}`,
+		`hbtd_components{state="DEAD"}`,
+		`hbtd_queue_depth{queue="hsm"}`,
+		`hbtd_queue_depth{queue="telemetry"}`,
+		`hbtd_kv_op_duration_seconds_count{op="get"}`,
+		`hbtd_kv_op_duration_seconds_count{op="delete"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Metric '%s' missing from metrics output.", name)
+		}
+	}
+}
+
+// Test that a poke dropped because the HSM queue is full doesn't lose the
+// transition it was for; the pokes already queued send it.
+
+func TestPokeHSMQ(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	clearOutbox()
+	kill_sm_goroutines()
+
+	origReady := hsmReady
+	hsmReady = false
+	defer func() { hsmReady = origReady }()
+
+	for len(hsmUpdateQ) < cap(hsmUpdateQ) {
+		hsmUpdateQ <- HSMQ_NEW
+	}
+	drops := testutil.ToFloat64(mQueueDrops.WithLabelValues(QUEUE_HSM))
+	hbb := hbinfo{Component: "x3006c0s5b0n0"}
+	hb_update_notify(&hbb, HB_stopped_warn)
+	pokeHSMQ(HSMQ_NEW)
+	if v := testutil.ToFloat64(mQueueDrops.WithLabelValues(QUEUE_HSM)); v != drops+1 {
+		t.Errorf("Expected %v HSM queue drops, got %v", drops+1, v)
+	}
+
+	//Leave just one of the earlier pokes queued; it alone must carry
+	//the transition whose own poke was dropped.
+	for len(hsmUpdateQ) > 1 {
+		<-hsmUpdateQ
+	}
+	done := make(chan struct{})
+	go func() {
+		send_sm_req()
+		close(done)
+	}()
+	hsmUpdateQ <- HSMQ_DIE
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("HSM queue not drained, %d left.", len(hsmUpdateQ))
+	}
+
+	ents, _ := outboxLoad(time.Now())
+	if (len(ents) != 1) || (ents[0].Component != hbb.Component) ||
+		(ents[0].Transition != hbTransitionName(HB_stopped_warn)) {
+		t.Errorf("Transition of dropped poke lost, outbox: %v", ents)
+	}
+	clearOutbox()
+}
@@ -0,0 +1,310 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// HSM notification outbox.  HB transitions waiting to be sent to HSM are
+// kept in the KV store, one key per component holding only the transition
+// with the highest sequence number, so that if the instance that saw the
+// transition goes away before HSM accepts it, the leader will send it.
+// Entries are marked as sent once HSM accepts them and are removed some
+// time later.
+/////////////////////////////////////////////////////////////////////////////
+
+type hbOutboxEntry struct {
+	Component  string    `json:"Component"`
+	Transition string    `json:"Transition"`
+	Seq        uint64    `json:"Seq"`
+	Sent       bool      `json:"Sent"`
+	Time       time.Time `json:"Time"`            //Time queued or sent
+	Cause      string    `json:"Cause,omitempty"` //Suspected cause of an error
+
+	raw string //KV value as read, for test-and-set
+}
+
+const (
+	HBTD_OUTBOX_KEY_PRE = "hbtd_outbox-"
+	HBTD_OUTBOX_KEY_END = HBTD_OUTBOX_KEY_PRE + "~"
+
+	OUTBOX_TAS_RETRIES = 5
+)
+
+// How long an entry is kept after it has been sent.
+
+var outboxRetention = 10 * time.Minute
+
+// HB transitions in the same order as the bulk state data structures
+// created by createBSI().
+
+var outboxTransitions = []int{HB_started, HB_restarted_warn, HB_stopped_warn,
+	HB_stopped_error, HB_stopped_expected}
+
+// Global HB transition maps, same order as outboxTransitions.
+
+func hbTransitionMaps() []map[string]uint64 {
+	return []map[string]uint64{StartMap, RestartMap, StopWarnMap,
+		StopErrorMap, StopExpectedMap}
+}
+
+// Convenience function, find an HB transition's index in outboxTransitions.
+// Returns -1 if not found.
+
+func outboxTransitionIndex(name string) int {
+	for ix, to_state := range outboxTransitions {
+		if hbTransitionName(to_state) == name {
+			return ix
+		}
+	}
+	return -1
+}
+
+// Generate the next HB transition sequence number.  These are time based
+// so that transitions seen by different instances can be ordered, but
+// never repeat or go backwards within an instance.
+//
+// last(in): Last sequence number generated.
+// Return:   Next sequence number.
+
+func nextHBSeq(last uint64) uint64 {
+	now := uint64(time.Now().UnixNano())
+	if now <= last {
+		return last + 1
+	}
+	return now
+}
+
+// Generate the next sequence number for a transition going into the global
+// HB transition maps.  Must be called with hbMapLock held.
+
+func takeHBSeq() uint64 {
+	hbSeq = nextHBSeq(hbSeq)
+	return hbSeq
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Take the HB transitions out of the global HB transition maps, keeping the
+// one with the highest sequence number for each component.  Must be called
+// with hbMapLock held.
+//
+// now(in): Current time.
+// Return:  Outbox entries, one per component.
+/////////////////////////////////////////////////////////////////////////////
+
+func takePendingTransitions(now time.Time) []*hbOutboxEntry {
+	var ents []*hbOutboxEntry
+	tmaps := hbTransitionMaps()
+	comps := make(map[string]bool)
+
+	for _, tmap := range tmaps {
+		for k, v := range tmap {
+			if v != 0 {
+				comps[k] = true
+			}
+		}
+	}
+
+	for comp, _ := range comps {
+		seqs := make([]uint64, len(tmaps))
+		for ix, tmap := range tmaps {
+			seqs[ix] = tmap[comp]
+			delete(tmap, comp)
+		}
+		cause := StopErrorCauseMap[comp]
+		delete(StopErrorCauseMap, comp)
+		hix := highestSeq(seqs...)
+		if hix >= 0 {
+			ent := &hbOutboxEntry{Component: comp,
+				Transition: hbTransitionName(outboxTransitions[hix]),
+				Seq:        seqs[hix], Time: now}
+			if outboxTransitions[hix] == HB_stopped_error {
+				ent.Cause = cause
+			}
+			ents = append(ents, ent)
+		}
+	}
+
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Component < ents[j].Component
+	})
+	return ents
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Put an HB transition into the outbox, unless a later one for the same
+// component is already there.
+//
+// ent(in): Outbox entry.
+// Return:  nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func outboxPut(ent *hbOutboxEntry) error {
+	key := HBTD_OUTBOX_KEY_PRE + ent.Component
+	ba, err := json.Marshal(ent)
+	if err != nil {
+		return err
+	}
+
+	for try := 0; try < OUTBOX_TAS_RETRIES; try++ {
+		val, exists, err := kvHandle.Get(key)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if exists {
+			var cur hbOutboxEntry
+			if (json.Unmarshal([]byte(val), &cur) == nil) && (cur.Seq >= ent.Seq) {
+				return nil
+			}
+			ok, err = kvHandle.TAS(key, val, string(ba))
+		} else {
+			//Another instance may be creating it too; if so, compare
+			//against theirs on the next try.
+			ok, err = kvHandle.Create(key, string(ba))
+		}
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("outbox entry changed %d times while being updated",
+		OUTBOX_TAS_RETRIES)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Put HB transitions into the outbox.  Those that can't be stored are put
+// back into the global HB transition maps so they will be tried again.
+//
+// ents(in): Outbox entries.
+// Return:   None.
+/////////////////////////////////////////////////////////////////////////////
+
+func outboxFlush(ents []*hbOutboxEntry) {
+	for _, ent := range ents {
+		err := outboxPut(ent)
+		if err == nil {
+			continue
+		}
+
+		logKV.Error(fmt.Sprintf("Error storing HSM outbox entry for '%s', will retry: %v",
+			ent.Component, err),
+			"component", ent.Component, "transition", ent.Transition, "error", err)
+
+		tmap := hbTransitionMaps()[outboxTransitionIndex(ent.Transition)]
+		hbMapLock.Lock()
+		if tmap[ent.Component] < ent.Seq {
+			tmap[ent.Component] = ent.Seq
+			if ent.Cause != "" {
+				StopErrorCauseMap[ent.Component] = ent.Cause
+			}
+		}
+		hbMapLock.Unlock()
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Fetch the contents of the outbox.  Sent entries older than the retention
+// time are deleted rather than returned.
+//
+// now(in): Current time.
+// Return:  Outbox entries, sorted by component;
+//          nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func outboxLoad(now time.Time) ([]*hbOutboxEntry, error) {
+	var ents []*hbOutboxEntry
+
+	kvlist, err := kvHandle.GetRange(HBTD_OUTBOX_KEY_PRE, HBTD_OUTBOX_KEY_END)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range kvlist {
+		var ent hbOutboxEntry
+		err = json.Unmarshal([]byte(kv.Value), &ent)
+		if (err != nil) || (outboxTransitionIndex(ent.Transition) < 0) {
+			logKV.Error(fmt.Sprintf("Bad HSM outbox entry '%s', deleting: '%s'",
+				kv.Key, kv.Value), "key", kv.Key)
+			kvHandle.Delete(kv.Key) //ignore errors
+			continue
+		}
+		ent.raw = kv.Value
+
+		if ent.Sent && (now.Sub(ent.Time) > outboxRetention) {
+			//Only delete it if it hasn't been replaced since we read it.
+			val, exists, verr := kvHandle.Get(kv.Key)
+			if (verr == nil) && exists && (val == ent.raw) {
+				kvHandle.Delete(kv.Key) //ignore errors
+			}
+			continue
+		}
+		ents = append(ents, &ent)
+	}
+
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Component < ents[j].Component
+	})
+	return ents, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Mark outbox entries as sent.  An entry that was replaced by a later
+// transition after it was read is left alone, so the later one still gets
+// sent.
+//
+// ents(in): Outbox entries, as returned by outboxLoad().
+// now(in):  Current time.
+// Return:   None.
+/////////////////////////////////////////////////////////////////////////////
+
+func outboxMarkSent(ents []*hbOutboxEntry, now time.Time) {
+	for _, ent := range ents {
+		sent := *ent
+		sent.Sent = true
+		sent.Time = now
+		ba, err := json.Marshal(&sent)
+		if err != nil {
+			logKV.Error(fmt.Sprintf("INTERNAL ERROR marshalling HSM outbox entry for '%s': %v",
+				ent.Component, err), "component", ent.Component, "error", err)
+			continue
+		}
+		ok, err := kvHandle.TAS(HBTD_OUTBOX_KEY_PRE+ent.Component, ent.raw, string(ba))
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error marking HSM outbox entry for '%s' as sent: %v",
+				ent.Component, err),
+				"component", ent.Component, "transition", ent.Transition, "error", err)
+		} else if !ok {
+			logTrace(logHSM, fmt.Sprintf("HSM outbox entry for '%s' replaced while being sent.",
+				ent.Component), "component", ent.Component)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Remove everything from the HSM outbox, so tests don't see transitions
+// left over by other tests.
+
+func clearOutbox() {
+	kvlist, _ := kvHandle.GetRange(HBTD_OUTBOX_KEY_PRE, HBTD_OUTBOX_KEY_END)
+	for _, kv := range kvlist {
+		kvHandle.Delete(kv.Key)
+	}
+}
+
+// Test outbox collapsing, marking as sent and expiry.
+
+func TestOutbox(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	clearOutbox()
+
+	if seq := nextHBSeq(1 << 62); seq != (1<<62)+1 {
+		t.Errorf("Sequence number went backwards: %d", seq)
+	}
+
+	//Collapse of the global maps, highest sequence number wins.
+
+	hbMapLock.Lock()
+	StartMap["x3005c0s0b0n0"] = 10
+	StopWarnMap["x3005c0s0b0n0"] = 11
+	StopErrorMap["x3005c0s0b0n1"] = 12
+	ents := takePendingTransitions(time.Now())
+	nleft := len(StartMap) + len(StopWarnMap) + len(StopErrorMap)
+	hbMapLock.Unlock()
+
+	if len(ents) != 2 ||
+		ents[0].Transition != hbTransitionName(HB_stopped_warn) || ents[0].Seq != 11 ||
+		ents[1].Transition != hbTransitionName(HB_stopped_error) || ents[1].Seq != 12 {
+		t.Errorf("Unexpected pending transitions: %v %v", ents[0], ents[1])
+	}
+	if nleft != 0 {
+		t.Errorf("Global maps not emptied, %d entries left.", nleft)
+	}
+
+	//An older transition (e.g. from another instance) doesn't replace a
+	//newer one.
+
+	outboxFlush(ents)
+	outboxFlush([]*hbOutboxEntry{{Component: "x3005c0s0b0n0",
+		Transition: hbTransitionName(HB_started), Seq: 5, Time: time.Now()}})
+
+	ents, err := outboxLoad(time.Now())
+	if err != nil {
+		t.Fatalf("ERROR loading outbox: %v", err)
+	}
+	if len(ents) != 2 || ents[0].Seq != 11 || ents[0].Sent {
+		t.Fatalf("Unexpected outbox contents: %v", ents)
+	}
+
+	//Marking as sent leaves an entry alone if it was replaced since being
+	//read.
+
+	outboxFlush([]*hbOutboxEntry{{Component: "x3005c0s0b0n1",
+		Transition: hbTransitionName(HB_restarted_warn), Seq: 13, Time: time.Now()}})
+	outboxMarkSent(ents, time.Now())
+
+	ents, _ = outboxLoad(time.Now())
+	if len(ents) != 2 || !ents[0].Sent || ents[1].Sent || ents[1].Seq != 13 {
+		t.Errorf("Unexpected outbox contents after send: %v %v", ents[0], ents[1])
+	}
+
+	//Sent entries go away after the retention time.
+
+	ents, _ = outboxLoad(time.Now().Add(outboxRetention + time.Minute))
+	if len(ents) != 1 || ents[0].Component != "x3005c0s0b0n1" {
+		t.Errorf("Sent outbox entry not expired: %v", ents)
+	}
+	if _, ok, _ := kvHandle.Get(HBTD_OUTBOX_KEY_PRE + "x3005c0s0b0n0"); ok {
+		t.Errorf("Expired outbox entry not deleted.")
+	}
+
+	clearOutbox()
+}
+
+// Test that transitions noted concurrently get distinct sequence numbers.
+
+func TestHBSeqConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	for ix := 0; ix < 8; ix++ {
+		wg.Add(1)
+		go func(ix int) {
+			defer wg.Done()
+			for jx := 0; jx < 50; jx++ {
+				hbb := hbinfo{Component: fmt.Sprintf("x3005c1s%db0n%d", ix, jx)}
+				hb_notify(&hbb, HB_started, "")
+			}
+		}(ix)
+	}
+	wg.Wait()
+
+	seqs := make(map[uint64]string)
+	hbMapLock.Lock()
+	for comp, seq := range StartMap {
+		if !strings.HasPrefix(comp, "x3005c1") {
+			continue
+		}
+		if other, dup := seqs[seq]; dup {
+			t.Errorf("Duplicate sequence number %d for '%s' and '%s'.", seq, comp, other)
+		}
+		seqs[seq] = comp
+		delete(StartMap, comp)
+	}
+	hbMapLock.Unlock()
+	if len(seqs) != 400 {
+		t.Errorf("Expected 400 transitions, got %d.", len(seqs))
+	}
+}
+
+// HB store which, the first time it is asked for a missing outbox key,
+// has another instance put a later transition there before saying it is
+// missing.
+
+type outboxRaceStore struct {
+	hbStore
+	ent   hbOutboxEntry
+	raced bool
+}
+
+func (os *outboxRaceStore) Get(key string) (string, bool, error) {
+	val, ok, err := os.hbStore.Get(key)
+	if !ok && !os.raced && (key == HBTD_OUTBOX_KEY_PRE+os.ent.Component) {
+		os.raced = true
+		ba, _ := json.Marshal(&os.ent)
+		os.hbStore.Store(key, string(ba))
+	}
+	return val, ok, err
+}
+
+// Test that a transition never replaces a later one put into the outbox
+// at the same time by another instance.
+
+func TestOutboxPutRace(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	clearOutbox()
+
+	origKV := kvHandle
+	rs := &outboxRaceStore{hbStore: kvHandle,
+		ent: hbOutboxEntry{Component: "x3005c0s2b0n0",
+			Transition: hbTransitionName(HB_stopped_error), Seq: 10, Time: time.Now()}}
+	kvHandle = rs
+	err := outboxPut(&hbOutboxEntry{Component: rs.ent.Component,
+		Transition: hbTransitionName(HB_started), Seq: 5, Time: time.Now()})
+	kvHandle = origKV
+
+	if err != nil {
+		t.Errorf("Error putting outbox entry: %v", err)
+	}
+	ents, _ := outboxLoad(time.Now())
+	if (len(ents) != 1) || (ents[0].Seq != 10) ||
+		(ents[0].Transition != rs.ent.Transition) {
+		t.Errorf("Later outbox entry replaced by an earlier one: %v", ents)
+	}
+
+	clearOutbox()
+}
+
+// Test that transitions left in the outbox by another instance are sent,
+// by the leader only.
+
+func TestOutboxDrain(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	clearOutbox()
+
+	startSMReq(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(fakeHSMPatchHandler))
+	defer srv.Close()
+
+	htrans.transport = &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	htrans.client = &http.Client{Transport: htrans.transport,
+		Timeout: (20 * time.Second),
+	}
+	app_params.statemgr_url.string_param = srv.URL
+	app_params.statemgr_timeout.int_param = 5
+	app_params.nosm.int_param = 0
+	testMode = true
+	hsmReady = true
+
+	compLock.Lock()
+	stopErrorComps = []string{}
+	compLock.Unlock()
+
+	origInterval := app_params.check_interval.int_param
+	app_params.check_interval.int_param = 3600
+	hbElectLock.Lock()
+	hbElect = &stubElection{leader: false}
+	hbElectLock.Unlock()
+	defer func() {
+		hbElectLock.Lock()
+		hbElect = nil
+		hbElectLock.Unlock()
+		app_params.check_interval.int_param = origInterval
+	}()
+
+	ent := hbOutboxEntry{Component: "x3005c0s1b0n0",
+		Transition: hbTransitionName(HB_stopped_error), Seq: 1, Time: time.Now()}
+	ba, _ := json.Marshal(&ent)
+	kvHandle.Store(HBTD_OUTBOX_KEY_PRE+ent.Component, string(ba))
+
+	//Not the leader, not sent.
+
+	setSMRVal(http.StatusOK)
+	hsmUpdateQ <- HSMQ_NEW
+	time.Sleep(time.Second)
+
+	compLock.Lock()
+	if len(stopErrorComps) != 0 {
+		t.Errorf("Outbox entry sent to HSM by non-leader: %v", stopErrorComps)
+	}
+	compLock.Unlock()
+
+	hbElectLock.Lock()
+	hbElect = &stubElection{leader: true}
+	hbElectLock.Unlock()
+
+	//HSM failure, stays in the outbox.
+
+	setSMRVal(http.StatusServiceUnavailable)
+	hsmUpdateQ <- HSMQ_NEW
+	time.Sleep(time.Second)
+
+	ents, _ := outboxLoad(time.Now())
+	if len(ents) != 1 || ents[0].Sent {
+		t.Fatalf("Outbox entry lost or marked sent after HSM failure: %v", ents)
+	}
+
+	setSMRVal(http.StatusOK)
+	hsmUpdateQ <- HSMQ_NEW
+	time.Sleep(time.Second)
+
+	compLock.Lock()
+	if len(stopErrorComps) != 1 || stopErrorComps[0] != ent.Component {
+		t.Errorf("Outbox entry not sent to HSM, got: %v", stopErrorComps)
+	}
+	compLock.Unlock()
+
+	ents, _ = outboxLoad(time.Now())
+	if len(ents) != 1 || !ents[0].Sent {
+		t.Errorf("Outbox entry not marked as sent: %v", ents)
+	}
+
+	clearOutbox()
+}
@@ -0,0 +1,212 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Parameter change history.  Every change to the parameters, through
+// either params API or a reload of the parameter file, is recorded in the
+// KV store under its revision number, along with who asked for it and
+// which instance made it.  Entries are never changed or removed.  Each
+// change is also sent to the telemetry bus.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	HBTD_PARAMHIST_KEY_PRE = "hbtd_paramhist-"
+	HBTD_PARAMHIST_KEY_END = HBTD_PARAMHIST_KEY_PRE + "~"
+
+	PARAMS_MESSAGE_ID = "Heartbeat Parameter Change"
+
+	PARAM_SRC_RELOAD = "SIGHUP"
+)
+
+type paramChange struct {
+	Parameter string `json:"Parameter"`
+	Old       string `json:"Old"`
+	New       string `json:"New"`
+}
+
+type paramHistEntry struct {
+	Revision     int           `json:"Revision"`
+	Time         time.Time     `json:"Time"`
+	Instance     string        `json:"Instance"`     //Life key
+	InstanceName string        `json:"InstanceName"` //Pod name
+	Source       string        `json:"Source"`       //Request, or SIGHUP
+	UserAgent    string        `json:"UserAgent,omitempty"`
+	RemoteAddr   string        `json:"RemoteAddr,omitempty"`
+	ForwardedFor string        `json:"ForwardedFor,omitempty"`
+	Changes      []paramChange `json:"Changes"`
+}
+
+type paramHistList struct {
+	History []paramHistEntry `json:"History"`
+}
+
+// History key for a revision.  Zero padded so keys sort by revision.
+
+func paramHistKey(rev int) string {
+	return fmt.Sprintf("%s%010d", HBTD_PARAMHIST_KEY_PRE, rev)
+}
+
+// Compare two sets of parameters.
+//
+// old(in): Parameters before the change.
+// cur(in): Parameters after the change.
+// Return:  Parameters that differ, in inidata order.
+
+func diffParams(old, cur inidata) []paramChange {
+	var changes []paramChange
+
+	ov := reflect.ValueOf(old)
+	cv := reflect.ValueOf(cur)
+	mtype := ov.Type()
+	for i := 0; i < mtype.NumField(); i++ {
+		oval := ov.Field(i).String()
+		cval := cv.Field(i).String()
+		if oval != cval {
+			nm := strings.Split(mtype.Field(i).Tag.Get("json"), ",")[0]
+			changes = append(changes, paramChange{Parameter: nm, Old: oval, New: cval})
+		}
+	}
+	return changes
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Record a parameter change in the history and send it to the telemetry
+// bus.  Called with paramLock held, after the new parameters have been
+// stored.  Nothing is recorded if no parameters changed.
+//
+// old(in): Parameters before the change.
+// src(in): What made the change, e.g. "PATCH /hmi/v1/params".
+// r(in):   Request that made the change, nil if not from a request.
+// Return:  None.
+/////////////////////////////////////////////////////////////////////////////
+
+func recordParamChange(old inidata, src string, r *http.Request) {
+	changes := diffParams(old, cur_param_data())
+	if len(changes) == 0 {
+		return
+	}
+
+	instanceLock.Lock()
+	ent := paramHistEntry{Revision: paramRevision, Time: time.Now(),
+		Instance: thisInstance.ID, InstanceName: thisInstance.Name,
+		Source: src, Changes: changes}
+	instanceLock.Unlock()
+	if r != nil {
+		ent.UserAgent = r.UserAgent()
+		ent.RemoteAddr = r.RemoteAddr
+		ent.ForwardedFor = r.Header.Get("X-Forwarded-For")
+	}
+
+	var chstr []string
+	for _, ch := range changes {
+		chstr = append(chstr, fmt.Sprintf("%s '%s' -> '%s'", ch.Parameter, ch.Old, ch.New))
+	}
+	who := src
+	if r != nil {
+		who = fmt.Sprintf("%s from %s (%s)", src, ent.RemoteAddr, ent.UserAgent)
+	}
+	info := fmt.Sprintf("Parameters changed to revision %d by %s on %s: %s",
+		ent.Revision, who, ent.InstanceName, strings.Join(chstr, ", "))
+	logMain.Info(info, "revision", ent.Revision, "source", src)
+
+	//The history is append-only; never replace an entry already there.
+
+	ok := false
+	ba, err := json.Marshal(&ent)
+	if err == nil {
+		ok, err = kvHandle.Create(paramHistKey(ent.Revision), string(ba))
+	}
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error recording parameter change, revision %d: %v",
+			ent.Revision, err), "revision", ent.Revision, "error", err)
+	} else if !ok {
+		logKV.Error(fmt.Sprintf("Parameter history already has revision %d, change not recorded.",
+			ent.Revision), "revision", ent.Revision)
+	}
+
+	telemsg := telemetry_json_v1{MessageID: PARAMS_MESSAGE_ID,
+		Id: ent.Instance, Info: info}
+	select {
+	case telemetryQ <- telemsg:
+	default:
+		mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+		logTelemetry.Info("Telemetry bus not accepting messages, parameter change not sent.",
+			"revision", ent.Revision)
+	}
+}
+
+// Get the parameter change history, oldest first.
+
+func getParamHistory() ([]paramHistEntry, error) {
+	kvlist, err := kvHandle.GetRange(HBTD_PARAMHIST_KEY_PRE, HBTD_PARAMHIST_KEY_END)
+	if err != nil {
+		return nil, err
+	}
+
+	hist := make([]paramHistEntry, 0, len(kvlist))
+	for _, kv := range kvlist {
+		var ent paramHistEntry
+		if umerr := json.Unmarshal([]byte(kv.Value), &ent); umerr != nil {
+			logKV.Error(fmt.Sprintf("Error unmarshalling parameter history entry '%s': %v", kv.Key, umerr),
+				"key", kv.Key, "error", umerr)
+			continue
+		}
+		hist = append(hist, ent)
+	}
+	sort.Slice(hist, func(i, j int) bool { return hist[i].Revision < hist[j].Revision })
+	return hist, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/params/history
+/////////////////////////////////////////////////////////////////////////////
+
+func paramsHistoryIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_PARAMS_HIST
+
+	hist, err := getParamHistory()
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GET operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	sendJSON(w, http.StatusOK, &paramHistList{History: hist}, errinst)
+}
@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Drain the telemetry queue.
+
+func drainTelemetryQ() []telemetry_json_v1 {
+	var msgs []telemetry_json_v1
+
+	for {
+		select {
+		case msg := <-telemetryQ:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+// PATCH parameters as a client would.
+
+func patchParams(t *testing.T, url, body string, code int) {
+	req, _ := http.NewRequest("PATCH", "http://localhost"+url, bytes.NewBufferString(body))
+	req.Header.Set("User-Agent", "paramhist-test/1.0")
+	req.Header.Set("X-Forwarded-For", "10.1.2.3")
+	req.RemoteAddr = "192.168.0.9:40000"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != code {
+		t.Errorf("PATCH %s: expected status %d, got %d (%s)", url, code, rr.Code,
+			rr.Body.String())
+	}
+}
+
+func clearParamHistory() {
+	kvlist, _ := kvHandle.GetRange(HBTD_PARAMHIST_KEY_PRE, HBTD_PARAMHIST_KEY_END)
+	for _, kv := range kvlist {
+		kvHandle.Delete(kv.Key)
+	}
+}
+
+// Test the parameter change history.
+
+func TestParamHistory(t *testing.T) {
+	var hlist paramHistList
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	origParams := app_params
+	initAppParams()
+	kvHandle.Delete(KV_PARAM_KEY)
+	clearParamHistory()
+	paramSynced = false
+	paramRevision = 0
+	initInstance(HBTD_LIFE_KEY_PRE + "201")
+	drainTelemetryQ()
+	defer func() {
+		app_params = origParams
+		paramSynced = false
+		paramRevision = 0
+		configFile = ""
+		cmdlineParams = op_params{}
+		kvHandle.Delete(KV_PARAM_KEY)
+		clearParamHistory()
+		applyLogParams()
+	}()
+
+	rr := policyReq(t, "GET", URL_PARAMS_HIST, "", http.StatusOK)
+	json.Unmarshal(rr.Body.Bytes(), &hlist)
+	if len(hlist.History) != 0 {
+		t.Errorf("Expected empty history, got %v", hlist.History)
+	}
+
+	//Changes through both APIs, a change that doesn't change anything, and
+	//one that fails.
+
+	patchParams(t, URL_PARAMS, `{"Errtime":"60","Warntime":"20"}`, http.StatusOK)
+	patchParams(t, URL_PARAMS, `{"Errtime":"60"}`, http.StatusOK)
+	patchParams(t, URL_PARAMS_V2, `{"Errtime":10}`, http.StatusBadRequest)
+	patchParams(t, URL_PARAMS_V2, `{"Errtime":90,"Use_telemetry":false}`, http.StatusOK)
+
+	//A reload of the parameter file
+
+	configFile = filepath.Join(t.TempDir(), "hbtd.yaml")
+	cmdlineParams = unsetCmdline()
+	writeConfig(t, configFile, "warntime: 15\nerrtime: 45\n")
+	reloadParams()
+
+	rr = policyReq(t, "GET", URL_PARAMS_HIST, "", http.StatusOK)
+	hlist = paramHistList{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &hlist); err != nil {
+		t.Fatalf("Can't unmarshal history: %v", err)
+	}
+	if len(hlist.History) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d: %s", len(hlist.History),
+			rr.Body.String())
+	}
+
+	ent := hlist.History[0]
+	if (ent.Revision != 1) || (ent.Source != "PATCH "+URL_PARAMS) ||
+		(ent.UserAgent != "paramhist-test/1.0") || (ent.RemoteAddr != "192.168.0.9:40000") ||
+		(ent.ForwardedFor != "10.1.2.3") || (ent.Instance != HBTD_LIFE_KEY_PRE+"201") ||
+		ent.Time.IsZero() {
+		t.Errorf("Unexpected first history entry: %+v", ent)
+	}
+	exp := []paramChange{{"Warntime", "10", "20"}, {"Errtime", "30", "60"}}
+	if (len(ent.Changes) != 2) || (ent.Changes[0] != exp[0]) || (ent.Changes[1] != exp[1]) {
+		t.Errorf("Expected changes %v, got %v", exp, ent.Changes)
+	}
+
+	ent = hlist.History[1]
+	exp = []paramChange{{"Use_telemetry", "1", "0"}, {"Errtime", "60", "90"}}
+	if (ent.Revision != 3) || (ent.Source != "PATCH "+URL_PARAMS_V2) ||
+		(len(ent.Changes) != 2) || (ent.Changes[0] != exp[0]) || (ent.Changes[1] != exp[1]) {
+		t.Errorf("Unexpected second history entry: %+v", ent)
+	}
+
+	//The reload starts from the defaults, so it turns telemetry back on.
+
+	ent = hlist.History[2]
+	if (ent.Revision != 4) || (ent.Source != PARAM_SRC_RELOAD) || (ent.UserAgent != "") ||
+		(len(ent.Changes) != 3) {
+		t.Errorf("Unexpected reload history entry: %+v", ent)
+	}
+
+	msgs := drainTelemetryQ()
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 telemetry messages, got %d", len(msgs))
+	}
+	if (msgs[0].MessageID != PARAMS_MESSAGE_ID) ||
+		!strings.Contains(msgs[0].Info, "Errtime '30' -> '60'") ||
+		!strings.Contains(msgs[0].Info, "paramhist-test/1.0") {
+		t.Errorf("Unexpected telemetry message: %+v", msgs[0])
+	}
+
+	//An existing history entry is never replaced.
+
+	paramLock.Lock()
+	oldParams := cur_param_data()
+	app_params.warntime.int_param = 25
+	paramRevision = 1
+	recordParamChange(oldParams, "test", nil)
+	paramLock.Unlock()
+	drainTelemetryQ()
+
+	val, _, _ := kvHandle.Get(paramHistKey(1))
+	ent = paramHistEntry{}
+	json.Unmarshal([]byte(val), &ent)
+	if ent.Source != "PATCH "+URL_PARAMS {
+		t.Errorf("History entry for revision 1 replaced: %+v", ent)
+	}
+}
@@ -0,0 +1,440 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Typed parameters (/hmi/v2/params).  Unlike /hmi/v1/params, parameter
+// values are native JSON types.  Every parameter is described by a schema
+// entry giving its type, range, default and whether it can be changed at
+// runtime, and there are cross-field rules between some of them.  The
+// schema is published at /hmi/v2/params/schema, and a PATCH is checked
+// against all of it, reporting every problem found in one response.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	PARAM_TYPE_INTEGER = "integer"
+	PARAM_TYPE_BOOLEAN = "boolean"
+	PARAM_TYPE_STRING  = "string"
+)
+
+type paramDef struct {
+	Name        string      `json:"Name"`
+	Type        string      `json:"Type"`
+	Minimum     *int        `json:"Minimum,omitempty"`
+	Maximum     *int        `json:"Maximum,omitempty"`
+	Default     interface{} `json:"Default"`
+	Mutable     bool        `json:"Mutable"`
+	Description string      `json:"Description"`
+
+	param  func(p *op_params) *app_param //Where the value is kept
+	strInt bool                          //Integer kept in string_param
+	check  func(val string) (string, error)
+}
+
+// Cross-field rule: Parameter's value must be less than LessThan's.
+
+type paramRule struct {
+	Parameter string `json:"Parameter"`
+	LessThan  string `json:"LessThan"`
+}
+
+type paramSchema struct {
+	Parameters []paramDef  `json:"Parameters"`
+	Rules      []paramRule `json:"Rules"`
+}
+
+// Validation failure response, with each problem listed separately.
+
+type paramsProblem struct {
+	base.ProblemDetails
+	Errors []string `json:"errors"`
+}
+
+func intp(val int) *int {
+	return &val
+}
+
+var paramDefs = []paramDef{
+	{Name: "Debug", Type: PARAM_TYPE_INTEGER, Minimum: intp(0), Mutable: true,
+		Description: "Debug logging level.",
+		param:       func(p *op_params) *app_param { return &p.debug_level }},
+	{Name: "Nosm", Type: PARAM_TYPE_BOOLEAN,
+		Description: "Don't contact HSM (testing only).",
+		param:       func(p *op_params) *app_param { return &p.nosm }},
+	{Name: "Use_telemetry", Type: PARAM_TYPE_BOOLEAN, Mutable: true,
+		Description: "Send notifications to the telemetry bus.",
+		param:       func(p *op_params) *app_param { return &p.use_telemetry }},
+	{Name: "Telemetry_host", Type: PARAM_TYPE_STRING,
+		Description: "Telemetry bus host:port:topic.",
+		param:       func(p *op_params) *app_param { return &p.telemetry_host },
+		check: func(val string) (string, error) {
+			_, _, _, err := get_telemetry_host(val)
+			return val, err
+		}},
+	{Name: "Warntime", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Seconds without a heartbeat before a warning.",
+		param:       func(p *op_params) *app_param { return &p.warntime }},
+	{Name: "Errtime", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Seconds without a heartbeat before an alert.",
+		param:       func(p *op_params) *app_param { return &p.errtime }},
+	{Name: "Port", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Maximum: intp(65535),
+		Description: "Port the service listens on.",
+		param:       func(p *op_params) *app_param { return &p.port }, strInt: true},
+	{Name: "Kv_url", Type: PARAM_TYPE_STRING,
+		Description: "K/V store URL.",
+		param:       func(p *op_params) *app_param { return &p.kv_url }},
+	{Name: "Interval", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Seconds between heartbeat audits.",
+		param:       func(p *op_params) *app_param { return &p.check_interval }},
+	{Name: "Sm_url", Type: PARAM_TYPE_STRING, Mutable: true,
+		Description: "HSM base URL.",
+		param:       func(p *op_params) *app_param { return &p.statemgr_url }},
+	{Name: "Sm_timeout", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "HSM request timeout in seconds.",
+		param:       func(p *op_params) *app_param { return &p.statemgr_timeout }},
+	{Name: "Sm_retries", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "HSM request retries.",
+		param:       func(p *op_params) *app_param { return &p.statemgr_retries }},
+	{Name: "Reconcile_interval", Type: PARAM_TYPE_INTEGER, Minimum: intp(0), Mutable: true,
+		Description: "Seconds between HSM reconciliation runs, 0 == never.",
+		param:       func(p *op_params) *app_param { return &p.reconcile_interval }},
+	{Name: "Hb_history", Type: PARAM_TYPE_INTEGER, Minimum: intp(0),
+		Maximum: intp(HB_HISTORY_MAX), Mutable: true,
+		Description: "Heartbeats kept per component for /hbhistory, 0 == none.",
+		param:       func(p *op_params) *app_param { return &p.hb_history }},
+	{Name: "Flap_count", Type: PARAM_TYPE_INTEGER, Minimum: intp(0), Mutable: true,
+		Description: "Warnings and restarts within Flap_window marking a component as flapping, 0 == no flap detection.",
+		param:       func(p *op_params) *app_param { return &p.flap_count }},
+	{Name: "Flap_window", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Flap detection window, in seconds.",
+		param:       func(p *op_params) *app_param { return &p.flap_window }},
+	{Name: "Flap_settle", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Seconds without warnings or restarts before a component is no longer flapping.",
+		param:       func(p *op_params) *app_param { return &p.flap_settle }},
+	{Name: "Topo_threshold", Type: PARAM_TYPE_INTEGER, Minimum: intp(0),
+		Maximum: intp(100), Mutable: true,
+		Description: "Percent of the components under a slot, chassis or cabinet declared dead within Topo_window for it to be the suspected cause, 0 == no topology correlation.",
+		param:       func(p *op_params) *app_param { return &p.topo_threshold }},
+	{Name: "Topo_window", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Topology correlation window, in seconds.",
+		param:       func(p *op_params) *app_param { return &p.topo_window }},
+	{Name: "Topo_tag", Type: PARAM_TYPE_BOOLEAN, Mutable: true,
+		Description: "Note the suspected cause found by topology correlation in HSM messages.",
+		param:       func(p *op_params) *app_param { return &p.topo_tag }},
+	{Name: "Inventory_interval", Type: PARAM_TYPE_INTEGER, Minimum: intp(0), Mutable: true,
+		Description: "Seconds between syncs of the components expected to heartbeat with HSM, 0 == never.",
+		param:       func(p *op_params) *app_param { return &p.inventory_interval }},
+	{Name: "Inventory_grace", Type: PARAM_TYPE_INTEGER, Minimum: intp(1), Mutable: true,
+		Description: "Seconds for a component expected to heartbeat to start before it is reported as never started.",
+		param:       func(p *op_params) *app_param { return &p.inventory_grace }},
+	{Name: "Inventory_roles", Type: PARAM_TYPE_STRING, Mutable: true,
+		Description: "HSM roles of the nodes expected to heartbeat, comma separated.",
+		param:       func(p *op_params) *app_param { return &p.inventory_roles }},
+	{Name: "Grpc_port", Type: PARAM_TYPE_INTEGER, Minimum: intp(0), Maximum: intp(65535),
+		Description: "Port the gRPC heartbeat service listens on, 0 == no gRPC.",
+		param:       func(p *op_params) *app_param { return &p.grpc_port }},
+	{Name: "Log_levels", Type: PARAM_TYPE_STRING, Mutable: true,
+		Description: "Per-subsystem log levels, e.g. 'info,checker=debug'.",
+		param:       func(p *op_params) *app_param { return &p.log_levels },
+		check: func(val string) (string, error) {
+			_, norm, err := parseLogLevels(val)
+			return norm, err
+		}},
+	{Name: "Log_format", Type: PARAM_TYPE_STRING, Mutable: true,
+		Description: "Log output format, 'text' or 'json'.",
+		param:       func(p *op_params) *app_param { return &p.log_format },
+		check:       parseLogFormat},
+}
+
+var paramRules = []paramRule{
+	{Parameter: "Warntime", LessThan: "Errtime"},
+	{Parameter: "Interval", LessThan: "Warntime"},
+}
+
+func init() {
+	defs := defaultParams()
+	for ix := range paramDefs {
+		paramDefs[ix].Default = paramDefs[ix].value(&defs)
+	}
+}
+
+// Find a parameter's schema entry.  Names are matched without regard to
+// case, as in /hmi/v1/params.
+
+func findParamDef(name string) *paramDef {
+	for ix := range paramDefs {
+		if strings.EqualFold(paramDefs[ix].Name, name) {
+			return &paramDefs[ix]
+		}
+	}
+	return nil
+}
+
+// Get a parameter's value as its schema type.
+
+func (def *paramDef) value(p *op_params) interface{} {
+	ap := def.param(p)
+	switch def.Type {
+	case PARAM_TYPE_BOOLEAN:
+		return ap.int_param != 0
+	case PARAM_TYPE_STRING:
+		return ap.string_param
+	}
+	if def.strInt {
+		ival, _ := strconv.Atoi(ap.string_param)
+		return ival
+	}
+	return ap.int_param
+}
+
+// Decode, check and set a parameter from its JSON value.
+//
+// p(out):   Parameters to set it in.
+// raw(in):  JSON value.
+// Return:   nil on success, else error describing the problem.
+
+func (def *paramDef) set(p *op_params, raw json.RawMessage) error {
+	ap := def.param(p)
+
+	switch def.Type {
+	case PARAM_TYPE_BOOLEAN:
+		var bval bool
+		if (string(raw) == "null") || (json.Unmarshal(raw, &bval) != nil) {
+			return fmt.Errorf("Parameter '%s' must be a boolean", def.Name)
+		}
+		ap.int_param = 0
+		if bval {
+			ap.int_param = 1
+		}
+		return nil
+
+	case PARAM_TYPE_STRING:
+		var sval string
+		if (string(raw) == "null") || (json.Unmarshal(raw, &sval) != nil) {
+			return fmt.Errorf("Parameter '%s' must be a string", def.Name)
+		}
+		if (def.check != nil) && (sval != "") {
+			norm, err := def.check(sval)
+			if err != nil {
+				return fmt.Errorf("Parameter '%s' has invalid value '%s'", def.Name, sval)
+			}
+			sval = norm
+		}
+		ap.string_param = sval
+		return nil
+	}
+
+	var ival int
+	if (string(raw) == "null") || (json.Unmarshal(raw, &ival) != nil) {
+		return fmt.Errorf("Parameter '%s' must be an integer", def.Name)
+	}
+	if (def.Minimum != nil) && (ival < *def.Minimum) {
+		return fmt.Errorf("Parameter '%s' must be at least %d", def.Name, *def.Minimum)
+	}
+	if (def.Maximum != nil) && (ival > *def.Maximum) {
+		return fmt.Errorf("Parameter '%s' must be at most %d", def.Name, *def.Maximum)
+	}
+	if def.strInt {
+		ap.string_param = strconv.Itoa(ival)
+	} else {
+		ap.int_param = ival
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Check a typed parameter PATCH against the schema and apply it to a copy
+// of the current parameters.  Cross-field rules are checked when the PATCH
+// sets either of their parameters.
+//
+// body(in): PATCH payload.
+// cur(in):  Current parameters.
+// Return:   Patched parameters; every problem found, empty if none.
+/////////////////////////////////////////////////////////////////////////////
+
+func patchParamsV2(body []byte, cur op_params) (op_params, []string) {
+	var patch map[string]json.RawMessage
+	var errs []string
+
+	np := cur
+	err := json.Unmarshal(body, &patch)
+	if err != nil {
+		return np, []string{fmt.Sprintf("Invalid JSON: %v", err)}
+	}
+
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := make(map[string]bool)
+	bad := make(map[string]bool)
+	for _, name := range names {
+		def := findParamDef(name)
+		if def == nil {
+			errs = append(errs, fmt.Sprintf("Unknown parameter '%s'", name))
+			continue
+		}
+		err = def.set(&np, patch[name])
+		if err != nil {
+			errs = append(errs, err.Error())
+			bad[def.Name] = true
+			continue
+		}
+		if !def.Mutable && (*def.param(&np) != *def.param(&cur)) {
+			errs = append(errs, fmt.Sprintf("Parameter '%s' can't be changed at runtime",
+				def.Name))
+			bad[def.Name] = true
+			continue
+		}
+		set[def.Name] = true
+	}
+
+	for _, rule := range paramRules {
+		if bad[rule.Parameter] || bad[rule.LessThan] ||
+			(!set[rule.Parameter] && !set[rule.LessThan]) {
+			continue
+		}
+		lval := findParamDef(rule.Parameter).value(&np).(int)
+		gval := findParamDef(rule.LessThan).value(&np).(int)
+		if lval >= gval {
+			errs = append(errs, fmt.Sprintf("%s (%d) must be less than %s (%d)",
+				rule.Parameter, lval, rule.LessThan, gval))
+		}
+	}
+
+	return np, errs
+}
+
+// Generate the typed parameter response: the current parameters, their
+// revision and the revision each running instance has.
+
+func paramsV2Response() map[string]interface{} {
+	rsp := make(map[string]interface{})
+	cur := app_params
+	for ix := range paramDefs {
+		rsp[paramDefs[ix].Name] = paramDefs[ix].value(&cur)
+	}
+
+	v1 := paramsResponse()
+	rsp["Revision"] = v1.Revision
+	if len(v1.Instances) > 0 {
+		rsp["Instances"] = v1.Instances
+	}
+	return rsp
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET and PATCH /hmi/v2/params
+/////////////////////////////////////////////////////////////////////////////
+
+func paramsV2IO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_PARAMS_V2
+
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, http.StatusOK, paramsV2Response(), errinst)
+
+	case http.MethodPatch:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			pdet := base.NewProblemDetails("about:blank",
+				"Invalid Request",
+				"Error reading inbound request",
+				errinst, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+
+		paramLock.Lock()
+		np, errs := patchParamsV2(body, app_params)
+		if len(errs) > 0 {
+			paramLock.Unlock()
+			logMain.Error(fmt.Sprintf("Error validating parameters: '%s'", strings.Join(errs, "; ")),
+				"errors", errs)
+			prob := paramsProblem{Errors: errs}
+			prob.ProblemDetails = *base.NewProblemDetails("about:blank",
+				"Invalid Request",
+				strings.Join(errs, "; "),
+				errinst, http.StatusBadRequest)
+			w.Header().Set("Content-Type", base.ProblemDetailContentType)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&prob)
+			return
+		}
+
+		oldParams := cur_param_data()
+		app_params = np
+		applyLogParams()
+		_, err = paramStore()
+		if err == nil {
+			recordParamChange(oldParams, "PATCH "+URL_PARAMS_V2, r)
+		}
+		paramLock.Unlock()
+		if err != nil {
+			logKV.Error(fmt.Sprintf("INTERNAL ERROR storing KV params value: %v", err),
+				"key", KV_PARAM_KEY, "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service STORE operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		sendJSON(w, http.StatusOK, paramsV2Response(), errinst)
+
+	default:
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Only PATCH and GET operations supported",
+			errinst, http.StatusMethodNotAllowed)
+		w.Header().Add("Allow", "GET,PATCH")
+		base.SendProblemDetails(w, pdet, 0)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v2/params/schema
+/////////////////////////////////////////////////////////////////////////////
+
+func paramsSchemaIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	sendJSON(w, http.StatusOK, &paramSchema{Parameters: paramDefs, Rules: paramRules},
+		URL_PARAMS_SCHEMA)
+}
@@ -0,0 +1,196 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the typed parameters API and its schema.
+
+func TestParamsV2IO(t *testing.T) {
+	var schema paramSchema
+	var rsp map[string]interface{}
+	var prob paramsProblem
+	var doc paramsDoc
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	origParams := app_params
+	initAppParams()
+	kvHandle.Delete(KV_PARAM_KEY)
+	paramSynced = false
+	paramRevision = 0
+	defer func() {
+		app_params = origParams
+		paramSynced = false
+		paramRevision = 0
+		kvHandle.Delete(KV_PARAM_KEY)
+		applyLogParams()
+	}()
+
+	//Schema
+
+	rr := policyReq(t, "GET", URL_PARAMS_SCHEMA, "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("Can't unmarshal schema: %v", err)
+	}
+	if len(schema.Parameters) != len(paramDefs) {
+		t.Errorf("Expected %d parameters in schema, got %d", len(paramDefs),
+			len(schema.Parameters))
+	}
+	for _, def := range schema.Parameters {
+		switch def.Name {
+		case "Warntime":
+			if (def.Type != PARAM_TYPE_INTEGER) || (def.Default != float64(10)) ||
+				!def.Mutable || (def.Minimum == nil) || (*def.Minimum != 1) {
+				t.Errorf("Unexpected Warntime schema: %v", def)
+			}
+		case "Port":
+			if def.Mutable || (def.Maximum == nil) || (*def.Maximum != 65535) ||
+				(def.Default != float64(28500)) {
+				t.Errorf("Unexpected Port schema: %v", def)
+			}
+		case "Use_telemetry":
+			if (def.Type != PARAM_TYPE_BOOLEAN) || (def.Default != true) {
+				t.Errorf("Unexpected Use_telemetry schema: %v", def)
+			}
+		}
+	}
+	if len(schema.Rules) != 2 {
+		t.Errorf("Expected 2 rules, got %v", schema.Rules)
+	}
+
+	//Current values, as native types
+
+	rr = policyReq(t, "GET", URL_PARAMS_V2, "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &rsp); err != nil {
+		t.Fatalf("Can't unmarshal parameters: %v", err)
+	}
+	if (rsp["Warntime"] != float64(10)) || (rsp["Use_telemetry"] != true) ||
+		(rsp["Port"] != float64(28500)) || (rsp["Sm_url"] != SM_URL_BASE) {
+		t.Errorf("Unexpected parameters: %v", rsp)
+	}
+
+	//A good PATCH is applied and stored.  Unchanged read-only parameters
+	//can be sent back.
+
+	rr = policyReq(t, "PATCH", URL_PARAMS_V2,
+		`{"Warntime":20,"errtime":60,"Use_telemetry":false,"Port":28500,"Log_levels":"info"}`,
+		http.StatusOK)
+	if (app_params.warntime.int_param != 20) || (app_params.errtime.int_param != 60) ||
+		(app_params.use_telemetry.int_param != 0) {
+		t.Errorf("PATCH not applied: %d/%d/%d", app_params.warntime.int_param,
+			app_params.errtime.int_param, app_params.use_telemetry.int_param)
+	}
+	rsp = nil
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if (rsp["Warntime"] != float64(20)) || (rsp["Revision"] != float64(1)) {
+		t.Errorf("Unexpected PATCH response: %v", rsp)
+	}
+	val, _, _ := kvHandle.Get(KV_PARAM_KEY)
+	json.Unmarshal([]byte(val), &doc)
+	if (doc.Revision != 1) || (doc.Warntime != "20") || (doc.Errtime != "60") {
+		t.Errorf("Unexpected stored parameters: %s", val)
+	}
+
+	//All problems are reported at once and nothing is applied.
+
+	before := app_params
+	rr = policyReq(t, "PATCH", URL_PARAMS_V2,
+		`{"Warntime":"25","Errtime":-1,"Bogus":1,"Port":1234,"Nosm":1,`+
+			`"Log_format":"xml","Sm_retries":2.5,"Debug":3}`,
+		http.StatusBadRequest)
+	if err := json.Unmarshal(rr.Body.Bytes(), &prob); err != nil {
+		t.Fatalf("Can't unmarshal problem: %v", err)
+	}
+	exps := []string{"Unknown parameter 'Bogus'",
+		"Parameter 'Errtime' must be at least 1",
+		"Parameter 'Log_format' has invalid value 'xml'",
+		"Parameter 'Nosm' must be a boolean",
+		"Parameter 'Port' can't be changed at runtime",
+		"Parameter 'Sm_retries' must be an integer",
+		"Parameter 'Warntime' must be an integer"}
+	if strings.Join(prob.Errors, "\n") != strings.Join(exps, "\n") {
+		t.Errorf("Expected errors:\n%s\ngot:\n%s", strings.Join(exps, "\n"),
+			strings.Join(prob.Errors, "\n"))
+	}
+	if prob.Detail != strings.Join(exps, "; ") {
+		t.Errorf("Unexpected problem detail: '%s'", prob.Detail)
+	}
+	if app_params != before {
+		t.Errorf("Parameters changed by bad PATCH.")
+	}
+
+	//Cross-field rules
+
+	tests := []struct {
+		body string
+		err  string
+	}{
+		{`{"Warntime":70}`, "Warntime (70) must be less than Errtime (60)"},
+		{`{"Errtime":20}`, "Warntime (20) must be less than Errtime (20)"},
+		{`{"Interval":25}`, "Interval (25) must be less than Warntime (20)"},
+	}
+	for _, tt := range tests {
+		prob = paramsProblem{}
+		rr = policyReq(t, "PATCH", URL_PARAMS_V2, tt.body, http.StatusBadRequest)
+		json.Unmarshal(rr.Body.Bytes(), &prob)
+		if (len(prob.Errors) != 1) || (prob.Errors[0] != tt.err) {
+			t.Errorf("%s: expected error '%s', got %v", tt.body, tt.err, prob.Errors)
+		}
+	}
+	policyReq(t, "PATCH", URL_PARAMS_V2, `{"Warntime":70,"Errtime":80}`, http.StatusOK)
+
+	//Rules aren't checked against parameters the PATCH doesn't touch.
+
+	app_params.check_interval.int_param = 100
+	policyReq(t, "PATCH", URL_PARAMS_V2, `{"Debug":1}`, http.StatusOK)
+
+	rr = policyReq(t, "PATCH", URL_PARAMS_V2, `[1]`, http.StatusBadRequest)
+	if !strings.Contains(rr.Body.String(), "Invalid JSON") {
+		t.Errorf("Unexpected response to bad JSON: %s", rr.Body.String())
+	}
+
+	req, _ := http.NewRequest("POST", "http://localhost"+URL_PARAMS_V2,
+		bytes.NewBufferString("{}"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(paramsV2IO).ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST %s: expected status %d, got %d", URL_PARAMS_V2,
+			http.StatusMethodNotAllowed, rr.Code)
+	}
+}
@@ -0,0 +1,240 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Parameter synchronization.  PATCH /params stores the full set of
+// parameters in KV_PARAM_KEY along with a revision number, which goes up
+// by one with each change.  Every instance loads the stored parameters at
+// startup and watches the key, applying changes made through any instance,
+// so that all instances run with the same parameters.
+/////////////////////////////////////////////////////////////////////////////
+
+// Stored parameters.  Parameters stored by older versions have no revision
+// and are taken as revision 0.
+
+type paramsDoc struct {
+	inidata
+	Revision int `json:"Revision"`
+}
+
+// Revision of the parameters an instance is running, for GET /params.
+
+type paramsInstRev struct {
+	ID       string `json:"ID"`
+	Name     string `json:"Name"`
+	Revision int    `json:"Revision"`
+}
+
+type paramsRsp struct {
+	inidata
+	Revision  int             `json:"Revision"`
+	Instances []paramsInstRev `json:"Instances,omitempty"`
+}
+
+// How many times to retry storing parameters changed by another instance
+// at the same time.
+
+const PARAM_STORE_TRIES = 5
+
+// Revision of the parameters this instance is running, and whether it has
+// loaded any.  Held while changing parameters, so changes from a PATCH
+// and from the watch don't interleave.
+
+var paramRevision int
+var paramSynced bool
+var paramLock sync.Mutex
+
+// Cancels the stored parameter watch.
+
+var paramWatchCancel func()
+
+// Record the revision of the parameters this instance is running.  Called
+// with paramLock held.
+
+func setParamRevision(rev int) {
+	paramRevision = rev
+	paramSynced = true
+	mParamRevision.Set(float64(rev))
+	updateInstance(func(inst *hbInstance) {
+		inst.ParamRevision = rev
+	})
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Apply stored parameters, if they are newer than the ones this instance
+// is running.
+//
+// val(in): Stored parameter JSON.
+// Return:  None.
+/////////////////////////////////////////////////////////////////////////////
+
+func paramApply(val string) {
+	var doc paramsDoc
+	var errstr string
+
+	paramLock.Lock()
+	defer paramLock.Unlock()
+
+	err := json.Unmarshal([]byte(val), &doc)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error unmarshalling stored parameters '%s': %v", val, err),
+			"error", err)
+		return
+	}
+	if paramSynced && (doc.Revision <= paramRevision) {
+		return
+	}
+
+	if parse_parm_json([]byte(val), PARAM_SYNC, &errstr) != 0 {
+		logMain.Error(fmt.Sprintf("Error applying stored parameters revision %d: %s",
+			doc.Revision, errstr), "revision", doc.Revision)
+		return
+	}
+	setParamRevision(doc.Revision)
+	logMain.Info(fmt.Sprintf("Parameters synchronized to revision %d.", doc.Revision),
+		"revision", doc.Revision)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Store this instance's current parameters as the next revision.  Called
+// with paramLock held.
+//
+// Args:   None.
+// Return: Stored parameter JSON; nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func paramStore() ([]byte, error) {
+	var ok bool
+
+	doc := paramsDoc{inidata: cur_param_data()}
+
+	for ix := 0; ix < PARAM_STORE_TRIES; ix++ {
+		var prev paramsDoc
+
+		cur, exists, err := kvHandle.Get(KV_PARAM_KEY)
+		if err != nil {
+			return nil, err
+		}
+		doc.Revision = paramRevision + 1
+		if exists && (json.Unmarshal([]byte(cur), &prev) == nil) &&
+			(prev.Revision >= paramRevision) {
+			doc.Revision = prev.Revision + 1
+		}
+		ba, err := json.Marshal(&doc)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			ok, err = kvHandle.TAS(KV_PARAM_KEY, cur, string(ba))
+		} else {
+			ok, err = kvHandle.Create(KV_PARAM_KEY, string(ba))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			setParamRevision(doc.Revision)
+			return ba, nil
+		}
+	}
+
+	return nil, fmt.Errorf("parameters changed by another instance %d times",
+		PARAM_STORE_TRIES)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Start synchronizing parameters.  Watches the stored parameters, then
+// loads them.  If no other instances are running the stored parameters
+// are left over from an earlier run of the service, so this instance's
+// startup parameters are stored as the next revision instead.
+//
+// Args:   None.
+// Return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func startParamSync() {
+	var err error
+
+	paramWatchCancel, err = kvHandle.Watch(KV_PARAM_KEY, paramApply)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error watching stored parameters, changes made through other instances won't be seen: %v",
+			err), "error", err)
+	}
+
+	if staleKeys {
+		paramLock.Lock()
+		_, err = paramStore()
+		paramLock.Unlock()
+		if err != nil {
+			logMain.Error(fmt.Sprintf("Error storing startup parameters: %v", err),
+				"error", err)
+		}
+		return
+	}
+
+	val, exists, err := kvHandle.Get(KV_PARAM_KEY)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error loading stored parameters: %v", err),
+			"error", err)
+		return
+	}
+	if exists {
+		paramApply(val)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Generate the GET /params response: the current parameters, their
+// revision and the revision each running instance has.
+//
+// Args:   None.
+// Return: Response data.
+/////////////////////////////////////////////////////////////////////////////
+
+func paramsResponse() *paramsRsp {
+	rsp := &paramsRsp{inidata: cur_param_data()}
+
+	paramLock.Lock()
+	rsp.Revision = paramRevision
+	paramLock.Unlock()
+
+	insts, err := getInstances()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching instances for parameter revisions: %v", err),
+			"error", err)
+		return rsp
+	}
+	for _, inst := range insts {
+		rsp.Instances = append(rsp.Instances, paramsInstRev{ID: inst.ID,
+			Name: inst.Name, Revision: inst.ParamRevision})
+	}
+	return rsp
+}
@@ -0,0 +1,231 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Store parameters as another instance would.
+
+func storeParamsDoc(t *testing.T, rev int, warntime string) {
+	doc := paramsDoc{inidata: cur_param_data(), Revision: rev}
+	doc.Warntime = warntime
+	doc.Kv_url = "https://elsewhere:2379"
+	ba, _ := json.Marshal(&doc)
+	if err := kvHandle.Store(KV_PARAM_KEY, string(ba)); err != nil {
+		t.Fatalf("ERROR storing parameters: %v", err)
+	}
+}
+
+// Wait for the watch to pick up a parameter change.
+
+func waitParamRevision(rev int) bool {
+	for ix := 0; ix < 60; ix++ {
+		paramLock.Lock()
+		cur := paramRevision
+		paramLock.Unlock()
+		if cur == rev {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+// HB store on which another instance stores its parameters just after this
+// one finds there are none.
+
+type paramRaceStore struct {
+	hbStore
+	t     *testing.T
+	raced bool
+}
+
+func (ps *paramRaceStore) Get(key string) (string, bool, error) {
+	val, exists, err := ps.hbStore.Get(key)
+	if (key == KV_PARAM_KEY) && !exists && !ps.raced {
+		ps.raced = true
+		storeParamsDoc(ps.t, 1, "5")
+	}
+	return val, exists, err
+}
+
+// Test two instances storing the first parameter revision at once.
+
+func TestParamStoreFirst(t *testing.T) {
+	var doc paramsDoc
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	origKV := kvHandle
+	origRev := paramRevision
+	kvHandle.Delete(KV_PARAM_KEY)
+	kvHandle = &paramRaceStore{hbStore: origKV, t: t}
+	paramRevision = 0
+	defer func() {
+		kvHandle = origKV
+		kvHandle.Delete(KV_PARAM_KEY)
+		paramRevision = origRev
+	}()
+
+	paramLock.Lock()
+	ba, err := paramStore()
+	paramLock.Unlock()
+	if err != nil {
+		t.Fatalf("ERROR storing parameters: %v", err)
+	}
+	json.Unmarshal(ba, &doc)
+	if doc.Revision != 2 {
+		t.Errorf("Expected revision 2 after another instance stored 1, got %d", doc.Revision)
+	}
+	val, _, _ := kvHandle.Get(KV_PARAM_KEY)
+	doc = paramsDoc{}
+	json.Unmarshal([]byte(val), &doc)
+	if doc.Revision != 2 {
+		t.Errorf("Expected stored revision 2, got %d", doc.Revision)
+	}
+}
+
+// Test parameter synchronization between instances.
+
+func TestParamSync(t *testing.T) {
+	var doc paramsDoc
+	var rsp paramsRsp
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+	clearLifeKeys()
+
+	origParams := app_params
+	initAppParams()
+	origPoll := storeWatchPoll
+	storeWatchPoll = 50 * time.Millisecond
+	kvHandle.Delete(KV_PARAM_KEY)
+	paramSynced = false
+	paramRevision = 0
+	defer func() {
+		if paramWatchCancel != nil {
+			paramWatchCancel()
+		}
+		storeWatchPoll = origPoll
+		app_params = origParams
+		staleKeys = false
+		kvHandle.Delete(KV_PARAM_KEY)
+		instanceLock.Lock()
+		instanceLive = false
+		instanceLock.Unlock()
+		clearLifeKeys()
+	}()
+
+	initInstance(HBTD_LIFE_KEY_PRE + "846930886")
+	registerInstance()
+
+	//First instance: its startup parameters are stored as revision 1.
+
+	app_params.warntime.int_param = 11
+	staleKeys = true
+	startParamSync()
+	val, _, _ := kvHandle.Get(KV_PARAM_KEY)
+	if err := json.Unmarshal([]byte(val), &doc); err != nil {
+		t.Fatalf("Can't unmarshal stored parameters '%s': %v", val, err)
+	}
+	if (doc.Revision != 1) || (doc.Warntime != "11") {
+		t.Errorf("Expected revision 1 with warntime 11, got %d/%s", doc.Revision, doc.Warntime)
+	}
+
+	//A change made by another instance is picked up, except for the K/V
+	//store URL.
+
+	kvurl := app_params.kv_url.string_param
+	storeParamsDoc(t, 2, "22")
+	if !waitParamRevision(2) {
+		t.Fatalf("Parameter change not picked up.")
+	}
+	if app_params.warntime.int_param != 22 {
+		t.Errorf("Expected warntime 22, got %d", app_params.warntime.int_param)
+	}
+	if app_params.kv_url.string_param != kvurl {
+		t.Errorf("K/V URL taken from another instance: '%s'", app_params.kv_url.string_param)
+	}
+
+	//Older revisions are ignored.
+
+	storeParamsDoc(t, 1, "5")
+	time.Sleep(300 * time.Millisecond)
+	if app_params.warntime.int_param != 22 {
+		t.Errorf("Older parameter revision applied, warntime %d", app_params.warntime.int_param)
+	}
+
+	//A PATCH stores the next revision.
+
+	rr := policyReq(t, "PATCH", URL_PARAMS, `{"Warntime":"33"}`, http.StatusOK)
+	doc = paramsDoc{}
+	json.Unmarshal(rr.Body.Bytes(), &doc)
+	if (doc.Revision != 3) || (doc.Warntime != "33") {
+		t.Errorf("Expected PATCH to store revision 3 with warntime 33, got %d/%s",
+			doc.Revision, doc.Warntime)
+	}
+
+	//GET shows the revision each instance is running.
+
+	rr = policyReq(t, "GET", URL_PARAMS, "", http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &rsp); err != nil {
+		t.Fatalf("Can't unmarshal GET response: %v", err)
+	}
+	if (rsp.Revision != 3) || (rsp.Warntime != "33") {
+		t.Errorf("Expected GET revision 3 with warntime 33, got %d/%s", rsp.Revision, rsp.Warntime)
+	}
+	if (len(rsp.Instances) != 1) || (rsp.Instances[0].Revision != 3) {
+		t.Errorf("Expected one instance at revision 3, got %v", rsp.Instances)
+	}
+
+	//A later instance loads the stored parameters at startup.
+
+	paramWatchCancel()
+	storeParamsDoc(t, 7, "44")
+	paramSynced = false
+	paramRevision = 0
+	staleKeys = false
+	startParamSync()
+	if (paramRevision != 7) || (app_params.warntime.int_param != 44) {
+		t.Errorf("Expected revision 7 with warntime 44 at startup, got %d/%d",
+			paramRevision, app_params.warntime.int_param)
+	}
+}
@@ -0,0 +1,215 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Adaptive (phi accrual) failure detection.  Each component's HB record
+// keeps the mean and variance of the time between its heartbeats, learned
+// as they arrive.  From these, the suspicion level phi that a component is
+// dead, t seconds after its last heartbeat, is:
+//
+//   phi(t) = -log10(P(next heartbeat arrives later than t))
+//
+// with inter-arrival times taken to be normally distributed.  Components
+// selected by a policy with Detector "phi" get a warning when phi reaches
+// the policy's PhiWarn and are declared dead when it reaches PhiError.
+// Since phi only grows with t, these are turned into the equivalent warn
+// and error times, so the HB checker works the same way for both modes.
+// Until enough heartbeats have been seen, the policy's Warntime and Errtime
+// are used.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	DETECTOR_FIXED = "fixed"
+	DETECTOR_PHI   = "phi"
+
+	PHI_WARN  = 3.0
+	PHI_ERROR = 8.0
+
+	PHI_SAMPLES     = 100  //Older intervals fade out after this many
+	PHI_MIN_SAMPLES = 10   //Intervals needed before phi is used
+	PHI_MIN_STDDEV  = 0.25 //Fraction of the mean
+	PHI_MAX         = 100.0
+)
+
+// Learned inter-arrival statistics of a component, in seconds.
+
+type hbArrivalStats struct {
+	Count int     `json:"Count"` //Intervals seen, up to PHI_SAMPLES
+	Mean  float64 `json:"Mean"`
+	Var   float64 `json:"Var"`
+	Last  int64   `json:"Last"` //Last arrival, Unix milliseconds
+}
+
+// Failure detection info for a component, for verbose HB state queries.
+
+type hbDetectRsp struct {
+	Detector     string  `json:"Detector"`
+	Policy       string  `json:"Policy,omitempty"`
+	Learning     bool    `json:"Learning,omitempty"` //Not enough samples for phi yet
+	Samples      int     `json:"Samples"`
+	MeanInterval float64 `json:"MeanInterval"`
+	StdDev       float64 `json:"StdDev"`
+	Phi          float64 `json:"Phi"`
+	Warntime     int     `json:"Warntime"` //In effect for the component
+	Errtime      int     `json:"Errtime"`
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Learn from a heartbeat's arrival.  The interval since the previous
+// heartbeat is added to the running mean and variance, which weigh all
+// intervals equally until PHI_SAMPLES have been seen and recent ones more
+// after that.  Intervals that ended a warning are not learned, since they
+// are outages rather than normal heartbeat behavior.
+//
+// hbb(in/out): HB record of the component.
+// rcv(in):     Arrival time.
+// Return:      None.
+/////////////////////////////////////////////////////////////////////////////
+
+func learnArrival(hbb *hbinfo, rcv time.Time) {
+	ms := rcv.UnixMilli()
+	if hbb.Arrivals == nil {
+		hbb.Arrivals = &hbArrivalStats{Last: ms}
+		return
+	}
+
+	st := hbb.Arrivals
+	ival := float64(ms-st.Last) / 1000.0
+	st.Last = ms
+	if (ival <= 0) || (hbb.Had_warning != HB_WARN_NONE) {
+		return
+	}
+
+	if st.Count < PHI_SAMPLES {
+		st.Count++
+	}
+	n := float64(st.Count)
+	delta := ival - st.Mean
+	st.Mean += delta / n
+	st.Var += (delta*(ival-st.Mean) - st.Var) / n
+}
+
+// Standard deviation to use for phi, with a floor so that very regular
+// heartbeats don't make phi jump at the slightest delay.
+
+func (st *hbArrivalStats) stddev() float64 {
+	return math.Max(math.Sqrt(st.Var), st.Mean*PHI_MIN_STDDEV)
+}
+
+// Suspicion level t seconds after the last heartbeat.
+
+func (st *hbArrivalStats) phi(t float64) float64 {
+	if (st == nil) || (st.Count < 2) || (st.Mean <= 0) {
+		return 0
+	}
+	plater := 0.5 * math.Erfc((t-st.Mean)/(st.stddev()*math.Sqrt2))
+	if plater <= 0 {
+		return PHI_MAX
+	}
+	return math.Min(-math.Log10(plater), PHI_MAX)
+}
+
+// Seconds after the last heartbeat at which phi reaches a threshold,
+// rounded up.
+
+func (st *hbArrivalStats) phiTime(threshold float64) int {
+	z := math.Sqrt2 * math.Erfcinv(2*math.Pow(10, -threshold))
+	return int(math.Ceil(st.Mean + z*st.stddev()))
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Determine the warn and error times to use for a component.  For
+// components selected by a phi policy whose heartbeat behavior has been
+// learned, these come from the policy's phi thresholds; otherwise they are
+// the policy's, or the global, warntime and errtime.
+//
+// hbb(in): HB record of the component.
+// Return:  Warn time in seconds.
+//          Error time in seconds.
+//          Policy used, nil if the global values are used.
+/////////////////////////////////////////////////////////////////////////////
+
+func resolveThresholds(hbb *hbinfo) (int, int, *hbPolicy) {
+	pol := resolvePolicy(hbb.Component)
+	if pol == nil {
+		return app_params.warntime.int_param, app_params.errtime.int_param, nil
+	}
+	if (pol.Detector != DETECTOR_PHI) || !phiLearned(hbb) {
+		return pol.Warntime, pol.Errtime, pol
+	}
+
+	warntime := hbb.Arrivals.phiTime(pol.PhiWarn)
+	errtime := hbb.Arrivals.phiTime(pol.PhiError)
+	if errtime <= warntime {
+		errtime = warntime + 1
+	}
+	return warntime, errtime, pol
+}
+
+// Check whether enough of a component's heartbeats have been seen to use
+// phi.
+
+func phiLearned(hbb *hbinfo) bool {
+	return (hbb.Arrivals != nil) && (hbb.Arrivals.Count >= PHI_MIN_SAMPLES)
+}
+
+// Convenience function, fill in failure detection info for a component.
+//
+// hbb(in): HB record of the component.
+// now(in): Time reference, Unix seconds.
+// Return:  Failure detection info.
+
+func detectRsp(hbb *hbinfo, now int64) *hbDetectRsp {
+	warntime, errtime, pol := resolveThresholds(hbb)
+	rsp := hbDetectRsp{Detector: DETECTOR_FIXED, Warntime: warntime, Errtime: errtime}
+	if pol != nil {
+		rsp.Policy = pol.Name
+		if pol.Detector == DETECTOR_PHI {
+			rsp.Detector = DETECTOR_PHI
+			rsp.Learning = !phiLearned(hbb)
+		}
+	}
+
+	st := hbb.Arrivals
+	if st == nil {
+		return &rsp
+	}
+	rsp.Samples = st.Count
+	rsp.MeanInterval = roundMS(st.Mean)
+	rsp.StdDev = roundMS(math.Sqrt(st.Var))
+
+	//Phi is measured from the last heartbeat's receive time, as the HB
+	//checker does, rather than from the learned arrival time: a monitoring
+	//gap freshens the former.
+
+	lhbtime, _ := strconv.ParseInt(hbb.Last_hb_rcv_time, 16, 64)
+	rsp.Phi = roundMS(st.phi(float64(now - lhbtime)))
+	return &rsp
+}
@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test learning of inter-arrival times and the phi calculations.
+
+func TestPhiLearning(t *testing.T) {
+	var hbb hbinfo
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if (&hbArrivalStats{}).phi(100) != 0 {
+		t.Errorf("Expected phi 0 with nothing learned")
+	}
+
+	//Intervals of 9 and 11 seconds: mean 10, std dev 1, so the floor of a
+	//quarter of the mean is used.
+
+	rcv := start
+	for ix := 0; ix <= 20; ix++ {
+		learnArrival(&hbb, rcv)
+		rcv = rcv.Add(time.Duration(9+2*(ix%2)) * time.Second)
+	}
+	st := hbb.Arrivals
+	if (st.Count != 20) || (math.Abs(st.Mean-10) > 0.001) || (math.Abs(st.Var-1) > 0.001) {
+		t.Fatalf("Unexpected learned stats: %+v", *st)
+	}
+	if (st.stddev() != 2.5) || (math.Abs(st.phi(10)-math.Log10(2)) > 0.001) {
+		t.Errorf("Unexpected std dev %g or phi %g", st.stddev(), st.phi(10))
+	}
+	if wt, et := st.phiTime(PHI_WARN), st.phiTime(PHI_ERROR); (wt != 18) || (et != 25) {
+		t.Errorf("Expected phi times 18 and 25, got %d and %d", wt, et)
+	}
+	if p := st.phi(float64(st.phiTime(PHI_ERROR))); p < PHI_ERROR {
+		t.Errorf("Phi %g at error time is below %g", p, PHI_ERROR)
+	}
+	if st.phi(1e6) != PHI_MAX {
+		t.Errorf("Expected phi to be capped at %g, got %g", PHI_MAX, st.phi(1e6))
+	}
+
+	//An interval which ended a warning isn't learned.
+
+	hbb.Had_warning = HB_WARN_NORMAL
+	learnArrival(&hbb, rcv.Add(300*time.Second))
+	if (st.Count != 20) || (math.Abs(st.Mean-10) > 0.001) ||
+		(st.Last != rcv.Add(300*time.Second).UnixMilli()) {
+		t.Errorf("Warning interval learned: %+v", *st)
+	}
+
+	//The count stops at PHI_SAMPLES, after which recent intervals weigh
+	//more.
+
+	hbb.Had_warning = HB_WARN_NONE
+	rcv = rcv.Add(300 * time.Second)
+	for ix := 0; ix < 2*PHI_SAMPLES; ix++ {
+		rcv = rcv.Add(20 * time.Second)
+		learnArrival(&hbb, rcv)
+	}
+	if (st.Count != PHI_SAMPLES) || (st.Mean < 19) {
+		t.Errorf("Unexpected stats after %d more intervals: %+v", 2*PHI_SAMPLES, *st)
+	}
+}
+
+// Test phi detection selected by a policy.
+
+func TestPhiPolicy(t *testing.T) {
+	var srsp hbSingleStateRsp
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	origParams := app_params
+	initAppParams()
+	app_params.warntime.int_param = 30
+	app_params.errtime.int_param = 60
+	defer func() { app_params = origParams }()
+
+	//Validation
+
+	badPols := []string{
+		`{"Name":"phi","Prefixes":["x3010"],"Warntime":30,"Errtime":60,"Detector":"bogus"}`,
+		`{"Name":"phi","Prefixes":["x3010"],"Warntime":30,"Errtime":60,"PhiWarn":2}`,
+		`{"Name":"phi","Prefixes":["x3010"],"Warntime":30,"Errtime":60,"Detector":"phi","PhiWarn":5,"PhiError":4}`,
+		`{"Name":"phi","Prefixes":["x3010"],"Warntime":30,"Errtime":60,"Detector":"phi","PhiWarn":-1}`,
+	}
+	for _, bp := range badPols {
+		policyReq(t, "POST", URL_POLICIES, bp, http.StatusBadRequest)
+	}
+
+	rr := policyReq(t, "POST", URL_POLICIES,
+		`{"Name":"phi","Prefixes":["x3010"],"Warntime":30,"Errtime":60,"Detector":"PHI"}`,
+		http.StatusCreated)
+	defer policyReq(t, "DELETE", URL_POLICIES+"/phi", "", http.StatusNoContent)
+	var pol hbPolicy
+	json.Unmarshal(rr.Body.Bytes(), &pol)
+	if (pol.Detector != DETECTOR_PHI) || (pol.PhiWarn != PHI_WARN) || (pol.PhiError != PHI_ERROR) {
+		t.Errorf("Unexpected phi policy: %s", rr.Body.String())
+	}
+
+	//A learned component 20 seconds after its last HB is in the warning
+	//state, one still being learned isn't, and neither is one not selected
+	//by the policy.
+
+	now := time.Now().Unix()
+	rcv := strconv.FormatUint(uint64(now-20), 16)
+	learned := &hbArrivalStats{Count: 20, Mean: 10, Var: 1, Last: (now - 20) * 1000}
+	learning := &hbArrivalStats{Count: 3, Mean: 10, Var: 1, Last: (now - 20) * 1000}
+	recs := []hbinfo{
+		{Component: "x3010c0s0b0n0", Last_hb_rcv_time: rcv, Arrivals: learned},
+		{Component: "x3010c0s1b0n0", Last_hb_rcv_time: rcv, Arrivals: learning},
+		{Component: "x3011c0s0b0n0", Last_hb_rcv_time: rcv, Arrivals: learned},
+	}
+	for _, rec := range recs {
+		ba, _ := json.Marshal(&rec)
+		kvHandle.Store(rec.Component, string(ba))
+		defer kvHandle.Delete(rec.Component)
+	}
+
+	exp := []struct {
+		state    string
+		detector string
+		learning bool
+		warntime int
+		errtime  int
+	}{
+		{HB_STATE_WARN, DETECTOR_PHI, false, 18, 25},
+		{HB_STATE_OK, DETECTOR_PHI, true, 30, 60},
+		{HB_STATE_OK, DETECTOR_FIXED, false, 30, 60},
+	}
+	for ix, rec := range recs {
+		rr = policyReq(t, "GET", URL_HB_STATE+"/"+rec.Component+"?verbose=true", "",
+			http.StatusOK)
+		srsp = hbSingleStateRsp{}
+		json.Unmarshal(rr.Body.Bytes(), &srsp)
+		det := srsp.Detection
+		if (srsp.State != exp[ix].state) || (det == nil) ||
+			(det.Detector != exp[ix].detector) || (det.Learning != exp[ix].learning) ||
+			(det.Warntime != exp[ix].warntime) || (det.Errtime != exp[ix].errtime) ||
+			(det.Samples != rec.Arrivals.Count) || (det.MeanInterval != 10) ||
+			(det.StdDev != 1) {
+			t.Errorf("Unexpected state for '%s': %s", rec.Component, rr.Body.String())
+		}
+	}
+	if srsp.Detection.Phi < PHI_WARN {
+		t.Errorf("Expected phi >= %g 20 seconds after HB, got %g", PHI_WARN,
+			srsp.Detection.Phi)
+	}
+
+	//Past the phi error time, the component is dead.
+
+	hbb, _ := getHBInfo("x3010c0s0b0n0", "test")
+	if isHeartbeating(hbb, now+5) {
+		t.Errorf("Learned component still heartbeating past its phi error time")
+	}
+}
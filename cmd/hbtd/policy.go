@@ -0,0 +1,533 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+	"github.com/gorilla/mux"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Heartbeat timeout policies.  A policy overrides the global warntime and
+// errtime for the components it selects.  Components can be selected by
+// explicit XName, by XName prefix or by HMS type.  If more than one policy
+// selects a component, the most specific one wins:
+//
+//   explicit XName > longest matching prefix > HMS type
+//
+// A policy can also select adaptive (phi accrual) failure detection, in
+// which case its Warntime and Errtime are only used until the heartbeat
+// behavior of a component has been learned; see phi.go.
+//
+// Policies are stored in the KV store, one key per policy, so all instances
+// see the same set.  Each instance keeps a cached copy which is refreshed
+// every HB check interval.
+/////////////////////////////////////////////////////////////////////////////
+
+// Selects a set of components.
+
+type hbSelector struct {
+	Components []string `json:"Components,omitempty"`
+	Prefixes   []string `json:"Prefixes,omitempty"`
+	Types      []string `json:"Types,omitempty"`
+}
+
+type hbPolicy struct {
+	Name string `json:"Name"`
+	hbSelector
+	Warntime int     `json:"Warntime"`
+	Errtime  int     `json:"Errtime"`
+	Detector string  `json:"Detector,omitempty"` //fixed (default) or phi
+	PhiWarn  float64 `json:"PhiWarn,omitempty"`
+	PhiError float64 `json:"PhiError,omitempty"`
+}
+
+type hbPolicyList struct {
+	Policies []hbPolicy `json:"Policies"`
+}
+
+const (
+	HBTD_POLICY_KEY_PRE = "hbtd_policy-"
+	HBTD_POLICY_KEY_END = HBTD_POLICY_KEY_PRE + "~"
+)
+
+// Selector match specificity.  Prefix matches add the prefix length so
+// that the longest prefix wins.
+
+const (
+	SEL_MATCH_NONE      = 0
+	SEL_MATCH_TYPE      = 1
+	SEL_MATCH_PREFIX    = 1000
+	SEL_MATCH_COMPONENT = 1000000
+)
+
+var policyNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+var policyCache []hbPolicy
+var policyLock sync.RWMutex
+
+// Validate and normalize a selector.  XNames and prefixes are lower-cased,
+// HMS types are converted to their canonical form.
+//
+// Return: nil on success, else error describing the problem.
+
+func (sel *hbSelector) normalize() error {
+	for ix, comp := range sel.Components {
+		ncomp := xnametypes.VerifyNormalizeCompID(comp)
+		if ncomp == "" {
+			return fmt.Errorf("Invalid component XName '%s'", comp)
+		}
+		sel.Components[ix] = ncomp
+	}
+	for ix, pfx := range sel.Prefixes {
+		npfx := strings.ToLower(strings.TrimSpace(pfx))
+		if npfx == "" {
+			return fmt.Errorf("Empty XName prefix")
+		}
+		sel.Prefixes[ix] = npfx
+	}
+	for ix, htype := range sel.Types {
+		ntype := xnametypes.VerifyNormalizeType(htype)
+		if ntype == "" {
+			return fmt.Errorf("Invalid HMS type '%s'", htype)
+		}
+		sel.Types[ix] = ntype
+	}
+	if (len(sel.Components) + len(sel.Prefixes) + len(sel.Types)) == 0 {
+		return fmt.Errorf("At least one of Components, Prefixes or Types must be specified")
+	}
+	return nil
+}
+
+// Check if a selector selects a component.
+//
+// xname(in): Component XName, normalized.
+// htype(in): HMS type of the component.
+// Return:    Match specificity, SEL_MATCH_NONE if there is no match.
+
+func (sel *hbSelector) match(xname, htype string) int {
+	for _, comp := range sel.Components {
+		if comp == xname {
+			return SEL_MATCH_COMPONENT
+		}
+	}
+
+	best := SEL_MATCH_NONE
+	for _, pfx := range sel.Prefixes {
+		if strings.HasPrefix(xname, pfx) && ((SEL_MATCH_PREFIX + len(pfx)) > best) {
+			best = SEL_MATCH_PREFIX + len(pfx)
+		}
+	}
+	if best != SEL_MATCH_NONE {
+		return best
+	}
+
+	for _, ht := range sel.Types {
+		if ht == htype {
+			return SEL_MATCH_TYPE
+		}
+	}
+	return SEL_MATCH_NONE
+}
+
+// Validate and normalize a policy.
+//
+// Return: nil on success, else error describing the problem.
+
+func (pol *hbPolicy) validate() error {
+	if !policyNameRE.MatchString(pol.Name) {
+		return fmt.Errorf("Invalid policy name '%s', must be 1-64 characters from [A-Za-z0-9_.-]",
+			pol.Name)
+	}
+	if pol.Warntime <= 0 {
+		return fmt.Errorf("Warntime must be > 0")
+	}
+	if pol.Errtime <= pol.Warntime {
+		return fmt.Errorf("Errtime must be > Warntime")
+	}
+
+	pol.Detector = strings.ToLower(strings.TrimSpace(pol.Detector))
+	switch pol.Detector {
+	case "", DETECTOR_FIXED:
+		if (pol.PhiWarn != 0) || (pol.PhiError != 0) {
+			return fmt.Errorf("PhiWarn and PhiError are only used with Detector '%s'",
+				DETECTOR_PHI)
+		}
+	case DETECTOR_PHI:
+		if pol.PhiWarn == 0 {
+			pol.PhiWarn = PHI_WARN
+		}
+		if pol.PhiError == 0 {
+			pol.PhiError = PHI_ERROR
+		}
+		if (pol.PhiWarn < 0) || (pol.PhiError > PHI_MAX) || (pol.PhiError <= pol.PhiWarn) {
+			return fmt.Errorf("PhiWarn and PhiError must be > 0, no more than %g, and PhiError must be > PhiWarn",
+				PHI_MAX)
+		}
+	default:
+		return fmt.Errorf("Invalid Detector '%s', must be '%s' or '%s'",
+			pol.Detector, DETECTOR_FIXED, DETECTOR_PHI)
+	}
+	return pol.hbSelector.normalize()
+}
+
+// Fetch all policies from the KV store and refresh the local cache.
+//
+// Return: All policies, sorted by name.
+//         nil on success, else error.
+
+func loadPolicies() ([]hbPolicy, error) {
+	kvlist, err := kvHandle.GetRange(HBTD_POLICY_KEY_PRE, HBTD_POLICY_KEY_END)
+	if err != nil {
+		return nil, err
+	}
+
+	pols := []hbPolicy{}
+	for _, kv := range kvlist {
+		var pol hbPolicy
+		umerr := json.Unmarshal([]byte(kv.Value), &pol)
+		if umerr != nil {
+			logKV.Error(fmt.Sprintf("Error unmarshalling policy '%s': %v", kv.Value, umerr),
+				"key", kv.Key, "error", umerr)
+			continue
+		}
+		pols = append(pols, pol)
+	}
+	sort.Slice(pols, func(i, j int) bool { return pols[i].Name < pols[j].Name })
+
+	policyLock.Lock()
+	policyCache = pols
+	policyLock.Unlock()
+
+	return pols, nil
+}
+
+// Determine the warn and error timeouts to use for a component, based on
+// the most specific policy that selects it.  If no policy selects the
+// component, the global warntime and errtime are used.
+//
+// xname(in): Component XName.
+// Return:    Warn time in seconds.
+//            Error time in seconds.
+//            Name of the policy used, "" if the global values are used.
+
+func resolveTimeouts(xname string) (int, int, string) {
+	pol := resolvePolicy(xname)
+	if pol == nil {
+		return app_params.warntime.int_param, app_params.errtime.int_param, ""
+	}
+	return pol.Warntime, pol.Errtime, pol.Name
+}
+
+// Find the most specific policy that selects a component.
+//
+// xname(in): Component XName.
+// Return:    Copy of the policy, nil if no policy selects the component.
+
+func resolvePolicy(xname string) *hbPolicy {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+
+	if len(policyCache) == 0 {
+		return nil
+	}
+
+	htype := string(xnametypes.GetHMSType(xname))
+	best := SEL_MATCH_NONE
+	var bpol *hbPolicy
+
+	//Policies are sorted by name, so ties go to the first name.
+
+	for ix := range policyCache {
+		m := policyCache[ix].match(xname, htype)
+		if m > best {
+			best = m
+			bpol = &policyCache[ix]
+		}
+	}
+
+	if bpol == nil {
+		return nil
+	}
+	pol := *bpol
+	return &pol
+}
+
+// Convenience function, read a policy from a request body and validate it.
+//
+// r(in):       HTTP request.
+// errinst(in): URL of the request (for error messaging).
+// Return:      Policy, or nil on error.
+//              Problem report on error for caller to use.
+
+func readPolicy(r *http.Request, errinst string) (*hbPolicy, *base.ProblemDetails) {
+	var pol hbPolicy
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error on message read: %v", err), "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Error reading inbound request",
+			errinst, http.StatusBadRequest)
+		return nil, pdet
+	}
+
+	err = json.Unmarshal(body, &pol)
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			fmt.Sprintf("Error unmarshalling inbound request: %v", err),
+			errinst, http.StatusBadRequest)
+		return nil, pdet
+	}
+	return &pol, nil
+}
+
+// Convenience function, validate and store a policy and refresh the local
+// cache.
+//
+// pol(in):     Policy to store.
+// errinst(in): URL of the request (for error messaging).
+// Return:      Problem report on error for caller to use, else nil.
+
+func storePolicy(pol *hbPolicy, errinst string) *base.ProblemDetails {
+	verr := pol.validate()
+	if verr != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			verr.Error(),
+			errinst, http.StatusBadRequest)
+		return pdet
+	}
+
+	ba, _ := json.Marshal(pol)
+	err := kvHandle.Store(HBTD_POLICY_KEY_PRE+pol.Name, string(ba))
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error storing policy '%s': %v", pol.Name, err),
+			"policy", pol.Name, "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service STORE operation",
+			errinst, http.StatusInternalServerError)
+		return pdet
+	}
+
+	_, err = loadPolicies()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error refreshing policies: %v", err), "error", err)
+	}
+	return nil
+}
+
+// Convenience function, send a JSON response.
+
+func sendJSON(w http.ResponseWriter, code int, data interface{}, errinst string) {
+	ba, baerr := json.Marshal(data)
+	if baerr != nil {
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR marshalling rsp data: %v", baerr),
+			"error", baerr)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Error marshalling JSON return data",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(ba)
+}
+
+// Entry point for GET and POST /hmi/v1/policies
+
+func policiesIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_POLICIES
+
+	if r.Method == "GET" {
+		pols, err := loadPolicies()
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error fetching policies: %v", err), "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service GETRANGE operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		sendJSON(w, http.StatusOK, &hbPolicyList{Policies: pols}, errinst)
+		return
+	}
+
+	//POST, create a new policy
+
+	pol, pdet := readPolicy(r, errinst)
+	if pdet != nil {
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	if policyNameRE.MatchString(pol.Name) {
+		_, exists, err := kvHandle.Get(HBTD_POLICY_KEY_PRE + pol.Name)
+		if err != nil {
+			pdet = base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service GET operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		if exists {
+			pdet = base.NewProblemDetails("about:blank",
+				"Conflict",
+				fmt.Sprintf("Policy '%s' already exists", pol.Name),
+				errinst, http.StatusConflict)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+	}
+
+	pdet = storePolicy(pol, errinst)
+	if pdet != nil {
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	logMain.Info(fmt.Sprintf("Created HB timeout policy '%s', warntime %d, errtime %d.",
+		pol.Name, pol.Warntime, pol.Errtime),
+		"policy", pol.Name, "warntime", pol.Warntime, "errtime", pol.Errtime)
+	sendJSON(w, http.StatusCreated, pol, errinst)
+}
+
+// Entry point for GET, PUT and DELETE /hmi/v1/policies/{name}
+
+func policyIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	name := mux.Vars(r)["name"]
+	errinst := URL_POLICIES + "/" + name
+	key := HBTD_POLICY_KEY_PRE + name
+
+	switch r.Method {
+	case "GET":
+		var pol hbPolicy
+		val, exists, err := kvHandle.Get(key)
+		if err != nil {
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service GET operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		if !exists {
+			pdet := base.NewProblemDetails("about:blank",
+				"Not Found",
+				fmt.Sprintf("No such policy '%s'", name),
+				errinst, http.StatusNotFound)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		err = json.Unmarshal([]byte(val), &pol)
+		if err != nil {
+			logMain.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", val, err),
+				"policy", name, "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				fmt.Sprintf("Error unmarshalling JSON for policy '%s'", name),
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		sendJSON(w, http.StatusOK, &pol, errinst)
+
+	case "PUT":
+		pol, pdet := readPolicy(r, errinst)
+		if pdet != nil {
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		if pol.Name == "" {
+			pol.Name = name
+		}
+		if pol.Name != name {
+			pdet = base.NewProblemDetails("about:blank",
+				"Invalid Request",
+				fmt.Sprintf("Policy name '%s' does not match URL", pol.Name),
+				errinst, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		pdet = storePolicy(pol, errinst)
+		if pdet != nil {
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		logMain.Info(fmt.Sprintf("Updated HB timeout policy '%s', warntime %d, errtime %d.",
+			pol.Name, pol.Warntime, pol.Errtime),
+			"policy", pol.Name, "warntime", pol.Warntime, "errtime", pol.Errtime)
+		sendJSON(w, http.StatusOK, pol, errinst)
+
+	case "DELETE":
+		_, exists, err := kvHandle.Get(key)
+		if err == nil && exists {
+			err = kvHandle.Delete(key)
+		}
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error deleting policy '%s': %v", name, err),
+				"policy", name, "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service DELETE operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		if !exists {
+			pdet := base.NewProblemDetails("about:blank",
+				"Not Found",
+				fmt.Sprintf("No such policy '%s'", name),
+				errinst, http.StatusNotFound)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		_, err = loadPolicies()
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error refreshing policies: %v", err), "error", err)
+		}
+		logMain.Info(fmt.Sprintf("Deleted HB timeout policy '%s'.", name),
+			"policy", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
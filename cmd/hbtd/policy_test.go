@@ -0,0 +1,209 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test policy resolution order and its effect on computed HB state.
+
+func TestResolveTimeouts(t *testing.T) {
+	app_params.warntime.int_param = 10
+	app_params.errtime.int_param = 30
+
+	pols := []hbPolicy{
+		{Name: "a-types", hbSelector: hbSelector{Types: []string{"node"}},
+			Warntime: 20, Errtime: 60},
+		{Name: "b-short", hbSelector: hbSelector{Prefixes: []string{"x1000"}},
+			Warntime: 21, Errtime: 61},
+		{Name: "c-long", hbSelector: hbSelector{Prefixes: []string{"X1000c0s1"}},
+			Warntime: 22, Errtime: 62},
+		{Name: "d-comps", hbSelector: hbSelector{Components: []string{"x1000c0s1b0n1"}},
+			Warntime: 23, Errtime: 63},
+	}
+	for ix := range pols {
+		err := pols[ix].validate()
+		if err != nil {
+			t.Fatalf("Policy '%s' unexpectedly invalid: %v", pols[ix].Name, err)
+		}
+	}
+	if pols[0].Types[0] != "Node" {
+		t.Errorf("HMS type not normalized, got '%s'", pols[0].Types[0])
+	}
+
+	policyLock.Lock()
+	policyCache = pols
+	policyLock.Unlock()
+
+	tests := []struct {
+		xname string
+		warn  int
+		err   int
+		name  string
+	}{
+		{"x1000c0s1b0n1", 23, 63, "d-comps"}, //explicit wins over all
+		{"x1000c0s1b0n0", 22, 62, "c-long"},  //longest prefix
+		{"x1000c0s2b0n0", 21, 61, "b-short"}, //shorter prefix
+		{"x2000c0s2b0n0", 20, 60, "a-types"}, //type
+		{"x2000c0s2b0", 10, 30, ""},          //global, NodeBMC
+		{"x1000c0s1b0", 22, 62, "c-long"},    //prefix, any type
+		{"x1000c0s1b0n10", 22, 62, "c-long"}, //not an explicit match
+	}
+
+	for _, tst := range tests {
+		w, e, n := resolveTimeouts(tst.xname)
+		if w != tst.warn || e != tst.err || n != tst.name {
+			t.Errorf("'%s': expected %d/%d/'%s', got %d/%d/'%s'",
+				tst.xname, tst.warn, tst.err, tst.name, w, e, n)
+		}
+	}
+
+	//The computed HB state should follow the policy.
+
+	now := time.Now().Unix()
+	hbb := hbinfo{Component: "x1000c0s1b0n1", Had_warning: HB_WARN_NONE}
+	states := []struct {
+		age   int64
+		state string
+	}{{22, HB_STATE_OK}, {23, HB_STATE_WARN}, {62, HB_STATE_WARN}, {63, HB_STATE_DEAD}}
+
+	for _, tst := range states {
+		hbb.Last_hb_rcv_time = strconv.FormatInt(now-tst.age, 16)
+		if st := hbState(&hbb, now); st != tst.state {
+			t.Errorf("Age %d: expected state %s, got %s", tst.age, tst.state, st)
+		}
+		if isHeartbeating(&hbb, now) != (tst.state != HB_STATE_DEAD) {
+			t.Errorf("Age %d: wrong heartbeating status", tst.age)
+		}
+	}
+
+	policyLock.Lock()
+	policyCache = nil
+	policyLock.Unlock()
+}
+
+func policyReq(t *testing.T, method, url, body string, expectedReturnCode int) *httptest.ResponseRecorder {
+	var req *http.Request
+
+	if body != "" {
+		req, _ = http.NewRequest(method, "http://localhost"+url, bytes.NewBufferString(body))
+	} else {
+		req, _ = http.NewRequest(method, "http://localhost"+url, nil)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != expectedReturnCode {
+		t.Errorf("%s %s: expected status %d, got %d (%s)", method, url,
+			expectedReturnCode, rr.Code, rr.Body.String())
+	}
+	return rr
+}
+
+// Test the /policies CRUD API.
+
+func TestPoliciesIO(t *testing.T) {
+	var pol hbPolicy
+	var plist hbPolicyList
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	//Create
+
+	rr := policyReq(t, "POST", URL_POLICIES,
+		`{"Name":"compute","Types":["Node"],"Warntime":20,"Errtime":60}`,
+		http.StatusCreated)
+	json.Unmarshal(rr.Body.Bytes(), &pol)
+	if pol.Name != "compute" || pol.Warntime != 20 || pol.Errtime != 60 {
+		t.Errorf("Unexpected create response: %s", rr.Body.String())
+	}
+	if _, _, n := resolveTimeouts("x0c0s0b0n0"); n != "compute" {
+		t.Errorf("Policy cache not refreshed after create, got '%s'", n)
+	}
+
+	policyReq(t, "POST", URL_POLICIES,
+		`{"Name":"compute","Types":["Node"],"Warntime":20,"Errtime":60}`,
+		http.StatusConflict)
+
+	//Validation
+
+	badPols := []string{
+		`{"Name":"bad name","Types":["Node"],"Warntime":20,"Errtime":60}`,
+		`{"Name":"bad","Types":["Node"],"Warntime":20,"Errtime":10}`,
+		`{"Name":"bad","Types":["Node"],"Warntime":0,"Errtime":10}`,
+		`{"Name":"bad","Types":["Nodule"],"Warntime":20,"Errtime":60}`,
+		`{"Name":"bad","Components":["xyzzy"],"Warntime":20,"Errtime":60}`,
+		`{"Name":"bad","Warntime":20,"Errtime":60}`,
+		`{"Name":"bad","Types":["Node"],"Warntime":"20","Errtime":60}`,
+	}
+	for _, bp := range badPols {
+		policyReq(t, "POST", URL_POLICIES, bp, http.StatusBadRequest)
+	}
+
+	//Replace, get, list
+
+	policyReq(t, "PUT", URL_POLICIES+"/compute",
+		`{"Prefixes":["x1000"],"Warntime":15,"Errtime":45}`, http.StatusOK)
+	policyReq(t, "PUT", URL_POLICIES+"/compute",
+		`{"Name":"other","Prefixes":["x1000"],"Warntime":15,"Errtime":45}`,
+		http.StatusBadRequest)
+	policyReq(t, "PUT", URL_POLICIES+"/service",
+		`{"Components":["x3000c0s1b0n0"],"Warntime":30,"Errtime":90}`, http.StatusOK)
+
+	rr = policyReq(t, "GET", URL_POLICIES+"/compute", "", http.StatusOK)
+	pol = hbPolicy{}
+	json.Unmarshal(rr.Body.Bytes(), &pol)
+	if pol.Warntime != 15 || len(pol.Prefixes) != 1 || len(pol.Types) != 0 {
+		t.Errorf("Unexpected policy after replace: %s", rr.Body.String())
+	}
+
+	rr = policyReq(t, "GET", URL_POLICIES, "", http.StatusOK)
+	json.Unmarshal(rr.Body.Bytes(), &plist)
+	if len(plist.Policies) != 2 || plist.Policies[0].Name != "compute" ||
+		plist.Policies[1].Name != "service" {
+		t.Errorf("Unexpected policy list: %s", rr.Body.String())
+	}
+
+	//Delete
+
+	policyReq(t, "DELETE", URL_POLICIES+"/compute", "", http.StatusNoContent)
+	policyReq(t, "DELETE", URL_POLICIES+"/service", "", http.StatusNoContent)
+	policyReq(t, "DELETE", URL_POLICIES+"/service", "", http.StatusNotFound)
+	policyReq(t, "GET", URL_POLICIES+"/compute", "", http.StatusNotFound)
+
+	if _, _, n := resolveTimeouts("x3000c0s1b0n0"); n != "" {
+		t.Errorf("Policy cache not refreshed after delete, got '%s'", n)
+	}
+}
@@ -0,0 +1,420 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// HSM reconciliation.  HBTD only tells HSM about HB state changes, so if a
+// PATCH is lost or HSM's state is changed behind HBTD's back, the two will
+// disagree until the component's HB state changes again.  Periodically, one
+// instance fetches the State/Flag of the tracked components from HSM,
+// compares them with the HB state and PATCHes HSM with the State/Flag it
+// should have.  The results are kept in the KV store for the API.
+/////////////////////////////////////////////////////////////////////////////
+
+type hbReconcileItem struct {
+	Component string `json:"Component"`
+	HBState   string `json:"HBState"`
+	HSMState  string `json:"HSMState"`
+	HSMFlag   string `json:"HSMFlag"`
+	State     string `json:"State"` //What HSM was told
+	Flag      string `json:"Flag"`
+	Fixed     bool   `json:"Fixed"`
+}
+
+type hbReconcileReport struct {
+	Instance      string            `json:"Instance"`
+	Start         time.Time         `json:"Start"`
+	End           time.Time         `json:"End"`
+	Checked       int               `json:"Checked"`
+	Skipped       int               `json:"Skipped"`  //Pending, suppressed, dead or stopping
+	NotInHSM      int               `json:"NotInHSM"` //Unknown to HSM
+	Found         int               `json:"Found"`
+	Fixed         int               `json:"Fixed"`
+	Error         string            `json:"Error,omitempty"`
+	Discrepancies []hbReconcileItem `json:"Discrepancies"`
+}
+
+// For querying component state from HSM.
+
+type hsmCompQuery struct {
+	ComponentIDs []string `json:"ComponentIDs"`
+	StateOnly    bool     `json:"stateonly"`
+}
+
+type hsmComp struct {
+	ID    string `json:"ID"`
+	State string `json:"State"`
+	Flag  string `json:"Flag"`
+}
+
+type hsmCompArray struct {
+	Components []hsmComp `json:"Components"`
+}
+
+const (
+	HBTD_RECONCILE_LAST_KEY   = "hbtd_reconcile-last"
+	HBTD_RECONCILE_REPORT_KEY = "hbtd_reconcile-report"
+
+	RECONCILE_INTERVAL  = 300 //seconds
+	RECONCILE_POLL      = 10  //seconds
+	RECONCILE_QUERY_MAX = 1000
+)
+
+// The BSI a component should be in for each HB state, same order as
+// createBSI().  Dead and stopping components are left to the HB checker.
+
+var reconcileBSIIndex = map[string]int{
+	HB_STATE_OK:   0,
+	HB_STATE_WARN: 2,
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Thread function.  Periodically reconciles HSM with the HB state, if this
+// instance is the one to do it.
+/////////////////////////////////////////////////////////////////////////////
+
+func reconcile_handler() {
+	for {
+		time.Sleep(RECONCILE_POLL * time.Second)
+
+		ivl := app_params.reconcile_interval.int_param
+		if (ivl == 0) || !hsmReady || (app_params.nosm.int_param != 0) {
+			continue
+		}
+		if !reconcileClaim(time.Now(), ivl) {
+			continue
+		}
+
+		rpt := reconcileHSM(time.Now())
+		if rpt.Error != "" {
+			logHSM.Error(fmt.Sprintf("Error reconciling HSM: %s", rpt.Error),
+				"error", rpt.Error)
+		} else if rpt.Found > 0 {
+			logHSM.Info(fmt.Sprintf("HSM reconciliation found %d discrepancies, fixed %d.",
+				rpt.Found, rpt.Fixed),
+				"checked", rpt.Checked, "found", rpt.Found, "fixed", rpt.Fixed,
+				"duration", rpt.End.Sub(rpt.Start))
+		} else {
+			logHSM.Debug(fmt.Sprintf("HSM reconciliation found no discrepancies in %d components.",
+				rpt.Checked),
+				"checked", rpt.Checked, "duration", rpt.End.Sub(rpt.Start))
+		}
+
+		ba, err := json.Marshal(rpt)
+		if err == nil {
+			err = kvHandle.Store(HBTD_RECONCILE_REPORT_KEY, string(ba))
+		}
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error storing HSM reconciliation report: %v", err),
+				"error", err)
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Claim the next reconciliation run.  Whichever instance first sees that
+// the interval has passed since the last run does it.
+//
+// now(in): Current time.
+// ivl(in): Reconciliation interval, seconds.
+// Return:  true if this instance should do the run, else false.
+/////////////////////////////////////////////////////////////////////////////
+
+func reconcileClaim(now time.Time, ivl int) bool {
+	return claimRun(HBTD_RECONCILE_LAST_KEY, "HSM reconciliation", now, ivl)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Claim the next run of a periodic job done by only one instance.  The time
+// of the last run is kept in the KV store.
+//
+// key(in):  KV key holding the time of the last run.
+// what(in): Job description, for logging.
+// now(in):  Current time.
+// ivl(in):  Job interval, seconds.
+// Return:   true if this instance should do the run, else false.
+/////////////////////////////////////////////////////////////////////////////
+
+func claimRun(key, what string, now time.Time, ivl int) bool {
+	val, exists, err := kvHandle.Get(key)
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching last %s time: %v", what, err),
+			"error", err)
+		return false
+	}
+
+	nowstr := now.UTC().Format(time.RFC3339Nano)
+	if !exists {
+		ok, err := kvHandle.Create(key, nowstr)
+		return (err == nil) && ok
+	}
+
+	last, perr := time.Parse(time.RFC3339Nano, val)
+	if (perr == nil) && (now.Sub(last) < (time.Duration(ivl) * time.Second)) {
+		return false
+	}
+
+	ok, err := kvHandle.TAS(key, val, nowstr)
+	return (err == nil) && ok
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Fetch the State and Flag of components from HSM.
+//
+// xnames(in): Components to fetch.
+// Return:     Map of component to HSM component data;
+//             nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func getHSMStates(xnames []string) (map[string]hsmComp, error) {
+	comps := make(map[string]hsmComp)
+	url := app_params.statemgr_url.string_param + "/" + SM_URL_MID + "/" + SM_URL_QUERY
+
+	for ix := 0; ix < len(xnames); ix += RECONCILE_QUERY_MAX {
+		end := ix + RECONCILE_QUERY_MAX
+		if end > len(xnames) {
+			end = len(xnames)
+		}
+		barr, err := json.Marshal(hsmCompQuery{ComponentIDs: xnames[ix:end],
+			StateOnly: true})
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			(time.Duration(app_params.statemgr_timeout.int_param) *
+				time.Second))
+		req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(barr))
+		req.Header.Set("Content-Type", "application/json")
+		base.SetHTTPUserAgent(req, serviceName)
+
+		rsp, err := htrans.client.Do(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(rsp.Body)
+		base.DrainAndCloseResponseBody(rsp)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if rsp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HSM component query returned '%s'", rsp.Status)
+		}
+
+		var carr hsmCompArray
+		err = json.Unmarshal(body, &carr)
+		if err != nil {
+			return nil, err
+		}
+		for _, comp := range carr.Components {
+			comps[comp.ID] = comp
+		}
+	}
+
+	return comps, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Compare the HB state of all tracked components with their HSM State/Flag
+// and fix any that are wrong.  Components with HB state changes not yet
+// sent to HSM, suppressed notifications or whose HB state is dead or
+// stopping are skipped, since HSM will be told about those anyway.
+//
+// now(in): Current time.
+// Return:  Reconciliation report.
+/////////////////////////////////////////////////////////////////////////////
+
+func reconcileHSM(now time.Time) *hbReconcileReport {
+	rpt := &hbReconcileReport{Instance: serviceName, Start: now,
+		Discrepancies: []hbReconcileItem{}}
+	hbStates := make(map[string]string)
+	var xnames []string
+
+	kvlist, err := kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	if err != nil {
+		rpt.Error = fmt.Sprintf("Can't fetch HB records: %v", err)
+		rpt.End = time.Now()
+		return rpt
+	}
+	ents, err := outboxLoad(now)
+	if err != nil {
+		rpt.Error = fmt.Sprintf("Can't fetch HSM outbox: %v", err)
+		rpt.End = time.Now()
+		return rpt
+	}
+
+	pending := make(map[string]bool)
+	for _, ent := range ents {
+		if !ent.Sent {
+			pending[ent.Component] = true
+		}
+	}
+	hbMapLock.Lock()
+	for _, tmap := range hbTransitionMaps() {
+		for k, v := range tmap {
+			if v != 0 {
+				pending[k] = true
+			}
+		}
+	}
+	hbMapLock.Unlock()
+
+	for _, kv := range kvlist {
+		var hbb hbinfo
+		if json.Unmarshal([]byte(kv.Value), &hbb) != nil {
+			continue
+		}
+		rpt.Checked++
+		state := hbState(&hbb, now.Unix())
+		if _, ok := reconcileBSIIndex[state]; !ok || pending[hbb.Component] ||
+			(suppressedBy(hbb.Component, HB_stopped_warn) != "") {
+			rpt.Skipped++
+			continue
+		}
+		hbStates[hbb.Component] = state
+		xnames = append(xnames, hbb.Component)
+	}
+	sort.Strings(xnames)
+
+	hsmComps, err := getHSMStates(xnames)
+	if err != nil {
+		rpt.Error = fmt.Sprintf("Can't fetch component states from HSM: %v", err)
+		rpt.End = time.Now()
+		return rpt
+	}
+
+	//Put the components HSM has wrong into the BSI for the State/Flag they
+	//should have.
+
+	bsiStart, bsiRestart, bsiStopWarn, bsiStopError, bsiStopExpected := createBSI()
+	bsis := []*smjbulk_v1{&bsiStart, &bsiRestart, &bsiStopWarn,
+		&bsiStopError, &bsiStopExpected}
+	bsiItems := make([][]int, len(bsis))
+
+	for _, xname := range xnames {
+		comp, ok := hsmComps[xname]
+		if !ok {
+			rpt.NotInHSM++
+			continue
+		}
+		bsi := bsis[reconcileBSIIndex[hbStates[xname]]]
+		if (comp.State == bsi.State) && (comp.Flag == bsi.Flag) {
+			continue
+		}
+		ix := reconcileBSIIndex[hbStates[xname]]
+		bsi.ComponentIDs = append(bsi.ComponentIDs, xname)
+		bsiItems[ix] = append(bsiItems[ix], len(rpt.Discrepancies))
+		rpt.Discrepancies = append(rpt.Discrepancies, hbReconcileItem{
+			Component: xname, HBState: hbStates[xname],
+			HSMState: comp.State, HSMFlag: comp.Flag,
+			State: bsi.State, Flag: bsi.Flag})
+	}
+	rpt.Found = len(rpt.Discrepancies)
+
+	//Send the fixes.  This is serialized with the sending of HB state
+	//changes so they don't get interleaved.
+
+	hsmSendLock.Lock()
+	for _, bsi := range bsis {
+		if len(bsi.ComponentIDs) > 0 {
+			bsi.ExtendedInfo.Message += " (reconciled)"
+			bsi.needSend = true
+			hsmWG.Add(1)
+			go send_sm_patch(bsi)
+		}
+	}
+	hsmWG.Wait()
+	hsmSendLock.Unlock()
+
+	for ix, bsi := range bsis {
+		if !bsi.needSend || !bsi.sentOK {
+			continue
+		}
+		for _, item := range bsiItems[ix] {
+			rpt.Discrepancies[item].Fixed = true
+			rpt.Fixed++
+		}
+	}
+	mReconcileFixes.Add(float64(rpt.Fixed))
+
+	rpt.End = time.Now()
+	return rpt
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Entry point for GET /hmi/v1/reconcile.  Returns the report of the last
+// HSM reconciliation run, done by any instance.
+/////////////////////////////////////////////////////////////////////////////
+
+func reconcileIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	var rpt hbReconcileReport
+	errinst := URL_RECONCILE
+
+	val, exists, err := kvHandle.Get(HBTD_RECONCILE_REPORT_KEY)
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GET operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if !exists {
+		pdet := base.NewProblemDetails("about:blank",
+			"Not Found",
+			"No HSM reconciliation has been done",
+			errinst, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal([]byte(val), &rpt)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", val, err),
+			"key", HBTD_RECONCILE_REPORT_KEY, "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Error unmarshalling JSON for HSM reconciliation report",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, &rpt, errinst)
+}
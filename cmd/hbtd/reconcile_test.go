@@ -0,0 +1,217 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+)
+
+// Component states the fake HSM returns from a component query.
+
+var fakeHSMComps = map[string]hsmComp{}
+
+func fakeHSMReconcileHandler(w http.ResponseWriter, req *http.Request) {
+	var query hsmCompQuery
+	var carr hsmCompArray
+
+	if !strings.HasSuffix(req.URL.Path, "/"+SM_URL_MID+"/"+SM_URL_QUERY) {
+		fakeHSMPatchHandler(w, req)
+		return
+	}
+	if json.NewDecoder(req.Body).Decode(&query) != nil || !query.StateOnly {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, id := range query.ComponentIDs {
+		if comp, ok := fakeHSMComps[id]; ok {
+			carr.Components = append(carr.Components, comp)
+		}
+	}
+	ba, _ := json.Marshal(&carr)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ba)
+}
+
+// Test HSM reconciliation and the report API.
+
+func TestReconcile(t *testing.T) {
+	var kval string
+	var rpt hbReconcileReport
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	routes := generateRoutes()
+	router = newRouter(routes)
+	clearOutbox()
+
+	srv := httptest.NewServer(http.HandlerFunc(fakeHSMReconcileHandler))
+	defer srv.Close()
+
+	htrans.transport = &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	htrans.client = &http.Client{Transport: htrans.transport,
+		Timeout: (20 * time.Second),
+	}
+	app_params.statemgr_url.string_param = srv.URL
+	app_params.statemgr_timeout.int_param = 5
+	app_params.nosm.int_param = 0
+	app_params.warntime.int_param = 10
+	app_params.errtime.int_param = 30
+	testMode = true
+	setSMRVal(http.StatusOK)
+
+	compLock.Lock()
+	startComps = []string{}
+	stopWarnComps = []string{}
+	compLock.Unlock()
+
+	//Claiming a run.  Only one of the instances seeing no last run time
+	//gets the first run.
+
+	kvHandle.Delete(HBTD_RECONCILE_LAST_KEY)
+	now := time.Now()
+	var wg sync.WaitGroup
+	var nclaimed int32
+	for ix := 0; ix < 8; ix++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if reconcileClaim(now, 60) {
+				atomic.AddInt32(&nclaimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if nclaimed != 1 {
+		t.Errorf("First reconciliation run claimed %d times.", nclaimed)
+	}
+	if reconcileClaim(now.Add(30*time.Second), 60) {
+		t.Errorf("Reconciliation run claimed before interval passed.")
+	}
+	if !reconcileClaim(now.Add(61*time.Second), 60) {
+		t.Errorf("Reconciliation run not claimed after interval passed.")
+	}
+
+	policyReq(t, "GET", URL_RECONCILE, "", http.StatusNotFound)
+
+	//Components: OK but HSM has it as dead, warning but HSM has it as OK,
+	//OK and HSM agrees, OK but with a pending outbox entry, not in HSM,
+	//going away and HSM has it as Standby.
+
+	keys := []string{"x3006c0s0b0n0", "x3006c0s1b0n0", "x3006c0s2b0n0",
+		"x3006c0s3b0n0", "x3006c0s4b0n0", "x3006c0s5b0n0"}
+	ages := []int64{1, 15, 1, 1, 1, 1}
+	fakeHSMComps = map[string]hsmComp{
+		keys[0]: {ID: keys[0], State: base.StateStandby.String(), Flag: base.FlagAlert.String()},
+		keys[1]: {ID: keys[1], State: base.StateReady.String(), Flag: base.FlagOK.String()},
+		keys[2]: {ID: keys[2], State: base.StateReady.String(), Flag: base.FlagOK.String()},
+		keys[3]: {ID: keys[3], State: base.StateStandby.String(), Flag: base.FlagAlert.String()},
+		keys[5]: {ID: keys[5], State: base.StateStandby.String(), Flag: base.FlagOK.String()},
+	}
+	for ix, key := range keys {
+		make_key(&kval, key, now.Unix()-ages[ix])
+		if ix == 5 {
+			kval = strings.Replace(kval, `"Last_hb_status":"OK"`,
+				`"Last_hb_status":"Shutdown"`, 1)
+		}
+		kvHandle.Store(key, kval)
+	}
+	outboxFlush([]*hbOutboxEntry{{Component: keys[3],
+		Transition: hbTransitionName(HB_started), Seq: 1, Time: now}})
+
+	rp := reconcileHSM(now)
+	if rp.Error != "" {
+		t.Fatalf("Reconciliation error: %s", rp.Error)
+	}
+	if rp.Found != 2 || rp.Fixed != 2 || len(rp.Discrepancies) != 2 {
+		t.Fatalf("Unexpected reconciliation report: %+v", rp)
+	}
+	if rp.Discrepancies[0].Component != keys[0] || !rp.Discrepancies[0].Fixed ||
+		rp.Discrepancies[0].State != base.StateReady.String() ||
+		rp.Discrepancies[0].Flag != base.FlagOK.String() ||
+		rp.Discrepancies[0].HSMFlag != base.FlagAlert.String() {
+		t.Errorf("Unexpected discrepancy: %+v", rp.Discrepancies[0])
+	}
+	if rp.Discrepancies[1].Component != keys[1] || !rp.Discrepancies[1].Fixed ||
+		rp.Discrepancies[1].HBState != HB_STATE_WARN ||
+		rp.Discrepancies[1].Flag != base.FlagWarning.String() {
+		t.Errorf("Unexpected discrepancy: %+v", rp.Discrepancies[1])
+	}
+	if rp.NotInHSM < 1 || rp.Skipped < 2 {
+		t.Errorf("Skipped components not counted: %+v", rp)
+	}
+
+	compLock.Lock()
+	if len(startComps) != 1 || startComps[0] != keys[0] {
+		t.Errorf("Wrong HSM start components: %v", startComps)
+	}
+	if len(stopWarnComps) != 1 || stopWarnComps[0] != keys[1] {
+		t.Errorf("Wrong HSM stop-warn components: %v", stopWarnComps)
+	}
+	compLock.Unlock()
+
+	//HSM failure is reported.
+
+	setSMRVal(http.StatusInternalServerError)
+	rp = reconcileHSM(now)
+	if rp.Found != 2 || rp.Fixed != 0 || rp.Discrepancies[0].Fixed {
+		t.Errorf("Unexpected reconciliation report after HSM failure: %+v", rp)
+	}
+	setSMRVal(http.StatusOK)
+
+	//Report API.
+
+	ba, _ := json.Marshal(rp)
+	kvHandle.Store(HBTD_RECONCILE_REPORT_KEY, string(ba))
+	rr := policyReq(t, "GET", URL_RECONCILE, "", http.StatusOK)
+	err := json.Unmarshal(rr.Body.Bytes(), &rpt)
+	if err != nil {
+		t.Fatalf("ERROR unmarshalling reconciliation report: %v", err)
+	}
+	if rpt.Found != 2 || len(rpt.Discrepancies) != 2 {
+		t.Errorf("Unexpected reconciliation report from API: %s", rr.Body.String())
+	}
+
+	for _, key := range keys {
+		kvHandle.Delete(key)
+	}
+	kvHandle.Delete(HBTD_RECONCILE_REPORT_KEY)
+	kvHandle.Delete(HBTD_RECONCILE_LAST_KEY)
+	clearOutbox()
+}
@@ -0,0 +1,190 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// HB audit sharding.  Each running instance (as seen by its life key) owns
+// a share of the components, assigned by consistent hashing of their
+// cabinets onto a ring of instance points, and audits only those.  Whole
+// cabinets are assigned so that topology correlation sees every component
+// under a slot, chassis or cabinet.
+// The ring is rebuilt from the life keys on every HB check, so shards are
+// rebalanced as instances come and go, moving as few components as
+// possible.
+/////////////////////////////////////////////////////////////////////////////
+
+// Points on the ring per instance.  More points spreads components more
+// evenly between instances.
+
+const SHARD_VNODES = 64
+
+type hbShardRing struct {
+	members []string //Sorted instance (life) keys
+	points  []uint32 //Sorted ring points
+	owners  []string //Instance key owning each point
+}
+
+// Instances seen by the last HB check, for spotting rebalances.
+
+var shardMembers string
+
+func shardHash(str string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(str))
+	return h.Sum32()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Create a consistent hash ring.
+//
+// members(in): Instance keys.
+// Return:      Hash ring.
+/////////////////////////////////////////////////////////////////////////////
+
+func newShardRing(members []string) *hbShardRing {
+	ring := &hbShardRing{members: append([]string{}, members...)}
+	sort.Strings(ring.members)
+
+	type point struct {
+		hash  uint32
+		owner string
+	}
+	pts := make([]point, 0, len(members)*SHARD_VNODES)
+	for _, mem := range ring.members {
+		for ix := 0; ix < SHARD_VNODES; ix++ {
+			pts = append(pts, point{hash: shardHash(fmt.Sprintf("%s#%d", mem, ix)),
+				owner: mem})
+		}
+	}
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].hash == pts[j].hash {
+			return pts[i].owner < pts[j].owner
+		}
+		return pts[i].hash < pts[j].hash
+	})
+
+	for _, pt := range pts {
+		ring.points = append(ring.points, pt.hash)
+		ring.owners = append(ring.owners, pt.owner)
+	}
+	return ring
+}
+
+// Find the instance which owns a key: the first point on the ring at or
+// after the key's hash.  Returns "" if the ring is empty.
+
+func (ring *hbShardRing) owner(key string) string {
+	if len(ring.points) == 0 {
+		return ""
+	}
+	hash := shardHash(key)
+	ix := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i] >= hash
+	})
+	if ix == len(ring.points) {
+		ix = 0
+	}
+	return ring.owners[ix]
+}
+
+// Find the key a component is sharded on: its cabinet, or the component
+// itself if it isn't in one.
+
+func shardKey(xname string) string {
+	for pname := xname; pname != ""; pname = xnametypes.GetHMSCompParent(pname) {
+		ptype := xnametypes.GetHMSType(pname)
+		if ptype == xnametypes.Cabinet {
+			return pname
+		}
+		if ptype == xnametypes.HMSTypeInvalid {
+			break
+		}
+	}
+	return xname
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Build the hash ring for an HB check from the current life keys.  If this
+// instance's own life key isn't there, the ring is built from the others,
+// so it owns no components while they go on checking their shards.  If the
+// life keys can't be read the ring is empty and nothing is checked this
+// time, since other instances may still be checking their shards.  Only if
+// there are no life keys at all (or no instance key) is there no ring, and
+// the caller falls back to the leader checking everything.
+//
+// Args:   None.
+// Return: Hash ring, or nil if sharding can't be done.
+/////////////////////////////////////////////////////////////////////////////
+
+func checkerShardRing() *hbShardRing {
+	var members []string
+
+	if instanceKey == "" {
+		return nil
+	}
+
+	kvlist, err := getLifeKeys()
+	if err != nil {
+		logChecker.Error(fmt.Sprintf("Error fetching life keys, skipping HB check: %v", err),
+			"error", err)
+		return newShardRing(nil)
+	}
+	if len(kvlist) == 0 {
+		logTrace(logChecker, "No life keys found, not sharding HB check.")
+		return nil
+	}
+
+	found := false
+	for _, kv := range kvlist {
+		members = append(members, kv.Key)
+		if kv.Key == instanceKey {
+			found = true
+		}
+	}
+	if !found {
+		logChecker.Warn(fmt.Sprintf("Life key '%s' not found, leaving HB check to the other instances.",
+			instanceKey), "instance", instanceKey)
+	}
+
+	ring := newShardRing(members)
+	mCheckerInstances.Set(float64(len(ring.members)))
+	memstr := strings.Join(ring.members, ",")
+	if memstr != shardMembers {
+		if shardMembers != "" {
+			mCheckerRebalances.Inc()
+		}
+		shardMembers = memstr
+		logChecker.Info(fmt.Sprintf("HB check shards rebalanced, %d instances.",
+			len(ring.members)), "instances", len(ring.members), "instance", instanceKey)
+	}
+	return ring
+}
@@ -0,0 +1,274 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Leader election stand-in.
+
+type stubElection struct {
+	leader bool
+}
+
+func (se *stubElection) IsLeader() bool {
+	return se.leader
+}
+
+func (se *stubElection) Leader() string {
+	return "stub"
+}
+
+func (se *stubElection) Resign() error {
+	return nil
+}
+
+// HB store whose range scans of life keys fail.
+
+type failRangeStore struct {
+	hbStore
+}
+
+func (fs *failRangeStore) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	if strings.HasPrefix(keystart, HBTD_LIFE_KEY_PRE) {
+		return nil, fmt.Errorf("range scan failed")
+	}
+	return fs.hbStore.GetRange(keystart, keyend)
+}
+
+// Remove all life keys, so tests only see the instances they create.
+
+func clearLifeKeys() {
+	kvlist, _ := kvHandle.GetRange(HBTD_LIFE_KEY_PRE, HBTD_LIFE_KEY_PRE+"~")
+	for _, kv := range kvlist {
+		kvHandle.Delete(kv.Key)
+	}
+}
+
+// Test the consistent hash ring.
+
+func TestShardRing(t *testing.T) {
+	var comps []string
+
+	for ix := 0; ix < 3000; ix++ {
+		comps = append(comps, fmt.Sprintf("x%dc%ds%db0n0", ix/64, (ix/8)%8, ix%8))
+	}
+
+	if owner := newShardRing(nil).owner(comps[0]); owner != "" {
+		t.Errorf("Empty ring has an owner: '%s'", owner)
+	}
+
+	members := []string{"hbtd_lifekey-1", "hbtd_lifekey-2", "hbtd_lifekey-3"}
+	ring := newShardRing(members)
+	counts := make(map[string]int)
+	owners := make(map[string]string)
+	for _, comp := range comps {
+		owners[comp] = ring.owner(comp)
+		counts[owners[comp]]++
+	}
+	for _, mem := range members {
+		if counts[mem] < len(comps)/6 {
+			t.Errorf("Uneven shards: %v", counts)
+			break
+		}
+	}
+
+	//Order of members doesn't matter
+
+	ring2 := newShardRing([]string{members[2], members[0], members[1]})
+	for _, comp := range comps {
+		if ring2.owner(comp) != owners[comp] {
+			t.Fatalf("Owner of '%s' depends on member order.", comp)
+		}
+	}
+
+	//When an instance goes away, only its components move.
+
+	ring = newShardRing(members[:2])
+	for _, comp := range comps {
+		owner := ring.owner(comp)
+		if (owners[comp] != members[2]) && (owner != owners[comp]) {
+			t.Fatalf("'%s' moved from '%s' to '%s'", comp, owners[comp], owner)
+		}
+		if owner == members[2] {
+			t.Fatalf("'%s' still owned by departed instance.", comp)
+		}
+	}
+
+	//Components are sharded by cabinet.
+
+	for comp, key := range map[string]string{"x3000c0s1b0n0": "x3000",
+		"x3000m0": "x3000", "x3000": "x3000", "d0w1": "d0w1", "foo": "foo"} {
+		if sk := shardKey(comp); sk != key {
+			t.Errorf("Expected shard key '%s' for '%s', got '%s'", key, comp, sk)
+		}
+	}
+}
+
+// Test two instances each checking their own shard of the components.
+
+func TestShardedChecker(t *testing.T) {
+	var kval string
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	kill_sm_goroutines()
+	clearLifeKeys()
+
+	origKey := instanceKey
+	app_params.debug_level.int_param = 0
+	app_params.warntime.int_param = 5
+	app_params.errtime.int_param = 60
+	app_params.check_interval.int_param = 3600
+	defer func() {
+		app_params.check_interval.int_param = 0
+		instanceKey = origKey
+		shardMembers = ""
+		clearLifeKeys()
+		clearOutbox()
+	}()
+
+	//Life key numbers as createInstanceKey() makes them, not zero-padded.
+
+	inst := []string{HBTD_LIFE_KEY_PRE + "1804289383", HBTD_LIFE_KEY_PRE + "846930886"}
+	for _, ik := range inst {
+		kvHandle.TempKey(ik, "1")
+	}
+
+	var comps []string
+	now := time.Now().Unix()
+	for ix := 0; ix < 20; ix++ {
+		comp := fmt.Sprintf("x%dc0s%db0n0", 7100+(ix/2), ix%2)
+		comps = append(comps, comp)
+		make_key(&kval, comp, now-7)
+		kvHandle.Store(comp, kval)
+	}
+
+	//Each instance warns about its own components only.
+
+	ring := newShardRing(inst)
+	warned := make(map[string]string)
+	for _, ik := range inst {
+		instanceKey = ik
+		testPrintClear()
+		hb_checker()
+		tpd := testPrintData()
+		for _, comp := range comps {
+			if !strings.Contains(tpd, "for '"+comp+"'") {
+				continue
+			}
+			if owner := ring.owner(shardKey(comp)); owner != ik {
+				t.Errorf("'%s' checked by '%s', owned by '%s'", comp, ik, owner)
+			}
+			if prev, ok := warned[comp]; ok {
+				t.Errorf("'%s' checked by both '%s' and '%s'", comp, prev, ik)
+			}
+			warned[comp] = ik
+		}
+	}
+	if len(warned) != len(comps) {
+		t.Errorf("Expected %d components checked, got %d", len(comps), len(warned))
+	}
+
+	//An instance whose life key is gone isn't in the ring; the other one
+	//takes over all the components.  An instance which can't find its own
+	//life key checks none of them, even if it is the leader.
+
+	kvHandle.Delete(inst[1])
+	instanceKey = inst[0]
+	ring = checkerShardRing()
+	if (ring == nil) || (len(ring.members) != 1) {
+		t.Fatalf("Expected a 1-instance ring, got %v", ring)
+	}
+	instanceKey = inst[1]
+	ring = checkerShardRing()
+	if (ring == nil) || (len(ring.members) != 1) || (ring.members[0] != inst[0]) {
+		t.Errorf("Expected a ring of the other instance, got %v", ring)
+	}
+
+	defer func() { hbElect = nil }()
+	checkAll := func(leader bool) int {
+		for _, comp := range comps {
+			make_key(&kval, comp, time.Now().Unix()-7)
+			kvHandle.Store(comp, kval)
+		}
+		hbElect = &stubElection{leader: leader}
+		testPrintClear()
+		hb_checker()
+		tpd := testPrintData()
+		nwarn := 0
+		for _, comp := range comps {
+			if strings.Contains(tpd, "for '"+comp+"'") {
+				nwarn++
+			}
+		}
+		return nwarn
+	}
+
+	if nwarn := checkAll(true); nwarn != 0 {
+		t.Errorf("Leader with no life key checked %d components", nwarn)
+	}
+
+	//If the life keys can't be read, no one checks.
+
+	origKV := kvHandle
+	kvHandle = &failRangeStore{hbStore: origKV}
+	nwarn := checkAll(true)
+	kvHandle = origKV
+	if nwarn != 0 {
+		t.Errorf("Leader which can't read life keys checked %d components", nwarn)
+	}
+
+	//With no life keys at all there is no ring; only the leader checks,
+	//and checks everything.
+
+	kvHandle.Delete(inst[0])
+	if checkerShardRing() != nil {
+		t.Errorf("Got a ring with no life keys.")
+	}
+	for _, leader := range []bool{false, true} {
+		nwarn := checkAll(leader)
+		if (leader && (nwarn != len(comps))) || (!leader && (nwarn != 0)) {
+			t.Errorf("Leader %v: expected %d components checked, got %d", leader,
+				len(comps), nwarn)
+		}
+	}
+
+	for _, comp := range comps {
+		kvHandle.Delete(comp)
+	}
+	hbMapLock.Lock()
+	for _, comp := range comps {
+		delete(StopWarnMap, comp)
+	}
+	hbMapLock.Unlock()
+}
@@ -0,0 +1,672 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cray-HPE/hms-hmetcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// HB store.  Everything HBTD keeps in its K/V store (HB records, policies,
+// suppressions, the HSM outbox, life keys) goes through this interface, so
+// that the backing store can be ETCD, memcached or Redis.  The backend is
+// picked by the scheme of the kv_url parameter:
+//
+//   mem:, http://, https://           ETCD (or in-memory ETCD stand-in)
+//   memcached://host:port[,host:port] memcached
+//   redis://..., rediss://...         Redis
+/////////////////////////////////////////////////////////////////////////////
+
+// Key/value pair, as returned by range scans.
+
+type hbKV struct {
+	Key   string
+	Value string
+}
+
+// A store or delete, for batched writes.
+
+type hbStoreOp struct {
+	Key    string
+	Value  string
+	Delete bool
+}
+
+type hbStore interface {
+	// Get a key's value.  Returns the value, whether the key exists and
+	// an error if the store could not be accessed.
+	Get(key string) (string, bool, error)
+
+	// Get the values of a list of keys, in as few round trips as the
+	// backend allows.  Keys that don't exist are left out of the returned
+	// map.
+	GetMulti(keys []string) (map[string]string, error)
+
+	// Create or update a key.
+	Store(key string, val string) error
+
+	// Create a key if it doesn't exist.  Returns true if the key was
+	// created, false if it already existed.
+	Create(key string, val string) (bool, error)
+
+	// Delete a key.  Deleting a key that doesn't exist is not an error.
+	Delete(key string) error
+
+	// Get all keys where (key >= keystart) && (key <= keyend).  The
+	// returned list is NOT sorted.  No matches is not an error.
+	GetRange(keystart string, keyend string) ([]hbKV, error)
+
+	// Set a key to 'setval' if its current value is 'testval'.  Returns
+	// true if the key was set.  Callers must not rely on the behavior for
+	// keys that don't exist, which differs between backends.
+	TAS(key string, testval string, setval string) (bool, error)
+
+	// Acquire the inter-instance lock, waiting up to 'tosec' seconds for
+	// it.  Returns an error if it could not be acquired.  The lock is
+	// released if the holding instance goes away.
+	DistTimedLock(tosec int) error
+
+	// Release the inter-instance lock.
+	DistUnlock() error
+
+	// Apply a batch of stores and deletes, in one transaction where the
+	// backend supports it.  Batches must have no more than STORE_BATCH_MAX
+	// ops and must not touch a key more than once; use storeApply() for
+	// arbitrary lists of writes.
+	Batch(ops []hbStoreOp) error
+
+	// Start campaigning for leadership as 'id'.  Doesn't wait to be
+	// elected.
+	Campaign(id string) (hbElection, error)
+
+	// Call 'cb' with a key's value whenever the key is created or changed,
+	// until the returned cancel func is called.  Deletions aren't reported.
+	Watch(key string, cb func(val string)) (func(), error)
+
+	// Create a key which exists only as long as this instance is running,
+	// or change the value of one this instance created.
+	TempKey(key string, val string) error
+
+	// Release the store's resources.
+	Close() error
+}
+
+const (
+	STORE_SCHEME_MEMCACHED = "memcached"
+	STORE_SCHEME_REDIS     = "redis"
+	STORE_SCHEME_REDISS    = "rediss"
+
+	// Key used for the inter-instance lock by backends without a native
+	// distributed lock.
+	STORE_LOCK_KEY = "hbtd_distlock"
+
+	// Time-to-live of life keys and locks in backends which implement them
+	// as expiring keys.  They are refreshed at a third of this.
+	STORE_LEASE_TTL = 15 * time.Second
+
+	// How often a backend without blocking locks retries a held lock.
+	STORE_LOCK_POLL = 250 * time.Millisecond
+
+	// Most ops in one batch.  ETCD's default limit (--max-txn-ops) is 128.
+	STORE_BATCH_MAX     = 128
+	STORE_BATCH_TIMEOUT = 10 * time.Second
+)
+
+// How often backends without native watches check a watched key.
+
+var storeWatchPoll = time.Second
+
+/////////////////////////////////////////////////////////////////////////////
+// Open an HB store.  The backend is chosen by the URL's scheme; anything
+// without a memcached or Redis scheme is handed to ETCD.
+//
+// url(in): Store URL (kv_url parameter).
+// Return:  HB store, nil on success, else error.
+/////////////////////////////////////////////////////////////////////////////
+
+func openStore(url string) (hbStore, error) {
+	scheme := ""
+	if ix := strings.Index(url, "://"); ix > 0 {
+		scheme = strings.ToLower(url[:ix])
+	}
+
+	switch scheme {
+	case STORE_SCHEME_MEMCACHED:
+		return openMemcachedStore(url)
+	case STORE_SCHEME_REDIS, STORE_SCHEME_REDISS:
+		return openRedisStore(url)
+	}
+
+	kvi, err := hmetcd.Open(url, "")
+	if err != nil {
+		return nil, err
+	}
+	if (scheme != "http") && (scheme != "https") {
+		return &etcdStore{kvi: kvi}, nil
+	}
+
+	//hmetcd has no multi-op transactions or elections, so those need a
+	//client of our own.
+
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{url},
+		DialTimeout: 10 * time.Second,
+	})
+	if err != nil {
+		kvi.Close()
+		return nil, err
+	}
+	es := &etcdStore{kvi: kvi, cli: cli, rangeExcl: true}
+	es.temp = newStoreTempKeys(es.tempPut)
+	return es, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Apply a list of stores and deletes in batches.  If a key appears more
+// than once only the last op for it is applied.  If a batch fails its ops
+// are retried one at a time, so that one bad key doesn't cost the rest.
+//
+// st(in):  HB store.
+// ops(in): Stores and deletes to apply.
+// Return:  Keys whose ops failed.
+/////////////////////////////////////////////////////////////////////////////
+
+func storeApply(st hbStore, ops []hbStoreOp) []string {
+	var batch []hbStoreOp
+	var failed []string
+
+	last := make(map[string]int, len(ops))
+	for ix, op := range ops {
+		last[op.Key] = ix
+	}
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		mKVBatchOps.Observe(float64(len(batch)))
+		err := st.Batch(batch)
+		if err != nil {
+			logKV.Warn(fmt.Sprintf("batch of %d K/V writes failed, writing one at a time: %v",
+				len(batch), err), "ops", len(batch), "error", err)
+			mKVBatchFallbacks.Inc()
+			for _, op := range batch {
+				if op.Delete {
+					err = st.Delete(op.Key)
+				} else {
+					err = st.Store(op.Key, op.Value)
+				}
+				if err != nil {
+					logKV.Error(fmt.Sprintf("Error writing key '%s' to KV store: %v", op.Key, err),
+						"key", op.Key, "delete", op.Delete, "error", err)
+					failed = append(failed, op.Key)
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for ix, op := range ops {
+		if last[op.Key] != ix {
+			continue
+		}
+		batch = append(batch, op)
+		if len(batch) >= STORE_BATCH_MAX {
+			flush()
+		}
+	}
+	flush()
+
+	return failed
+}
+
+// Split a list of keys into chunks of at most 'max' keys and call 'get'
+// with each, for backends whose multi-key reads have a size limit.
+//
+// keys(in): Keys to read.
+// max(in):  Most keys per chunk.
+// get(in):  Func to read a chunk of keys into the map.
+// Return:   Map of key to value, for the keys that exist; error, if any.
+
+func storeGetChunked(keys []string, max int,
+	get func(keys []string, vals map[string]string) error) (map[string]string, error) {
+	vals := make(map[string]string, len(keys))
+	for start := 0; start < len(keys); start += max {
+		end := start + max
+		if end > len(keys) {
+			end = len(keys)
+		}
+		err := get(keys[start:end], vals)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// Keep a lease-type key (life key or lock) alive until told to stop.  If
+// a refresh fails the key is left to expire and the refresher exits, since
+// another instance may now own it.
+//
+// refresh(in): Func to extend the key's lifetime.  Returns false if the
+//              key is no longer ours.
+// stop(in):    Closed to stop refreshing.
+// Return:      None.
+
+func storeKeepAlive(refresh func() (bool, error), stop chan struct{}) {
+	ticker := time.NewTicker(STORE_LEASE_TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ok, err := refresh()
+			if err != nil {
+				logKV.Error(fmt.Sprintf("Error refreshing K/V lease: %v", err),
+					"error", err)
+				continue
+			}
+			if !ok {
+				logKV.Warn("K/V lease lost.")
+				return
+			}
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Watch a key by polling it, for backends without native watches.
+//
+// get(in): Func to read the key.
+// key(in): Key to watch.
+// cb(in):  Func to call with the key's new value.
+// Return:  Func to cancel the watch.
+/////////////////////////////////////////////////////////////////////////////
+
+func storePollWatch(get func(key string) (string, bool, error), key string,
+	cb func(val string)) func() {
+	var once sync.Once
+
+	stop := make(chan struct{})
+	poll := storeWatchPoll
+	prev, _, _ := get(key)
+
+	go func() {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				val, exists, err := get(key)
+				if (err != nil) || !exists || (val == prev) {
+					continue
+				}
+				prev = val
+				cb(val)
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Temporary keys created by this instance, for backends which implement
+// them by rewriting them before they expire.  Each key is rewritten with
+// its current value every third of STORE_LEASE_TTL until the store is
+// closed.
+/////////////////////////////////////////////////////////////////////////////
+
+type storeTempKeys struct {
+	mutex  sync.Mutex
+	vals   map[string]string
+	write  func(key string, val string) error
+	stop   chan struct{}
+	closed bool
+}
+
+func newStoreTempKeys(write func(key string, val string) error) *storeTempKeys {
+	return &storeTempKeys{vals: make(map[string]string), write: write,
+		stop: make(chan struct{})}
+}
+
+// Create or update a temp key.  Writes are serialized so a refresh can't
+// put back a value which was just changed.
+
+func (tk *storeTempKeys) set(key string, val string) error {
+	tk.mutex.Lock()
+	defer tk.mutex.Unlock()
+
+	if tk.closed {
+		return fmt.Errorf("K/V store is closed")
+	}
+	err := tk.write(key, val)
+	if err != nil {
+		return err
+	}
+	_, exists := tk.vals[key]
+	tk.vals[key] = val
+	if !exists {
+		go storeKeepAlive(func() (bool, error) {
+			tk.mutex.Lock()
+			defer tk.mutex.Unlock()
+			return true, tk.write(key, tk.vals[key])
+		}, tk.stop)
+	}
+	return nil
+}
+
+// Stop refreshing the temp keys, leaving them to expire.
+
+func (tk *storeTempKeys) close() {
+	tk.mutex.Lock()
+	defer tk.mutex.Unlock()
+	if !tk.closed {
+		close(tk.stop)
+		tk.closed = true
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// ETCD HB store, a thin layer over the hmetcd package.  Also used for the
+// hmetcd in-memory store.
+/////////////////////////////////////////////////////////////////////////////
+
+type etcdStore struct {
+	kvi       hmetcd.Kvi
+	cli       *clientv3.Client //Batches, elections and temp keys, nil for the in-memory store
+	temp      *storeTempKeys
+	tempLease clientv3.LeaseID
+	rangeExcl bool //GetRange's end is exclusive, as it is in ETCD
+}
+
+// hmetcd's in-memory store keeps its data in one map shared by all handles
+// on it in the process, but each handle has its own lock and GetRange takes
+// none, so ops on the in-memory store are serialized here.
+
+var etcdMemLock sync.Mutex
+
+func (es *etcdStore) memLock() func() {
+	if es.cli != nil {
+		return func() {}
+	}
+	etcdMemLock.Lock()
+	return etcdMemLock.Unlock
+}
+
+func (es *etcdStore) Get(key string) (string, bool, error) {
+	defer es.memLock()()
+	return es.kvi.Get(key)
+}
+
+func (es *etcdStore) GetMulti(keys []string) (map[string]string, error) {
+	if es.cli == nil {
+		defer es.memLock()()
+		vals := make(map[string]string, len(keys))
+		for _, key := range keys {
+			val, ok, err := es.kvi.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				vals[key] = val
+			}
+		}
+		return vals, nil
+	}
+
+	//One transaction of Gets per chunk, as with batched writes.
+
+	return storeGetChunked(keys, STORE_BATCH_MAX, func(chunk []string, vals map[string]string) error {
+		eops := make([]clientv3.Op, 0, len(chunk))
+		for _, key := range chunk {
+			eops = append(eops, clientv3.OpGet(key))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), STORE_BATCH_TIMEOUT)
+		defer cancel()
+		rsp, err := es.cli.Txn(ctx).Then(eops...).Commit()
+		if err != nil {
+			return err
+		}
+		for _, orsp := range rsp.Responses {
+			for _, kv := range orsp.GetResponseRange().Kvs {
+				vals[string(kv.Key)] = string(kv.Value)
+			}
+		}
+		return nil
+	})
+}
+
+func (es *etcdStore) Store(key string, val string) error {
+	defer es.memLock()()
+	return es.kvi.Store(key, val)
+}
+
+func (es *etcdStore) Create(key string, val string) (bool, error) {
+	if es.cli == nil {
+		//hmetcd's in-memory TAS sets missing keys, but only this process
+		//uses the in-memory store anyway.
+
+		defer es.memLock()()
+		_, exists, err := es.kvi.Get(key)
+		if (err != nil) || exists {
+			return false, err
+		}
+		return true, es.kvi.Store(key, val)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), STORE_BATCH_TIMEOUT)
+	defer cancel()
+	rsp, err := es.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, val)).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return rsp.Succeeded, nil
+}
+
+func (es *etcdStore) Delete(key string) error {
+	defer es.memLock()()
+	return es.kvi.Delete(key)
+}
+
+func (es *etcdStore) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	defer es.memLock()()
+	if es.rangeExcl {
+		//The next key after keyend.
+		keyend += "\x00"
+	}
+	kvlist, err := es.kvi.GetRange(keystart, keyend)
+	if err != nil {
+		return nil, err
+	}
+	rlist := make([]hbKV, 0, len(kvlist))
+	for _, kv := range kvlist {
+		rlist = append(rlist, hbKV{Key: kv.Key, Value: kv.Value})
+	}
+	return rlist, nil
+}
+
+func (es *etcdStore) TAS(key string, testval string, setval string) (bool, error) {
+	defer es.memLock()()
+	return es.kvi.TAS(key, testval, setval)
+}
+
+func (es *etcdStore) DistTimedLock(tosec int) error {
+	return es.kvi.DistTimedLock(tosec)
+}
+
+func (es *etcdStore) DistUnlock() error {
+	return es.kvi.DistUnlock()
+}
+
+func (es *etcdStore) Batch(ops []hbStoreOp) error {
+	if es.cli == nil {
+		//In-memory store, nothing to gain from a transaction.
+
+		defer es.memLock()()
+		for _, op := range ops {
+			var err error
+			if op.Delete {
+				err = es.kvi.Delete(op.Key)
+			} else {
+				err = es.kvi.Store(op.Key, op.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	eops := make([]clientv3.Op, 0, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			eops = append(eops, clientv3.OpDelete(op.Key))
+		} else {
+			eops = append(eops, clientv3.OpPut(op.Key, op.Value))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), STORE_BATCH_TIMEOUT)
+	defer cancel()
+	_, err := es.cli.Txn(ctx).Then(eops...).Commit()
+	return err
+}
+
+func (es *etcdStore) Campaign(id string) (hbElection, error) {
+	if es.cli == nil {
+		//In-memory store, there can only be one instance.
+		return &soloElection{id: id}, nil
+	}
+	return newEtcdElection(es.cli, id), nil
+}
+
+func (es *etcdStore) Watch(key string, cb func(val string)) (func(), error) {
+	var mutex sync.Mutex
+	cancelled := false
+
+	if es.cli == nil {
+		//hmetcd's in-memory watcher starts watching some time after it is
+		//set up, missing changes made in the meantime.
+
+		return storePollWatch(es.Get, key, cb), nil
+	}
+
+	//hmetcd's watcher only notices a cancel between events, so make sure
+	//nothing is delivered once cancelled.
+
+	hnd, err := es.kvi.WatchWithCB(key, hmetcd.KVC_KEYCHANGE_PUT,
+		func(key string, val string, op int, userdata interface{}) bool {
+			mutex.Lock()
+			stop := cancelled
+			mutex.Unlock()
+			if stop {
+				return false
+			}
+			cb(val)
+			return true
+		}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !cancelled {
+			cancelled = true
+			es.kvi.WatchCBCancel(hnd)
+		}
+	}, nil
+}
+
+func (es *etcdStore) TempKey(key string, val string) error {
+	if es.cli == nil {
+		//In-memory store, keys live as long as the process anyway.
+		return es.Store(key, val)
+	}
+	return es.temp.set(key, val)
+}
+
+// Write a temp key attached to this instance's lease, renewing the lease
+// (or getting a new one if it has lapsed) first.  Called with the temp key
+// lock held.
+
+func (es *etcdStore) tempPut(key string, val string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), STORE_LEASE_TTL/3)
+	defer cancel()
+
+	if es.tempLease != 0 {
+		_, err := es.cli.KeepAliveOnce(ctx, es.tempLease)
+		if err != nil {
+			logKV.Warn(fmt.Sprintf("Can't renew temp key lease, getting a new one: %v",
+				err), "error", err)
+			es.tempLease = 0
+		}
+	}
+	if es.tempLease == 0 {
+		rsp, err := es.cli.Grant(ctx, int64(STORE_LEASE_TTL/time.Second))
+		if err != nil {
+			return err
+		}
+		es.tempLease = rsp.ID
+	}
+	_, err := es.cli.Put(ctx, key, val, clientv3.WithLease(es.tempLease))
+	return err
+}
+
+func (es *etcdStore) Close() error {
+	if es.cli != nil {
+		es.temp.close()
+		es.temp.mutex.Lock()
+		if es.tempLease != 0 {
+			//Take our temp keys with us rather than waiting for them to expire.
+
+			ctx, cancel := context.WithTimeout(context.Background(), STORE_LEASE_TTL/3)
+			es.cli.Revoke(ctx, es.tempLease)
+			cancel()
+			es.tempLease = 0
+		}
+		es.temp.mutex.Unlock()
+		es.cli.Close()
+	}
+	return es.kvi.Close()
+}
@@ -0,0 +1,527 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// memcached HB store.
+//
+// memcached can't enumerate keys, so the keys are also listed in a set of
+// index keys (keys hashed into MC_INDEX_BUCKETS buckets, each bucket a
+// newline-separated list) which range scans read.  Index buckets are
+// updated with compare-and-swap.  Keys are only added to the index when
+// created, so updating an existing key costs no more than a plain set.
+//
+// The lock, leader and life keys are expiring keys refreshed by a
+// goroutine, so they go away on their own if this instance does.
+//
+// NOTE: memcached evicts keys when it runs out of memory, and HBTD has no
+// way to find out.  memcached must be run with enough memory and with
+// eviction disabled (-M), and should not be shared with other
+// applications.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	MC_INDEX_KEY_PRE  = "hbtd_index-"
+	MC_INDEX_BUCKETS  = 64
+	MC_INDEX_RETRIES  = 50
+	MC_GETMULTI_MAX   = 1000
+	MC_CLIENT_TIMEOUT = 5 * time.Second
+)
+
+// The memcached operations the store needs, so tests can use an in-memory
+// stand-in.  Errors are the gomemcache ones (memcache.ErrCacheMiss,
+// memcache.ErrNotStored, memcache.ErrCASConflict).  Get() returns an
+// opaque token to pass to CAS().  An expiration of 0 means never, a
+// negative one expires the key immediately.
+
+type mcClient interface {
+	Get(key string) ([]byte, interface{}, error)
+	GetMulti(keys []string) (map[string][]byte, error)
+	Add(key string, val []byte, exp int32) error
+	Set(key string, val []byte, exp int32) error
+	Replace(key string, val []byte, exp int32) error
+	CAS(key string, val []byte, exp int32, token interface{}) error
+	Delete(key string) error
+	Close() error
+}
+
+type memcachedStore struct {
+	mc        mcClient
+	mutex     sync.Mutex
+	lockToken string
+	lockStop  chan struct{}
+	temp      *storeTempKeys
+}
+
+// Open a memcached HB store.
+//
+// url(in): memcached://host:port[,host:port...]
+// Return:  HB store, nil on success, else error.
+
+func openMemcachedStore(url string) (hbStore, error) {
+	hosts := strings.Split(strings.TrimPrefix(url[strings.Index(url, "://")+3:], "/"), ",")
+	if (len(hosts) == 0) || (hosts[0] == "") {
+		return nil, fmt.Errorf("no memcached servers in URL '%s'", url)
+	}
+
+	client := memcache.New(hosts...)
+	client.Timeout = MC_CLIENT_TIMEOUT
+	err := client.Ping()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return newMemcachedStore(&gomemcacheClient{client: client}), nil
+}
+
+func newMemcachedStore(mc mcClient) *memcachedStore {
+	ms := &memcachedStore{mc: mc}
+	ms.temp = newStoreTempKeys(ms.tempWrite)
+	return ms
+}
+
+func mcIndexKey(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%s%02x", MC_INDEX_KEY_PRE, h.Sum32()%MC_INDEX_BUCKETS)
+}
+
+// Add a key to or remove a key from its index bucket.  Adding always
+// rewrites the bucket, even if the key is already listed, so a concurrent
+// removal based on a stale read of the key will fail and try again.
+//
+// key(in):   Key to add or remove.
+// add(in):   True to add, false to remove.
+// check(in): When removing, called after the bucket is read; the key is
+//            only removed if it returns true.  May be nil.
+// Return:    nil on success, else error.
+
+func (ms *memcachedStore) indexUpdate(key string, add bool, check func() (bool, error)) error {
+	bkey := mcIndexKey(key)
+
+	for try := 0; try < MC_INDEX_RETRIES; try++ {
+		val, token, err := ms.mc.Get(bkey)
+		if err == memcache.ErrCacheMiss {
+			if !add {
+				return nil
+			}
+			err = ms.mc.Add(bkey, []byte(key), 0)
+			if err == memcache.ErrNotStored {
+				continue
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		var keys []string
+		found := false
+		for _, k := range strings.Split(string(val), "\n") {
+			if k == key {
+				found = true
+				if !add {
+					continue
+				}
+			}
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+		if add && !found {
+			keys = append(keys, key)
+		}
+		if !add {
+			if !found {
+				return nil
+			}
+			if check != nil {
+				ok, cerr := check()
+				if (cerr != nil) || !ok {
+					return cerr
+				}
+			}
+		}
+
+		err = ms.mc.CAS(bkey, []byte(strings.Join(keys, "\n")), 0, token)
+		if (err == memcache.ErrCASConflict) || (err == memcache.ErrCacheMiss) ||
+			(err == memcache.ErrNotStored) {
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("memcached index bucket '%s' changed %d times while being updated",
+		bkey, MC_INDEX_RETRIES)
+}
+
+func (ms *memcachedStore) Get(key string) (string, bool, error) {
+	val, _, err := ms.mc.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(val), true, nil
+}
+
+func (ms *memcachedStore) GetMulti(keys []string) (map[string]string, error) {
+	return storeGetChunked(keys, MC_GETMULTI_MAX, func(chunk []string, vals map[string]string) error {
+		mvals, err := ms.mc.GetMulti(chunk)
+		if err != nil {
+			return err
+		}
+		for key, val := range mvals {
+			vals[key] = string(val)
+		}
+		return nil
+	})
+}
+
+func (ms *memcachedStore) Store(key string, val string) error {
+	for try := 0; try < MC_INDEX_RETRIES; try++ {
+		err := ms.mc.Add(key, []byte(val), 0)
+		if err == nil {
+			return ms.indexUpdate(key, true, nil)
+		}
+		if err != memcache.ErrNotStored {
+			return err
+		}
+
+		//Already exists, just update it.  If it was deleted in the
+		//meantime, go back to creating it.
+
+		err = ms.mc.Replace(key, []byte(val), 0)
+		if err != memcache.ErrNotStored {
+			return err
+		}
+	}
+
+	return fmt.Errorf("memcached key '%s' created and deleted %d times while being stored",
+		key, MC_INDEX_RETRIES)
+}
+
+func (ms *memcachedStore) Create(key string, val string) (bool, error) {
+	err := ms.mc.Add(key, []byte(val), 0)
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, ms.indexUpdate(key, true, nil)
+}
+
+func (ms *memcachedStore) Delete(key string) error {
+	err := ms.mc.Delete(key)
+	if (err != nil) && (err != memcache.ErrCacheMiss) {
+		return err
+	}
+
+	//Leave the key in the index if it was stored again in the meantime.
+
+	return ms.indexUpdate(key, false, func() (bool, error) {
+		return ms.missing(key)
+	})
+}
+
+// Check that a key doesn't exist, before removing it from the index.
+
+func (ms *memcachedStore) missing(key string) (bool, error) {
+	_, exists, err := ms.Get(key)
+	return !exists, err
+}
+
+func (ms *memcachedStore) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	var keys []string
+	var kvlist []hbKV
+
+	bkeys := make([]string, 0, MC_INDEX_BUCKETS)
+	for ix := 0; ix < MC_INDEX_BUCKETS; ix++ {
+		bkeys = append(bkeys, fmt.Sprintf("%s%02x", MC_INDEX_KEY_PRE, ix))
+	}
+	buckets, err := ms.mc.GetMulti(bkeys)
+	if err != nil {
+		return nil, err
+	}
+	for _, bval := range buckets {
+		for _, key := range strings.Split(string(bval), "\n") {
+			if (key != "") && (key >= keystart) && (key <= keyend) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for start := 0; start < len(keys); start += MC_GETMULTI_MAX {
+		end := start + MC_GETMULTI_MAX
+		if end > len(keys) {
+			end = len(keys)
+		}
+		vals, err := ms.mc.GetMulti(keys[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys[start:end] {
+			val, ok := vals[key]
+			if ok {
+				kvlist = append(kvlist, hbKV{Key: key, Value: string(val)})
+				continue
+			}
+
+			//Expired life key, or evicted.  Drop it from the index if it
+			//is still gone.
+
+			perr := ms.indexUpdate(key, false, func() (bool, error) {
+				return ms.missing(key)
+			})
+			if perr != nil {
+				logTrace(logKV, fmt.Sprintf("Can't remove missing key '%s' from memcached index: %v",
+					key, perr), "key", key, "error", perr)
+			}
+		}
+	}
+
+	return kvlist, nil
+}
+
+func (ms *memcachedStore) TAS(key string, testval string, setval string) (bool, error) {
+	val, token, err := ms.mc.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if string(val) != testval {
+		return false, nil
+	}
+
+	err = ms.mc.CAS(key, []byte(setval), 0, token)
+	if (err == memcache.ErrCASConflict) || (err == memcache.ErrCacheMiss) ||
+		(err == memcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// memcached has no transactions or multi-key writes, so a batch is just
+// applied one op at a time.
+
+func (ms *memcachedStore) Batch(ops []hbStoreOp) error {
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = ms.Delete(op.Key)
+		} else {
+			err = ms.Store(op.Key, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lease keys (the lock and the leader key) hold their owner's token and
+// expire unless renewed.  Create one if it doesn't exist.
+
+func (ms *memcachedStore) leaseAcquire(key string, token string, ttl time.Duration) (bool, error) {
+	err := ms.mc.Add(key, []byte(token), int32(ttl/time.Second))
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Renew a lease key if we still own it.  A TTL of 0 releases it.
+
+func (ms *memcachedStore) leaseRenew(key string, token string, ttl time.Duration) (bool, error) {
+	exp := int32(ttl / time.Second)
+	if exp == 0 {
+		exp = -1
+	}
+
+	val, ctoken, err := ms.mc.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if string(val) != token {
+		return false, nil
+	}
+	err = ms.mc.CAS(key, val, exp, ctoken)
+	if (err == memcache.ErrCASConflict) || (err == memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (ms *memcachedStore) DistTimedLock(tosec int) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if ms.lockStop != nil {
+		return fmt.Errorf("ERROR: distributed lock already held by this process")
+	}
+
+	token := fmt.Sprintf("%x", rand.Int63())
+	deadline := time.Now().Add(time.Duration(tosec) * time.Second)
+
+	for {
+		ok, err := ms.leaseAcquire(STORE_LOCK_KEY, token, STORE_LEASE_TTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("distributed lock held by another instance")
+		}
+		time.Sleep(STORE_LOCK_POLL)
+	}
+
+	ms.lockToken = token
+	ms.lockStop = make(chan struct{})
+	go storeKeepAlive(func() (bool, error) {
+		return ms.leaseRenew(STORE_LOCK_KEY, token, STORE_LEASE_TTL)
+	}, ms.lockStop)
+	return nil
+}
+
+func (ms *memcachedStore) DistUnlock() error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if ms.lockStop == nil {
+		return nil
+	}
+	close(ms.lockStop)
+	ms.lockStop = nil
+
+	//Expire the lock key right away, but only if it is still ours.
+
+	_, err := ms.leaseRenew(STORE_LOCK_KEY, ms.lockToken, 0)
+	return err
+}
+
+func (ms *memcachedStore) Campaign(id string) (hbElection, error) {
+	return newLeaseElection(ms, id), nil
+}
+
+func (ms *memcachedStore) Watch(key string, cb func(val string)) (func(), error) {
+	return storePollWatch(ms.Get, key, cb), nil
+}
+
+func (ms *memcachedStore) TempKey(key string, val string) error {
+	return ms.temp.set(key, val)
+}
+
+func (ms *memcachedStore) tempWrite(key string, val string) error {
+	err := ms.mc.Set(key, []byte(val), int32(STORE_LEASE_TTL/time.Second))
+	if err != nil {
+		return err
+	}
+	return ms.indexUpdate(key, true, nil)
+}
+
+func (ms *memcachedStore) Close() error {
+	ms.DistUnlock()
+	ms.temp.close()
+	return ms.mc.Close()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// mcClient implemented with gomemcache.
+/////////////////////////////////////////////////////////////////////////////
+
+type gomemcacheClient struct {
+	client *memcache.Client
+}
+
+func (gc *gomemcacheClient) Get(key string) ([]byte, interface{}, error) {
+	item, err := gc.client.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item.Value, item, nil
+}
+
+func (gc *gomemcacheClient) GetMulti(keys []string) (map[string][]byte, error) {
+	items, err := gc.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[string][]byte, len(items))
+	for key, item := range items {
+		vals[key] = item.Value
+	}
+	return vals, nil
+}
+
+func (gc *gomemcacheClient) Add(key string, val []byte, exp int32) error {
+	return gc.client.Add(&memcache.Item{Key: key, Value: val, Expiration: exp})
+}
+
+func (gc *gomemcacheClient) Set(key string, val []byte, exp int32) error {
+	return gc.client.Set(&memcache.Item{Key: key, Value: val, Expiration: exp})
+}
+
+func (gc *gomemcacheClient) Replace(key string, val []byte, exp int32) error {
+	return gc.client.Replace(&memcache.Item{Key: key, Value: val, Expiration: exp})
+}
+
+func (gc *gomemcacheClient) CAS(key string, val []byte, exp int32, token interface{}) error {
+	item, ok := token.(*memcache.Item)
+	if !ok || (item.Key != key) {
+		return fmt.Errorf("INTERNAL ERROR: bad memcached CAS token for '%s'", key)
+	}
+	item.Value = val
+	item.Expiration = exp
+	return gc.client.CompareAndSwap(item)
+}
+
+func (gc *gomemcacheClient) Delete(key string) error {
+	return gc.client.Delete(key)
+}
+
+func (gc *gomemcacheClient) Close() error {
+	return gc.client.Close()
+}
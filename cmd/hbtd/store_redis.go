@@ -0,0 +1,365 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Redis HB store.
+//
+// Keys are also kept in a sorted set (all scores 0) so range scans can use
+// ZRANGEBYLEX.  A key and its index entry are always written and deleted
+// in one MULTI/EXEC transaction.  Test-and-set and lock release are Lua
+// scripts, so they are atomic.  The lock, leader and life keys are
+// expiring keys refreshed by a goroutine.
+//
+// The index and the keys it lists must be on the same server, so Redis
+// Cluster is not supported.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	REDIS_INDEX_KEY    = "hbtd_index"
+	REDIS_MGET_MAX     = 1000
+	REDIS_OP_TIMEOUT   = 5 * time.Second
+	REDIS_DIAL_TIMEOUT = 10 * time.Second
+)
+
+var redisTASScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+// Create a key and add it to the index (KEYS[2]) if it doesn't exist.
+
+var redisCreateScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX") then
+	redis.call("ZADD", KEYS[2], 0, KEYS[1])
+	return 1
+end
+return 0`)
+
+// Set the expiration of a key (or delete it if ARGV[2] is 0) if it holds
+// the given value.
+
+var redisLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[2]) == 0 then
+	return redis.call("DEL", KEYS[1])
+end
+return redis.call("PEXPIRE", KEYS[1], ARGV[2])`)
+
+// Remove a key from the index if the key no longer exists.
+
+var redisPruneScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return redis.call("ZREM", KEYS[2], KEYS[1])
+end
+return 0`)
+
+type redisStore struct {
+	rc        *redis.Client
+	mutex     sync.Mutex
+	lockToken string
+	lockStop  chan struct{}
+	temp      *storeTempKeys
+}
+
+// Open a Redis HB store.
+//
+// url(in): redis://[user:password@]host:port[/db] or rediss://...
+// Return:  HB store, nil on success, else error.
+
+func openRedisStore(url string) (hbStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	opts.DialTimeout = REDIS_DIAL_TIMEOUT
+
+	rc := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), REDIS_OP_TIMEOUT)
+	defer cancel()
+	err = rc.Ping(ctx).Err()
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return newRedisStore(rc), nil
+}
+
+func newRedisStore(rc *redis.Client) *redisStore {
+	rs := &redisStore{rc: rc}
+	rs.temp = newStoreTempKeys(func(key string, val string) error {
+		return rs.store(key, val, STORE_LEASE_TTL)
+	})
+	return rs
+}
+
+func redisCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), REDIS_OP_TIMEOUT)
+}
+
+func (rs *redisStore) Get(key string) (string, bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	val, err := rs.rc.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+func (rs *redisStore) GetMulti(keys []string) (map[string]string, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	return storeGetChunked(keys, REDIS_MGET_MAX, func(chunk []string, vals map[string]string) error {
+		rvals, err := rs.rc.MGet(ctx, chunk...).Result()
+		if err != nil {
+			return err
+		}
+		for ix, val := range rvals {
+			if sval, ok := val.(string); ok {
+				vals[chunk[ix]] = sval
+			}
+		}
+		return nil
+	})
+}
+
+// Write a key and its index entry.  A zero TTL means no expiration.
+
+func (rs *redisStore) store(key string, val string, ttl time.Duration) error {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	_, err := rs.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, val, ttl)
+		pipe.ZAdd(ctx, REDIS_INDEX_KEY, redis.Z{Score: 0, Member: key})
+		return nil
+	})
+	return err
+}
+
+func (rs *redisStore) Store(key string, val string) error {
+	return rs.store(key, val, 0)
+}
+
+func (rs *redisStore) Create(key string, val string) (bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	rv, err := redisCreateScript.Run(ctx, rs.rc, []string{key, REDIS_INDEX_KEY}, val).Int()
+	if err != nil {
+		return false, err
+	}
+	return rv == 1, nil
+}
+
+func (rs *redisStore) Delete(key string) error {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	_, err := rs.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZRem(ctx, REDIS_INDEX_KEY, key)
+		return nil
+	})
+	return err
+}
+
+func (rs *redisStore) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	var kvlist []hbKV
+
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	keys, err := rs.rc.ZRangeByLex(ctx, REDIS_INDEX_KEY,
+		&redis.ZRangeBy{Min: "[" + keystart, Max: "[" + keyend}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(keys); start += REDIS_MGET_MAX {
+		end := start + REDIS_MGET_MAX
+		if end > len(keys) {
+			end = len(keys)
+		}
+		vals, err := rs.rc.MGet(ctx, keys[start:end]...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for ix, val := range vals {
+			key := keys[start+ix]
+			if sval, ok := val.(string); ok {
+				kvlist = append(kvlist, hbKV{Key: key, Value: sval})
+				continue
+			}
+
+			//Expired life key.
+
+			perr := redisPruneScript.Run(ctx, rs.rc, []string{key, REDIS_INDEX_KEY}).Err()
+			if perr != nil {
+				logTrace(logKV, fmt.Sprintf("Can't remove missing key '%s' from Redis index: %v",
+					key, perr), "key", key, "error", perr)
+			}
+		}
+	}
+
+	return kvlist, nil
+}
+
+func (rs *redisStore) TAS(key string, testval string, setval string) (bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	rv, err := redisTASScript.Run(ctx, rs.rc, []string{key}, testval, setval).Int()
+	if err != nil {
+		return false, err
+	}
+	return rv == 1, nil
+}
+
+func (rs *redisStore) Batch(ops []hbStoreOp) error {
+	ctx, cancel := context.WithTimeout(context.Background(), STORE_BATCH_TIMEOUT)
+	defer cancel()
+
+	_, err := rs.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, op := range ops {
+			if op.Delete {
+				pipe.Del(ctx, op.Key)
+				pipe.ZRem(ctx, REDIS_INDEX_KEY, op.Key)
+			} else {
+				pipe.Set(ctx, op.Key, op.Value, 0)
+				pipe.ZAdd(ctx, REDIS_INDEX_KEY, redis.Z{Score: 0, Member: op.Key})
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Lease keys (the lock and the leader key) hold their owner's token and
+// expire unless renewed.  Create one if it doesn't exist.
+
+func (rs *redisStore) leaseAcquire(key string, token string, ttl time.Duration) (bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	return rs.rc.SetNX(ctx, key, token, ttl).Result()
+}
+
+// Renew a lease key if we still own it.  A TTL of 0 releases it.
+
+func (rs *redisStore) leaseRenew(key string, token string, ttl time.Duration) (bool, error) {
+	ctx, cancel := redisCtx()
+	defer cancel()
+
+	rv, err := redisLeaseScript.Run(ctx, rs.rc, []string{key},
+		token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return rv == 1, nil
+}
+
+func (rs *redisStore) DistTimedLock(tosec int) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.lockStop != nil {
+		return fmt.Errorf("ERROR: distributed lock already held by this process")
+	}
+
+	token := fmt.Sprintf("%x", rand.Int63())
+	deadline := time.Now().Add(time.Duration(tosec) * time.Second)
+
+	for {
+		ok, err := rs.leaseAcquire(STORE_LOCK_KEY, token, STORE_LEASE_TTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("distributed lock held by another instance")
+		}
+		time.Sleep(STORE_LOCK_POLL)
+	}
+
+	rs.lockToken = token
+	rs.lockStop = make(chan struct{})
+	go storeKeepAlive(func() (bool, error) {
+		return rs.leaseRenew(STORE_LOCK_KEY, token, STORE_LEASE_TTL)
+	}, rs.lockStop)
+	return nil
+}
+
+func (rs *redisStore) DistUnlock() error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if rs.lockStop == nil {
+		return nil
+	}
+	close(rs.lockStop)
+	rs.lockStop = nil
+
+	_, err := rs.leaseRenew(STORE_LOCK_KEY, rs.lockToken, 0)
+	return err
+}
+
+func (rs *redisStore) Campaign(id string) (hbElection, error) {
+	return newLeaseElection(rs, id), nil
+}
+
+func (rs *redisStore) Watch(key string, cb func(val string)) (func(), error) {
+	return storePollWatch(rs.Get, key, cb), nil
+}
+
+func (rs *redisStore) TempKey(key string, val string) error {
+	return rs.temp.set(key, val)
+}
+
+func (rs *redisStore) Close() error {
+	rs.DistUnlock()
+	rs.temp.close()
+	return rs.rc.Close()
+}
@@ -0,0 +1,565 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/hms-hmetcd"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// In-memory memcached stand-in.
+
+type fakeMCItem struct {
+	val []byte
+	cas uint64
+	exp time.Time
+}
+
+type fakeMC struct {
+	mutex  sync.Mutex
+	items  map[string]*fakeMCItem
+	cas    uint64
+	offset time.Duration //Added to the clock, to expire keys
+}
+
+func newFakeMC() *fakeMC {
+	return &fakeMC{items: make(map[string]*fakeMCItem)}
+}
+
+func (fm *fakeMC) advance(d time.Duration) {
+	fm.mutex.Lock()
+	fm.offset += d
+	fm.mutex.Unlock()
+}
+
+func (fm *fakeMC) item(key string) *fakeMCItem {
+	it, ok := fm.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.exp.IsZero() && !time.Now().Add(fm.offset).Before(it.exp) {
+		delete(fm.items, key)
+		return nil
+	}
+	return it
+}
+
+func (fm *fakeMC) put(key string, val []byte, exp int32) {
+	if exp < 0 {
+		delete(fm.items, key)
+		return
+	}
+	fm.cas++
+	it := &fakeMCItem{val: append([]byte{}, val...), cas: fm.cas}
+	if exp > 0 {
+		it.exp = time.Now().Add(fm.offset).Add(time.Duration(exp) * time.Second)
+	}
+	fm.items[key] = it
+}
+
+func (fm *fakeMC) Get(key string) ([]byte, interface{}, error) {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	it := fm.item(key)
+	if it == nil {
+		return nil, nil, memcache.ErrCacheMiss
+	}
+	return it.val, it.cas, nil
+}
+
+func (fm *fakeMC) GetMulti(keys []string) (map[string][]byte, error) {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	vals := make(map[string][]byte)
+	for _, key := range keys {
+		if it := fm.item(key); it != nil {
+			vals[key] = it.val
+		}
+	}
+	return vals, nil
+}
+
+func (fm *fakeMC) Add(key string, val []byte, exp int32) error {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	if fm.item(key) != nil {
+		return memcache.ErrNotStored
+	}
+	fm.put(key, val, exp)
+	return nil
+}
+
+func (fm *fakeMC) Set(key string, val []byte, exp int32) error {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	fm.put(key, val, exp)
+	return nil
+}
+
+func (fm *fakeMC) Replace(key string, val []byte, exp int32) error {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	if fm.item(key) == nil {
+		return memcache.ErrNotStored
+	}
+	fm.put(key, val, exp)
+	return nil
+}
+
+func (fm *fakeMC) CAS(key string, val []byte, exp int32, token interface{}) error {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	it := fm.item(key)
+	if it == nil {
+		return memcache.ErrCacheMiss
+	}
+	if it.cas != token.(uint64) {
+		return memcache.ErrCASConflict
+	}
+	fm.put(key, val, exp)
+	return nil
+}
+
+func (fm *fakeMC) Delete(key string) error {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	if fm.item(key) == nil {
+		return memcache.ErrCacheMiss
+	}
+	delete(fm.items, key)
+	return nil
+}
+
+func (fm *fakeMC) Close() error {
+	return nil
+}
+
+// Conformance tests run against every HB store backend.
+//
+// st, other(in): Two handles on the same store, as two instances would have.
+// exclusive(in): True if the backend's distributed lock excludes other
+//                instances (the in-memory ETCD stand-in's doesn't).
+// expire(in):    Moves the store's clock past the lease TTL; nil if the
+//                backend doesn't expire life keys.
+
+func storeConformance(t *testing.T, st hbStore, other hbStore, exclusive bool,
+	expire func(time.Duration)) {
+	pre := "hbtd_conf-"
+
+	//Basic CRUD
+
+	if _, ok, err := st.Get(pre + "a"); ok || (err != nil) {
+		t.Errorf("Get of missing key: exists %v, error %v", ok, err)
+	}
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := st.Store(pre+k, "val-"+k); err != nil {
+			t.Fatalf("ERROR storing '%s': %v", pre+k, err)
+		}
+	}
+	st.Store(pre+"b", "val-b2")
+	if val, ok, err := other.Get(pre + "b"); !ok || (err != nil) || (val != "val-b2") {
+		t.Errorf("Get: expected 'val-b2', got '%s' %v %v", val, ok, err)
+	}
+
+	//Range scans are inclusive at both ends, and don't include keys which
+	//start with the end key.
+
+	st.Store(pre+"c0", "val-c0")
+	kvlist, err := other.GetRange(pre+"b", pre+"c")
+	if err != nil {
+		t.Fatalf("ERROR in range scan: %v", err)
+	}
+	sort.Slice(kvlist, func(i, j int) bool { return kvlist[i].Key < kvlist[j].Key })
+	if (len(kvlist) != 2) || (kvlist[0] != hbKV{Key: pre + "b", Value: "val-b2"}) ||
+		(kvlist[1] != hbKV{Key: pre + "c", Value: "val-c"}) {
+		t.Errorf("Unexpected range scan result: %v", kvlist)
+	}
+	if kvlist, _ = st.GetRange(pre+"x", pre+"z"); len(kvlist) != 0 {
+		t.Errorf("Expected empty range scan, got %v", kvlist)
+	}
+	st.Delete(pre + "c0")
+
+	//Multi-key reads leave out missing keys
+
+	vals, err := other.GetMulti([]string{pre + "d", pre + "x", pre + "b"})
+	if (err != nil) || (len(vals) != 2) || (vals[pre+"b"] != "val-b2") ||
+		(vals[pre+"d"] != "val-d") {
+		t.Errorf("Unexpected multi-key read result: %v %v", vals, err)
+	}
+
+	if err = st.Delete(pre + "c"); err != nil {
+		t.Errorf("ERROR deleting key: %v", err)
+	}
+	if err = st.Delete(pre + "c"); err != nil {
+		t.Errorf("ERROR deleting missing key: %v", err)
+	}
+	if _, ok, _ := other.Get(pre + "c"); ok {
+		t.Errorf("Deleted key still exists.")
+	}
+	if kvlist, _ = other.GetRange(pre+"a", pre+"z"); len(kvlist) != 3 {
+		t.Errorf("Deleted key in range scan: %v", kvlist)
+	}
+
+	//Test-and-set
+
+	if ok, err := st.TAS(pre+"a", "wrong", "val-a2"); ok || (err != nil) {
+		t.Errorf("TAS with wrong value: %v %v", ok, err)
+	}
+	if ok, err := other.TAS(pre+"a", "val-a", "val-a2"); !ok || (err != nil) {
+		t.Errorf("TAS with right value: %v %v", ok, err)
+	}
+	if val, _, _ := st.Get(pre + "a"); val != "val-a2" {
+		t.Errorf("TAS didn't set the value, got '%s'", val)
+	}
+
+	//Create-if-absent, only one instance gets to create a key
+
+	if ok, err := st.Create(pre+"a", "val-x"); ok || (err != nil) {
+		t.Errorf("Create of existing key: %v %v", ok, err)
+	}
+	var wg sync.WaitGroup
+	created := make([]bool, 2)
+	for ix, sth := range []hbStore{st, other} {
+		wg.Add(1)
+		go func(ix int, sth hbStore) {
+			defer wg.Done()
+			created[ix], _ = sth.Create(pre+"f", fmt.Sprintf("val-f%d", ix))
+		}(ix, sth)
+	}
+	wg.Wait()
+	if created[0] == created[1] {
+		t.Errorf("Expected one create to succeed, got %v", created)
+	}
+	kvlist, _ = other.GetRange(pre+"f", pre+"f")
+	if (len(kvlist) != 1) || !strings.HasPrefix(kvlist[0].Value, "val-f") {
+		t.Errorf("Created key not in range scan: %v", kvlist)
+	}
+
+	//Batched writes
+
+	err = st.Batch([]hbStoreOp{{Key: pre + "a", Value: "val-a3"},
+		{Key: pre + "d", Delete: true}, {Key: pre + "e", Value: "val-e"}})
+	if err != nil {
+		t.Errorf("ERROR in batched write: %v", err)
+	}
+	kvlist, _ = other.GetRange(pre+"a", pre+"e")
+	sort.Slice(kvlist, func(i, j int) bool { return kvlist[i].Key < kvlist[j].Key })
+	if (len(kvlist) != 3) || (kvlist[0].Value != "val-a3") || (kvlist[1].Key != pre+"b") ||
+		(kvlist[2].Value != "val-e") {
+		t.Errorf("Unexpected range scan result after batch: %v", kvlist)
+	}
+
+	//Distributed lock
+
+	if err = st.DistTimedLock(1); err != nil {
+		t.Fatalf("ERROR acquiring lock: %v", err)
+	}
+	if exclusive {
+		if st.DistTimedLock(0) == nil {
+			t.Errorf("Lock acquired twice by the same instance.")
+		}
+		if other.DistTimedLock(0) == nil {
+			t.Errorf("Lock acquired by two instances.")
+			other.DistUnlock()
+		}
+	}
+	if err = st.DistUnlock(); err != nil {
+		t.Errorf("ERROR releasing lock: %v", err)
+	}
+	if err = other.DistTimedLock(1); err != nil {
+		t.Errorf("ERROR acquiring released lock: %v", err)
+	}
+	other.DistUnlock()
+
+	//Leader election
+
+	origPoll := electionPoll
+	electionPoll = 50 * time.Millisecond
+	el1, err := st.Campaign("one")
+	if err != nil {
+		t.Fatalf("ERROR campaigning for leader: %v", err)
+	}
+	el2, _ := other.Campaign("two")
+	if !el1.IsLeader() || (el1.Leader() != "one") {
+		t.Errorf("First campaigner not leader, leader is '%s'", el1.Leader())
+	}
+	if exclusive {
+		if el2.IsLeader() || (el2.Leader() != "one") {
+			t.Errorf("Two leaders, or wrong leader '%s'", el2.Leader())
+		}
+
+		//Leader resigns, the other takes over.
+
+		el1.Resign()
+		time.Sleep(300 * time.Millisecond)
+		if !el2.IsLeader() || (el2.Leader() != "two") {
+			t.Errorf("No takeover after resignation, leader is '%s'", el2.Leader())
+		}
+
+		//Leader stalls, its lease lapses and another takes over.
+
+		close(el2.(*leaseElection).stop)
+		if expire != nil {
+			expire(ELECTION_TTL + time.Second)
+			el3, _ := st.Campaign("three")
+			if !el3.IsLeader() {
+				t.Errorf("No takeover after leader's lease lapsed, leader is '%s'",
+					el3.Leader())
+			}
+			el3.Resign()
+		}
+	} else {
+		el1.Resign()
+		el2.Resign()
+	}
+	electionPoll = origPoll
+
+	//Watches see changes made by other instances, until cancelled.
+
+	origWPoll := storeWatchPoll
+	storeWatchPoll = 50 * time.Millisecond
+	wch := make(chan string, 10)
+	cancel, err := st.Watch(pre+"w", func(val string) { wch <- val })
+	if err != nil {
+		t.Fatalf("ERROR watching key: %v", err)
+	}
+	other.Store(pre+"w", "w1")
+	select {
+	case val := <-wch:
+		if val != "w1" {
+			t.Errorf("Watch: expected 'w1', got '%s'", val)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("Watch didn't see key creation.")
+	}
+	cancel()
+	other.Store(pre+"w", "w2")
+	select {
+	case val := <-wch:
+		t.Errorf("Watch saw '%s' after being cancelled.", val)
+	case <-time.After(time.Second):
+	}
+	other.Delete(pre + "w")
+	storeWatchPoll = origWPoll
+
+	//Life keys
+
+	if err = st.TempKey(pre+"life-1", "v1"); err != nil {
+		t.Fatalf("ERROR creating life key: %v", err)
+	}
+	if kvlist, _ = other.GetRange(pre+"life-0", pre+"life-9"); len(kvlist) != 1 {
+		t.Errorf("Life key not in range scan: %v", kvlist)
+	}
+	if err = st.TempKey(pre+"life-1", "v2"); err != nil {
+		t.Errorf("ERROR updating life key: %v", err)
+	}
+	if val, _, _ := other.Get(pre + "life-1"); val != "v2" {
+		t.Errorf("Life key not updated, expected 'v2', got '%s'", val)
+	}
+	if expire != nil {
+		//The instance stops refreshing (as if it went away), its life key
+		//and lock go with it.
+
+		st.DistTimedLock(1)
+		expire(STORE_LEASE_TTL + time.Second)
+		if kvlist, _ = other.GetRange(pre+"life-0", pre+"life-9"); len(kvlist) != 0 {
+			t.Errorf("Life key outlived its instance: %v", kvlist)
+		}
+		if err = other.DistTimedLock(0); err != nil {
+			t.Errorf("Lock outlived its instance: %v", err)
+		}
+		other.DistUnlock()
+		st.Close()
+	}
+
+	for _, k := range []string{"a", "b", "e", "f", "life-1"} {
+		other.Delete(pre + k)
+	}
+}
+
+func TestStoreEtcd(t *testing.T) {
+	st, err := openStore("mem:")
+	if err != nil {
+		t.Fatalf("ERROR opening mem: store: %v", err)
+	}
+	if _, ok := st.(*etcdStore); !ok {
+		t.Fatalf("mem: opened the wrong store type: %T", st)
+	}
+	other, _ := openStore("mem:")
+	storeConformance(t, st, other, false, nil)
+}
+
+// hmetcd in-memory store whose range scans exclude the end key, like ETCD's.
+
+type exclRangeKvi struct {
+	hmetcd.Kvi
+}
+
+func (ek *exclRangeKvi) GetRange(keystart string, keyend string) ([]hmetcd.Kvi_KV, error) {
+	var rlist []hmetcd.Kvi_KV
+
+	kvlist, err := ek.Kvi.GetRange(keystart, keyend)
+	for _, kv := range kvlist {
+		if kv.Key < keyend {
+			rlist = append(rlist, kv)
+		}
+	}
+	return rlist, err
+}
+
+func TestStoreEtcdExclusiveRange(t *testing.T) {
+	var stores []hbStore
+
+	for ix := 0; ix < 2; ix++ {
+		kvi, err := hmetcd.Open("mem:", "")
+		if err != nil {
+			t.Fatalf("ERROR opening mem: store: %v", err)
+		}
+		stores = append(stores, &etcdStore{kvi: &exclRangeKvi{Kvi: kvi}, rangeExcl: true})
+	}
+	storeConformance(t, stores[0], stores[1], false, nil)
+}
+
+func TestStoreMemcached(t *testing.T) {
+	if _, err := openStore("memcached://"); err == nil {
+		t.Errorf("Expected an error opening memcached store with no servers.")
+	}
+
+	mc := newFakeMC()
+	st := newMemcachedStore(mc)
+	other := newMemcachedStore(mc)
+	storeConformance(t, st, other, true, mc.advance)
+
+	//Lots of keys, so that some land in the same index bucket.
+
+	for ix := 0; ix < 200; ix++ {
+		other.Store(HBTD_POLICY_KEY_PRE+string(rune('A'+ix%26))+string(rune('a'+ix/26)), "p")
+	}
+	kvlist, _ := other.GetRange(HBTD_POLICY_KEY_PRE, HBTD_POLICY_KEY_END)
+	if len(kvlist) != 200 {
+		t.Errorf("Expected 200 keys, got %d", len(kvlist))
+	}
+	for _, kv := range kvlist {
+		other.Delete(kv.Key)
+	}
+	if kvlist, _ = other.GetRange(HBTD_POLICY_KEY_PRE, HBTD_POLICY_KEY_END); len(kvlist) != 0 {
+		t.Errorf("Expected no keys after deletion, got %d", len(kvlist))
+	}
+
+	//A key left by a store racing a delete stays in the index.
+
+	key := HBTD_POLICY_KEY_PRE + "race"
+	for ix := 0; ix < 200; ix++ {
+		var wg sync.WaitGroup
+		other.Store(key, "p")
+		wg.Add(2)
+		go func() { defer wg.Done(); st.Delete(key) }()
+		go func() { defer wg.Done(); other.Store(key, "p2") }()
+		wg.Wait()
+		_, exists, _ := st.Get(key)
+		kvlist, _ = st.GetRange(key, key)
+		if exists != (len(kvlist) == 1) {
+			t.Fatalf("Key exists %v but range scan found %v", exists, kvlist)
+		}
+	}
+	st.Delete(key)
+	other.Close()
+}
+
+func TestStoreRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	st, err := openStore("redis://" + mr.Addr())
+	if err != nil {
+		t.Fatalf("ERROR opening Redis store: %v", err)
+	}
+	if _, ok := st.(*redisStore); !ok {
+		t.Fatalf("redis:// opened the wrong store type: %T", st)
+	}
+	other, _ := openStore("redis://" + mr.Addr())
+	storeConformance(t, st, other, true, mr.FastForward)
+	other.Close()
+}
+
+// HB store whose batched writes always fail.
+
+type failBatchStore struct {
+	hbStore
+	batches int
+}
+
+func (fs *failBatchStore) Batch(ops []hbStoreOp) error {
+	fs.batches++
+	return fmt.Errorf("batch too large")
+}
+
+// Test batching, and falling back to one write at a time.
+
+func TestStoreApply(t *testing.T) {
+	var ops []hbStoreOp
+
+	mc := newFakeMC()
+	st := newMemcachedStore(mc)
+	defer st.Close()
+
+	for ix := 0; ix < (STORE_BATCH_MAX*2)+10; ix++ {
+		ops = append(ops, hbStoreOp{Key: fmt.Sprintf("x%dc0s0b0n0", ix), Value: "hb"})
+	}
+	ops = append(ops, hbStoreOp{Key: "x0c0s0b0n0", Delete: true})
+
+	fallbacks := testutil.ToFloat64(mKVBatchFallbacks)
+	if failed := storeApply(st, ops); len(failed) != 0 {
+		t.Errorf("Expected no failed writes, got %v", failed)
+	}
+	kvlist, _ := st.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	if len(kvlist) != (STORE_BATCH_MAX*2)+9 {
+		t.Errorf("Expected %d keys, got %d", (STORE_BATCH_MAX*2)+9, len(kvlist))
+	}
+
+	fs := &failBatchStore{hbStore: st}
+	ops = []hbStoreOp{{Key: "x0c0s0b0n0", Value: "hb2"}, {Key: "x1c0s0b0n0", Delete: true}}
+	if failed := storeApply(fs, ops); len(failed) != 0 {
+		t.Errorf("Expected no failed writes, got %v", failed)
+	}
+	if fs.batches != 1 {
+		t.Errorf("Expected 1 batch, got %d", fs.batches)
+	}
+	if v := testutil.ToFloat64(mKVBatchFallbacks); v != fallbacks+1 {
+		t.Errorf("Expected %v batch fallbacks, got %v", fallbacks+1, v)
+	}
+	if val, _, _ := st.Get("x0c0s0b0n0"); val != "hb2" {
+		t.Errorf("Fallback store not done, got '%s'", val)
+	}
+	if _, ok, _ := st.Get("x1c0s0b0n0"); ok {
+		t.Errorf("Fallback delete not done.")
+	}
+}
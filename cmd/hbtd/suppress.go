@@ -0,0 +1,620 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+	"github.com/gorilla/mux"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Notification suppressions (maintenance windows).  While a suppression is
+// active, heartbeat stop warnings and errors for the components it selects
+// are recorded in the KV store but not sent to HSM or the telemetry bus.
+// When the window ends, what was suppressed is logged, sent to the telemetry
+// bus as a single message and kept available through the API until the
+// suppression is deleted or ages out.  The held notifications of components
+// still stopped then are sent, so HSM isn't left thinking they are fine.
+/////////////////////////////////////////////////////////////////////////////
+
+type hbSuppression struct {
+	ID string `json:"ID"`
+	hbSelector
+	Start    time.Time `json:"Start"`
+	End      time.Time `json:"End"`
+	Reason   string    `json:"Reason"`
+	Reported bool      `json:"Reported"` //End of window has been reported
+}
+
+// A component's suppressed transitions within one suppression window.
+
+type hbSuppressedRec struct {
+	Component       string    `json:"Component"`
+	Transition      string    `json:"Transition"` //Last suppressed transition
+	Count           int       `json:"Count"`
+	Last            time.Time `json:"Last"`
+	LastHBTimestamp string    `json:"LastHBTimestamp,omitempty"`
+}
+
+type hbSuppressionRsp struct {
+	hbSuppression
+	State           string            `json:"State"`
+	SuppressedCount int               `json:"SuppressedCount"`
+	Suppressed      []hbSuppressedRec `json:"Suppressed,omitempty"`
+}
+
+type hbSuppressionList struct {
+	Suppressions []hbSuppressionRsp `json:"Suppressions"`
+}
+
+const (
+	HBTD_SUPPRESS_KEY_PRE   = "hbtd_suppress-"
+	HBTD_SUPPRESS_KEY_END   = HBTD_SUPPRESS_KEY_PRE + "~"
+	HBTD_SUPPRESSED_KEY_PRE = "hbtd_suppressed-"
+
+	SUPPRESS_STATE_PENDING = "Pending"
+	SUPPRESS_STATE_ACTIVE  = "Active"
+	SUPPRESS_STATE_ENDED   = "Ended"
+
+	SUPPRESS_MESSAGE_ID = "Heartbeat Suppression Ended"
+)
+
+// How long a suppression and its records are kept after it ends.
+
+var suppressRetention = 24 * time.Hour
+
+var suppressCache []hbSuppression
+var suppressLock sync.RWMutex
+
+// Convenience function, return a human readable name for an HB transition.
+
+func hbTransitionName(to_state int) string {
+	switch to_state {
+	case HB_started:
+		return "Started"
+	case HB_restarted_warn:
+		return "Restarted"
+	case HB_stopped_warn:
+		return "StoppedWarning"
+	case HB_stopped_error:
+		return "StoppedError"
+	case HB_stopped_expected:
+		return "StoppedExpected"
+	}
+	return "Unknown"
+}
+
+// Validate and normalize a suppression.
+//
+// Return: nil on success, else error describing the problem.
+
+func (sup *hbSuppression) validate() error {
+	if sup.Start.IsZero() {
+		sup.Start = time.Now()
+	}
+	if sup.End.IsZero() {
+		return fmt.Errorf("End time must be specified")
+	}
+	if !sup.End.After(sup.Start) {
+		return fmt.Errorf("End time must be after Start time")
+	}
+	if strings.TrimSpace(sup.Reason) == "" {
+		return fmt.Errorf("Reason must be specified")
+	}
+	return sup.hbSelector.normalize()
+}
+
+// Return the state of a suppression at a given time.
+
+func (sup *hbSuppression) state(now time.Time) string {
+	if now.Before(sup.Start) {
+		return SUPPRESS_STATE_PENDING
+	}
+	if now.Before(sup.End) {
+		return SUPPRESS_STATE_ACTIVE
+	}
+	return SUPPRESS_STATE_ENDED
+}
+
+// Fetch all suppressions from the KV store and refresh the local cache.
+//
+// Return: All suppressions, sorted by start time.
+//         nil on success, else error.
+
+func loadSuppressions() ([]hbSuppression, error) {
+	kvlist, err := kvHandle.GetRange(HBTD_SUPPRESS_KEY_PRE, HBTD_SUPPRESS_KEY_END)
+	if err != nil {
+		return nil, err
+	}
+
+	sups := []hbSuppression{}
+	for _, kv := range kvlist {
+		var sup hbSuppression
+		umerr := json.Unmarshal([]byte(kv.Value), &sup)
+		if umerr != nil {
+			logKV.Error(fmt.Sprintf("Error unmarshalling suppression '%s': %v", kv.Value, umerr),
+				"key", kv.Key, "error", umerr)
+			continue
+		}
+		sups = append(sups, sup)
+	}
+	sort.Slice(sups, func(i, j int) bool {
+		if sups[i].Start.Equal(sups[j].Start) {
+			return sups[i].ID < sups[j].ID
+		}
+		return sups[i].Start.Before(sups[j].Start)
+	})
+
+	suppressLock.Lock()
+	suppressCache = sups
+	suppressLock.Unlock()
+
+	return sups, nil
+}
+
+// Determine if an HB transition for a component is suppressed.  Only stop
+// warnings and errors are ever suppressed; transitions back to a good state
+// are always sent so HSM does not end up with a stale bad state.
+//
+// xname(in):    Component XName.
+// to_state(in): HB transition.
+// Return:       ID of the active suppression selecting the component, or
+//               "" if the transition is not suppressed.
+
+func suppressedBy(xname string, to_state int) string {
+	if (to_state != HB_stopped_warn) && (to_state != HB_stopped_error) {
+		return ""
+	}
+
+	suppressLock.RLock()
+	defer suppressLock.RUnlock()
+
+	if len(suppressCache) == 0 {
+		return ""
+	}
+
+	now := time.Now()
+	htype := string(xnametypes.GetHMSType(xname))
+	for ix := range suppressCache {
+		if suppressCache[ix].state(now) != SUPPRESS_STATE_ACTIVE {
+			continue
+		}
+		if suppressCache[ix].match(xname, htype) != SEL_MATCH_NONE {
+			return suppressCache[ix].ID
+		}
+	}
+	return ""
+}
+
+// Record a suppressed HB transition in the KV store.
+//
+// id(in):       Suppression ID.
+// hb(in):       HB record of the component.
+// to_state(in): HB transition that was suppressed.
+// Return:       None.
+
+func recordSuppressed(id string, hb *hbinfo, to_state int) {
+	var rec hbSuppressedRec
+
+	key := HBTD_SUPPRESSED_KEY_PRE + id + "-" + hb.Component
+	val, exists, err := kvHandle.Get(key)
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error reading suppressed record '%s': %v", key, err),
+			"key", key, "error", err)
+	}
+	if exists {
+		umerr := json.Unmarshal([]byte(val), &rec)
+		if umerr != nil {
+			logKV.Error(fmt.Sprintf("Error unmarshalling '%s': %v", val, umerr),
+				"key", key, "error", umerr)
+		}
+	}
+
+	rec.Component = hb.Component
+	rec.Transition = hbTransitionName(to_state)
+	rec.Count++
+	rec.Last = time.Now()
+	rec.LastHBTimestamp = hb.Last_hb_timestamp
+
+	ba, _ := json.Marshal(&rec)
+	err = kvHandle.Store(key, string(ba))
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error storing suppressed record '%s': %v", key, err),
+			"key", key, "error", err)
+	}
+
+	logChecker.Debug(fmt.Sprintf("Suppressed %s notification for '%s' (suppression '%s').",
+		rec.Transition, hb.Component, id),
+		"component", hb.Component, "transition", rec.Transition, "suppression", id)
+}
+
+// Fetch the suppressed transition records of a suppression.
+//
+// id(in): Suppression ID.
+// Return: Records, sorted by component.
+//         KV store keys of the records.
+//         nil on success, else error.
+
+func getSuppressed(id string) ([]hbSuppressedRec, []string, error) {
+	pre := HBTD_SUPPRESSED_KEY_PRE + id + "-"
+	kvlist, err := kvHandle.GetRange(pre, pre+"~")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recs := []hbSuppressedRec{}
+	var keys []string
+	for _, kv := range kvlist {
+		var rec hbSuppressedRec
+		keys = append(keys, kv.Key)
+		umerr := json.Unmarshal([]byte(kv.Value), &rec)
+		if umerr != nil {
+			logKV.Error(fmt.Sprintf("Error unmarshalling '%s': %v", kv.Value, umerr),
+				"key", kv.Key, "error", umerr)
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Component < recs[j].Component })
+	return recs, keys, nil
+}
+
+// Delete a suppression and all of its suppressed transition records.
+
+func deleteSuppression(id string) error {
+	_, keys, err := getSuppressed(id)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err = kvHandle.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return kvHandle.Delete(HBTD_SUPPRESS_KEY_PRE + id)
+}
+
+// Send the notifications held back by a suppression whose window has ended,
+// for components still in the state they were suppressed in.  A component
+// declared dead during the window has no HB record, unless it has since
+// come back, in which case its start was sent.  One with a warning still
+// has it in its HB record, unless it has since recovered.
+//
+// recs(in): Suppressed transition records of the suppression.
+// Return:   Number of notifications sent.
+
+func releaseSuppressed(recs []hbSuppressedRec) int {
+	nsent := 0
+
+	for _, rec := range recs {
+		var hbb hbinfo
+
+		val, exists, err := kvHandle.Get(rec.Component)
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error reading HB record for '%s', suppressed notification not sent: %v",
+				rec.Component, err), "component", rec.Component, "error", err)
+			continue
+		}
+
+		switch rec.Transition {
+		case hbTransitionName(HB_stopped_error):
+			if exists {
+				continue
+			}
+			hbb = hbinfo{Component: rec.Component, Last_hb_timestamp: rec.LastHBTimestamp}
+			hb_update_notify(&hbb, HB_stopped_error)
+		case hbTransitionName(HB_stopped_warn):
+			if !exists {
+				continue
+			}
+			umerr := json.Unmarshal([]byte(val), &hbb)
+			if umerr != nil {
+				logChecker.Error(fmt.Sprintf("Error unmarshalling '%s': %v", val, umerr),
+					"component", rec.Component, "error", umerr)
+				continue
+			}
+			if hbb.Had_warning == HB_WARN_NONE {
+				continue
+			}
+			hb_update_notify(&hbb, HB_stopped_warn)
+		default:
+			continue
+		}
+
+		logChecker.Info(fmt.Sprintf("Sending suppressed %s notification for '%s'.",
+			rec.Transition, rec.Component),
+			"component", rec.Component, "transition", rec.Transition)
+		nsent++
+	}
+
+	return nsent
+}
+
+// Called by the HB checker.  Report suppressions whose window has ended,
+// and delete those that have been ended for longer than the retention time.
+//
+// now(in): Time reference.
+// Return:  None.
+
+func checkSuppressions(now time.Time) {
+	suppressLock.RLock()
+	sups := make([]hbSuppression, len(suppressCache))
+	copy(sups, suppressCache)
+	suppressLock.RUnlock()
+
+	for _, sup := range sups {
+		if sup.state(now) != SUPPRESS_STATE_ENDED {
+			continue
+		}
+
+		if now.Sub(sup.End) >= suppressRetention {
+			err := deleteSuppression(sup.ID)
+			if err != nil {
+				logKV.Error(fmt.Sprintf("Error deleting expired suppression '%s': %v", sup.ID, err),
+					"suppression", sup.ID, "error", err)
+			}
+			continue
+		}
+		if sup.Reported {
+			continue
+		}
+
+		recs, _, err := getSuppressed(sup.ID)
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error fetching suppressed records for '%s': %v", sup.ID, err),
+				"suppression", sup.ID, "error", err)
+			continue
+		}
+
+		var comps []string
+		for _, rec := range recs {
+			comps = append(comps, fmt.Sprintf("%s:%s", rec.Component, rec.Transition))
+		}
+		info := fmt.Sprintf("Suppression '%s' (%s) ended, %d components had notifications suppressed: %s",
+			sup.ID, sup.Reason, len(recs), strings.Join(comps, ","))
+		logChecker.Info(info, "suppression", sup.ID, "reason", sup.Reason,
+			"components", len(recs))
+		releaseSuppressed(recs)
+
+		telemsg := telemetry_json_v1{MessageID: SUPPRESS_MESSAGE_ID,
+			Id: sup.ID, Info: info}
+		select {
+		case telemetryQ <- telemsg:
+		default:
+			mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+			logTelemetry.Info("Telemetry bus not accepting messages, suppression event not sent.",
+				"suppression", sup.ID)
+		}
+
+		sup.Reported = true
+		ba, _ := json.Marshal(&sup)
+		err = kvHandle.Store(HBTD_SUPPRESS_KEY_PRE+sup.ID, string(ba))
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error storing suppression '%s': %v", sup.ID, err),
+				"suppression", sup.ID, "error", err)
+		}
+	}
+
+	_, err := loadSuppressions()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error refreshing suppressions: %v", err), "error", err)
+	}
+}
+
+// Convenience function, generate a suppression API response.
+
+func suppressionRsp(sup *hbSuppression, now time.Time, full bool) (*hbSuppressionRsp, error) {
+	rsp := hbSuppressionRsp{hbSuppression: *sup, State: sup.state(now)}
+	recs, _, err := getSuppressed(sup.ID)
+	if err != nil {
+		return nil, err
+	}
+	rsp.SuppressedCount = len(recs)
+	if full {
+		rsp.Suppressed = recs
+	}
+	return &rsp, nil
+}
+
+// Entry point for GET and POST /hmi/v1/suppressions
+
+func suppressionsIO(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_SUPPRESSIONS
+	now := time.Now()
+
+	if r.Method == "GET" {
+		var rspData hbSuppressionList
+
+		sups, err := loadSuppressions()
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error fetching suppressions: %v", err), "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service GETRANGE operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		rspData.Suppressions = []hbSuppressionRsp{}
+		for ix := range sups {
+			rsp, rerr := suppressionRsp(&sups[ix], now, false)
+			if rerr != nil {
+				pdet := base.NewProblemDetails("about:blank",
+					"Internal Server Error",
+					"Failed KV service GETRANGE operation",
+					errinst, http.StatusInternalServerError)
+				base.SendProblemDetails(w, pdet, 0)
+				return
+			}
+			rspData.Suppressions = append(rspData.Suppressions, *rsp)
+		}
+		sendJSON(w, http.StatusOK, &rspData, errinst)
+		return
+	}
+
+	//POST, create a new suppression
+
+	var sup hbSuppression
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("Error on message read: %v", err), "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Error reading inbound request",
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	err = json.Unmarshal(body, &sup)
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			fmt.Sprintf("Error unmarshalling inbound request: %v", err),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	sup.ID = fmt.Sprintf("%x", now.UnixNano())
+	sup.Reported = false
+	verr := sup.validate()
+	if verr != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			verr.Error(),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	ba, _ := json.Marshal(&sup)
+	err = kvHandle.Store(HBTD_SUPPRESS_KEY_PRE+sup.ID, string(ba))
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error storing suppression '%s': %v", sup.ID, err),
+			"suppression", sup.ID, "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service STORE operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	_, err = loadSuppressions()
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error refreshing suppressions: %v", err), "error", err)
+	}
+
+	logMain.Info(fmt.Sprintf("Created HB notification suppression '%s' (%s), %s to %s.",
+		sup.ID, sup.Reason, sup.Start.Format(time.RFC3339), sup.End.Format(time.RFC3339)),
+		"suppression", sup.ID, "reason", sup.Reason, "start", sup.Start, "end", sup.End)
+	sendJSON(w, http.StatusCreated, &hbSuppressionRsp{hbSuppression: sup,
+		State: sup.state(time.Now())}, errinst)
+}
+
+// Entry point for GET and DELETE /hmi/v1/suppressions/{id}
+
+func suppressionIO(w http.ResponseWriter, r *http.Request) {
+	var sup hbSuppression
+
+	defer base.DrainAndCloseRequestBody(r)
+
+	id := mux.Vars(r)["id"]
+	errinst := URL_SUPPRESSIONS + "/" + id
+
+	val, exists, err := kvHandle.Get(HBTD_SUPPRESS_KEY_PRE + id)
+	if err != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GET operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if !exists {
+		pdet := base.NewProblemDetails("about:blank",
+			"Not Found",
+			fmt.Sprintf("No such suppression '%s'", id),
+			errinst, http.StatusNotFound)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		err = deleteSuppression(id)
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error deleting suppression '%s': %v", id, err),
+				"suppression", id, "error", err)
+			pdet := base.NewProblemDetails("about:blank",
+				"Internal Server Error",
+				"Failed KV service DELETE operation",
+				errinst, http.StatusInternalServerError)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		_, err = loadSuppressions()
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error refreshing suppressions: %v", err), "error", err)
+		}
+		logMain.Info(fmt.Sprintf("Deleted HB notification suppression '%s'.", id),
+			"suppression", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = json.Unmarshal([]byte(val), &sup)
+	if err != nil {
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", val, err),
+			"suppression", id, "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			fmt.Sprintf("Error unmarshalling JSON for suppression '%s'", id),
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	rsp, rerr := suppressionRsp(&sup, time.Now(), true)
+	if rerr != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GETRANGE operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	sendJSON(w, http.StatusOK, rsp, errinst)
+}
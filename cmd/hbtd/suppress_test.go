@@ -0,0 +1,227 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the /suppressions API and suppression of HB notifications.
+
+func TestSuppressions(t *testing.T) {
+	var rsp hbSuppressionRsp
+	var list hbSuppressionList
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	app_params.debug_level.int_param = 0
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	now := time.Now()
+	end := now.Add(time.Hour).UTC().Format(time.RFC3339)
+
+	//Validation
+
+	badSups := []string{
+		`{"Prefixes":["x3004c0"],"Reason":"fw"}`,
+		fmt.Sprintf(`{"Prefixes":["x3004c0"],"End":"%s"}`, end),
+		fmt.Sprintf(`{"Reason":"fw","End":"%s"}`, end),
+		fmt.Sprintf(`{"Types":["Nodule"],"Reason":"fw","End":"%s"}`, end),
+		fmt.Sprintf(`{"Prefixes":["x3004c0"],"Reason":"fw","Start":"%s","End":"%s"}`,
+			end, now.UTC().Format(time.RFC3339)),
+	}
+	for _, bs := range badSups {
+		policyReq(t, "POST", URL_SUPPRESSIONS, bs, http.StatusBadRequest)
+	}
+
+	//Create an active one and a pending one
+
+	rr := policyReq(t, "POST", URL_SUPPRESSIONS,
+		fmt.Sprintf(`{"Prefixes":["x3004c0"],"Reason":"firmware update","End":"%s"}`, end),
+		http.StatusCreated)
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if rsp.ID == "" || rsp.State != SUPPRESS_STATE_ACTIVE {
+		t.Fatalf("Unexpected create response: %s", rr.Body.String())
+	}
+	supID := rsp.ID
+
+	rr = policyReq(t, "POST", URL_SUPPRESSIONS,
+		fmt.Sprintf(`{"Types":["Node"],"Reason":"later","Start":"%s","End":"%s"}`,
+			end, now.Add(2*time.Hour).UTC().Format(time.RFC3339)),
+		http.StatusCreated)
+	rsp = hbSuppressionRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if rsp.State != SUPPRESS_STATE_PENDING {
+		t.Errorf("Expected pending suppression, got: %s", rr.Body.String())
+	}
+	pendID := rsp.ID
+
+	//Notifications: stops in the window are suppressed, starts are not,
+	//other components are not.
+
+	hbIn := hbinfo{Component: "x3004c0s0b0n0"}
+	hbOut := hbinfo{Component: "x3005c0s0b0n0"}
+
+	hb_update_notify(&hbIn, HB_stopped_warn)
+	hb_update_notify(&hbIn, HB_stopped_error)
+	hb_update_notify(&hbOut, HB_stopped_error)
+
+	hbMapLock.Lock()
+	if StopWarnMap[hbIn.Component] != 0 || StopErrorMap[hbIn.Component] != 0 {
+		t.Errorf("Suppressed component has stop notifications pending.")
+	}
+	if StopErrorMap[hbOut.Component] == 0 {
+		t.Errorf("Non-suppressed component has no stop-error notification pending.")
+	}
+	StopErrorMap[hbOut.Component] = 0
+	hbMapLock.Unlock()
+
+	hb_update_notify(&hbIn, HB_started)
+	hbMapLock.Lock()
+	if StartMap[hbIn.Component] == 0 {
+		t.Errorf("Start notification was suppressed.")
+	}
+	StartMap[hbIn.Component] = 0
+	hbMapLock.Unlock()
+
+	//Suppressed records
+
+	rr = policyReq(t, "GET", URL_SUPPRESSIONS+"/"+supID, "", http.StatusOK)
+	rsp = hbSuppressionRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if rsp.SuppressedCount != 1 || len(rsp.Suppressed) != 1 ||
+		rsp.Suppressed[0].Component != hbIn.Component ||
+		rsp.Suppressed[0].Count != 2 ||
+		rsp.Suppressed[0].Transition != "StoppedError" {
+		t.Errorf("Unexpected suppressed records: %s", rr.Body.String())
+	}
+
+	rr = policyReq(t, "GET", URL_SUPPRESSIONS, "", http.StatusOK)
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Suppressions) != 2 || list.Suppressions[0].ID != supID ||
+		list.Suppressions[0].SuppressedCount != 1 ||
+		list.Suppressions[0].Suppressed != nil {
+		t.Errorf("Unexpected suppression list: %s", rr.Body.String())
+	}
+
+	//More suppressed components: one declared dead, one with a warning,
+	//one which had a warning but has recovered.  The first one has come
+	//back since it was declared dead.
+
+	hbDead := hbinfo{Component: "x3004c0s1b0n0", Last_hb_timestamp: "Jan 1, 0000"}
+	hbWarn := hbinfo{Component: "x3004c0s2b0n0", Had_warning: HB_WARN_NORMAL}
+	hbOK := hbinfo{Component: "x3004c0s3b0n0"}
+	hb_update_notify(&hbDead, HB_stopped_error)
+	hb_update_notify(&hbWarn, HB_stopped_warn)
+	hb_update_notify(&hbOK, HB_stopped_warn)
+	for _, hb := range []hbinfo{hbIn, hbWarn, hbOK} {
+		ba, _ := json.Marshal(&hb)
+		kvHandle.Store(hb.Component, string(ba))
+		defer kvHandle.Delete(hb.Component)
+	}
+
+	//End the window and check that it gets reported, once, and that the
+	//held notifications of those still stopped are sent.
+
+	var sup hbSuppression
+	val, _, _ := kvHandle.Get(HBTD_SUPPRESS_KEY_PRE + supID)
+	json.Unmarshal([]byte(val), &sup)
+	sup.Start = now.Add(-2 * time.Hour)
+	sup.End = now.Add(-time.Minute)
+	ba, _ := json.Marshal(&sup)
+	kvHandle.Store(HBTD_SUPPRESS_KEY_PRE+supID, string(ba))
+	loadSuppressions()
+
+	if suppressedBy(hbIn.Component, HB_stopped_error) != "" {
+		t.Errorf("Ended suppression still suppressing.")
+	}
+
+	testPrintClear()
+	checkSuppressions(now)
+	tpd := testPrintData()
+	exp := fmt.Sprintf("INFO: Suppression '%s' (firmware update) ended, 4 components had notifications suppressed: %s:StoppedError,%s:StoppedError,%s:StoppedWarning,%s:StoppedWarning",
+		supID, hbIn.Component, hbDead.Component, hbWarn.Component, hbOK.Component)
+	if !strings.Contains(tpd, exp) {
+		t.Errorf("Missing suppression report, expected '%s', got:\n%s", exp, tpd)
+	}
+
+	hbMapLock.Lock()
+	if (StopErrorMap[hbDead.Component] == 0) || (StopWarnMap[hbWarn.Component] == 0) {
+		t.Errorf("Held notifications not sent when the suppression ended.")
+	}
+	if (StopErrorMap[hbIn.Component] != 0) || (StopWarnMap[hbOK.Component] != 0) {
+		t.Errorf("Held notifications sent for components no longer stopped.")
+	}
+	delete(StopErrorMap, hbDead.Component)
+	delete(StopWarnMap, hbWarn.Component)
+	hbMapLock.Unlock()
+
+	testPrintClear()
+	checkSuppressions(now)
+	if strings.Contains(testPrintData(), "ended") {
+		t.Errorf("Suppression reported twice.")
+	}
+	hbMapLock.Lock()
+	if (StopErrorMap[hbDead.Component] != 0) || (StopWarnMap[hbWarn.Component] != 0) {
+		t.Errorf("Held notifications sent twice.")
+	}
+	hbMapLock.Unlock()
+
+	rr = policyReq(t, "GET", URL_SUPPRESSIONS+"/"+supID, "", http.StatusOK)
+	rsp = hbSuppressionRsp{}
+	json.Unmarshal(rr.Body.Bytes(), &rsp)
+	if rsp.State != SUPPRESS_STATE_ENDED || !rsp.Reported || len(rsp.Suppressed) != 4 {
+		t.Errorf("Unexpected ended suppression: %s", rr.Body.String())
+	}
+
+	//Age it out
+
+	checkSuppressions(now.Add(suppressRetention))
+	policyReq(t, "GET", URL_SUPPRESSIONS+"/"+supID, "", http.StatusNotFound)
+	recs, keys, _ := getSuppressed(supID)
+	if len(recs) != 0 || len(keys) != 0 {
+		t.Errorf("Suppressed records not deleted: %v", recs)
+	}
+
+	//Delete
+
+	policyReq(t, "DELETE", URL_SUPPRESSIONS+"/"+pendID, "", http.StatusNoContent)
+	policyReq(t, "DELETE", URL_SUPPRESSIONS+"/"+pendID, "", http.StatusNotFound)
+
+	suppressLock.RLock()
+	if len(suppressCache) != 0 {
+		t.Errorf("Suppression cache not empty: %v", suppressCache)
+	}
+	suppressLock.RUnlock()
+}
@@ -0,0 +1,319 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	base "github.com/Cray-HPE/hms-base/v2"
+	"github.com/Cray-HPE/hms-xname/xnametypes"
+)
+
+/////////////////////////////////////////////////////////////////////////////
+// Topology-aware correlation of dead components.  When a slot, chassis or
+// cabinet loses power, every component in it stops heartbeating at once,
+// which would otherwise mean one telemetry message per component.  The HB
+// checker holds back the HB_stopped_error notifications it finds in a
+// pass, and for each one looks at the component's parents, highest first.
+// If at least topo_threshold percent of the components under a parent have
+// been declared dead within topo_window seconds, the parent is taken to be
+// the cause: one aggregated telemetry message is sent for it, rather than
+// one per component, and if topo_tag is set the suspected cause is added to
+// the message sent to HSM for each component.  HSM is still updated for
+// every component.
+//
+// With multiple instances, components are sharded by cabinet, so the
+// instance correlating a parent checks every component under it.  The
+// aggregated message for a parent is claimed in the KV store before it is
+// sent, so it is sent once per window even if the cabinet moves to another
+// instance.
+/////////////////////////////////////////////////////////////////////////////
+
+const (
+	TOPO_THRESHOLD = 80  //Percent
+	TOPO_WINDOW    = 120 //Seconds
+	TOPO_TAG       = 1
+
+	TOPO_MIN_COMPONENTS = 2 //Under a parent, for it to be a suspect
+
+	TOPO_MESSAGE_ID = "Heartbeat Stopped Group"
+
+	HBTD_TOPO_KEY_PRE = "hbtd_topo-" //Time a parent was last reported
+)
+
+// Parent types to correlate on, highest first.
+
+var topoLevels = []struct {
+	htype xnametypes.HMSType
+	name  string
+}{
+	{xnametypes.Cabinet, "cabinet"},
+	{xnametypes.Chassis, "chassis"},
+	{xnametypes.ComputeModule, "slot"},
+}
+
+// Components declared dead within the window, and parents reported, with
+// the time (Unix seconds).  Only used by the HB checker, which never runs
+// concurrently with itself.
+
+var topoDead = make(map[string]int64)
+var topoReported = make(map[string]int64)
+
+// Per-pass correlation data.
+
+type topoCorrelator struct {
+	now     int64
+	tracked map[string]bool //All components checked this pass
+	dead    []hbinfo        //Declared dead this pass, notifications held
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Start correlating dead components for an HB checker pass.
+//
+// now(in): Current time, Unix seconds.
+// Return:  Correlator, nil if correlation is turned off.
+/////////////////////////////////////////////////////////////////////////////
+
+func newTopoCorrelator(now int64) *topoCorrelator {
+	if app_params.topo_threshold.int_param <= 0 {
+		return nil
+	}
+	return &topoCorrelator{now: now, tracked: make(map[string]bool)}
+}
+
+// Find the parents of a component that are correlated on, highest first.
+
+func topoParents(xname string) []string {
+	var parents []string
+
+	for pname := xnametypes.GetHMSCompParent(xname); pname != ""; pname = xnametypes.GetHMSCompParent(pname) {
+		ptype := xnametypes.GetHMSType(pname)
+		for _, lvl := range topoLevels {
+			if ptype == lvl.htype {
+				parents = append([]string{pname}, parents...)
+				break
+			}
+		}
+		if (ptype == xnametypes.Cabinet) || (ptype == xnametypes.HMSTypeInvalid) {
+			break
+		}
+	}
+	return parents
+}
+
+// Convenience function, get the name of a parent's level.
+
+func topoLevelName(parent string) string {
+	ptype := xnametypes.GetHMSType(parent)
+	for _, lvl := range topoLevels {
+		if ptype == lvl.htype {
+			return lvl.name
+		}
+	}
+	return ptype.String()
+}
+
+// Note a component checked in this pass.
+
+func (tc *topoCorrelator) track(xname string) {
+	tc.tracked[xname] = true
+}
+
+// Hold the notification of a component declared dead in this pass.  Its
+// record is copied, since the HB checker reuses it.
+
+func (tc *topoCorrelator) declareDead(hbb *hbinfo) {
+	tc.dead = append(tc.dead, *hbb)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Correlate the components declared dead in this pass and send their
+// notifications.  Those whose parent is a suspected cause are sent with
+// that parent noted, plus one aggregated telemetry message for the parent
+// if it hasn't been reported within the window.  The rest are sent as
+// usual.  Called by the HB checker at the end of a pass.
+//
+// Args, Return: None.
+/////////////////////////////////////////////////////////////////////////////
+
+func (tc *topoCorrelator) notify() {
+	window := int64(app_params.topo_window.int_param)
+	for comp, when := range topoDead {
+		if (tc.now-when > window) || tc.tracked[comp] {
+			delete(topoDead, comp)
+		}
+	}
+	for parent, when := range topoReported {
+		if tc.now-when > window {
+			delete(topoReported, parent)
+		}
+	}
+	if len(tc.dead) == 0 {
+		return
+	}
+
+	//Count the components under each parent, and how many are dead.
+	//Components declared dead in earlier passes are no longer tracked,
+	//but count for both.
+
+	total := make(map[string]int)
+	down := make(map[string]int)
+	for comp := range tc.tracked {
+		for _, parent := range topoParents(comp) {
+			total[parent]++
+		}
+	}
+	for comp := range topoDead {
+		for _, parent := range topoParents(comp) {
+			total[parent]++
+			down[parent]++
+		}
+	}
+	for ix := range tc.dead {
+		for _, parent := range topoParents(tc.dead[ix].Component) {
+			down[parent]++
+		}
+	}
+
+	//Find each dead component's suspected cause, if any.
+
+	groups := make(map[string][]*hbinfo)
+	for ix := range tc.dead {
+		hbb := &tc.dead[ix]
+		topoDead[hbb.Component] = tc.now
+		cause := ""
+		for _, parent := range topoParents(hbb.Component) {
+			if (total[parent] >= TOPO_MIN_COMPONENTS) &&
+				(down[parent]*100 >= app_params.topo_threshold.int_param*total[parent]) {
+				cause = parent
+				break
+			}
+		}
+		if cause == "" {
+			hb_update_notify(hbb, HB_stopped_error)
+		} else {
+			groups[cause] = append(groups[cause], hbb)
+		}
+	}
+
+	parents := make([]string, 0, len(groups))
+	for parent := range groups {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+
+	for _, parent := range parents {
+		members := groups[parent]
+		level := topoLevelName(parent)
+		if (topoReported[parent] == 0) && topoClaim(parent, tc.now) {
+			topoReport(parent, level, members, down[parent], total[parent])
+		}
+		topoReported[parent] = tc.now
+
+		cause := fmt.Sprintf("suspected %s %s outage", level, parent)
+		for _, hbb := range members {
+			hb_notify(hbb, HB_stopped_error, cause)
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Claim the aggregated report of a parent for this instance, unless it was
+// reported (by any instance) within the window.  If the KV store can't be
+// reached it is reported anyway, rather than risk not reporting it at all.
+//
+// parent(in): Parent xname.
+// now(in):    Current time, Unix seconds.
+// Return:     true if this instance is to report it.
+/////////////////////////////////////////////////////////////////////////////
+
+func topoClaim(parent string, now int64) bool {
+	key := HBTD_TOPO_KEY_PRE + parent
+	nowstr := strconv.FormatInt(now, 10)
+
+	ok, err := kvHandle.Create(key, nowstr)
+	if (err == nil) && !ok {
+		var val string
+		var exists bool
+		val, exists, err = kvHandle.Get(key)
+		if (err == nil) && !exists {
+			ok, err = kvHandle.Create(key, nowstr)
+		} else if err == nil {
+			when, _ := strconv.ParseInt(val, 10, 64)
+			if now-when > int64(app_params.topo_window.int_param) {
+				ok, err = kvHandle.TAS(key, val, nowstr)
+			}
+		}
+	}
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error claiming report of %s, reporting it: %v", parent, err),
+			"parent", parent, "error", err)
+		return true
+	}
+	if !ok {
+		logTrace(logChecker, fmt.Sprintf("%s already reported by another instance.", parent),
+			"parent", parent)
+	}
+	return ok
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Send the aggregated telemetry message for a parent suspected of causing
+// its components to stop heartbeating.
+//
+// parent(in):  Parent xname.
+// level(in):   Parent's level (slot, chassis, cabinet).
+// members(in): HB records of the components declared dead this pass.
+// down(in):    Components under the parent declared dead in the window.
+// total(in):   Components under the parent.
+// Return:      None.
+/////////////////////////////////////////////////////////////////////////////
+
+func topoReport(parent, level string, members []*hbinfo, down, total int) {
+	comps := make([]string, 0, len(members))
+	for _, hbb := range members {
+		comps = append(comps, hbb.Component)
+	}
+	sort.Strings(comps)
+
+	info := fmt.Sprintf("Heartbeat stopped for %d of %d components under %s %s within %d seconds, suspected %s outage.",
+		down, total, level, parent, app_params.topo_window.int_param, level)
+	logChecker.Error(info,
+		"parent", parent, "level", level, "dead", down, "components", total,
+		"held", len(comps))
+	mTopoOutages.WithLabelValues(level).Inc()
+
+	telemsg := telemetry_json_v1{MessageID: TOPO_MESSAGE_ID, Id: parent,
+		NewState: base.StateStandby.String(), NewFlag: base.FlagAlert.String(),
+		LastHBTimeStamp: members[0].Last_hb_timestamp, Info: info,
+		Components: comps}
+	select {
+	case telemetryQ <- telemsg:
+	default:
+		mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+		logTelemetry.Info("Telemetry bus not accepting messages, aggregated heartbeat event not sent.",
+			"parent", parent)
+	}
+}
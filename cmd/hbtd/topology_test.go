@@ -0,0 +1,252 @@
+// MIT License
+//
+// (C) Copyright [2026] Hewlett Packard Enterprise Development LP
+//
+// Permission is hereby granted, free of charge, to any person obtaining a
+// copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL
+// THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR
+// OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE,
+// ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR
+// OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test finding the parents correlated on.
+
+func TestTopoParents(t *testing.T) {
+	tests := []struct {
+		xname   string
+		parents []string
+	}{
+		{"x3012c0s4b0n1", []string{"x3012", "x3012c0", "x3012c0s4"}},
+		{"x3012c0s4b0", []string{"x3012", "x3012c0", "x3012c0s4"}},
+		{"x3012c0r2b0", []string{"x3012", "x3012c0"}},
+		{"x3012c0", []string{"x3012"}},
+		{"x3012", nil},
+		{"bogus", nil},
+	}
+	for _, tst := range tests {
+		if parents := topoParents(tst.xname); !reflect.DeepEqual(parents, tst.parents) {
+			t.Errorf("Expected parents %v for '%s', got %v", tst.parents, tst.xname, parents)
+		}
+	}
+	if (topoLevelName("x3012") != "cabinet") || (topoLevelName("x3012c0") != "chassis") ||
+		(topoLevelName("x3012c0s4") != "slot") {
+		t.Errorf("Unexpected level names")
+	}
+
+	bsiStart, _, _, bsiStopError, _ := createBSI()
+	bsiStopError.ComponentIDs = []string{"x3012c0s4b0n1"}
+	bsi := causeBSI(&bsiStopError, "suspected chassis x3012c0 outage")
+	if (bsi.ComponentIDs != nil) || (bsi.State != bsiStopError.State) ||
+		(bsi.Flag != bsiStopError.Flag) || (bsi.bulkType != bsiStopError.bulkType) ||
+		(bsi.ExtendedInfo.Message != "Heartbeat stopped -- declared dead, suspected chassis x3012c0 outage") {
+		t.Errorf("Unexpected cause BSI: %+v", *bsi)
+	}
+	if bsiStart.ExtendedInfo.Message != "Heartbeat started" {
+		t.Errorf("Unexpected start BSI: %+v", bsiStart)
+	}
+}
+
+// Remove all claims of aggregated reports.
+
+func clearTopoClaims() {
+	kvlist, _ := kvHandle.GetRange(HBTD_TOPO_KEY_PRE, HBTD_TOPO_KEY_PRE+"~")
+	for _, kv := range kvlist {
+		kvHandle.Delete(kv.Key)
+	}
+}
+
+// Test claiming aggregated reports.
+
+func TestTopoClaim(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	origWindow := app_params.topo_window.int_param
+	app_params.topo_window.int_param = 120
+	clearTopoClaims()
+	defer func() {
+		app_params.topo_window.int_param = origWindow
+		clearTopoClaims()
+	}()
+
+	now := time.Now().Unix()
+	if !topoClaim("x3013c0", now) {
+		t.Errorf("First claim failed.")
+	}
+	if topoClaim("x3013c0", now+60) {
+		t.Errorf("Claimed again within the window.")
+	}
+	if !topoClaim("x3013c1", now+60) {
+		t.Errorf("Claim of another parent failed.")
+	}
+	if !topoClaim("x3013c0", now+121) {
+		t.Errorf("Claim after the window failed.")
+	}
+	if topoClaim("x3013c0", now+130) {
+		t.Errorf("Claimed again within the new window.")
+	}
+}
+
+// Take the pending HB_stopped_error transitions and return their causes.
+
+func takeStopErrors(t *testing.T) map[string]string {
+	causes := make(map[string]string)
+	hbMapLock.Lock()
+	ents := takePendingTransitions(time.Now())
+	hbMapLock.Unlock()
+	for _, ent := range ents {
+		if ent.Transition != hbTransitionName(HB_stopped_error) {
+			t.Errorf("Unexpected transition for '%s': %s", ent.Component, ent.Transition)
+			continue
+		}
+		causes[ent.Component] = ent.Cause
+	}
+	return causes
+}
+
+// Test correlation of dead components by the HB checker.
+
+func TestTopoCorrelation(t *testing.T) {
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+	kill_sm_goroutines()
+
+	origParams := app_params
+	initAppParams()
+	app_params.check_interval.int_param = 0
+	app_params.warntime.int_param = 30
+	app_params.errtime.int_param = 60
+	topoDead = make(map[string]int64)
+	topoReported = make(map[string]int64)
+	clearTopoClaims()
+	defer func() {
+		app_params = origParams
+		topoDead = make(map[string]int64)
+		topoReported = make(map[string]int64)
+		clearTopoClaims()
+		for len(hsmUpdateQ) > 0 {
+			<-hsmUpdateQ
+		}
+	}()
+	drainTelemetryQ()
+
+	//All of chassis c0 is dead, but only one of three in chassis c1, and
+	//one of two in its slot.  The cabinet, at 5 of 7, is below 80%.
+
+	now := time.Now().Unix()
+	store := func(comp string, last int64) {
+		rec := hbinfo{Component: comp, Last_hb_rcv_time: strconv.FormatUint(uint64(last), 16),
+			Last_hb_status: "OK", Had_warning: HB_WARN_NONE}
+		ba, _ := json.Marshal(&rec)
+		kvHandle.Store(comp, string(ba))
+	}
+	chassis := []string{"x3012c0s0b0n0", "x3012c0s0b0n1", "x3012c0s1b0n0", "x3012c0s1b0n1"}
+	alive := []string{"x3012c1s0b0n1", "x3012c1s1b0n0"}
+	for _, comp := range chassis {
+		store(comp, now-100)
+	}
+	store("x3012c1s0b0n0", now-100)
+	for _, comp := range alive {
+		store(comp, now)
+		defer kvHandle.Delete(comp)
+	}
+
+	hb_checker()
+
+	msgs := drainTelemetryQ()
+	if len(msgs) != 2 {
+		t.Fatalf("Expected 2 telemetry messages, got %+v", msgs)
+	}
+	for _, msg := range msgs {
+		if msg.MessageID == TOPO_MESSAGE_ID {
+			if (msg.Id != "x3012c0") || (msg.NewFlag != "Alert") ||
+				!reflect.DeepEqual(msg.Components, chassis) {
+				t.Errorf("Unexpected aggregated message: %+v", msg)
+			}
+		} else if (msg.MessageID != TELEMETRY_MESSAGE_ID) || (msg.Id != "x3012c1s0b0n0") {
+			t.Errorf("Unexpected telemetry message: %+v", msg)
+		}
+	}
+
+	causes := takeStopErrors(t)
+	if (len(causes) != 5) || (causes["x3012c1s0b0n0"] != "") {
+		t.Errorf("Unexpected HSM notifications: %v", causes)
+	}
+	for _, comp := range chassis {
+		if causes[comp] != "suspected chassis x3012c0 outage" {
+			t.Errorf("Unexpected cause for '%s': '%s'", comp, causes[comp])
+		}
+	}
+
+	//One more in the chassis within the window: correlated with the ones
+	//already dead, but the chassis isn't reported again, even by an
+	//instance which didn't report it (as when the cabinet moves to
+	//another instance).
+
+	topoReported = make(map[string]int64)
+	store("x3012c0s2b0n0", now-100)
+	hb_checker()
+	if msgs = drainTelemetryQ(); len(msgs) != 0 {
+		t.Errorf("Unexpected telemetry messages: %+v", msgs)
+	}
+	causes = takeStopErrors(t)
+	if (len(causes) != 1) || (causes["x3012c0s2b0n0"] != "suspected chassis x3012c0 outage") {
+		t.Errorf("Unexpected HSM notifications: %v", causes)
+	}
+
+	//With tagging off, the cause isn't sent to HSM.
+
+	app_params.topo_tag.int_param = 0
+	store("x3012c0s3b0n0", now-100)
+	hb_checker()
+	if msgs = drainTelemetryQ(); len(msgs) != 0 {
+		t.Errorf("Unexpected telemetry messages: %+v", msgs)
+	}
+	causes = takeStopErrors(t)
+	if (len(causes) != 1) || (causes["x3012c0s3b0n0"] != "") {
+		t.Errorf("Unexpected HSM notifications: %v", causes)
+	}
+
+	//With correlation off, each component is sent on its own.
+
+	app_params.topo_threshold.int_param = 0
+	store("x3012c0s4b0n0", now-100)
+	hb_checker()
+	msgs = drainTelemetryQ()
+	if (len(msgs) != 1) || (msgs[0].MessageID != TELEMETRY_MESSAGE_ID) ||
+		(msgs[0].Id != "x3012c0s4b0n0") {
+		t.Errorf("Unexpected telemetry messages: %+v", msgs)
+	}
+	takeStopErrors(t)
+}
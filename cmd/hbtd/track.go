@@ -1,6 +1,6 @@
 // MIT License
 //
-// (C) Copyright [2018-2021,2023,2025] Hewlett Packard Enterprise Development LP
+// (C) Copyright [2018-2021,2023,2025-2026] Hewlett Packard Enterprise Development LP
 //
 // Permission is hereby granted, free of charge, to any person obtaining a
 // copy of this software and associated documentation files (the "Software"),
@@ -28,9 +28,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,7 +41,6 @@ import (
 	base "github.com/Cray-HPE/hms-base/v2"
 	"github.com/Cray-HPE/hms-xname/xnametypes"
 
-	hmetcd "github.com/Cray-HPE/hms-hmetcd"
 	"github.com/gorilla/mux"
 )
 
@@ -55,6 +56,12 @@ type hbinfo struct {
 	Last_hb_timestamp string `json:"Last_hb_timestamp"` //ISO8601 time stamp, set by sender
 	Last_hb_status    string `json:"Last_hb_status"`    //Any special status of last HB, from sender
 	Had_warning       string `json:"Had_warning"`       //Flag to mark start/stop edge conditions
+
+	History    []hbHistEntry `json:"History,omitempty"`    //Recent HBs, oldest first
+	Flap_times []int64       `json:"Flap_times,omitempty"` //Recent warnings/restarts, Unix time
+	Flapping   int64         `json:"Flapping,omitempty"`   //When flapping was detected, 0 if not
+
+	Arrivals *hbArrivalStats `json:"Arrivals,omitempty"` //Learned HB inter-arrival times
 }
 
 // Heartbeat JSON.  This is the HB message format, which must follow all
@@ -73,6 +80,19 @@ type hbjson_v1 struct {
 	Timestamp string `json:"Timestamp"`
 }
 
+// Per-component result of a batch heartbeat request.  Status is an HTTP
+// status code describing how that one heartbeat was handled.
+
+type hbBatchResult struct {
+	Component string `json:"Component"`
+	Status    int    `json:"Status"`
+	Error     string `json:"Error,omitempty"`
+}
+
+type hbBatchRsp struct {
+	Results []hbBatchResult `json:"Results"`
+}
+
 // Data passed to the SM message sender thread
 
 type sminfo struct {
@@ -96,25 +116,38 @@ type smjbulk_v1 struct {
 	Flag         string   `json:"Flag"`
 	ExtendedInfo smjson_einfo
 
-	//Unmarshallable, used for HSM communication status
+	//Unmarshallable, used for HSM communication status and metrics
 	needSend bool
 	sentOK   bool
+	bulkType string
 }
 
 // for sending HB state changes to the telemetry bus
 
 type telemetry_json_v1 struct {
-	MessageID       string `json:"MessageID"`
-	Id              string `json:"ID"`
-	NewState        string `json:"NewState"`
-	NewFlag         string `json:"NewFlag"`
-	LastHBTimeStamp string `json:"LastHBTimeStamp"`
-	Info            string `json:"Info"`
+	MessageID       string   `json:"MessageID"`
+	Id              string   `json:"ID"`
+	NewState        string   `json:"NewState"`
+	NewFlag         string   `json:"NewFlag"`
+	LastHBTimeStamp string   `json:"LastHBTimeStamp"`
+	Info            string   `json:"Info"`
+	Components      []string `json:"Components,omitempty"` //Aggregated messages only
 }
 
+// Heartbeat state of a single component.  The fields after Heartbeating
+// are only filled in for verbose requests (?verbose=true).
+
 type hbSingleStateRsp struct {
-	XName        string `json:"XName"`
-	Heartbeating bool   `json:"Heartbeating"`
+	XName              string `json:"XName"`
+	Heartbeating       bool   `json:"Heartbeating"`
+	State              string `json:"State,omitempty"`
+	SecondsSinceLastHB *int64 `json:"SecondsSinceLastHB,omitempty"`
+	Last_hb_timestamp  string `json:"Last_hb_timestamp,omitempty"`
+	Last_hb_status     string `json:"Last_hb_status,omitempty"`
+	WarningReason      string `json:"WarningReason,omitempty"`
+	Flapping           bool   `json:"Flapping,omitempty"`
+
+	Detection *hbDetectRsp `json:"Detection,omitempty"`
 }
 
 type hbStatesRsp struct {
@@ -125,6 +158,25 @@ type hbStatesReq struct {
 	XNames []string `json:"XNames"`
 }
 
+// Full HB record of a tracked component, as returned by GET /hbstates.
+
+type hbInfoRsp struct {
+	Component         string `json:"Component"`
+	Last_hb_rcv_time  string `json:"Last_hb_rcv_time"` //RFC3339
+	Last_hb_timestamp string `json:"Last_hb_timestamp"`
+	Last_hb_status    string `json:"Last_hb_status"`
+	Had_warning       bool   `json:"Had_warning"`
+	State             string `json:"State"`
+	Flapping          bool   `json:"Flapping,omitempty"`
+}
+
+type hbInfoListRsp struct {
+	Total      int         `json:"Total"`
+	Offset     int         `json:"Offset"`
+	Limit      int         `json:"Limit"`
+	Components []hbInfoRsp `json:"Components"`
+}
+
 /////////////////////////////////////////////////////////////////////////////
 // Constants and enums
 /////////////////////////////////////////////////////////////////////////////
@@ -132,11 +184,22 @@ type hbStatesReq struct {
 // HB states
 
 const (
-	HB_started        = 1
-	HB_stopped_warn   = 2
-	HB_restarted_warn = 3
-	HB_stopped_error  = 4
-	HB_quit           = 0x8675309
+	HB_started          = 1
+	HB_stopped_warn     = 2
+	HB_restarted_warn   = 3
+	HB_stopped_error    = 4
+	HB_stopped_expected = 5
+	HB_quit             = 0x8675309
+)
+
+// Heartbeat Status values sent by components that are being taken down
+// on purpose.  When the heartbeat stops after one of these, it is not
+// reported as a warning or error.
+
+const (
+	HB_STATUS_SHUTDOWN    = "shutdown"
+	HB_STATUS_REBOOT      = "reboot"
+	HB_STATUS_MAINTENANCE = "maintenance"
 )
 
 const (
@@ -145,8 +208,29 @@ const (
 	HB_WARN_GAP    = "WG"
 )
 
+// Computed HB state of a component, as seen by the HB checker.
+
+const (
+	HB_STATE_OK       = "OK"
+	HB_STATE_WARN     = "WARN"
+	HB_STATE_DEAD     = "DEAD"
+	HB_STATE_STOPPING = "STOPPING" //going away, stop is expected
+	HB_STATE_UNKNOWN  = "UNKNOWN"  //not tracked
+)
+
+// Reasons for a heartbeat warning, from the Had_warning flag
+
+const (
+	HB_WARN_REASON_NORMAL = "Normal"
+	HB_WARN_REASON_GAP    = "MonitoringGap"
+)
+
 const TELEMETRY_MESSAGE_ID = "Heartbeat Change Notification"
 
+// Max number of heartbeats accepted in a single batch request.
+
+const HB_BATCH_MAX = 4096
+
 // Values used to signify HSM processing activity
 
 const HSMQ_DIE = 0x8675309
@@ -164,8 +248,11 @@ var StartMap = make(map[string]uint64)
 var RestartMap = make(map[string]uint64)
 var StopWarnMap = make(map[string]uint64)
 var StopErrorMap = make(map[string]uint64)
-var hbSeq uint64
+var StopExpectedMap = make(map[string]uint64)
+var StopErrorCauseMap = make(map[string]string) //Suspected cause, for HSM
+var hbSeq uint64                                //Protected by hbMapLock
 var hsmWG sync.WaitGroup
+var hsmSendLock sync.Mutex
 var hbMapLock sync.Mutex
 var testMode bool
 
@@ -188,7 +275,8 @@ func send_sm_patch(smjinfo *smjbulk_v1) {
 
 	barr, err := json.Marshal(smjinfo)
 	if err != nil {
-		hbtdPrintln("INTERNAL ERROR marshalling SM info:", err)
+		logHSM.Error(fmt.Sprintf("INTERNAL ERROR marshalling SM info: %v", err),
+			"type", smjinfo.bulkType, "error", err)
 		return
 	}
 
@@ -198,9 +286,9 @@ func send_sm_patch(smjinfo *smjbulk_v1) {
 		url = url + "/" + SM_URL_MID + "/" + SM_URL_SUFFIX
 	}
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("Sending PATCH to State Mgr URL: '%s', Data: '%s'",
-			url, string(barr))
+	if logEnabled(logHSM, LevelTrace) {
+		logTrace(logHSM, fmt.Sprintf("Sending PATCH to State Mgr URL: '%s', Data: '%s'",
+			url, string(barr)), "url", url, "type", smjinfo.bulkType)
 	}
 
 	//Don't actually send anything to the SM if we're in "--nosm" mode.
@@ -219,22 +307,31 @@ func send_sm_patch(smjinfo *smjbulk_v1) {
 	req.Header.Set("Content-Type", "application/json")
 	base.SetHTTPUserAgent(req, serviceName)
 
+	tstart := time.Now()
 	rsp, err := htrans.client.Do(req)
 	defer base.DrainAndCloseResponseBody(rsp)
+	mHSMPatchDuration.WithLabelValues(smjinfo.bulkType).Observe(time.Since(tstart).Seconds())
 
 	if err != nil {
-		hbtdPrintln("ERROR sending PATCH to SM:", err)
+		mHSMPatchFailures.WithLabelValues(smjinfo.bulkType).Inc()
+		logHSM.Error(fmt.Sprintf("Error sending PATCH to SM: %v", err),
+			"type", smjinfo.bulkType, "components", len(smjinfo.ComponentIDs),
+			"duration", time.Since(tstart), "error", err)
 		return
 	} else {
 		_, _ = ioutil.ReadAll(rsp.Body)
 		if (rsp.StatusCode == http.StatusOK) ||
 			(rsp.StatusCode == http.StatusNoContent) ||
 			(rsp.StatusCode == http.StatusAccepted) {
-			if app_params.debug_level.int_param > 1 {
-				hbtdPrintln("SUCCESS sending PATCH to SM, response:", rsp)
-			}
+			logTrace(logHSM, fmt.Sprintf("SUCCESS sending PATCH to SM, response: %v", rsp),
+				"type", smjinfo.bulkType, "components", len(smjinfo.ComponentIDs),
+				"duration", time.Since(tstart))
 		} else {
-			hbtdPrintln("ERROR response from State Manager:", rsp.Status, "Error code:", rsp.StatusCode)
+			mHSMPatchFailures.WithLabelValues(smjinfo.bulkType).Inc()
+			logHSM.Error(fmt.Sprintf("Error response from State Manager: %s Error code: %d",
+				rsp.Status, rsp.StatusCode),
+				"type", smjinfo.bulkType, "components", len(smjinfo.ComponentIDs),
+				"duration", time.Since(tstart), "status", rsp.StatusCode)
 			return
 		}
 	}
@@ -243,90 +340,34 @@ func send_sm_patch(smjinfo *smjbulk_v1) {
 	return
 }
 
-// Convenience function.  Takes component heartbeat status maps and prepares
-// them for HB change processing.  Populates an "all components map" that is
-// the superset of recent HB changes to persistent ones.
-
-func groomCompLocalMapsPRE(allCompsMap map[string]bool, cpStartMap, cpRestartMap, cpStopWarnMap, cpStopErrorMap map[string]uint64) {
-	//For each HB change map, populate the "all components" map, plus
-	//copy the global HB change map entries for each node into the more
-	//persistent one.
-
-	// HB Started maps
-	for k, v := range cpStartMap {
-		if v != 0 {
-			allCompsMap[k] = true
-		}
-	}
-	for k, v := range StartMap {
-		if v != 0 {
-			cpStartMap[k] = v
-			StartMap[k] = 0
-			allCompsMap[k] = true
-		}
-	}
-
-	// HB Restarted maps
-	for k, v := range cpRestartMap {
-		if v != 0 {
-			allCompsMap[k] = true
-		}
-	}
-	for k, v := range RestartMap {
-		if v != 0 {
-			cpRestartMap[k] = v
-			RestartMap[k] = 0
-			allCompsMap[k] = true
-		}
-	}
-
-	// HB Stopped/warning maps
-	for k, v := range cpStopWarnMap {
-		if v != 0 {
-			allCompsMap[k] = true
-		}
-	}
-	for k, v := range StopWarnMap {
-		if v != 0 {
-			cpStopWarnMap[k] = v
-			StopWarnMap[k] = 0
-			allCompsMap[k] = true
-		}
-	}
-
-	// HB Stopped/error maps
-	for k, v := range cpStopErrorMap {
-		if v != 0 {
-			allCompsMap[k] = true
-		}
-	}
-	for k, v := range StopErrorMap {
-		if v != 0 {
-			cpStopErrorMap[k] = v
-			StopErrorMap[k] = 0
-			allCompsMap[k] = true
+// Convenience function.  Given the HB state change sequence numbers of a
+// component, one per state change type, find the one that came in last.
+//
+// seqs(in): Sequence numbers, 0 == no state change of that type.
+// Return:   Index of the highest sequence number, or -1 if there is no
+//           single highest one.
+
+func highestSeq(seqs ...uint64) int {
+	hix := -1
+	var hval uint64
+
+	for ix, seq := range seqs {
+		if seq > hval {
+			hix = ix
+			hval = seq
+		} else if (seq == hval) && (seq != 0) {
+			hix = -1
 		}
 	}
-}
-
-// Convenience function.  For each entry in map1, clear the matching map
-// entry for all other maps.  This is done after successful SM PATCH
-// operations to clear the HB state maps.
-
-func groomCompLocalMapsPOST(map1, map2, map3, map4 map[string]uint64) {
-	for k, _ := range map1 {
-		map2[k] = 0
-		map3[k] = 0
-		map4[k] = 0
-	}
+	return hix
 }
 
 // Convenience function.  Creates HSM BulkStateInfo data structures, one
-// for each HB status change type (start/restart/stop-warn/stop-error) and
-// populates default values.
+// for each HB status change type (start/restart/stop-warn/stop-error/
+// stop-expected) and populates default values.
 
-func createBSI() (smjbulk_v1, smjbulk_v1, smjbulk_v1, smjbulk_v1) {
-	var bsiStart, bsiRestart, bsiStopWarn, bsiStopError smjbulk_v1
+func createBSI() (smjbulk_v1, smjbulk_v1, smjbulk_v1, smjbulk_v1, smjbulk_v1) {
+	var bsiStart, bsiRestart, bsiStopWarn, bsiStopError, bsiStopExpected smjbulk_v1
 	bsiStart.State = base.StateReady.String()
 	bsiStart.Flag = base.FlagOK.String()
 	bsiStart.ExtendedInfo.Message = "Heartbeat started"
@@ -339,152 +380,162 @@ func createBSI() (smjbulk_v1, smjbulk_v1, smjbulk_v1, smjbulk_v1) {
 	bsiStopError.State = base.StateStandby.String()
 	bsiStopError.Flag = base.FlagAlert.String()
 	bsiStopError.ExtendedInfo.Message = "Heartbeat stopped -- declared dead"
-	return bsiStart, bsiRestart, bsiStopWarn, bsiStopError
+	bsiStopExpected.State = base.StateStandby.String()
+	bsiStopExpected.Flag = base.FlagOK.String()
+	bsiStopExpected.ExtendedInfo.Message = "Heartbeat stopped -- expected, component going away"
+	bsiStart.bulkType = hbTransitionName(HB_started)
+	bsiRestart.bulkType = hbTransitionName(HB_restarted_warn)
+	bsiStopWarn.bulkType = hbTransitionName(HB_stopped_warn)
+	bsiStopError.bulkType = hbTransitionName(HB_stopped_error)
+	bsiStopExpected.bulkType = hbTransitionName(HB_stopped_expected)
+	return bsiStart, bsiRestart, bsiStopWarn, bsiStopError, bsiStopExpected
+}
+
+// Convenience function.  Creates an HSM BulkStateInfo data structure for
+// HB_stopped_error with a suspected cause noted in its message.
+//
+// bsiStopError(in): BulkStateInfo for HB_stopped_error, from createBSI().
+// cause(in):        Suspected cause.
+// Return:           New BulkStateInfo.
+
+func causeBSI(bsiStopError *smjbulk_v1, cause string) *smjbulk_v1 {
+	bsi := *bsiStopError
+	bsi.ComponentIDs = nil
+	bsi.ExtendedInfo.Message = bsiStopError.ExtendedInfo.Message + ", " + cause
+	return &bsi
 }
 
 /////////////////////////////////////////////////////////////////////////////
-// Thread func.  Takes the values found in the global and local/persistent
-// HB status change maps, determines which ones to place into an HSM
-// BulkStateChange data structure and then PATCH them to HSM.  If there are
-// failures with the PATCH, the persistent maps will help resolve conflicts
-// when more than one HB state change occurs while HSM is unavailable.
+// Thread func.  Moves the HB status changes found in the global HB status
+// change maps into the HSM notification outbox in the KV store, then places
+// everything in the outbox that has not yet been sent into HSM
+// BulkStateChange data structures and PATCHes them to HSM.  If there are
+// failures with the PATCH, the outbox entries are left as they are, and
+// the highest sequence number per component resolves conflicts when more
+// than one HB state change occurs while HSM is unavailable.  Since the
+// outbox is shared, changes seen by an instance that goes away are sent by
+// the ones that remain.  Every instance puts its changes into the outbox,
+// but only the leader sends it, so each entry is sent once and an entry
+// replaced by a later change while being sent is sent again afterwards.
 /////////////////////////////////////////////////////////////////////////////
 
 func send_sm_req() {
-	cpStartMap := make(map[string]uint64)
-	cpRestartMap := make(map[string]uint64)
-	cpStopWarnMap := make(map[string]uint64)
-	cpStopErrorMap := make(map[string]uint64)
-
 	for {
 		//Wait for next HB scan to complete.
 
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("Waiting for a Q Pop.")
-		}
+		logTrace(logHSM, "Waiting for a Q Pop.")
 		qval := <-hsmUpdateQ
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("Q Popped, val: %x.", qval)
-		}
+		logTrace(logHSM, fmt.Sprintf("Q Popped, val: %x.", qval))
 		if qval == HSMQ_DIE {
-			hbtdPrintf("DIE message received, exiting send_sm_req().")
+			logHSM.Info("DIE message received, exiting send_sm_req().")
 			break
 		}
-		//Copy HB state changes from the global component maps into into local
-		//maps.
-		//Also build a map of all pertinent components (superset of local and
-		//global maps).
 
-		allCompsMap := make(map[string]bool)
+		//Move HB state changes from the global component maps into the
+		//outbox.  If any component saw more than one HB change, take the
+		//one with the highest sequence number.
+
 		hbMapLock.Lock()
-		groomCompLocalMapsPRE(allCompsMap, cpStartMap, cpRestartMap, cpStopWarnMap, cpStopErrorMap)
+		pending := takePendingTransitions(time.Now())
 		hbMapLock.Unlock()
+		outboxFlush(pending)
 
-		//Populate local copies of the HB state maps from the global ones.
-		//De-duplicate the maps.  If any component saw more than one HB
-		//change, take the one with the highest sequence number.  Note that
-		//each time through the outer most for() loop (indicating a new HB
-		//scan was done) if there were any HSM send errors from the previous
-		//scan, the HB states will still be retained in local copies of the
-		//maps, so we'll just add from the global state maps (not start over).
-		//If the HSM data was sent OK, the local maps are cleared and new
-		//data is sent.
-
-		bsiStart, bsiRestart, bsiStopWarn, bsiStopError := createBSI()
-
-		for k, _ := range allCompsMap {
-			start, _ := cpStartMap[k]
-			restart, _ := cpRestartMap[k]
-			swarn, _ := cpStopWarnMap[k]
-			serr, _ := cpStopErrorMap[k]
-
-			//The state change category with the highest value wins, meaning
-			//that it came in last, time-wise.
-
-			if (start > restart) && (start > swarn) && (start > serr) {
-				bsiStart.ComponentIDs = append(bsiStart.ComponentIDs, k)
-			} else if (restart > start) && (restart > swarn) && (restart > serr) {
-				bsiRestart.ComponentIDs = append(bsiRestart.ComponentIDs, k)
-			} else if (swarn > start) && (swarn > restart) && (swarn > serr) {
-				bsiStopWarn.ComponentIDs = append(bsiStopWarn.ComponentIDs, k)
-			} else if (serr > start) && (serr > restart) && (serr > swarn) {
-				bsiStopError.ComponentIDs = append(bsiStopError.ComponentIDs, k)
-			}
+		//Only the leader sends the outbox.
+
+		if !isChecker() {
+			logTrace(logHSM, fmt.Sprintf("HSM outbox being sent by leader '%s', skipping.",
+				leaderName()), "leader", leaderName())
+			continue
 		}
 
 		//If HSM is not ready, bail until next scan.
 
 		if !hsmReady {
-			hbtdPrintf("HSM Not ready, waiting until next scan.")
+			logHSM.Info("HSM Not ready, waiting until next scan.")
 			continue //wait until next scan.
 		}
 
+		ents, err := outboxLoad(time.Now())
+		if err != nil {
+			logKV.Error(fmt.Sprintf("Error fetching HSM outbox, waiting until next scan: %v", err),
+				"error", err)
+			continue
+		}
+
+		//Populate the bulk state data from the outbox entries not yet sent.
+
+		//Errors with a suspected cause go in a separate bulk state data
+		//structure per cause, so the cause can be noted in its message.
+
+		bsiStart, bsiRestart, bsiStopWarn, bsiStopError, bsiStopExpected := createBSI()
+		bsis := []*smjbulk_v1{&bsiStart, &bsiRestart, &bsiStopWarn,
+			&bsiStopError, &bsiStopExpected}
+		bsiEnts := make([][]*hbOutboxEntry, len(bsis))
+		causeIX := make(map[string]int)
+		nunsent := 0
+
+		for _, ent := range ents {
+			if ent.Sent {
+				continue
+			}
+			ix := outboxTransitionIndex(ent.Transition)
+			if ent.Cause != "" {
+				cix, ok := causeIX[ent.Cause]
+				if !ok {
+					bsi := causeBSI(&bsiStopError, ent.Cause)
+					cix = len(bsis)
+					causeIX[ent.Cause] = cix
+					bsis = append(bsis, bsi)
+					bsiEnts = append(bsiEnts, nil)
+				}
+				ix = cix
+			}
+			bsis[ix].ComponentIDs = append(bsis[ix].ComponentIDs, ent.Component)
+			bsiEnts[ix] = append(bsiEnts[ix], ent)
+			nunsent++
+		}
+		mOutboxPending.Set(float64(nunsent))
+
 		//Check each bulk operation and add a wait count, then send the SM
 		//patches, in parallel, one for each HB state change type.
 
-		if len(bsiStart.ComponentIDs) > 0 {
-			hsmWG.Add(1)
-			bsiStart.needSend = true
-			go send_sm_patch(&bsiStart)
-		}
-		if len(bsiRestart.ComponentIDs) > 0 {
-			hsmWG.Add(1)
-			bsiRestart.needSend = true
-			go send_sm_patch(&bsiRestart)
-		}
-		if len(bsiStopWarn.ComponentIDs) > 0 {
-			hsmWG.Add(1)
-			bsiStopWarn.needSend = true
-			go send_sm_patch(&bsiStopWarn)
-		}
-		if len(bsiStopError.ComponentIDs) > 0 {
-			hsmWG.Add(1)
-			bsiStopError.needSend = true
-			go send_sm_patch(&bsiStopError)
+		if nunsent == 0 {
+			logTrace(logHSM, "Nothing to send to HSM.")
+			continue
 		}
 
-		if !bsiStart.needSend && !bsiRestart.needSend &&
-			!bsiStopWarn.needSend && !bsiStopError.needSend {
-			if app_params.debug_level.int_param > 1 {
-				hbtdPrintf("Nothing to send to HSM.")
+		hsmSendLock.Lock()
+		for _, bsi := range bsis {
+			if len(bsi.ComponentIDs) > 0 {
+				hsmWG.Add(1)
+				bsi.needSend = true
+				go send_sm_patch(bsi)
 			}
-			continue
 		}
 
 		//Wait until they are all complete.
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("Waiting for HSM PATCHs to complete...")
-		}
+		logTrace(logHSM, "Waiting for HSM PATCHs to complete...")
 		hsmWG.Wait()
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("PATCHs complete: %t %t %t %t",
-				bsiStart.sentOK, bsiRestart.sentOK, bsiStopWarn.sentOK,
-				bsiStopError.sentOK)
+		hsmSendLock.Unlock()
+		if logEnabled(logHSM, slog.LevelDebug) {
+			var sent []any
+			for _, bsi := range bsis {
+				if bsi.needSend {
+					sent = append(sent, slog.Bool(bsi.bulkType, bsi.sentOK))
+				}
+			}
+			logHSM.Debug("PATCHs complete.", slog.Group("sent", sent...))
 		}
 
-		//See if all of the ones we sent have completed OK.  For the ones
-		//that sent to SM OK, delete it's local map to start over.  Any
-		//that failed, retain the local map so it can be updated on the next
-		//scan.
+		//Mark the outbox entries of the ones that were sent to SM OK.  Any
+		//that failed are left in the outbox to be sent again after the
+		//next scan.
 
-		hbMapLock.Lock()
-		if bsiStart.needSend && bsiStart.sentOK {
-			groomCompLocalMapsPOST(cpStartMap, cpRestartMap, cpStopWarnMap, cpStopErrorMap)
-			cpStartMap = make(map[string]uint64)
-		}
-		if bsiRestart.needSend && bsiRestart.sentOK {
-			groomCompLocalMapsPOST(cpRestartMap, cpStartMap, cpStopWarnMap, cpStopErrorMap)
-			cpRestartMap = make(map[string]uint64)
-		}
-		if bsiStopWarn.needSend && bsiStopWarn.sentOK {
-			groomCompLocalMapsPOST(cpStopWarnMap, cpStartMap, cpRestartMap, cpStopErrorMap)
-			cpStopWarnMap = make(map[string]uint64)
-		}
-		if bsiStopError.needSend && bsiStopError.sentOK {
-			groomCompLocalMapsPOST(cpStopErrorMap, cpStartMap, cpRestartMap, cpStopWarnMap)
-			cpStopErrorMap = make(map[string]uint64)
+		for ix, bsi := range bsis {
+			if bsi.needSend && bsi.sentOK {
+				outboxMarkSent(bsiEnts[ix], time.Now())
+			}
 		}
-		hbMapLock.Unlock()
 	}
 }
 
@@ -508,10 +559,12 @@ func telemetry_handler() {
 			if err == nil {
 				err = msgbusHandle.MessageWrite(string(jdata))
 				if err != nil {
-					hbtdPrintln("ERROR injecting telemetry data:", err)
+					logTelemetry.Error(fmt.Sprintf("Error injecting telemetry data: %v", err),
+						"component", tmsg.Id, "error", err)
 				}
 			} else {
-				hbtdPrintln("ERROR marshalling telemetry data:", err)
+				logTelemetry.Error(fmt.Sprintf("Error marshalling telemetry data: %v", err),
+					"component", tmsg.Id, "error", err)
 			}
 		}
 		tbMutex.Unlock()
@@ -528,50 +581,95 @@ func telemetry_handler() {
 /////////////////////////////////////////////////////////////////////////////
 
 func hb_update_notify(hb *hbinfo, to_state int) {
+	hb_notify(hb, to_state, "")
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Same as hb_update_notify(), for an HB_stopped_error whose suspected cause
+// was found by topology correlation.  No telemetry message is sent, since
+// an aggregated one is sent for the cause, and if topo_tag is set the cause
+// is noted in the message sent to HSM.
+//
+// hb(in):       HB record of the component.
+// to_state(in): HB transition.
+// cause(in):    Suspected cause, "" if none.
+// Return:       None.
+/////////////////////////////////////////////////////////////////////////////
+
+func hb_notify(hb *hbinfo, to_state int, cause string) {
 	var telemsg telemetry_json_v1
 
+	//If notifications for this component are being suppressed, just record
+	//the transition.
+
+	if supid := suppressedBy(hb.Component, to_state); supid != "" {
+		recordSuppressed(supid, hb, to_state)
+		mTransitionsSuppressed.WithLabelValues(hbTransitionName(to_state)).Inc()
+		return
+	}
+	mTransitions.WithLabelValues(hbTransitionName(to_state)).Inc()
+
 	telemsg.MessageID = TELEMETRY_MESSAGE_ID
 	telemsg.Id = hb.Component
 	telemsg.LastHBTimeStamp = hb.Last_hb_timestamp
-	hbSeq++
 
 	switch to_state {
 	case HB_started:
 		hbMapLock.Lock()
-		StartMap[hb.Component] = hbSeq
+		StartMap[hb.Component] = takeHBSeq()
 		hbMapLock.Unlock()
 		telemsg.NewState = base.StateReady.String()
 		telemsg.NewFlag = base.FlagOK.String()
 		telemsg.Info = "Heartbeat started."
 	case HB_restarted_warn:
 		hbMapLock.Lock()
-		RestartMap[hb.Component] = hbSeq
+		RestartMap[hb.Component] = takeHBSeq()
 		hbMapLock.Unlock()
 		telemsg.NewState = base.StateReady.String()
 		telemsg.NewFlag = base.FlagOK.String()
 		telemsg.Info = "Heartbeat re-started."
 	case HB_stopped_warn:
 		hbMapLock.Lock()
-		StopWarnMap[hb.Component] = hbSeq
+		StopWarnMap[hb.Component] = takeHBSeq()
 		hbMapLock.Unlock()
 		telemsg.NewState = base.StateReady.String()
 		telemsg.NewFlag = base.FlagWarning.String()
 		telemsg.Info = "Heartbeat stopped, node may be dead."
 	case HB_stopped_error:
 		hbMapLock.Lock()
-		StopErrorMap[hb.Component] = hbSeq
+		StopErrorMap[hb.Component] = takeHBSeq()
+		if (cause != "") && (app_params.topo_tag.int_param != 0) {
+			StopErrorCauseMap[hb.Component] = cause
+		} else {
+			delete(StopErrorCauseMap, hb.Component)
+		}
 		hbMapLock.Unlock()
 		telemsg.NewState = base.StateStandby.String()
 		telemsg.NewFlag = base.FlagAlert.String()
 		telemsg.Info = "Heartbeat stopped, node is dead."
+	case HB_stopped_expected:
+		hbMapLock.Lock()
+		StopExpectedMap[hb.Component] = takeHBSeq()
+		hbMapLock.Unlock()
+		telemsg.NewState = base.StateStandby.String()
+		telemsg.NewFlag = base.FlagOK.String()
+		telemsg.Info = fmt.Sprintf("Heartbeat stopped, node going away (%s).",
+			hb.Last_hb_status)
 	default:
-		hbtdPrintf("INTERNAL ERROR: UNKNOWN STATE: %d", to_state)
+		logChecker.Error(fmt.Sprintf("INTERNAL ERROR: UNKNOWN STATE: %d", to_state),
+			"component", hb.Component)
+	}
+
+	if cause != "" {
+		return
 	}
 
 	select {
 	case telemetryQ <- telemsg:
 	default:
-		hbtdPrintf("INFO: Telemetry bus not accepting messages, heartbeat event not sent.")
+		mQueueDrops.WithLabelValues(QUEUE_TELEMETRY).Inc()
+		logTelemetry.Info("Telemetry bus not accepting messages, heartbeat event not sent.",
+			"component", hb.Component, "transition", hbTransitionName(to_state))
 	}
 }
 
@@ -603,60 +701,69 @@ func hb_checker() {
 	var verr error
 	var now, tdiff, lhbtime int64
 	var deleteKeys []string
-	var updateKeys []hmetcd.Kvi_KV
+	var updateKeys []hbKV
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("HB CHECKER entry.")
-	}
+	logTrace(logChecker, "HB CHECKER entry.")
 
 	ncomp := 0
 
-	// Grab the inter-process lock and get all keys/vals.
+	//Refresh the HB timeout policies and notification suppressions.  This
+	//is done whether or not this instance does the check, since policies
+	//are also used by the HB state queries and suppressions by the HB
+	//receive path.
 
-	if app_params.check_interval.int_param > 0 {
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("Locking...")
-		}
-		tstart := time.Now()
-		lckerr := kvHandle.DistTimedLock(app_params.check_interval.int_param * 2)
-		tfin := time.Now()
-		elapsed := tfin.Sub(tstart) / time.Second
-		if int(elapsed) > (app_params.check_interval.int_param * 3) {
-			hbtdPrintf("WARNING: Distributed lock acquisition attempt took %d seconds.",
-				elapsed)
-		}
-		if lckerr != nil {
-			//Lock is already held.  This means someone else is doing the check,
-			//so we don't have to.
+	_, perr := loadPolicies()
+	if perr != nil {
+		logChecker.Error(fmt.Sprintf("Error fetching HB timeout policies, using cached copy: %v", perr),
+			"error", perr)
+	}
+	_, perr = loadSuppressions()
+	if perr != nil {
+		logChecker.Error(fmt.Sprintf("Error fetching HB notification suppressions, using cached copy: %v", perr),
+			"error", perr)
+	}
 
-			if app_params.debug_level.int_param > 1 {
-				hbtdPrintf("HB checker being done elsewhere, skipping.\n")
-				hbtdPrintln("  (returned:", lckerr, ")")
-			}
+	// With multiple instances, each one checks its own shard of the
+	// components.  If sharding can't be done, only the elected leader
+	// checks, and checks them all; if there are no shards this time, no
+	// one checks.  Then get all keys/vals.
+
+	var ring *hbShardRing
+	leader := isChecker()
+	if app_params.check_interval.int_param > 0 {
+		ring = checkerShardRing()
+		if (ring == nil) && !leader {
+			logTrace(logChecker, fmt.Sprintf("HB checker being done by leader '%s', skipping.",
+				leaderName()), "leader", leaderName())
 			rearm_hbcheck_timer()
 			return
 		}
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("HB Checker lock acquired.")
+		if (ring != nil) && (len(ring.members) == 0) {
+			rearm_hbcheck_timer()
+			return
 		}
 	}
 
+	checkStart := time.Now()
+	topo := newTopoCorrelator(checkStart.Unix())
+	stateCounts := map[string]int{HB_STATE_OK: 0, HB_STATE_WARN: 0, HB_STATE_DEAD: 0,
+		HB_STATE_STOPPING: 0}
+
 	//Test code, activated by environment variable.  Causes the HB checker
-	//to sleep whilst holding the lock, simulating cases where the HB checker
-	//takes a long time, to verify that multi-instances will take over for
-	//each other.
+	//to sleep, simulating cases where the HB checker takes a long time, to
+	//verify that multi-instances will take over for each other.
 
 	envstr := os.Getenv("HBTD_RSLEEP")
 	if envstr != "" {
 		slp, _ := strconv.Atoi(envstr)
-		hbtdPrintf("Sleeping for %d seconds..\n", slp)
+		logChecker.Info(fmt.Sprintf("Sleeping for %d seconds..", slp))
 		time.Sleep(time.Duration(slp) * time.Second)
 	}
 
 	kvlist, err := kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
 	if err != nil {
-		hbtdPrintln("ERROR fetching all hbtd keys from KV store: ", err)
-		kvHandle.DistUnlock() //ignore errors
+		logKV.Error(fmt.Sprintf("Error fetching all hbtd keys from KV store: %v", err),
+			"error", err)
 		rearm_hbcheck_timer()
 		return
 	}
@@ -666,19 +773,29 @@ func hb_checker() {
 		if kv.Key == KV_PARAM_KEY {
 			continue
 		}
+		//Skip other instances' components
+		if (ring != nil) && (ring.owner(shardKey(kv.Key)) != instanceKey) {
+			continue
+		}
 
 		storeit = false
 		ncomp++
 
-		if app_params.debug_level.int_param > 1 {
-			hbtdPrintf("Checking component: '%s'\n", kv.Key)
+		if logEnabled(logChecker, LevelTrace) {
+			logTrace(logChecker, fmt.Sprintf("Checking component: '%s'", kv.Key),
+				"component", kv.Key)
 		}
 
+		nhb = hbinfo{}
 		verr = json.Unmarshal([]byte(kv.Value), &nhb)
 		if verr != nil {
-			hbtdPrintln("ERROR unmarshalling '", kv.Value, "': ", verr)
+			logChecker.Error(fmt.Sprintf("Error unmarshalling '%s': %v", kv.Value, verr),
+				"component", kv.Key, "error", verr)
 			continue
 		}
+		if topo != nil {
+			topo.track(nhb.Component)
+		}
 
 		//Get the current time.  We will get it here rather than once at the
 		//beginning of this function since there can be delays in getting
@@ -694,13 +811,44 @@ func hb_checker() {
 
 		lhbtime, _ = strconv.ParseInt(nhb.Last_hb_rcv_time, 16, 64)
 		tdiff = now - lhbtime
+		warntime, errtime, _ := resolveThresholds(&nhb)
+
+		if isGoingAway(nhb.Last_hb_status) {
+			stateCounts[HB_STATE_STOPPING]++
+			if tdiff >= int64(warntime) {
+				logChecker.Info(fmt.Sprintf("Heartbeat stopped for '%s' (expected), last status: '%s'",
+					nhb.Component, nhb.Last_hb_status),
+					"component", nhb.Component, "transition", hbTransitionName(HB_stopped_expected),
+					"overdue", tdiff, "status", nhb.Last_hb_status)
+
+				//Send an expected stop to SM and take it out of the list.
+				hb_update_notify(&nhb, HB_stopped_expected)
+				deleteKeys = append(deleteKeys, kv.Key)
+				ncomp--
+				continue
+			}
 
-		if tdiff >= int64(app_params.errtime.int_param) {
+			//The going-away HB arrived in time, so as with any other HB, a
+			//prior warning is over.  Send a restart, or HSM is left with
+			//the Warning flag until the expected stop.
+			if nhb.Had_warning != HB_WARN_NONE {
+				nhb.Had_warning = HB_WARN_NONE
+				storeit = true
+				if flapTransition(&nhb, HB_restarted_warn, now) {
+					logChecker.Info(fmt.Sprintf("Heartbeat restarted for '%s'", nhb.Component),
+						"component", nhb.Component, "transition", hbTransitionName(HB_restarted_warn),
+						"status", nhb.Last_hb_status)
+					hb_update_notify(&nhb, HB_restarted_warn)
+				}
+			}
+		} else if tdiff >= int64(errtime) {
 			if staleKeys {
 				//This means there was a time when there was no HBTD instance
 				//running.  We'll treat these the same as warnings.
-				hbtdPrintf("WARNING: Heartbeat overdue %d seconds for '%s' due to HB monitoring gap; might be dead, last status: '%s'",
-					tdiff, nhb.Component, nhb.Last_hb_status)
+				logChecker.Warn(fmt.Sprintf("Heartbeat overdue %d seconds for '%s' due to HB monitoring gap; might be dead, last status: '%s'",
+					tdiff, nhb.Component, nhb.Last_hb_status),
+					"component", nhb.Component, "transition", hbTransitionName(HB_stopped_warn),
+					"overdue", tdiff, "status", nhb.Last_hb_status, "reason", HB_WARN_REASON_GAP)
 
 				//Update the HB's last received time.  This will freshen the
 				//stale key so if it's really still heartbeating, it will
@@ -712,89 +860,128 @@ func hb_checker() {
 				nhb.Had_warning = HB_WARN_GAP
 				hb_update_notify(&nhb, HB_stopped_warn)
 				storeit = true
+				stateCounts[HB_STATE_WARN]++
 			} else {
-				hbtdPrintf("ERROR: Heartbeat overdue %d seconds for '%s' (declared dead), last status: '%s'\n",
-					tdiff, nhb.Component, nhb.Last_hb_status)
-
-				//Send an error to SM
-				hb_update_notify(&nhb, HB_stopped_error)
+				logChecker.Error(fmt.Sprintf("Heartbeat overdue %d seconds for '%s' (declared dead), last status: '%s'",
+					tdiff, nhb.Component, nhb.Last_hb_status),
+					"component", nhb.Component, "transition", hbTransitionName(HB_stopped_error),
+					"overdue", tdiff, "status", nhb.Last_hb_status)
+
+				//Send an error to SM.  If it may be part of a slot, chassis
+				//or cabinet outage, hold it until all are checked.
+				if (topo != nil) && (suppressedBy(nhb.Component, HB_stopped_error) == "") {
+					topo.declareDead(&nhb)
+				} else {
+					hb_update_notify(&nhb, HB_stopped_error)
+				}
 
 				//Since it's dead, take it out of the list.
+				stateCounts[HB_STATE_DEAD]++
 				deleteKeys = append(deleteKeys, kv.Key)
 				ncomp--
 				continue
 			}
-		} else if tdiff >= int64(app_params.warntime.int_param) {
+		} else if tdiff >= int64(warntime) {
+			stateCounts[HB_STATE_WARN]++
 			if nhb.Had_warning == HB_WARN_NONE {
-				hbtdPrintf("WARNING: Heartbeat overdue %d seconds for '%s' (might be dead), last status: '%s'\n",
-					tdiff, nhb.Component, nhb.Last_hb_status)
-
-				//Send a warning to SM
+				//Send a warning to SM, unless the component is flapping
 				nhb.Had_warning = HB_WARN_NORMAL
-				hb_update_notify(&nhb, HB_stopped_warn)
+				storeit = true
+				if flapTransition(&nhb, HB_stopped_warn, now) {
+					logChecker.Warn(fmt.Sprintf("Heartbeat overdue %d seconds for '%s' (might be dead), last status: '%s'",
+						tdiff, nhb.Component, nhb.Last_hb_status),
+						"component", nhb.Component, "transition", hbTransitionName(HB_stopped_warn),
+						"overdue", tdiff, "status", nhb.Last_hb_status, "reason", HB_WARN_REASON_NORMAL)
+					hb_update_notify(&nhb, HB_stopped_warn)
+				}
+			}
+			if flapSettled(&nhb, now) {
 				storeit = true
 			}
 		} else {
 			//HB arrived in time.  Check if there was a prior warning, and if
-			//so, send a HB re-started message
+			//so, send a HB re-started message, unless the component is
+			//flapping.
 			if nhb.Had_warning == HB_WARN_NORMAL {
 				nhb.Had_warning = HB_WARN_NONE
 				storeit = true
-				hbtdPrintf("INFO: Heartbeat restarted for '%s'\n", nhb.Component)
-				hb_update_notify(&nhb, HB_restarted_warn)
+				if flapTransition(&nhb, HB_restarted_warn, now) {
+					logChecker.Info(fmt.Sprintf("Heartbeat restarted for '%s'", nhb.Component),
+						"component", nhb.Component, "transition", hbTransitionName(HB_restarted_warn))
+					hb_update_notify(&nhb, HB_restarted_warn)
+				}
+			}
+			if flapSettled(&nhb, now) {
+				storeit = true
+			}
+
+			//A flapping component is held in the warning state.
+			if nhb.Flapping != 0 {
+				stateCounts[HB_STATE_WARN]++
+			} else {
+				stateCounts[HB_STATE_OK]++
 			}
 		}
 
 		if storeit {
 			jstr, err := json.Marshal(nhb)
 			if err != nil {
-				hbtdPrintln("INTERNAL ERROR marshaling JSON for ", nhb.Component, ": ", err)
+				logChecker.Error(fmt.Sprintf("INTERNAL ERROR marshaling JSON for %s: %v", nhb.Component, err),
+					"component", nhb.Component, "error", err)
 			} else {
-				updateKeys = append(updateKeys, hmetcd.Kvi_KV{Key: nhb.Component,
+				updateKeys = append(updateKeys, hbKV{Key: nhb.Component,
 					Value: string(jstr)})
 			}
 		}
 	}
 
-	//Delete keys of dead HBs
+	//Send the held notifications of dead HBs, correlated by topology.
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("Deleting %d keys...", len(deleteKeys))
-	}
-	for _, dkey := range deleteKeys {
-		verr = kvHandle.Delete(dkey)
-		if verr != nil {
-			hbtdPrintln("ERROR deleting key '", dkey, "' from KV store: ", verr)
-		}
+	if topo != nil {
+		topo.notify()
 	}
 
-	//Update keys that need updating
+	//Delete keys of dead HBs and update keys that need updating.  These
+	//are written in batches, since one at a time takes far too long with
+	//lots of components.
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("Updating %d keys...", len(updateKeys))
+	logTrace(logChecker, fmt.Sprintf("Deleting %d keys, updating %d keys...",
+		len(deleteKeys), len(updateKeys)))
+	writeOps := make([]hbStoreOp, 0, len(deleteKeys)+len(updateKeys))
+	for _, dkey := range deleteKeys {
+		writeOps = append(writeOps, hbStoreOp{Key: dkey, Delete: true})
 	}
 	for _, ukey := range updateKeys {
-		merr := kvHandle.Store(ukey.Key, ukey.Value)
-		if merr != nil {
-			hbtdPrintf("ERROR storing key '%s': %v", ukey.Key, merr)
-		}
+		writeOps = append(writeOps, hbStoreOp{Key: ukey.Key, Value: ukey.Value})
 	}
+	storeApply(kvHandle, writeOps)
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("Unlocking...")
-	}
-	if app_params.check_interval.int_param > 0 {
-		err := kvHandle.DistUnlock()
-		if err != nil {
-			hbtdPrintln("ERROR unlocking distributed lock:", err)
-		}
+	//Report and clean up ended suppression windows.  Only the leader
+	//does this.
+
+	if leader {
+		checkSuppressions(time.Now())
 	}
 
-	hsmUpdateQ <- HSMQ_NEW
+	pokeHSMQ(HSMQ_NEW)
+
+	for state, cnt := range stateCounts {
+		mComponents.WithLabelValues(state).Set(float64(cnt))
+	}
+	checkTime := time.Since(checkStart)
+	mCheckerDuration.Observe(checkTime.Seconds())
+	updateInstance(func(inst *hbInstance) {
+		inst.LastAudit = &checkStart
+	})
+	logChecker.Debug(fmt.Sprintf("HB check done, %d components, %d expired, %d updated.",
+		ncomp, len(deleteKeys), len(updateKeys)),
+		"components", ncomp, "expired", len(deleteKeys), "updated", len(updateKeys),
+		"duration", checkTime)
 
 	if ncomp != sg_ncomp {
 		sg_ncomp = ncomp
-		hbtdPrintf("Number of components heartbeating: %d\n", ncomp)
+		logChecker.Info(fmt.Sprintf("Number of components heartbeating: %d", ncomp),
+			"components", ncomp)
 	}
 
 	staleKeys = false
@@ -805,20 +992,61 @@ func hb_checker() {
 	rearm_hbcheck_timer()
 }
 
+// Convenience function.  Check if a heartbeat Status value means the
+// component is going away on purpose.
+//
+// status(in): Status field of a heartbeat.
+// Return:     true if the component is going away, else false.
+
+func isGoingAway(status string) bool {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case HB_STATUS_SHUTDOWN, HB_STATUS_REBOOT, HB_STATUS_MAINTENANCE:
+		return true
+	}
+	return false
+}
+
+// Convenience function.  Apply a newly arrived heartbeat to a component's
+// HB record: update the receive time, sender time stamp, status, heartbeat
+// history and learned inter-arrival times.
+
+func applyHB(hbb *hbinfo, timestamp, status string) {
+	now := time.Now()
+	learnArrival(hbb, now)
+	hbb.Last_hb_rcv_time = strconv.FormatUint(uint64(now.Unix()), 16)
+	hbb.Last_hb_timestamp = timestamp
+	hbb.Last_hb_status = status
+	addHBHistory(hbb, now, timestamp, status)
+
+	//Special case: if this heartbeat record Had_warning flag shows a coverage
+	//gap, set it to a normal warning so the checker handles is correctly.
+
+	if hbb.Had_warning == HB_WARN_GAP {
+		hbb.Had_warning = HB_WARN_NORMAL
+	}
+}
+
 // Convenience function.  Update the time stamp and associated info for this
-// component.
+// component.  Used by all of the single heartbeat paths, HTTP and gRPC.
 //
 // TODO: maybe we don't mess with unmarshalling the KV HB data -- we pretty
 // much just overwrite it anyway.  But, doing it this way makes it easy
 // to do any data compares from the previous HB if we want to.
+//
+// xname(in):     Component the heartbeat is for.
+// timestamp(in): Time stamp from the heartbeat.
+// status(in):    Status from the heartbeat.
+// Return:        HTTP status code describing the result;
+//                Description of the problem if not http.StatusOK.
 
-func updateHB(errinst, xname, timestamp, status string, w http.ResponseWriter) {
+func storeHB(xname, timestamp, status string) (int, string) {
 	var hbb hbinfo
 
 	newkey := 0
 	kval, kok, kerr := kvHandle.Get(xname)
 	if kerr != nil {
-		hbtdPrintf("Error reading KV key for: '%s', '%v'", xname, kerr)
+		logKV.Error(fmt.Sprintf("Error reading KV key for: '%s', '%v'", xname, kerr),
+			"component", xname, "error", kerr)
 	}
 
 	if (kok == false) || (kerr != nil) {
@@ -831,54 +1059,103 @@ func updateHB(errinst, xname, timestamp, status string, w http.ResponseWriter) {
 
 		umerr := json.Unmarshal([]byte(kval), &hbb)
 		if umerr != nil {
-			hbtdPrintln("INTERNAL ERROR unmarshalling '", kval, "': ", umerr)
-			pdet := base.NewProblemDetails("about:blank",
-				"Internal Server Error",
-				"Error unmarshalling JSON string",
-				errinst, http.StatusInternalServerError)
-			base.SendProblemDetails(w, pdet, 0)
-			return
+			logIngest.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", kval, umerr),
+				"component", xname, "error", umerr)
+			return http.StatusInternalServerError, "Error unmarshalling JSON string"
 		}
 	}
 
-	hbb.Last_hb_rcv_time = strconv.FormatUint(uint64(time.Now().Unix()), 16)
-	hbb.Last_hb_timestamp = timestamp
-	hbb.Last_hb_status = status
-
-	//Special case: if this heartbeat record Had_warning flag shows a coverage
-	//gap, set it to a normal warning so the checker handles is correctly.
-
-	if hbb.Had_warning == HB_WARN_GAP {
-		hbb.Had_warning = HB_WARN_NORMAL
-	}
+	applyHB(&hbb, timestamp, status)
 
 	jstr, jerr := json.Marshal(hbb)
 	if jerr != nil {
-		hbtdPrintln("INTERNAL ERROR marshaling JSON: ", jerr)
-		pdet := base.NewProblemDetails("about:blank",
-			"Internal Server Error",
-			"Error marshalling JSON data",
-			errinst, http.StatusInternalServerError)
-		base.SendProblemDetails(w, pdet, 0)
-		return
+		logIngest.Error(fmt.Sprintf("INTERNAL ERROR marshaling JSON: %v", jerr),
+			"component", xname, "error", jerr)
+		return http.StatusInternalServerError, "Error marshalling JSON data"
 	}
 
 	merr := kvHandle.Store(xname, string(jstr))
 	if merr != nil {
-		hbtdPrintln("INTERNAL ERROR storing key ", string(jstr), ": ", merr)
+		logKV.Error(fmt.Sprintf("INTERNAL ERROR storing key %s: %v", string(jstr), merr),
+			"component", xname, "error", merr)
+		return http.StatusInternalServerError, "Key/Value service store operation failed"
+	}
+
+	//Send notification of a new HB startup, unless the very first HB
+	//says the component is going away.
+
+	if (newkey != 0) && !isGoingAway(status) {
+		logIngest.Info(fmt.Sprintf("Heartbeat started for '%s'", hbb.Component),
+			"component", hbb.Component, "transition", hbTransitionName(HB_started),
+			"status", status)
+		hb_update_notify(&hbb, HB_started)
+	}
+	return http.StatusOK, ""
+}
+
+// Convenience function.  Update the time stamp and associated info for this
+// component, sending an error response if that fails.
+
+func updateHB(errinst, xname, timestamp, status string, w http.ResponseWriter) {
+	code, errstr := storeHB(xname, timestamp, status)
+	if code != http.StatusOK {
 		pdet := base.NewProblemDetails("about:blank",
 			"Internal Server Error",
-			"Key/Value service store operation failed",
-			errinst, http.StatusInternalServerError)
+			errstr,
+			errinst, code)
 		base.SendProblemDetails(w, pdet, 0)
-		return
 	}
+}
 
-	if newkey != 0 {
-		//Send notification of a new HB startup
-		hbtdPrintf("INFO: Heartbeat started for '%s'\n", hbb.Component)
-		hb_update_notify(&hbb, HB_started)
+// Convenience function.  Check all the fields of a full heartbeat message
+// to be sure they are present and valid.
+//
+// jdata(in): Heartbeat message to check.
+// Return:    Empty string if valid, else a description of the problem.
+
+func validateHBFull(jdata *hbjson_full_v1) string {
+	//Check all the fields to be sure they are valid.  TODO: we could
+	//check the Component to be sure it's a valid XName, but some
+	//customer might want to use their own node names and track things
+	//anyway; thus, for now at least, we won't limit tracking to just
+	//valid XNames.  Note that this makes it possible for typos to be
+	//acceptable component names!
+
+	ferrstr := ""
+	if jdata.Component == "" {
+		ferrstr = "Missing Component field"
+	} else if jdata.Hostname == "" {
+		ferrstr = "Missing Hostname field"
+	} else if jdata.NID == "" {
+		ferrstr = "Missing NID field"
+	} else if jdata.Status == "" {
+		ferrstr = "Missing Status field"
+	} else if jdata.Timestamp == "" {
+		ferrstr = "Missing Timestamp field"
+	}
+
+	if ferrstr != "" {
+		logIngest.Info(fmt.Sprintf("Incomplete heartbeat JSON: %s", ferrstr),
+			"component", jdata.Component, "error", ferrstr)
+		return ferrstr
+	}
+
+	//Check to be sure that certain fields' values are valid.
+
+	if xnametypes.GetHMSType(jdata.Component) == xnametypes.HMSTypeInvalid {
+		logIngest.Info(fmt.Sprintf("Invalid XName in heartbeat JSON: %s", jdata.Component),
+			"component", jdata.Component)
+		return "Invalid Component Name"
+	}
+
+	_, cerr := strconv.ParseInt(jdata.NID, 0, 64)
+	if cerr != nil {
+		logIngest.Info(fmt.Sprintf("Invalid NID in heartbeat JSON: %s", jdata.NID),
+			"component", jdata.Component, "nid", jdata.NID)
+		return "Invalid NID"
 	}
+
+	return ""
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -892,7 +1169,7 @@ func hbRcv(w http.ResponseWriter, r *http.Request) {
 	errinst := URL_HEARTBEAT
 
 	if r.Method != "POST" {
-		hbtdPrintf("ERROR: request is not a POST.\n")
+		logIngest.Error("Request is not a POST.", "method", r.Method)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			"Only POST operations supported",
@@ -918,7 +1195,8 @@ func hbRcv(w http.ResponseWriter, r *http.Request) {
 		errstr := "Invalid JSON data type"
 		errb := json.Unmarshal(body, &v)
 		if errb != nil {
-			hbtdPrintln("Unmarshal into map[string]interface{} didn't work:", errb)
+			logIngest.Error(fmt.Sprintf("Unmarshal into map[string]interface{} didn't work: %v", errb),
+				"error", errb)
 		} else {
 			//Figure out what field(s) == bad and report them.  For now, they're
 			//all strings.
@@ -936,7 +1214,7 @@ func hbRcv(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-		hbtdPrintln("Bad heartbeat JSON decode:", err)
+		logIngest.Error(fmt.Sprintf("Bad heartbeat JSON decode: %v", err), "error", err)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			errstr,
@@ -945,28 +1223,8 @@ func hbRcv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Check all the fields to be sure they are valid.  TODO: we could
-	//check the Component to be sure it's a valid XName, but some
-	//customer might want to use their own node names and track things
-	//anyway; thus, for now at least, we won't limit tracking to just
-	//valid XNames.  Note that this makes it possible for typos to be
-	//acceptable component names!
-
-	ferrstr := ""
-	if jdata.Component == "" {
-		ferrstr = "Missing Component field"
-	} else if jdata.Hostname == "" {
-		ferrstr = "Missing Hostname field"
-	} else if jdata.NID == "" {
-		ferrstr = "Missing NID field"
-	} else if jdata.Status == "" {
-		ferrstr = "Missing Status field"
-	} else if jdata.Timestamp == "" {
-		ferrstr = "Missing Timestamp field"
-	}
-
+	ferrstr := validateHBFull(&jdata)
 	if ferrstr != "" {
-		hbtdPrintf("Incomplete heartbeat JSON: %s\n", ferrstr)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			ferrstr,
@@ -975,37 +1233,12 @@ func hbRcv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Check to be sure that certain fields' values are valid.
-
-	if xnametypes.GetHMSType(jdata.Component) == xnametypes.HMSTypeInvalid {
-		hbtdPrintf("Invalid XName in heartbeat JSON: %s\n", jdata.Component)
-		pdet := base.NewProblemDetails("about:blank",
-			"Invalid Request",
-			"Invalid Component Name",
-			errinst, http.StatusBadRequest)
-		base.SendProblemDetails(w, pdet, 0)
-		return
-	}
-
-	_, cerr := strconv.ParseInt(jdata.NID, 0, 64)
-	if cerr != nil {
-		hbtdPrintf("Invalid NID in heartbeat JSON: %s\n", jdata.NID)
-		pdet := base.NewProblemDetails("about:blank",
-			"Invalid Request",
-			"Invalid NID",
-			errinst, http.StatusBadRequest)
-		base.SendProblemDetails(w, pdet, 0)
-		return
-	}
-
-	if app_params.debug_level.int_param > 0 {
-		hbtdPrintf("Heartbeat: Component: %s, Host: %s, NID: %s, Status: %s, time: %s\n",
+	if logEnabled(logIngest, slog.LevelDebug) {
+		logIngest.Debug(fmt.Sprintf("Heartbeat: Component: %s, Host: %s, NID: %s, Status: %s, time: %s",
 			jdata.Component, jdata.Hostname, jdata.NID, jdata.Status,
-			jdata.Timestamp)
-	}
-
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("HB received for: '%s'", jdata.Component)
+			jdata.Timestamp),
+			"component", jdata.Component, "hostname", jdata.Hostname,
+			"nid", jdata.NID, "status", jdata.Status, "timestamp", jdata.Timestamp)
 	}
 
 	//Update the time stamp and info for this component.
@@ -1024,7 +1257,7 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 		xn := xnametypes.NormalizeHMSCompID(toks[len(toks)-1])
 		if xn == "" {
 			//Enforce valid XName
-			hbtdPrintf("ERROR: request is not a POST.\n")
+			logIngest.Error("Request is not a POST.", "method", r.Method)
 			pdet := base.NewProblemDetails("about:blank",
 				"Invalid Request",
 				"Only POST operations supported",
@@ -1036,7 +1269,7 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		hbtdPrintf("ERROR: request is not a POST.\n")
+		logIngest.Error("Request is not a POST.", "method", r.Method)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			"Only POST operations supported",
@@ -1062,7 +1295,8 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 		errstr := "Invalid JSON data type"
 		errb := json.Unmarshal(body, &v)
 		if errb != nil {
-			hbtdPrintln("Unmarshal into map[string]interface{} didn't work:", errb)
+			logIngest.Error(fmt.Sprintf("Unmarshal into map[string]interface{} didn't work: %v", errb),
+				"error", errb)
 		} else {
 			//Figure out what field(s) == bad and report them.  For now, they're
 			//all strings.
@@ -1080,7 +1314,7 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-		hbtdPrintln("Bad heartbeat JSON decode:", err)
+		logIngest.Error(fmt.Sprintf("Bad heartbeat JSON decode: %v", err), "error", err)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			errstr,
@@ -1099,7 +1333,8 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if ferrstr != "" {
-		hbtdPrintf("Incomplete heartbeat JSON: %s\n", ferrstr)
+		logIngest.Info(fmt.Sprintf("Incomplete heartbeat JSON: %s", ferrstr),
+			"component", xname, "error", ferrstr)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			ferrstr,
@@ -1108,18 +1343,279 @@ func hbRcvXName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if app_params.debug_level.int_param > 0 {
-		hbtdPrintf("Heartbeat: Status: %s, time: %s\n",
-			jdata.Status, jdata.Timestamp)
+	if logEnabled(logIngest, slog.LevelDebug) {
+		logIngest.Debug(fmt.Sprintf("Heartbeat: Component: %s, Status: %s, time: %s",
+			xname, jdata.Status, jdata.Timestamp),
+			"component", xname, "status", jdata.Status, "timestamp", jdata.Timestamp)
 	}
 
 	//Update the time stamp and info for this component.
 
-	if app_params.debug_level.int_param > 1 {
-		hbtdPrintf("HB received for: '%s'", xname)
+	updateHB(errinst, xname, jdata.Timestamp, jdata.Status, w)
+}
+
+// Convenience function.  Fetch the existing HB records for a set of
+// components.  They are fetched with one multi-key read, rather than a
+// range scan, since a batch's components can be spread across the whole
+// machine.  If that fails, each is fetched on its own.
+//
+// xnames(in): De-duplicated list of components.
+// Return:     Map of component name to HB record JSON, for those that exist;
+//             Map of component name to error, for those that couldn't be read.
+
+func getHBRecords(xnames []string) (map[string]string, map[string]error) {
+	errs := make(map[string]error)
+
+	if len(xnames) == 0 {
+		return map[string]string{}, errs
 	}
 
-	updateHB(errinst, xname, jdata.Timestamp, jdata.Status, w)
+	recs, err := kvHandle.GetMulti(xnames)
+	if err == nil {
+		return recs, errs
+	}
+	logKV.Error(fmt.Sprintf("Error fetching %d HB keys, fetching individually: %v",
+		len(xnames), err), "components", len(xnames), "error", err)
+
+	recs = make(map[string]string)
+	for _, xname := range xnames {
+		kval, kok, kerr := kvHandle.Get(xname)
+		if kerr != nil {
+			errs[xname] = kerr
+		} else if kok {
+			recs[xname] = kval
+		}
+	}
+	return recs, errs
+}
+
+// Apply a batch of already-validated heartbeats to the KV store.  Existing
+// HB records are fetched in bulk, and the updated ones are written back in
+// batches.  If a component appears more than once in the batch, the last
+// one wins.
+//
+// hbs(in):     Validated heartbeats.
+// results(in): Result for each heartbeat (same order as hbs), filled in
+//              by this function.
+// Return:      None.
+
+func updateHBBatch(hbs []*hbjson_full_v1, results []*hbBatchResult) {
+	last := make(map[string]int)
+	var xnames []string
+
+	for ix, hb := range hbs {
+		if _, ok := last[hb.Component]; !ok {
+			xnames = append(xnames, hb.Component)
+		}
+		last[hb.Component] = ix
+	}
+	sort.Strings(xnames)
+
+	recs, rerrs := getHBRecords(xnames)
+
+	status := make(map[string]int, len(xnames))
+	errstr := make(map[string]string, len(xnames))
+	started := make(map[string]*hbinfo)
+	var ops []hbStoreOp
+
+	for _, xname := range xnames {
+		var hbb hbinfo
+		hb := hbs[last[xname]]
+		status[xname] = http.StatusOK
+
+		if rerr, bad := rerrs[xname]; bad {
+			//Same as the single HB case -- treat an unreadable record as
+			//a new one.
+			logKV.Error(fmt.Sprintf("Error reading KV key for: '%s', '%v'", xname, rerr),
+				"component", xname, "error", rerr)
+		}
+
+		kval, exists := recs[xname]
+		if exists {
+			umerr := json.Unmarshal([]byte(kval), &hbb)
+			if umerr != nil {
+				logIngest.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", kval, umerr),
+					"component", xname, "error", umerr)
+				status[xname] = http.StatusInternalServerError
+				errstr[xname] = "Error unmarshalling JSON string"
+				continue
+			}
+		} else {
+			hbb.Component = xname
+		}
+
+		applyHB(&hbb, hb.Timestamp, hb.Status)
+		jstr, jerr := json.Marshal(hbb)
+		if jerr != nil {
+			logIngest.Error(fmt.Sprintf("INTERNAL ERROR marshaling JSON: %v", jerr),
+				"component", xname, "error", jerr)
+			status[xname] = http.StatusInternalServerError
+			errstr[xname] = "Error marshalling JSON data"
+			continue
+		}
+		ops = append(ops, hbStoreOp{Key: xname, Value: string(jstr)})
+		if !exists && !isGoingAway(hb.Status) {
+			started[xname] = &hbb
+		}
+	}
+
+	for _, xname := range storeApply(kvHandle, ops) {
+		status[xname] = http.StatusInternalServerError
+		errstr[xname] = "Key/Value service store operation failed"
+	}
+
+	//Send notifications of new HB startups, for those that were stored.
+
+	for _, xname := range xnames {
+		hbb, ok := started[xname]
+		if !ok || (status[xname] != http.StatusOK) {
+			continue
+		}
+		logIngest.Info(fmt.Sprintf("Heartbeat started for '%s'", hbb.Component),
+			"component", hbb.Component, "transition", hbTransitionName(HB_started),
+			"status", hbb.Last_hb_status)
+		hb_update_notify(hbb, HB_started)
+	}
+
+	//Every occurrence of a component in the batch gets the same result,
+	//since they were collapsed into a single update.
+
+	for ix, hb := range hbs {
+		results[ix].Status = status[hb.Component]
+		results[ix].Error = errstr[hb.Component]
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Callback from the server loop when a batch HB request comes in.  The
+// payload is an array of full heartbeat messages, typically forwarded by an
+// aggregator.  Each heartbeat is validated and applied independently; the
+// response carries a result per heartbeat so that partial failures don't
+// reject the whole batch.
+/////////////////////////////////////////////////////////////////////////////
+
+func hbRcvBatch(w http.ResponseWriter, r *http.Request) {
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_HEARTBEATS
+
+	if r.Method != "POST" {
+		logIngest.Error("Request is not a POST.", "method", r.Method)
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Only POST operations supported",
+			errinst, http.StatusMethodNotAllowed)
+
+		//It is required to have an "Allow:" header with this error
+		w.Header().Add("Allow", "POST")
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	//Unmarshal into raw messages first so that a bad element only fails
+	//that element, not the whole batch.
+
+	var rawHBs []json.RawMessage
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &rawHBs)
+	}
+	if err != nil {
+		logIngest.Error(fmt.Sprintf("Bad heartbeat batch JSON decode: %v", err), "error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Invalid JSON data type, expecting an array of heartbeats",
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	if len(rawHBs) == 0 {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			"Empty heartbeat batch",
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	if len(rawHBs) > HB_BATCH_MAX {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			fmt.Sprintf("Too many heartbeats in batch (%d), max is %d",
+				len(rawHBs), HB_BATCH_MAX),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	var rspData hbBatchRsp
+	var validHBs []*hbjson_full_v1
+	var validResults []*hbBatchResult
+
+	rspData.Results = make([]hbBatchResult, len(rawHBs))
+
+	for ix, raw := range rawHBs {
+		var jdata hbjson_full_v1
+		res := &rspData.Results[ix]
+
+		uerr := json.Unmarshal(raw, &jdata)
+		if uerr != nil {
+			//Try to at least report which component it was.
+			var v map[string]interface{}
+			if json.Unmarshal(raw, &v) == nil {
+				if comp, ok := v["Component"].(string); ok {
+					res.Component = comp
+				}
+			}
+			res.Status = http.StatusBadRequest
+			res.Error = "Invalid JSON data type"
+			continue
+		}
+
+		res.Component = jdata.Component
+		ferrstr := validateHBFull(&jdata)
+		if ferrstr != "" {
+			res.Status = http.StatusBadRequest
+			res.Error = ferrstr
+			continue
+		}
+
+		if logEnabled(logIngest, slog.LevelDebug) {
+			logIngest.Debug(fmt.Sprintf("Heartbeat: Component: %s, Host: %s, NID: %s, Status: %s, time: %s (batch)",
+				jdata.Component, jdata.Hostname, jdata.NID, jdata.Status,
+				jdata.Timestamp),
+				"component", jdata.Component, "hostname", jdata.Hostname,
+				"nid", jdata.NID, "status", jdata.Status, "timestamp", jdata.Timestamp)
+		}
+
+		validHBs = append(validHBs, &jdata)
+		validResults = append(validResults, res)
+	}
+
+	logIngest.Debug(fmt.Sprintf("Heartbeat batch: %d received, %d valid.",
+		len(rawHBs), len(validHBs)),
+		"received", len(rawHBs), "valid", len(validHBs))
+
+	updateHBBatch(validHBs, validResults)
+
+	for _, res := range rspData.Results {
+		mHBReceived.WithLabelValues(HB_EP_HEARTBEATS, hbResultLabel(res.Status)).Inc()
+	}
+
+	ba, baerr := json.Marshal(&rspData)
+	if baerr != nil {
+		logIngest.Error(fmt.Sprintf("INTERNAL ERROR marshalling rsp data: %v", baerr),
+			"error", baerr)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Error marshalling JSON return data",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -1130,13 +1626,14 @@ func paramsIO(w http.ResponseWriter, r *http.Request) {
 	defer base.DrainAndCloseRequestBody(r)
 
 	var rparams []byte
+	var merr error
 	errinst := URL_PARAMS
 
 	if r.Method == "PATCH" {
 		body, err := ioutil.ReadAll(r.Body)
 
 		if err != nil {
-			hbtdPrintln("Error on message read:", err)
+			logMain.Error(fmt.Sprintf("Error on message read: %v", err), "error", err)
 			pdet := base.NewProblemDetails("about:blank",
 				"Invalid Request",
 				"Error reading inbound request",
@@ -1149,8 +1646,12 @@ func paramsIO(w http.ResponseWriter, r *http.Request) {
 
 		var errstrs string
 
+		paramLock.Lock()
+		oldParams := cur_param_data()
 		if parse_parm_json(body, PARAM_PATCH, &errstrs) != 0 {
-			hbtdPrintf("Error parsing parameter JSON: '%s'\n", errstrs)
+			paramLock.Unlock()
+			logMain.Error(fmt.Sprintf("Error parsing parameter JSON: '%s'", errstrs),
+				"errors", errstrs)
 			pdet := base.NewProblemDetails("about:blank",
 				"Invalid Request",
 				errstrs,
@@ -1159,26 +1660,19 @@ func paramsIO(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		//OK, if we got here, things applied correctly.  Generate a JSON
-		//response with the current values of the parameters.
+		//OK, if we got here, things applied correctly.  Store the current
+		//values of the parameters as a new revision in the KV store so
+		//that all instances of this service see it and use the same
+		//values of parameters, and return them.
 
-		if gen_cur_param_json(&rparams) != 0 {
-			pdet := base.NewProblemDetails("about:blank",
-				"Internal Server Error",
-				"Failed JSON marshall",
-				errinst, http.StatusInternalServerError)
-			base.SendProblemDetails(w, pdet, 0)
-			return
+		rparams, merr = paramStore()
+		if merr == nil {
+			recordParamChange(oldParams, "PATCH "+URL_PARAMS, r)
 		}
-
-		//Set this JSON blob as a key for the KV store so that all
-		//instances of this service see it and use the same values of
-		//parameters.
-
-		merr := kvHandle.Store(KV_PARAM_KEY, string(rparams))
+		paramLock.Unlock()
 		if merr != nil {
-			hbtdPrintln("INTERNAL ERROR storing KV params value ",
-				string(rparams), ": ", merr)
+			logKV.Error(fmt.Sprintf("INTERNAL ERROR storing KV params value: %v", merr),
+				"key", KV_PARAM_KEY, "error", merr)
 			pdet := base.NewProblemDetails("about:blank",
 				"Internal Server Error",
 				"Failed KV service STORE operation",
@@ -1190,19 +1684,9 @@ func paramsIO(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(rparams)
 	} else if r.Method == "GET" {
-		if gen_cur_param_json(&rparams) != 0 {
-			pdet := base.NewProblemDetails("about:blank",
-				"Internal Server Error",
-				"Failed JSON marshall",
-				errinst, http.StatusInternalServerError)
-			base.SendProblemDetails(w, pdet, 0)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(rparams)
+		sendJSON(w, http.StatusOK, paramsResponse(), errinst)
 	} else {
-		hbtdPrintf("ERROR: request is not a PATCH or a GET.\n")
+		logMain.Error("Request is not a PATCH or a GET.", "method", r.Method)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			"Only PATCH and GET operations supported",
@@ -1214,16 +1698,14 @@ func paramsIO(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Convenience function, given a component name and time reference, determine
-// whether that component is heartbeating.
+// Convenience function, fetch and decode the HB record of a component.
 //
-// xname(in):   Name of component to check.
-// now(in):     Time reference, used to calculate heartbeat state.
+// xname(in):   Name of component to fetch.
 // errinst(in): Function name of caller (for error messaging).
-// Return:      true if component is heartbeating, else false
+// Return:      HB record of the component, nil if it is not being tracked.
 //              Problem report on error for caller to use.
 
-func isHeartbeating(xname string, now int64, errinst string) (bool, *base.ProblemDetails) {
+func getHBInfo(xname string, errinst string) (*hbinfo, *base.ProblemDetails) {
 	var hbb hbinfo
 
 	kval, kok, kerr := kvHandle.Get(xname)
@@ -1232,20 +1714,36 @@ func isHeartbeating(xname string, now int64, errinst string) (bool, *base.Proble
 			"Invalid Request",
 			fmt.Sprintf("Error retrieving key '%s'", xname),
 			errinst, http.StatusInternalServerError)
-		return false, pdet
+		return nil, pdet
 	}
 	if kok == false {
-		return false, nil
+		return nil, nil
 	}
 
 	umerr := json.Unmarshal([]byte(kval), &hbb)
 	if umerr != nil {
-		hbtdPrintln("INTERNAL ERROR unmarshalling '", kval, "': ", umerr)
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR unmarshalling '%s': %v", kval, umerr),
+			"component", xname, "error", umerr)
 		pdet := base.NewProblemDetails("about:blank",
 			"Internal Server Error",
 			fmt.Sprintf("Error unmarshalling JSON for key '%s'", xname),
 			errinst, http.StatusInternalServerError)
-		return false, pdet
+		return nil, pdet
+	}
+
+	return &hbb, nil
+}
+
+// Convenience function, given a component's HB record and time reference,
+// determine whether that component is heartbeating.
+//
+// hbb(in): HB record of the component, nil if not being tracked.
+// now(in): Time reference, used to calculate heartbeat state.
+// Return:  true if component is heartbeating, else false
+
+func isHeartbeating(hbb *hbinfo, now int64) bool {
+	if hbb == nil {
+		return false
 	}
 
 	//Get the HB record's Last_hb_rcv_time timestamp and decode it.
@@ -1255,11 +1753,297 @@ func isHeartbeating(xname string, now int64, errinst string) (bool, *base.Proble
 
 	lhbtime, _ := strconv.ParseInt(hbb.Last_hb_rcv_time, 16, 64)
 	tdiff := now - lhbtime
-	if tdiff >= int64(app_params.errtime.int_param) {
+	_, errtime, _ := resolveThresholds(hbb)
+	if tdiff >= int64(errtime) {
+		return false
+	}
+
+	return true
+}
+
+// Convenience function, fill in a single component HB state response.
+//
+// rsp(out):    Response to fill in.
+// xname(in):   Name of component.
+// hbb(in):     HB record of the component, nil if not being tracked.
+// now(in):     Time reference, used to calculate heartbeat state.
+// verbose(in): If true, fill in the verbose fields too.
+// Return:      None.
+
+func fillHBStateRsp(rsp *hbSingleStateRsp, xname string, hbb *hbinfo, now int64, verbose bool) {
+	*rsp = hbSingleStateRsp{XName: xname, Heartbeating: isHeartbeating(hbb, now)}
+
+	if !verbose {
+		return
+	}
+	if hbb == nil {
+		rsp.State = HB_STATE_UNKNOWN
+		return
+	}
+
+	lhbtime, _ := strconv.ParseInt(hbb.Last_hb_rcv_time, 16, 64)
+	tdiff := now - lhbtime
+	rsp.State = hbState(hbb, now)
+	rsp.SecondsSinceLastHB = &tdiff
+	rsp.Last_hb_timestamp = hbb.Last_hb_timestamp
+	rsp.Last_hb_status = hbb.Last_hb_status
+	rsp.Flapping = (hbb.Flapping != 0)
+	rsp.Detection = detectRsp(hbb, now)
+
+	switch hbb.Had_warning {
+	case HB_WARN_NORMAL:
+		rsp.WarningReason = HB_WARN_REASON_NORMAL
+	case HB_WARN_GAP:
+		rsp.WarningReason = HB_WARN_REASON_GAP
+	}
+}
+
+// Convenience function, parse the optional 'verbose' query parameter.
+//
+// r(in):  HTTP request.
+// Return: Value of the parameter, false if not present.
+//         Error if the parameter is not a boolean.
+
+func queryVerbose(r *http.Request) (bool, error) {
+	qstr := r.URL.Query().Get("verbose")
+	if qstr == "" {
 		return false, nil
 	}
+	val, err := strconv.ParseBool(qstr)
+	if err != nil {
+		return false, fmt.Errorf("Invalid 'verbose' value '%s', must be true or false",
+			qstr)
+	}
+	return val, nil
+}
+
+// Convenience function, given a component's HB record and a time reference,
+// compute the HB state the way the HB checker sees it.
+//
+// hbb(in): HB record of the component.
+// now(in): Time reference, used to calculate heartbeat state.
+// Return:  HB_STATE_OK, HB_STATE_WARN, HB_STATE_DEAD or HB_STATE_STOPPING
+
+func hbState(hbb *hbinfo, now int64) string {
+	lhbtime, _ := strconv.ParseInt(hbb.Last_hb_rcv_time, 16, 64)
+	tdiff := now - lhbtime
+	warntime, errtime, _ := resolveThresholds(hbb)
+
+	if isGoingAway(hbb.Last_hb_status) {
+		return HB_STATE_STOPPING
+	}
+	if tdiff >= int64(errtime) {
+		return HB_STATE_DEAD
+	}
+
+	//A monitoring gap warning freshens the receive time, so it has to be
+	//checked explicitly.  A flapping component is held in the warning state.
+
+	if (tdiff >= int64(warntime)) ||
+		(hbb.Had_warning == HB_WARN_GAP) || (hbb.Flapping != 0) {
+		return HB_STATE_WARN
+	}
+	return HB_STATE_OK
+}
+
+// Convenience function, parse a non-negative integer query parameter.
+//
+// r(in):    HTTP request.
+// name(in): Name of query parameter.
+// Return:   Value of the parameter, 0 if not present.
+//           Error if the parameter is not a non-negative integer.
+
+func queryUint(r *http.Request, name string) (int, error) {
+	qstr := r.URL.Query().Get(name)
+	if qstr == "" {
+		return 0, nil
+	}
+	val, err := strconv.Atoi(qstr)
+	if (err != nil) || (val < 0) {
+		return 0, fmt.Errorf("Invalid '%s' value '%s', must be a non-negative integer",
+			name, qstr)
+	}
+	return val, nil
+}
+
+// Entry point for GET /hmi/v1/hbstates.  Returns the full HB record of every
+// tracked component.  Optional query parameters:
+//
+//   state=s[,s...]  Only return components in the given state(s):
+//                   OK, WARN, DEAD or STOPPING
+//   prefix=xname    Only return components with this xname prefix
+//   type=hmstype    Only return components of this HMS type
+//   offset=n        Skip the first n matching components
+//   limit=n         Return at most n components (0 == all)
+
+func hbStatesList(w http.ResponseWriter, r *http.Request) {
+	var hbb hbinfo
+	var rspData hbInfoListRsp
+
+	defer base.DrainAndCloseRequestBody(r)
+
+	errinst := URL_HB_STATES
+	qvals := r.URL.Query()
+
+	stateMap := make(map[string]bool)
+	for _, sv := range strings.Split(qvals.Get("state"), ",") {
+		sv = strings.ToUpper(strings.TrimSpace(sv))
+		if sv == "" {
+			continue
+		}
+		if (sv != HB_STATE_OK) && (sv != HB_STATE_WARN) &&
+			(sv != HB_STATE_DEAD) && (sv != HB_STATE_STOPPING) {
+			pdet := base.NewProblemDetails("about:blank",
+				"Invalid Request",
+				fmt.Sprintf("Invalid state '%s', must be one of %s, %s, %s, %s",
+					sv, HB_STATE_OK, HB_STATE_WARN, HB_STATE_DEAD,
+					HB_STATE_STOPPING),
+				errinst, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+		stateMap[sv] = true
+	}
+
+	prefix := strings.ToLower(strings.TrimSpace(qvals.Get("prefix")))
+
+	htype := ""
+	if qvals.Get("type") != "" {
+		htype = xnametypes.VerifyNormalizeType(qvals.Get("type"))
+		if htype == "" {
+			pdet := base.NewProblemDetails("about:blank",
+				"Invalid Request",
+				fmt.Sprintf("Invalid HMS type '%s'", qvals.Get("type")),
+				errinst, http.StatusBadRequest)
+			base.SendProblemDetails(w, pdet, 0)
+			return
+		}
+	}
+
+	offset, oerr := queryUint(r, "offset")
+	limit, lerr := queryUint(r, "limit")
+	if (oerr != nil) || (lerr != nil) {
+		if oerr == nil {
+			oerr = lerr
+		}
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			oerr.Error(),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	kvlist, err := kvHandle.GetRange(HB_KEYRANGE_START, HB_KEYRANGE_END)
+	if err != nil {
+		logKV.Error(fmt.Sprintf("Error fetching all hbtd keys from KV store: %v", err),
+			"error", err)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Failed KV service GETRANGE operation",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
+	//Not all KV implementations return keys in order; sort them so that
+	//pagination is stable.
+
+	sort.Slice(kvlist, func(i, j int) bool { return kvlist[i].Key < kvlist[j].Key })
+
+	now := time.Now().Unix()
+	rspData.Components = []hbInfoRsp{}
+
+	for _, kv := range kvlist {
+		//Skip special keys
+		if kv.Key == KV_PARAM_KEY {
+			continue
+		}
+		if (prefix != "") && !strings.HasPrefix(kv.Key, prefix) {
+			continue
+		}
+		if (htype != "") && (string(xnametypes.GetHMSType(kv.Key)) != htype) {
+			continue
+		}
+
+		hbb = hbinfo{}
+		umerr := json.Unmarshal([]byte(kv.Value), &hbb)
+		if umerr != nil {
+			logMain.Error(fmt.Sprintf("Error unmarshalling '%s': %v", kv.Value, umerr),
+				"component", kv.Key, "error", umerr)
+			continue
+		}
+
+		state := hbState(&hbb, now)
+		if (len(stateMap) > 0) && !stateMap[state] {
+			continue
+		}
+
+		rspData.Total++
+		if rspData.Total <= offset {
+			continue
+		}
+		if (limit > 0) && (len(rspData.Components) >= limit) {
+			continue
+		}
+
+		lhbtime, _ := strconv.ParseInt(hbb.Last_hb_rcv_time, 16, 64)
+		rspData.Components = append(rspData.Components, hbInfoRsp{
+			Component:         hbb.Component,
+			Last_hb_rcv_time:  time.Unix(lhbtime, 0).UTC().Format(time.RFC3339),
+			Last_hb_timestamp: hbb.Last_hb_timestamp,
+			Last_hb_status:    hbb.Last_hb_status,
+			Had_warning:       (hbb.Had_warning != HB_WARN_NONE),
+			State:             state,
+			Flapping:          (hbb.Flapping != 0),
+		})
+	}
+
+	rspData.Offset = offset
+	rspData.Limit = limit
+
+	ba, baerr := json.Marshal(&rspData)
+	if baerr != nil {
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR marshalling rsp data: %v", baerr),
+			"error", baerr)
+		pdet := base.NewProblemDetails("about:blank",
+			"Internal Server Error",
+			"Error marshalling JSON return data",
+			errinst, http.StatusInternalServerError)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(ba)
+}
 
-	return true, nil
+// Convenience function, get the HB states of a list of components.  Used
+// by both POST /hmi/v1/hbstates and the gRPC HB state service.
+//
+// xnames(in):  Components to get the HB states of.
+// errinst(in): Instance for error responses.
+// verbose(in): If true, fill in the verbose fields too.
+// Return:      HB states, in the same order as xnames;
+//              Error details if a HB record couldn't be read, else nil.
+
+func getHBStates(xnames []string, errinst string, verbose bool) ([]hbSingleStateRsp, *base.ProblemDetails) {
+	var rspSingle hbSingleStateRsp
+	var states []hbSingleStateRsp
+
+	now := time.Now().Unix()
+
+	for _, comp := range xnames {
+		hbb, pdet := getHBInfo(comp, errinst)
+
+		if pdet != nil {
+			return nil, pdet
+		}
+
+		fillHBStateRsp(&rspSingle, comp, hbb, now, verbose)
+		states = append(states, rspSingle)
+	}
+	return states, nil
 }
 
 // Entry point for /hmi/v1/hbstates
@@ -1267,7 +2051,6 @@ func isHeartbeating(xname string, now int64, errinst string) (bool, *base.Proble
 func hbStates(w http.ResponseWriter, r *http.Request) {
 	var jdata hbStatesReq
 	var rspData hbStatesRsp
-	var rspSingle hbSingleStateRsp
 
 	defer base.DrainAndCloseRequestBody(r)
 
@@ -1275,7 +2058,7 @@ func hbStates(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 
 	if err != nil {
-		hbtdPrintln("Error on message read:", err)
+		logMain.Error(fmt.Sprintf("Error on message read: %v", err), "error", err)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			"Error reading inbound request",
@@ -1286,7 +2069,8 @@ func hbStates(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &jdata)
 	if err != nil {
-		hbtdPrintf("Error unmarshalling HB state req data: %v", err)
+		logMain.Error(fmt.Sprintf("Error unmarshalling HB state req data: %v", err),
+			"error", err)
 		pdet := base.NewProblemDetails("about:blank",
 			"Invalid Request",
 			"Error unmarshalling inbound request",
@@ -1295,24 +2079,27 @@ func hbStates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	now := time.Now().Unix()
-
-	for _, comp := range jdata.XNames {
-		isHB, pdet := isHeartbeating(comp, now, errinst)
-
-		if pdet != nil {
-			base.SendProblemDetails(w, pdet, 0)
-			return
-		}
+	verbose, verr := queryVerbose(r)
+	if verr != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			verr.Error(),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
 
-		rspSingle.XName = comp
-		rspSingle.Heartbeating = isHB
-		rspData.HBStates = append(rspData.HBStates, rspSingle)
+	var pdet *base.ProblemDetails
+	rspData.HBStates, pdet = getHBStates(jdata.XNames, errinst, verbose)
+	if pdet != nil {
+		base.SendProblemDetails(w, pdet, 0)
+		return
 	}
 
 	ba, baerr := json.Marshal(&rspData)
 	if baerr != nil {
-		hbtdPrintf("INTERNAL ERROR marshalling rsp data: %v", baerr)
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR marshalling rsp data: %v", baerr),
+			"error", baerr)
 		pdet := base.NewProblemDetails("about:blank",
 			"Internal Server Error",
 			"Error marshalling JSON return data",
@@ -1335,21 +2122,32 @@ func hbStateSingle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	targ := xnametypes.NormalizeHMSCompID(vars["xname"])
 	errinst := URL_HB_STATE + "/" + targ
+
+	verbose, verr := queryVerbose(r)
+	if verr != nil {
+		pdet := base.NewProblemDetails("about:blank",
+			"Invalid Request",
+			verr.Error(),
+			errinst, http.StatusBadRequest)
+		base.SendProblemDetails(w, pdet, 0)
+		return
+	}
+
 	now := time.Now().Unix()
 
-	isHB, pdet := isHeartbeating(targ, now, errinst)
+	hbb, pdet := getHBInfo(targ, errinst)
 
 	if pdet != nil {
 		base.SendProblemDetails(w, pdet, 0)
 		return
 	}
 
-	rspSingle.XName = targ
-	rspSingle.Heartbeating = isHB
+	fillHBStateRsp(&rspSingle, targ, hbb, now, verbose)
 
 	ba, baerr := json.Marshal(&rspSingle)
 	if baerr != nil {
-		hbtdPrintf("INTERNAL ERROR marshalling rsp data: %v", baerr)
+		logMain.Error(fmt.Sprintf("INTERNAL ERROR marshalling rsp data: %v", baerr),
+			"error", baerr)
 		pdet := base.NewProblemDetails("about:blank",
 			"Internal Server Error",
 			"Error marshalling JSON return data",
@@ -39,7 +39,6 @@ import (
 	"time"
 
 	base "github.com/Cray-HPE/hms-base/v2"
-	hmetcd "github.com/Cray-HPE/hms-hmetcd"
 	"github.com/gorilla/mux"
 )
 
@@ -103,7 +102,7 @@ func one_time_setup() error {
 	var err error = nil
 
 	if is_setup == 0 {
-		kvHandle, err = hmetcd.Open("mem:", "")
+		kvHandle, err = openStore("mem:")
 	}
 
 	return err
@@ -132,7 +131,7 @@ func hb_compare(exps []string, acts string) int {
 
 	la := strings.Split(strings.TrimSuffix(acts, "\n"), "\n")
 	for ix, _ := range la {
-		if strings.Contains(la[ix], "ERROR sending PATCH") {
+		if strings.Contains(la[ix], "Error sending PATCH") {
 			continue
 		}
 		loc_acts = append(loc_acts, la[ix])
@@ -178,7 +177,7 @@ func TestHb_checker(t *testing.T) {
 	var kval string
 	var tpd string
 	var exp_strs_1 = []string{ //after 4 sec
-		`Number of components heartbeating: 3`,
+		`INFO: Number of components heartbeating: 3`,
 	}
 
 	var exp_strs_2 = []string{ //after 7 sec
@@ -191,14 +190,14 @@ func TestHb_checker(t *testing.T) {
 		`WARNING: Heartbeat overdue 10 seconds for 'x1c2s3b0n4' (might be dead), last status: 'OK'`,
 		//`WARNING: Heartbeat overdue 9 seconds for 'x2c3s4b0n5' (might be dead), last status: 'OK'`,
 		//`WARNING: Heartbeat overdue 8 seconds for 'x3c4s5b0n6' (might be dead), last status: 'OK'`,
-		//`Number of components heartbeating: 1`,
+		//`INFO: Number of components heartbeating: 1`,
 	}
 
 	var exp_strs_4 = []string{ //after 30 sec
 		`ERROR: Heartbeat overdue 30 seconds for 'x0c1s2b0n3' (declared dead), last status: 'OK'`,
 		`ERROR: Heartbeat overdue 25 seconds for 'x1c2s3b0n4' (declared dead), last status: 'OK'`,
 		`ERROR: Heartbeat overdue 20 seconds for 'x2c3s4b0n5' (declared dead), last status: 'OK'`,
-		`Number of components heartbeating: 0`,
+		`INFO: Number of components heartbeating: 0`,
 	}
 
 	// Set up the app_params to specify warning and error HB timeouts
@@ -348,6 +347,31 @@ func kill_sm_goroutines() {
 	time.Sleep(2 * time.Second)
 }
 
+// Start send_sm_req(), stopping it when the test ends and waiting for it
+// to exit, so it doesn't run on into later tests.
+
+func startSMReq(t *testing.T) {
+	kill_sm_goroutines()
+	done := make(chan struct{})
+	go func() {
+		send_sm_req()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		for {
+			hsmUpdateQ <- HSMQ_DIE
+			select {
+			case <-done:
+				for len(hsmUpdateQ) > 0 {
+					<-hsmUpdateQ
+				}
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	})
+}
+
 // Similar to TestHb_checker(), but this version checks for stale keys
 
 func TestHb_checker2(t *testing.T) {
@@ -358,7 +382,7 @@ func TestHb_checker2(t *testing.T) {
 	var exp_strs_1 = []string{
 		`WARNING: Heartbeat overdue 60 seconds for 'x0c1s2b0n3' due to HB monitoring gap; might be dead, last status: 'OK'`,
 		`WARNING: Heartbeat overdue 60 seconds for 'x0c1s2b0n4' due to HB monitoring gap; might be dead, last status: 'OK'`,
-		`Number of components heartbeating: 2`,
+		`INFO: Number of components heartbeating: 2`,
 	}
 
 	var exp_strs_2 = []string{`INFO: Heartbeat restarted for 'x0c1s2b0n3'`}
@@ -367,7 +391,7 @@ func TestHb_checker2(t *testing.T) {
 
 	var exp_strs_4 = []string{
 		`ERROR: Heartbeat overdue 24 seconds for 'x0c1s2b0n4' (declared dead), last status: 'OK'`,
-		`Number of components heartbeating: 1`,
+		`INFO: Number of components heartbeating: 1`,
 	}
 
 	//TODO: do we need a time second edge detect?
@@ -389,11 +413,7 @@ func TestHb_checker2(t *testing.T) {
 	app_params.warntime.int_param = 15
 	app_params.errtime.int_param = 20
 
-	for len(hsmUpdateQ) > 0 {
-		<-hsmUpdateQ
-	}
-
-	go send_sm_req()
+	startSMReq(t)
 	time.Sleep(500 * time.Millisecond)
 
 	// Create  KV entries for test components.  Note that this would also
@@ -835,6 +855,11 @@ func TestParams_io(t *testing.T) {
 			rr_e1.Code, http.StatusBadRequest)
 	}
 
+	//The debug level also sets the log levels, put it back.
+
+	app_params.debug_level.int_param = 0
+	applyLogParams()
+
 	t.Logf("  ==> FINISHED PARAMETER HTTP OPERATIONS TEST\n")
 }
 
@@ -871,7 +896,7 @@ func fakeHSMHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(getSMRVal())
 }
 
-var startComps, restartComps, stopWarnComps, stopErrorComps []string
+var startComps, restartComps, stopWarnComps, stopErrorComps, stopExpectedComps []string
 var compLock sync.Mutex
 
 func fakeHSMPatchHandler(w http.ResponseWriter, req *http.Request) {
@@ -903,6 +928,8 @@ func fakeHSMPatchHandler(w http.ResponseWriter, req *http.Request) {
 		stopWarnComps = append(stopWarnComps, sinfo.ComponentIDs...)
 	} else if (sinfo.State == base.StateStandby.String()) && (sinfo.Flag == base.FlagAlert.String()) {
 		stopErrorComps = append(stopErrorComps, sinfo.ComponentIDs...)
+	} else if (sinfo.State == base.StateStandby.String()) && (sinfo.Flag == base.FlagOK.String()) {
+		stopExpectedComps = append(stopExpectedComps, sinfo.ComponentIDs...)
 	}
 	compLock.Unlock()
 
@@ -1241,8 +1268,8 @@ func TestHBMapping(t *testing.T) {
 	hbi4 := hbinfo{Component: "x0c0s0b0n4", Last_hb_rcv_time: "00000004",
 		Last_hb_timestamp: "00000004", Last_hb_status: "OK"}
 
-	kill_sm_goroutines()
-	go send_sm_req()
+	app_params.check_interval.int_param = 0
+	startSMReq(t)
 
 	htrans.transport = &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -1282,6 +1309,7 @@ func TestHBMapping(t *testing.T) {
 
 	is_setup = 0
 	one_time_setup()
+	clearOutbox()
 
 	testMode = true
 
@@ -1393,8 +1421,8 @@ func TestHBMapping2(t *testing.T) {
 	hbi5 := hbinfo{Component: "x0c0s0b0n4", Last_hb_rcv_time: "00000004",
 		Last_hb_timestamp: "00000004", Last_hb_status: "OK"}
 
-	kill_sm_goroutines()
-	go send_sm_req()
+	app_params.check_interval.int_param = 0
+	startSMReq(t)
 
 	htrans.transport = &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -1417,6 +1445,7 @@ func TestHBMapping2(t *testing.T) {
 	stopWarnComps = []string{}
 	stopErrorComps = []string{}
 	compLock.Unlock()
+	clearOutbox()
 
 	hb_update_notify(&hbi1, HB_started)
 	hb_update_notify(&hbi2, HB_restarted_warn)
@@ -1547,3 +1576,618 @@ func TestHBMapping2(t *testing.T) {
 		t.Errorf("HB stop-error not found on '%s'", hbi3.Component)
 	}
 }
+
+func postHeartbeatBatch(t *testing.T, body string, expectedReturnCode int) hbBatchRsp {
+	var rdata hbBatchRsp
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/hmi/v1/heartbeats",
+		bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(hbRcvBatch)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != expectedReturnCode {
+		t.Errorf("Wrong http code: expected: %v, actual: %v, requestBody: %s",
+			expectedReturnCode, rr.Code, body)
+		return rdata
+	}
+	if rr.Code == http.StatusOK {
+		err = json.Unmarshal(rr.Body.Bytes(), &rdata)
+		if err != nil {
+			t.Errorf("ERROR unmarshalling batch response: %v", err)
+		}
+	}
+	return rdata
+}
+
+// HB store whose range scans leave out the end key, as ETCD's do.  The
+// in-memory store includes it.
+
+type exclRangeStore struct {
+	hbStore
+}
+
+func (es *exclRangeStore) GetRange(keystart string, keyend string) ([]hbKV, error) {
+	var rlist []hbKV
+
+	kvlist, err := es.hbStore.GetRange(keystart, keyend)
+	for _, kv := range kvlist {
+		if kv.Key < keyend {
+			rlist = append(rlist, kv)
+		}
+	}
+	return rlist, err
+}
+
+// Test entry point for hbRcvBatch(), the batch HB HTTP request handler.
+
+func TestHb_rcvBatch(t *testing.T) {
+	t.Logf("** RUNNING BATCH HEARTBEAT HTTP OPERATIONS TEST\n")
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+
+	//Mixed batch: 2 good components (one of which is sent twice), one bad
+	//XName and one bad data type.
+
+	body := "[" + heartbeatBody("x3c0s1b0n0", "OK", "Jan 1, 0000").String() + "," +
+		heartbeatBody("x3c0s1b0n1", "OK", "Jan 2, 0000").String() + "," +
+		heartbeatBody("x3c0s1b0n0", "OK", "Jan 3, 0000").String() + "," +
+		heartbeatBody("xxyyzz", "OK", "Jan 4, 0000").String() + "," +
+		`{"Component":1234,"Hostname":"nid0001","NID":"0001","Status":"OK","Timestamp":"Jan 5, 0000"}` +
+		"]"
+	rdata := postHeartbeatBatch(t, body, http.StatusOK)
+
+	expStatus := []int{http.StatusOK, http.StatusOK, http.StatusOK,
+		http.StatusBadRequest, http.StatusBadRequest}
+	if len(rdata.Results) != len(expStatus) {
+		t.Fatalf("Wrong number of batch results, expected %d, got %d",
+			len(expStatus), len(rdata.Results))
+	}
+	for ix, exp := range expStatus {
+		if rdata.Results[ix].Status != exp {
+			t.Errorf("Batch result %d (%s): expected status %d, got %d (%s)",
+				ix, rdata.Results[ix].Component, exp,
+				rdata.Results[ix].Status, rdata.Results[ix].Error)
+		}
+	}
+	if rdata.Results[3].Component != "xxyyzz" {
+		t.Errorf("Batch result 3: expected component 'xxyyzz', got '%s'",
+			rdata.Results[3].Component)
+	}
+
+	hb_cmp(t, "x3c0s1b0n0", "Jan 3, 0000", "OK")
+	hb_cmp(t, "x3c0s1b0n1", "Jan 2, 0000", "OK")
+
+	//Bigger batch, including the ones that already exist.
+
+	var comps []string
+	for ix := 0; ix < 10; ix++ {
+		comps = append(comps, fmt.Sprintf("x3c0s1b0n%d", ix))
+	}
+	var hbs []string
+	for _, comp := range comps {
+		hbs = append(hbs, heartbeatBody(comp, "OK", "Jan 6, 0000").String())
+	}
+	rdata = postHeartbeatBatch(t, "["+strings.Join(hbs, ",")+"]", http.StatusOK)
+	if len(rdata.Results) != len(comps) {
+		t.Errorf("Wrong number of batch results, expected %d, got %d",
+			len(comps), len(rdata.Results))
+	}
+	for _, res := range rdata.Results {
+		if res.Status != http.StatusOK {
+			t.Errorf("Batch result for '%s': expected status %d, got %d (%s)",
+				res.Component, http.StatusOK, res.Status, res.Error)
+		}
+	}
+	for _, comp := range comps {
+		hb_cmp(t, comp, "Jan 6, 0000", "OK")
+	}
+
+	//Resend it to a store whose range scans leave out the end key, as
+	//ETCD's do.  All of the records must be found, so none are restarted,
+	//and the warning on the last one must be kept.
+
+	origKV := kvHandle
+	kvHandle = &exclRangeStore{hbStore: origKV}
+	defer func() { kvHandle = origKV }()
+
+	lastComp := comps[len(comps)-1]
+	hbb, _ := getHBInfo(lastComp, "test")
+	hbb.Had_warning = HB_WARN_NORMAL
+	ba, _ := json.Marshal(hbb)
+	kvHandle.Store(lastComp, string(ba))
+
+	hbMapLock.Lock()
+	for _, comp := range comps {
+		delete(StartMap, comp)
+	}
+	hbMapLock.Unlock()
+
+	postHeartbeatBatch(t, "["+strings.Join(hbs, ",")+"]", http.StatusOK)
+	hbMapLock.Lock()
+	for _, comp := range comps {
+		if _, ok := StartMap[comp]; ok {
+			t.Errorf("Existing component '%s' restarted by batch", comp)
+		}
+	}
+	hbMapLock.Unlock()
+	if hbb, _ = getHBInfo(lastComp, "test"); hbb.Had_warning != HB_WARN_NORMAL {
+		t.Errorf("Warning of last batch component lost: %+v", hbb)
+	}
+	kvHandle = origKV
+
+	//Whole-request failures
+
+	postHeartbeatBatch(t, heartbeatBody("x3c0s1b0n0", "OK", "Jan 1, 0000").String(),
+		http.StatusBadRequest)
+	postHeartbeatBatch(t, "[]", http.StatusBadRequest)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/hmi/v1/heartbeats", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(hbRcvBatch).ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HTTP handler returned bad error code, got %v, want %v",
+			rr.Code, http.StatusMethodNotAllowed)
+	}
+
+	for _, comp := range comps {
+		kvHandle.Delete(comp)
+	}
+	t.Logf("  ==> FINISHED BATCH HEARTBEAT HTTP OPERATIONS TEST\n")
+}
+
+func getHBStatesList(t *testing.T, query string, expectedReturnCode int) hbInfoListRsp {
+	var rdata hbInfoListRsp
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/hmi/v1/hbstates"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(hbStatesList)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != expectedReturnCode {
+		t.Errorf("Wrong http code for query '%s': expected: %v, actual: %v",
+			query, expectedReturnCode, rr.Code)
+		return rdata
+	}
+	if rr.Code == http.StatusOK {
+		err = json.Unmarshal(rr.Body.Bytes(), &rdata)
+		if err != nil {
+			t.Errorf("ERROR unmarshalling hbstates list response: %v", err)
+		}
+	}
+	return rdata
+}
+
+// Test entry point for hbStatesList(), the GET /hbstates handler.
+
+func Test_HbStatesList(t *testing.T) {
+	var kval string
+
+	t.Logf("** RUNNING hbStatesList TEST **")
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	staleKeys = false
+	app_params.check_interval.int_param = 30
+	app_params.warntime.int_param = 5
+	app_params.errtime.int_param = 20
+
+	//4 nodes -- OK, WARN, DEAD, OK -- and one BMC that is OK.
+
+	basetime := time.Now().Unix()
+	xnameArr := []string{"x3001c0s0b0n0", "x3001c0s1b0n0", "x3001c0s2b0n0",
+		"x3001c0s3b0n0", "x3001c0s4b0"}
+	times := []int64{0, 10, 30, 0, 0}
+
+	for ix := 0; ix < len(xnameArr); ix++ {
+		make_key(&kval, xnameArr[ix], (basetime - times[ix]))
+		err := kvHandle.Store(xnameArr[ix], kval)
+		if err != nil {
+			t.Errorf("ERROR storing key data for '%s': %v", xnameArr[ix], err)
+		}
+	}
+
+	rdata := getHBStatesList(t, "?prefix=x3001c0", http.StatusOK)
+	if rdata.Total != len(xnameArr) || len(rdata.Components) != len(xnameArr) {
+		t.Fatalf("Expected %d components, got total %d, %d returned",
+			len(xnameArr), rdata.Total, len(rdata.Components))
+	}
+	expStates := []string{HB_STATE_OK, HB_STATE_WARN, HB_STATE_DEAD,
+		HB_STATE_OK, HB_STATE_OK}
+	for ix, comp := range rdata.Components {
+		if comp.Component != xnameArr[ix] {
+			t.Errorf("Component %d: expected '%s', got '%s'",
+				ix, xnameArr[ix], comp.Component)
+		}
+		if comp.State != expStates[ix] {
+			t.Errorf("Component '%s': expected state %s, got %s",
+				comp.Component, expStates[ix], comp.State)
+		}
+		if comp.Last_hb_status != "OK" {
+			t.Errorf("Component '%s': expected status 'OK', got '%s'",
+				comp.Component, comp.Last_hb_status)
+		}
+		rtime, terr := time.Parse(time.RFC3339, comp.Last_hb_rcv_time)
+		if terr != nil {
+			t.Errorf("Component '%s': bad receive time '%s': %v",
+				comp.Component, comp.Last_hb_rcv_time, terr)
+		} else if rtime.Unix() != (basetime - times[ix]) {
+			t.Errorf("Component '%s': expected receive time %d, got %d",
+				comp.Component, basetime-times[ix], rtime.Unix())
+		}
+	}
+
+	//Filters
+
+	rdata = getHBStatesList(t, "?prefix=x3001c0&state=warn,dead", http.StatusOK)
+	if rdata.Total != 2 || rdata.Components[0].Component != xnameArr[1] ||
+		rdata.Components[1].Component != xnameArr[2] {
+		t.Errorf("State filter: unexpected result: %v", rdata)
+	}
+
+	rdata = getHBStatesList(t, "?prefix=x3001c0&type=NodeBMC", http.StatusOK)
+	if rdata.Total != 1 || rdata.Components[0].Component != xnameArr[4] {
+		t.Errorf("Type filter: unexpected result: %v", rdata)
+	}
+
+	//Pagination
+
+	rdata = getHBStatesList(t, "?prefix=x3001c0&offset=1&limit=2", http.StatusOK)
+	if rdata.Total != len(xnameArr) || len(rdata.Components) != 2 ||
+		rdata.Components[0].Component != xnameArr[1] ||
+		rdata.Components[1].Component != xnameArr[2] {
+		t.Errorf("Pagination: unexpected result: %v", rdata)
+	}
+
+	rdata = getHBStatesList(t, "?prefix=x3001c0&offset=10", http.StatusOK)
+	if rdata.Total != len(xnameArr) || len(rdata.Components) != 0 {
+		t.Errorf("Pagination past end: unexpected result: %v", rdata)
+	}
+
+	//Bad queries
+
+	getHBStatesList(t, "?state=sleepy", http.StatusBadRequest)
+	getHBStatesList(t, "?type=notatype", http.StatusBadRequest)
+	getHBStatesList(t, "?limit=-1", http.StatusBadRequest)
+	getHBStatesList(t, "?offset=abc", http.StatusBadRequest)
+
+	for _, comp := range xnameArr {
+		kvHandle.Delete(comp)
+	}
+}
+
+// Test entry point for the verbose forms of hbStates() and hbStateSingle().
+
+func Test_HbStatesVerbose(t *testing.T) {
+	var jdata hbStatesReq
+	var rdata hbStatesRsp
+	var sdata hbSingleStateRsp
+
+	t.Logf("** RUNNING verbose hbStates TEST **")
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+	staleKeys = false
+	app_params.check_interval.int_param = 30
+	app_params.warntime.int_param = 5
+	app_params.errtime.int_param = 20
+
+	//OK, warned, dead, monitoring gap, and one that is not tracked.
+
+	basetime := time.Now().Unix()
+	xnameArr := []string{"x3002c0s0b0n0", "x3002c0s1b0n0", "x3002c0s2b0n0",
+		"x3002c0s3b0n0", "x3002c0s4b0n0"}
+	times := []int64{0, 10, 30, 0}
+	warns := []string{HB_WARN_NONE, HB_WARN_NORMAL, HB_WARN_NONE, HB_WARN_GAP}
+
+	for ix := 0; ix < len(times); ix++ {
+		kval := fmt.Sprintf("{\"Component\":\"%s\",\"Last_hb_rcv_time\":\"%x\",\"Last_hb_timestamp\":\"ts%d\",\"Last_hb_status\":\"OK\",\"Had_warning\":\"%s\"}",
+			xnameArr[ix], basetime-times[ix], ix, warns[ix])
+		err := kvHandle.Store(xnameArr[ix], kval)
+		if err != nil {
+			t.Errorf("ERROR storing key data for '%s': %v", xnameArr[ix], err)
+		}
+	}
+
+	type expRsp struct {
+		hb     bool
+		state  string
+		reason string
+	}
+	exps := []expRsp{
+		{true, HB_STATE_OK, ""},
+		{true, HB_STATE_WARN, HB_WARN_REASON_NORMAL},
+		{false, HB_STATE_DEAD, ""},
+		{true, HB_STATE_WARN, HB_WARN_REASON_GAP},
+		{false, HB_STATE_UNKNOWN, ""},
+	}
+
+	checkRsp := func(ix int, rsp *hbSingleStateRsp) {
+		if rsp.XName != xnameArr[ix] {
+			t.Errorf("Expected XName '%s', got '%s'", xnameArr[ix], rsp.XName)
+		}
+		if rsp.Heartbeating != exps[ix].hb || rsp.State != exps[ix].state ||
+			rsp.WarningReason != exps[ix].reason {
+			t.Errorf("'%s': expected %v/%s/'%s', got %v/%s/'%s'", rsp.XName,
+				exps[ix].hb, exps[ix].state, exps[ix].reason,
+				rsp.Heartbeating, rsp.State, rsp.WarningReason)
+		}
+		if ix >= len(times) {
+			if rsp.SecondsSinceLastHB != nil {
+				t.Errorf("'%s': expected no SecondsSinceLastHB, got %d",
+					rsp.XName, *rsp.SecondsSinceLastHB)
+			}
+			return
+		}
+		if rsp.SecondsSinceLastHB == nil {
+			t.Errorf("'%s': missing SecondsSinceLastHB", rsp.XName)
+		} else if *rsp.SecondsSinceLastHB < times[ix] {
+			t.Errorf("'%s': expected SecondsSinceLastHB >= %d, got %d",
+				rsp.XName, times[ix], *rsp.SecondsSinceLastHB)
+		}
+		if rsp.Last_hb_timestamp != fmt.Sprintf("ts%d", ix) ||
+			rsp.Last_hb_status != "OK" {
+			t.Errorf("'%s': bad timestamp/status '%s'/'%s'", rsp.XName,
+				rsp.Last_hb_timestamp, rsp.Last_hb_status)
+		}
+	}
+
+	//Multi-component, verbose
+
+	jdata.XNames = xnameArr
+	ba, _ := json.Marshal(&jdata)
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://localhost:8080/hmi/v1/hbstates?verbose=true",
+		bytes.NewBuffer(ba))
+	http.HandlerFunc(hbStates).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HTTP handler returned bad status code: %v", rr.Code)
+	}
+	err := json.Unmarshal(rr.Body.Bytes(), &rdata)
+	if err != nil {
+		t.Fatalf("ERROR umnarshalling hbstates return body: %v", err)
+	}
+	if len(rdata.HBStates) != len(xnameArr) {
+		t.Fatalf("Invalid HB states length, expected %d, got %d",
+			len(xnameArr), len(rdata.HBStates))
+	}
+	for ix := range xnameArr {
+		checkRsp(ix, &rdata.HBStates[ix])
+	}
+
+	//Multi-component, not verbose: verbose fields must not be present.
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://localhost:8080/hmi/v1/hbstates",
+		bytes.NewBuffer(ba))
+	http.HandlerFunc(hbStates).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("HTTP handler returned bad status code: %v", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "State\"") ||
+		strings.Contains(rr.Body.String(), "SecondsSinceLastHB") {
+		t.Errorf("Non-verbose response contains verbose fields: %s", rr.Body.String())
+	}
+
+	//Single component, verbose
+
+	routes := generateRoutes()
+	router = newRouter(routes)
+
+	for ix := range xnameArr {
+		rr = httptest.NewRecorder()
+		url := fmt.Sprintf("http://localhost/hmi/v1/hbstate/%s?verbose=1", xnameArr[ix])
+		req, _ = http.NewRequest("GET", url, nil)
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("HTTP handler returned bad status code: %v", rr.Code)
+			continue
+		}
+		sdata = hbSingleStateRsp{}
+		err = json.Unmarshal(rr.Body.Bytes(), &sdata)
+		if err != nil {
+			t.Errorf("ERROR umnarshalling hbstate return body: %v", err)
+			continue
+		}
+		checkRsp(ix, &sdata)
+	}
+
+	//Bad verbose value
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "http://localhost/hmi/v1/hbstate/x3002c0s0b0n0?verbose=maybe", nil)
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("HTTP handler returned bad status code: %v, expected %v",
+			rr.Code, http.StatusBadRequest)
+	}
+
+	for _, comp := range xnameArr {
+		kvHandle.Delete(comp)
+	}
+}
+
+func TestHighestSeq(t *testing.T) {
+	tests := []struct {
+		seqs []uint64
+		exp  int
+	}{
+		{[]uint64{0, 0, 0, 0, 0}, -1},
+		{[]uint64{1, 0, 0, 0, 0}, 0},
+		{[]uint64{1, 2, 3, 4, 5}, 4},
+		{[]uint64{9, 2, 3, 4, 5}, 0},
+		{[]uint64{1, 7, 3, 7, 5}, -1},
+		{[]uint64{1, 7, 3, 7, 8}, 4},
+		{[]uint64{0, 0, 6, 0, 0}, 2},
+	}
+
+	for _, tst := range tests {
+		if hix := highestSeq(tst.seqs...); hix != tst.exp {
+			t.Errorf("highestSeq(%v): expected %d, got %d", tst.seqs, tst.exp, hix)
+		}
+	}
+}
+
+// Test "going away" heartbeat statuses: a component that says it is going
+// away gets no start notification if it is new, and when its heartbeat
+// stops it gets an expected-stop notification instead of warnings/errors.
+
+func TestHb_goingAway(t *testing.T) {
+	var kval string
+
+	t.Logf("** RUNNING going-away HB TEST **")
+
+	ots_err := one_time_setup()
+	if ots_err != nil {
+		t.Error("ERROR setting up KV store:", ots_err)
+		return
+	}
+
+	startSMReq(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(fakeHSMPatchHandler))
+	defer srv.Close()
+
+	htrans.transport = &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	htrans.client = &http.Client{Transport: htrans.transport,
+		Timeout: (20 * time.Second),
+	}
+	app_params.statemgr_url.string_param = srv.URL
+	app_params.statemgr_timeout.int_param = 5
+	app_params.nosm.int_param = 0
+	app_params.debug_level.int_param = 0
+	app_params.check_interval.int_param = 0
+	app_params.warntime.int_param = 5
+	app_params.errtime.int_param = 20
+	testMode = true
+	hsmReady = true
+	staleKeys = false
+	setSMRVal(http.StatusOK)
+	hbtdPrintf = testPrintf
+	hbtdPrintln = testPrintln
+
+	compLock.Lock()
+	startComps = []string{}
+	restartComps = []string{}
+	stopWarnComps = []string{}
+	stopErrorComps = []string{}
+	stopExpectedComps = []string{}
+	compLock.Unlock()
+	clearOutbox()
+
+	//New component whose first HB says it's going away: no start
+
+	postHeartbeat(t, heartbeatBody("x3003c0s0b0n0", "Maintenance", "Jan 1, 0000"),
+		http.StatusOK)
+	hbMapLock.Lock()
+	if StartMap["x3003c0s0b0n0"] != 0 {
+		t.Errorf("Going-away component got a start notification.")
+	}
+	hbMapLock.Unlock()
+	kvHandle.Delete("x3003c0s0b0n0")
+
+	//Components: going away and overdue, going away and not yet overdue,
+	//going away and long overdue, normal and overdue, going away and not
+	//yet overdue after a warning.
+
+	basetime := time.Now().Unix()
+	keys := []string{"x3003c0s1b0n0", "x3003c0s2b0n0", "x3003c0s3b0n0", "x3003c0s4b0n0",
+		"x3003c0s5b0n0"}
+	stats := []string{"reboot", "Shutdown", "maintenance", "OK", "Shutdown"}
+	ages := []int64{8, 1, 40, 8, 1}
+	warns := []string{"", "", "", "", HB_WARN_NORMAL}
+	for ix, key := range keys {
+		kval = fmt.Sprintf("{\"Component\":\"%s\",\"Last_hb_rcv_time\":\"%x\",\"Last_hb_timestamp\":\"\",\"Last_hb_status\":\"%s\",\"Had_warning\":\"%s\"}",
+			key, basetime-ages[ix], stats[ix], warns[ix])
+		err := kvHandle.Store(key, kval)
+		if err != nil {
+			t.Errorf("ERROR storing key data for '%s': %v", key, err)
+		}
+	}
+
+	hbb, _ := getHBInfo(keys[1], "test")
+	if st := hbState(hbb, basetime); st != HB_STATE_STOPPING {
+		t.Errorf("Going-away component state: expected %s, got %s",
+			HB_STATE_STOPPING, st)
+	}
+
+	testPrintClear()
+	hb_checker()
+	time.Sleep(2 * time.Second)
+	tpd := testPrintData()
+
+	for _, ix := range []int{0, 2} {
+		exp := fmt.Sprintf("INFO: Heartbeat stopped for '%s' (expected), last status: '%s'",
+			keys[ix], stats[ix])
+		if !strings.Contains(tpd, exp) {
+			t.Errorf("Missing expected-stop message for '%s', output:\n%s", keys[ix], tpd)
+		}
+		if _, ok, _ := kvHandle.Get(keys[ix]); ok {
+			t.Errorf("Expected-stop component '%s' not deleted.", keys[ix])
+		}
+	}
+	if strings.Contains(tpd, keys[1]) {
+		t.Errorf("Unexpected message for '%s', output:\n%s", keys[1], tpd)
+	}
+	if _, ok, _ := kvHandle.Get(keys[1]); !ok {
+		t.Errorf("Going-away component '%s' deleted before it was overdue.", keys[1])
+	}
+	if !strings.Contains(tpd, fmt.Sprintf("WARNING: Heartbeat overdue 8 seconds for '%s'", keys[3])) {
+		t.Errorf("Missing warning message for '%s', output:\n%s", keys[3], tpd)
+	}
+	hbb, _ = getHBInfo(keys[4], "test")
+	if (hbb == nil) || (hbb.Had_warning != HB_WARN_NONE) {
+		t.Errorf("Warning not cleared for going-away component '%s': %+v", keys[4], hbb)
+	}
+
+	compLock.Lock()
+	sort.Strings(stopExpectedComps)
+	if len(stopExpectedComps) != 2 || stopExpectedComps[0] != keys[0] ||
+		stopExpectedComps[1] != keys[2] {
+		t.Errorf("Wrong expected-stop HSM components: %v", stopExpectedComps)
+	}
+	for _, comp := range stopWarnComps {
+		if (comp != keys[3]) && strings.HasPrefix(comp, "x3003") {
+			t.Errorf("Unexpected stop-warn HSM component: %s", comp)
+		}
+	}
+	for _, comp := range stopErrorComps {
+		if strings.HasPrefix(comp, "x3003") {
+			t.Errorf("Unexpected stop-error HSM component: %s", comp)
+		}
+	}
+	found := false
+	for _, comp := range restartComps {
+		found = found || (comp == keys[4])
+	}
+	if !found {
+		t.Errorf("No restart sent to HSM for going-away component '%s' with a warning: %v",
+			keys[4], restartComps)
+	}
+	compLock.Unlock()
+
+	for _, key := range keys {
+		kvHandle.Delete(key)
+	}
+	sg_ncomp = 0
+}